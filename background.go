@@ -0,0 +1,98 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth2
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// backgroundRefreshMinInterval is the shortest time between two background
+// refreshes, which bounds the retries of failed refreshes and the refreshes
+// of tokens living shorter than the refresh window.
+var backgroundRefreshMinInterval = 10 * time.Second
+
+// ReuseTokenSourceWithBackgroundRefresh returns a TokenSource that acts in
+// the same manner as the TokenSource returned by ReuseTokenSource, except
+// that a goroutine fetches a new token from src window before the current
+// one expires, so that callers of Token do not wait for refreshes. The first
+// token is fetched right away. Failed background refreshes are retried;
+// should the token expire in the meantime, Token refreshes it as usual.
+//
+// The goroutine stops when ctx is done, or when a token without expiry is
+// fetched. If src is a TokenSource returned by one of the ReuseTokenSource
+// functions, it is left as is: the returned TokenSource starts from its
+// current token, if any, keeps its settings and refreshes from the source
+// it wraps.
+func ReuseTokenSourceWithBackgroundRefresh(ctx context.Context, src TokenSource, window time.Duration) TokenSource {
+	ts := &reuseTokenSource{new: src}
+	// A reuseTokenSource may be shared, so it is wrapped rather than
+	// refreshed in the background itself. Its source is called with its
+	// lock held, since it may not be safe for concurrent use.
+	if rt, ok := src.(*reuseTokenSource); ok {
+		ts.new = &lockedTokenSource{mu: &rt.newMu, src: rt.new}
+		rt.mu.Lock()
+		ts.t = rt.t
+		ts.expiryDelta, ts.jitter, ts.failureBackoff = rt.expiryDelta, rt.jitter, rt.failureBackoff
+		rt.mu.Unlock()
+	}
+	go ts.refreshInBackground(ctx, window)
+	return ts
+}
+
+// lockedTokenSource serializes the calls to src with mu.
+type lockedTokenSource struct {
+	mu  *sync.Mutex
+	src TokenSource
+}
+
+func (s *lockedTokenSource) Token() (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Token()
+}
+
+// refreshInBackground refreshes the token of s window before it expires
+// until ctx is done.
+func (s *reuseTokenSource) refreshInBackground(ctx context.Context, window time.Duration) {
+	var minWait time.Duration // none before the first refresh
+	for {
+		s.mu.Lock()
+		t := s.t
+		s.mu.Unlock()
+		var wait time.Duration
+		if t != nil {
+			if t.Expiry.IsZero() {
+				return
+			}
+			wait = t.Expiry.Add(-window).Sub(timeNow())
+		}
+		if wait < minWait {
+			wait = minWait
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		minWait = backgroundRefreshMinInterval
+
+		// The refresh is made without holding s.mu, so that callers keep
+		// getting the current token in the meantime.
+		s.newMu.Lock()
+		t, err := s.new.Token()
+		s.newMu.Unlock()
+		if err != nil {
+			continue
+		}
+		s.mu.Lock()
+		s.lastErr = nil
+		s.setLocked(t)
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// shortLivedTokenSource returns tokens living for lifetime, failing while
+// err is set.
+type shortLivedTokenSource struct {
+	lifetime time.Duration
+
+	mu    sync.Mutex
+	calls int
+	err   error
+}
+
+func (s *shortLivedTokenSource) Token() (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	tok := &Token{AccessToken: fmt.Sprint(s.calls)}
+	if s.lifetime > 0 {
+		tok.Expiry = time.Now().Add(s.lifetime)
+	}
+	return tok, nil
+}
+
+func (s *shortLivedTokenSource) callCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+// waitForCalls waits until src has been called at least n times.
+func waitForCalls(t *testing.T, src *shortLivedTokenSource, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for src.callCount() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d calls but want at least %d", src.callCount(), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestReuseTokenSourceWithBackgroundRefresh(t *testing.T) {
+	defer func(old time.Duration) { backgroundRefreshMinInterval = old }(backgroundRefreshMinInterval)
+	backgroundRefreshMinInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	src := &shortLivedTokenSource{lifetime: time.Hour}
+	ts := ReuseTokenSourceWithBackgroundRefresh(ctx, src, time.Hour-50*time.Millisecond)
+
+	waitForCalls(t, src, 3)
+	tok, err := ts.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.AccessToken == "" {
+		t.Error("got an empty access token")
+	}
+
+	cancel()
+	time.Sleep(20 * time.Millisecond) // let an in-flight refresh finish
+	calls := src.callCount()
+	time.Sleep(150 * time.Millisecond)
+	if got := src.callCount(); got != calls {
+		t.Errorf("got %d calls after the context was canceled but want %d", got, calls)
+	}
+}
+
+func TestReuseTokenSourceWithBackgroundRefresh_Retry(t *testing.T) {
+	defer func(old time.Duration) { backgroundRefreshMinInterval = old }(backgroundRefreshMinInterval)
+	backgroundRefreshMinInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	src := &shortLivedTokenSource{lifetime: time.Hour, err: errors.New("unavailable")}
+	ts := ReuseTokenSourceWithBackgroundRefresh(ctx, src, time.Minute)
+
+	waitForCalls(t, src, 2)
+	src.mu.Lock()
+	src.err = nil
+	src.mu.Unlock()
+	rts := ts.(*reuseTokenSource)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rts.mu.Lock()
+		refreshed := rts.t != nil
+		rts.mu.Unlock()
+		if refreshed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the token was not refreshed after the source recovered")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	calls := src.callCount()
+	if _, err := ts.Token(); err != nil {
+		t.Fatal(err)
+	}
+	if got := src.callCount(); got != calls {
+		t.Errorf("Token() called the source, got %d calls but want %d", got, calls)
+	}
+}
+
+func TestReuseTokenSourceWithBackgroundRefresh_NoExpiry(t *testing.T) {
+	defer func(old time.Duration) { backgroundRefreshMinInterval = old }(backgroundRefreshMinInterval)
+	backgroundRefreshMinInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	src := &shortLivedTokenSource{}
+	ReuseTokenSourceWithBackgroundRefresh(ctx, src, time.Minute)
+	waitForCalls(t, src, 1)
+	time.Sleep(50 * time.Millisecond)
+	if got := src.callCount(); got != 1 {
+		t.Errorf("got %d calls for a token without expiry but want 1", got)
+	}
+}
+
+func TestReuseTokenSourceWithBackgroundRefresh_Shared(t *testing.T) {
+	defer func(old time.Duration) { backgroundRefreshMinInterval = old }(backgroundRefreshMinInterval)
+	backgroundRefreshMinInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	src := &shortLivedTokenSource{lifetime: time.Hour}
+	held := &Token{AccessToken: "held", Expiry: time.Now().Add(time.Hour)}
+	shared := ReuseTokenSourceWithExpiry(held, src, time.Minute)
+	ts := ReuseTokenSourceWithBackgroundRefresh(ctx, shared, time.Minute)
+	if ts == shared {
+		t.Fatal("the shared TokenSource was returned, want a new one")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if got := src.callCount(); got != 0 {
+		t.Errorf("got %d calls while a valid token was held but want 0", got)
+	}
+	tok, err := ts.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tok.AccessToken, "held"; got != want {
+		t.Errorf("got token %q but want %q", got, want)
+	}
+	if got, want := ts.(*reuseTokenSource).expiryDelta, time.Minute; got != want {
+		t.Errorf("got expiry delta %v but want %v", got, want)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth2
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/http/httptrace"
+)
+
+const (
+	// defaultExporterLabel is the exporter label of Token Binding (RFC 8471
+	// section 3.3).
+	defaultExporterLabel  = "EXPORTER-Token-Binding"
+	defaultExporterLength = 32
+)
+
+// ChannelBindingTransport is an http.RoundTripper that lets proofs of
+// possession, such as DPoP proofs, be bound to the TLS connection a request
+// is sent on. Once the connection is established, and before the request is
+// written, Bind is called with keying material exported from it as described
+// in RFC 5705, in the style of Token Binding (RFC 8471).
+//
+// ChannelBindingTransport is experimental. Keying material is only available
+// when Base is an *http.Transport, or wraps one, and the connection negotiated
+// TLS 1.3 or the extended master secret extension.
+type ChannelBindingTransport struct {
+	// Bind adds the proof of the request to its headers. exporter is nil
+	// when no keying material could be exported from the connection. Bind
+	// must not retain req. Required.
+	Bind func(req *http.Request, exporter []byte)
+
+	// Label is the exporter label. If empty, "EXPORTER-Token-Binding" is
+	// used.
+	Label string
+
+	// Length is the number of bytes of keying material to export. If zero,
+	// 32 is used.
+	Length int
+
+	// Base is the base RoundTripper used to make HTTP requests.
+	// If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+}
+
+// RoundTrip sends the request with Base, calling Bind once its connection is
+// known.
+func (t *ChannelBindingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.Bind == nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, errors.New("oauth2: ChannelBindingTransport's Bind is nil")
+	}
+	req2 := cloneRequest(req) // per RoundTripper contract
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.Bind(req2, t.exporter(info))
+		},
+	}
+	req2 = req2.WithContext(httptrace.WithClientTrace(req2.Context(), trace))
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req2)
+}
+
+// exporter returns the keying material of the connection in info, or nil.
+func (t *ChannelBindingTransport) exporter(info httptrace.GotConnInfo) []byte {
+	conn, ok := info.Conn.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+	label, length := t.Label, t.Length
+	if label == "" {
+		label = defaultExporterLabel
+	}
+	if length == 0 {
+		length = defaultExporterLength
+	}
+	state := conn.ConnectionState()
+	ekm, err := state.ExportKeyingMaterial(label, nil, length)
+	if err != nil {
+		return nil
+	}
+	return ekm
+}
@@ -0,0 +1,44 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth2
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestChannelBindingTransport(t *testing.T) {
+	var serverEKM string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ekm, err := r.TLS.ExportKeyingMaterial(defaultExporterLabel, nil, defaultExporterLength)
+		if err != nil {
+			t.Errorf("server ExportKeyingMaterial() failed: %v", err)
+		}
+		serverEKM = base64.RawURLEncoding.EncodeToString(ekm)
+		if got := r.Header.Get("X-Binding"); got != serverEKM {
+			t.Errorf("X-Binding header = %q; want %q", got, serverEKM)
+		}
+	}))
+	defer server.Close()
+
+	var bound bool
+	client := &http.Client{Transport: &ChannelBindingTransport{
+		Bind: func(req *http.Request, exporter []byte) {
+			bound = exporter != nil
+			req.Header.Set("X-Binding", base64.RawURLEncoding.EncodeToString(exporter))
+		},
+		Base: server.Client().Transport,
+	}}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if !bound {
+		t.Error("Bind was not called with keying material")
+	}
+}
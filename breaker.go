@@ -0,0 +1,204 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth2
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFailureThreshold = 5
+	defaultOpenTimeout      = 30 * time.Second
+)
+
+// CircuitState is the state of a CircuitBreaker.
+type CircuitState int
+
+const (
+	// CircuitClosed lets every call through.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails every call without reaching the token endpoint.
+	CircuitOpen
+	// CircuitHalfOpen lets a single probe call through to find out whether
+	// the token endpoint has recovered.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", int(s))
+}
+
+// ErrCircuitOpen is matched by the errors returned while a CircuitBreaker is
+// open, see CircuitOpenError.
+var ErrCircuitOpen = errors.New("oauth2: circuit breaker is open")
+
+// CircuitOpenError is returned without calling the token endpoint while a
+// CircuitBreaker is open, or half-open with its probe in flight.
+type CircuitOpenError struct {
+	// Err is the failure that opened the circuit.
+	Err error
+	// RetryAfter is when the next probe call will be let through.
+	RetryAfter time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("oauth2: token endpoint circuit breaker is open until %v after failure: %v", e.RetryAfter.Format(time.RFC3339), e.Err)
+}
+
+// Unwrap returns the failure that opened the circuit.
+func (e *CircuitOpenError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrCircuitOpen.
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// CircuitBreaker stops calling a failing token endpoint, so that an outage
+// of the authorization server results in fast errors rather than refreshes
+// hanging on the endpoint one after the other. After FailureThreshold
+// consecutive failures the circuit opens: calls fail with a
+// *CircuitOpenError for OpenTimeout. A single probe call is then let
+// through, which closes the circuit if it succeeds and opens it again if not.
+//
+// A CircuitBreaker is installed with WithCircuitBreaker and may be shared by
+// the TokenSources of one token endpoint. It must not be copied after first
+// use.
+type CircuitBreaker struct {
+	// FailureThreshold is the number of consecutive failures opening the
+	// circuit. If zero, 5 is used.
+	FailureThreshold int
+
+	// OpenTimeout is how long the circuit stays open before a probe call is
+	// let through. If zero, 30 seconds is used.
+	OpenTimeout time.Duration
+
+	// IsFailure, if non-nil, reports whether an error counts as a failure of
+	// the token endpoint. By default, every error counts except a
+	// *RetrieveError with a 4xx status code other than 408 and 429, since
+	// errors such as invalid_grant are not outages.
+	IsFailure func(error) bool
+
+	// OnStateChange, if non-nil, is called when the circuit changes state,
+	// e.g. to export it as a metric. It must not call the CircuitBreaker.
+	OnStateChange func(from, to CircuitState)
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	lastErr  error
+	openedAt time.Time
+}
+
+// WithCircuitBreaker returns a middleware that guards the wrapped TokenSource
+// with cb. It should be placed inside WithReuse, so that cached tokens keep
+// being served while the circuit is open:
+//
+//	WrapTokenSource(src, WithReuse(), WithCircuitBreaker(cb))
+func WithCircuitBreaker(cb *CircuitBreaker) TokenSourceMiddleware {
+	return func(src TokenSource) TokenSource {
+		return TokenSourceFunc(func() (*Token, error) {
+			if err := cb.allow(); err != nil {
+				return nil, err
+			}
+			tok, err := src.Token()
+			cb.record(err)
+			return tok, err
+		})
+	}
+}
+
+// State returns the current state of the circuit.
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.state == CircuitOpen && !timeNow().Before(cb.retryAfter()) {
+		return CircuitHalfOpen
+	}
+	return cb.state
+}
+
+func (cb *CircuitBreaker) retryAfter() time.Time {
+	timeout := cb.OpenTimeout
+	if timeout == 0 {
+		timeout = defaultOpenTimeout
+	}
+	return cb.openedAt.Add(timeout)
+}
+
+// allow returns a *CircuitOpenError if a call must not be made. Once the
+// open timeout has elapsed, it lets a single probe call through.
+func (cb *CircuitBreaker) allow() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch cb.state {
+	case CircuitOpen:
+		if timeNow().Before(cb.retryAfter()) {
+			return &CircuitOpenError{Err: cb.lastErr, RetryAfter: cb.retryAfter()}
+		}
+		cb.setState(CircuitHalfOpen)
+	case CircuitHalfOpen:
+		// A probe is in flight.
+		return &CircuitOpenError{Err: cb.lastErr, RetryAfter: cb.retryAfter()}
+	}
+	return nil
+}
+
+// record updates the circuit with the result of a call.
+func (cb *CircuitBreaker) record(err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if err == nil || !cb.isFailure(err) {
+		cb.failures = 0
+		cb.setState(CircuitClosed)
+		return
+	}
+	cb.failures++
+	cb.lastErr = err
+	threshold := cb.FailureThreshold
+	if threshold == 0 {
+		threshold = defaultFailureThreshold
+	}
+	if cb.state == CircuitHalfOpen || cb.failures >= threshold {
+		cb.openedAt = timeNow()
+		cb.setState(CircuitOpen)
+	}
+}
+
+func (cb *CircuitBreaker) isFailure(err error) bool {
+	if cb.IsFailure != nil {
+		return cb.IsFailure(err)
+	}
+	var re *RetrieveError
+	if errors.As(err, &re) && re.Response != nil {
+		sc := re.Response.StatusCode
+		return sc < 400 || sc > 499 || sc == 408 || sc == 429
+	}
+	return true
+}
+
+// setState changes the state, calling OnStateChange. cb.mu must be held.
+func (cb *CircuitBreaker) setState(s CircuitState) {
+	if cb.state == s {
+		return
+	}
+	from := cb.state
+	cb.state = s
+	if cb.OnStateChange != nil {
+		cb.OnStateChange(from, s)
+	}
+}
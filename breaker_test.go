@@ -0,0 +1,126 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth2
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	defer func(old func() time.Time) { timeNow = old }(timeNow)
+	current := time.Now()
+	timeNow = func() time.Time { return current }
+
+	var changes []string
+	cb := &CircuitBreaker{
+		FailureThreshold: 2,
+		OpenTimeout:      time.Minute,
+		OnStateChange: func(from, to CircuitState) {
+			changes = append(changes, from.String()+"->"+to.String())
+		},
+	}
+	errFetch := errors.New("unavailable")
+	src := &countingTokenSource{err: errFetch}
+	ts := WrapTokenSource(src, WithCircuitBreaker(cb))
+
+	for i := 0; i < 2; i++ {
+		if _, err := ts.Token(); err != errFetch {
+			t.Fatalf("got %v but want %v", err, errFetch)
+		}
+	}
+	if got := cb.State(); got != CircuitOpen {
+		t.Fatalf("got state %v but want %v", got, CircuitOpen)
+	}
+
+	_, err := ts.Token()
+	var coe *CircuitOpenError
+	if !errors.As(err, &coe) || !errors.Is(err, ErrCircuitOpen) || !errors.Is(err, errFetch) {
+		t.Fatalf("got %v but want a *CircuitOpenError wrapping %v", err, errFetch)
+	}
+	if want := current.Add(time.Minute); !coe.RetryAfter.Equal(want) {
+		t.Errorf("got RetryAfter %v but want %v", coe.RetryAfter, want)
+	}
+	if src.calls != 2 {
+		t.Errorf("got %d calls while open but want 2", src.calls)
+	}
+
+	// A failed probe opens the circuit again.
+	current = current.Add(time.Minute)
+	if got := cb.State(); got != CircuitHalfOpen {
+		t.Errorf("got state %v but want %v", got, CircuitHalfOpen)
+	}
+	if _, err := ts.Token(); err != errFetch {
+		t.Fatalf("got %v from the probe but want %v", err, errFetch)
+	}
+	if _, err := ts.Token(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("got %v after a failed probe but want %v", err, ErrCircuitOpen)
+	}
+
+	// A successful probe closes it.
+	current = current.Add(time.Minute)
+	src.tok, src.err = &Token{AccessToken: "abc"}, nil
+	if _, err := ts.Token(); err != nil {
+		t.Fatal(err)
+	}
+	if got := cb.State(); got != CircuitClosed {
+		t.Errorf("got state %v but want %v", got, CircuitClosed)
+	}
+
+	want := []string{"closed->open", "open->half-open", "half-open->open", "open->half-open", "half-open->closed"}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("got state changes %v but want %v", changes, want)
+	}
+}
+
+func TestCircuitBreaker_SingleProbe(t *testing.T) {
+	defer func(old func() time.Time) { timeNow = old }(timeNow)
+	current := time.Now()
+	timeNow = func() time.Time { return current }
+
+	cb := &CircuitBreaker{FailureThreshold: 1}
+	src := &countingTokenSource{err: errors.New("unavailable")}
+	ts := WrapTokenSource(src, WithCircuitBreaker(cb))
+	ts.Token()
+
+	current = current.Add(defaultOpenTimeout)
+	probe := WrapTokenSource(TokenSourceFunc(func() (*Token, error) {
+		// Calls made while the probe is in flight fail fast.
+		if _, err := ts.Token(); !errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("got %v during the probe but want %v", err, ErrCircuitOpen)
+		}
+		return &Token{AccessToken: "abc"}, nil
+	}), WithCircuitBreaker(cb))
+	if _, err := probe.Token(); err != nil {
+		t.Fatal(err)
+	}
+	if src.calls != 1 {
+		t.Errorf("got %d calls but want 1", src.calls)
+	}
+}
+
+func TestCircuitBreaker_ClientErrors(t *testing.T) {
+	cb := &CircuitBreaker{FailureThreshold: 1}
+	src := &countingTokenSource{err: &RetrieveError{Response: &http.Response{StatusCode: http.StatusBadRequest}, ErrorCode: "invalid_grant"}}
+	ts := WrapTokenSource(src, WithCircuitBreaker(cb))
+	for i := 0; i < 3; i++ {
+		ts.Token()
+	}
+	if src.calls != 3 {
+		t.Errorf("got %d calls but want 3", src.calls)
+	}
+	if got := cb.State(); got != CircuitClosed {
+		t.Errorf("got state %v but want %v", got, CircuitClosed)
+	}
+
+	src.err = &RetrieveError{Response: &http.Response{StatusCode: http.StatusServiceUnavailable}}
+	ts.Token()
+	if got := cb.State(); got != CircuitOpen {
+		t.Errorf("got state %v after a 503 but want %v", got, CircuitOpen)
+	}
+}
@@ -15,10 +15,13 @@ package clientcredentials // import "golang.org/x/oauth2/clientcredentials"
 
 import (
 	"context"
+	"crypto"
+	"crypto/tls"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/internal"
@@ -47,6 +50,51 @@ type Config struct {
 	// client ID & client secret sent. The zero value means to
 	// auto-detect.
 	AuthStyle oauth2.AuthStyle
+
+	// ScopeDowngrade, if non-nil, is called when a token response grants
+	// fewer scopes than were requested. Returning err fails the token
+	// retrieval; returning nil accepts the token, e.g. after logging the
+	// downgrade. Responses without a scope field are taken to grant the
+	// requested scopes, as described in RFC 6749 section 5.1.
+	ScopeDowngrade func(err *ScopeDowngradeError) error
+
+	// ErrorHook, if non-nil, is called with the sanitized response whenever
+	// the token endpoint returns an error.
+	ErrorHook func(*oauth2.TokenErrorResponse)
+
+	// ResponseHook, if non-nil, is called with the status code and headers
+	// of every successful token endpoint response.
+	ResponseHook func(*oauth2.TokenResponseMetadata)
+
+	// Certificate, if non-nil, authenticates the client with mutual TLS as
+	// described in RFC 8705: it is presented to the token endpoint, the
+	// client ID is sent as a parameter and ClientSecret is not sent. The
+	// HTTP client returned by Client presents it too, so that
+	// certificate-bound tokens can be used. The HTTP client of the context,
+	// if any, must use an *http.Transport.
+	Certificate *tls.Certificate
+
+	// DPoPKey, if non-nil, binds tokens to a key with DPoP proofs, as
+	// described in RFC 9449. A proof signed with the key is sent with every
+	// token request and, by the HTTP client returned by Client, with every
+	// request authorized with a DPoP token. Nonces required by the server are
+	// handled. ECDSA P-256 and RSA keys are supported.
+	DPoPKey crypto.Signer
+}
+
+// ScopeDowngradeError is the error passed to Config.ScopeDowngrade when the
+// authorization server grants fewer scopes than were requested.
+type ScopeDowngradeError struct {
+	// Requested are the scopes of the token request.
+	Requested []string
+	// Granted are the scopes of the token response.
+	Granted []string
+	// Missing are the requested scopes that were not granted.
+	Missing []string
+}
+
+func (e *ScopeDowngradeError) Error() string {
+	return fmt.Sprintf("oauth2: token response is missing requested scopes %q", strings.Join(e.Missing, " "))
 }
 
 // Token uses client credentials to retrieve a token.
@@ -64,7 +112,38 @@ func (c *Config) Token(ctx context.Context) (*oauth2.Token, error) {
 //
 // The returned Client and its Transport should not be modified.
 func (c *Config) Client(ctx context.Context) *http.Client {
-	return oauth2.NewClient(ctx, c.TokenSource(ctx))
+	if c.Certificate == nil && c.DPoPKey == nil {
+		return oauth2.NewClient(ctx, c.TokenSource(ctx))
+	}
+	hc, signer, err := c.baseClient(ctx)
+	if err != nil {
+		return &http.Client{Transport: errorTransport{err}}
+	}
+	ts := c.TokenSource(ctx)
+	if signer != nil {
+		return &http.Client{Transport: &dpopTransport{signer: signer, source: ts, base: hc.Transport}}
+	}
+	return &http.Client{Transport: &oauth2.Transport{Source: ts, Base: hc.Transport}}
+}
+
+// baseClient returns the HTTP client of ctx, presenting c.Certificate if
+// set, and a signer of DPoP proofs if c.DPoPKey is set.
+func (c *Config) baseClient(ctx context.Context) (*http.Client, *dpopSigner, error) {
+	hc := oauth2.HTTPClientFrom(ctx)
+	if c.Certificate != nil {
+		var err error
+		if hc, err = withCertificate(hc, c.Certificate); err != nil {
+			return nil, nil, err
+		}
+	}
+	if c.DPoPKey == nil {
+		return hc, nil, nil
+	}
+	signer, err := newDPoPSigner(c.DPoPKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return hc, signer, nil
 }
 
 // TokenSource returns a TokenSource that returns t until t expires,
@@ -83,6 +162,15 @@ func (c *Config) TokenSource(ctx context.Context) oauth2.TokenSource {
 type tokenSource struct {
 	ctx  context.Context
 	conf *Config
+
+	once   sync.Once // guards client and err
+	client *http.Client
+	err    error
+}
+
+// DescribeTokenSource describes c, see oauth2.DescribeTokenSource.
+func (c *tokenSource) DescribeTokenSource() (string, oauth2.TokenSource) {
+	return internal.DescribeParams("client_credentials", "client_id", c.conf.ClientID, "token_url", internal.RedactURL(c.conf.TokenURL)), nil
 }
 
 // Token refreshes the token by using a new client credentials request.
@@ -92,7 +180,7 @@ func (c *tokenSource) Token() (*oauth2.Token, error) {
 		"grant_type": {"client_credentials"},
 	}
 	if len(c.conf.Scopes) > 0 {
-		v.Set("scope", strings.Join(c.conf.Scopes, " "))
+		v.Set("scope", internal.ScopeString(c.conf.Scopes))
 	}
 	for k, p := range c.conf.EndpointParams {
 		// Allow grant_type to be overridden to allow interoperability with
@@ -103,18 +191,94 @@ func (c *tokenSource) Token() (*oauth2.Token, error) {
 		v[k] = p
 	}
 
-	tk, err := internal.RetrieveToken(c.ctx, c.conf.ClientID, c.conf.ClientSecret, c.conf.TokenURL, v, internal.AuthStyle(c.conf.AuthStyle))
+	ctx, clientSecret, authStyle := c.ctx, c.conf.ClientSecret, internal.AuthStyle(c.conf.AuthStyle)
+	if c.conf.Certificate != nil || c.conf.DPoPKey != nil {
+		client, err := c.tokenClient()
+		if err != nil {
+			return nil, err
+		}
+		ctx = oauth2.WithHTTPClient(ctx, client)
+	}
+	if c.conf.Certificate != nil {
+		clientSecret, authStyle = "", internal.AuthStyleInParams
+	}
+	tk, err := internal.RetrieveToken(ctx, c.conf.ClientID, clientSecret, c.conf.TokenURL, v, authStyle)
 	if err != nil {
 		if rErr, ok := err.(*internal.RetrieveError); ok {
-			return nil, (*oauth2.RetrieveError)(rErr)
+			retrieveErr := (*oauth2.RetrieveError)(rErr)
+			if c.conf.ErrorHook != nil {
+				c.conf.ErrorHook(retrieveErr.SanitizedResponse())
+			}
+			if authStyle == internal.AuthStyleStrict && internal.IsClientAuthError(rErr) {
+				return nil, &oauth2.AuthStyleError{Err: retrieveErr}
+			}
+			return nil, retrieveErr
 		}
 		return nil, err
 	}
+	if c.conf.ResponseHook != nil {
+		c.conf.ResponseHook(&oauth2.TokenResponseMetadata{StatusCode: tk.StatusCode, Header: internal.SanitizeHeader(tk.Header)})
+	}
 	t := &oauth2.Token{
 		AccessToken:  tk.AccessToken,
 		TokenType:    tk.TokenType,
 		RefreshToken: tk.RefreshToken,
 		Expiry:       tk.Expiry,
+		IssuedAt:     tk.IssuedAt,
+	}
+	t = t.WithExtra(tk.Raw)
+	if c.conf.ScopeDowngrade != nil {
+		if err := c.checkScopes(t); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// tokenClient returns the HTTP client of token requests.
+func (c *tokenSource) tokenClient() (*http.Client, error) {
+	c.once.Do(func() {
+		hc, signer, err := c.conf.baseClient(c.ctx)
+		if err != nil {
+			c.err = err
+			return
+		}
+		c.client = hc
+		if signer != nil {
+			c.client = &http.Client{
+				Transport: &dpopTransport{signer: signer, base: hc.Transport},
+				Jar:       hc.Jar,
+				Timeout:   hc.Timeout,
+			}
+		}
+	})
+	return c.client, c.err
+}
+
+// checkScopes reports a downgrade of the scopes granted in t to
+// conf.ScopeDowngrade.
+func (c *tokenSource) checkScopes(t *oauth2.Token) error {
+	scope, _ := t.Extra("scope").(string)
+	if scope == "" {
+		return nil
+	}
+	granted := strings.Fields(scope)
+	grantedSet := make(map[string]bool, len(granted))
+	for _, s := range granted {
+		grantedSet[s] = true
+	}
+	var missing []string
+	for _, s := range c.conf.Scopes {
+		if !grantedSet[s] {
+			missing = append(missing, s)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
 	}
-	return t.WithExtra(tk.Raw), nil
+	return c.conf.ScopeDowngrade(&ScopeDowngradeError{
+		Requested: c.conf.Scopes,
+		Granted:   granted,
+		Missing:   missing,
+	})
 }
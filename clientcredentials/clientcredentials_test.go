@@ -139,3 +139,48 @@ func TestTokenRefreshRequest(t *testing.T) {
 	c := conf.Client(context.Background())
 	c.Get(ts.URL + "/somethingelse")
 }
+
+func TestTokenScopeDowngrade(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"access_token": "foo", "token_type": "bearer", "scope": "scope1"}`)
+	}))
+	defer ts.Close()
+
+	var reported *ScopeDowngradeError
+	conf := newConf(ts.URL)
+	conf.ScopeDowngrade = func(err *ScopeDowngradeError) error {
+		reported = err
+		return err
+	}
+	if _, err := conf.Token(context.Background()); err == nil {
+		t.Fatal("Token() succeeded, want scope downgrade error")
+	}
+	if reported == nil || len(reported.Missing) != 1 || reported.Missing[0] != "scope2" {
+		t.Errorf("reported downgrade = %+v; want missing scope2", reported)
+	}
+
+	conf.ScopeDowngrade = func(err *ScopeDowngradeError) error { return nil }
+	if _, err := conf.Token(context.Background()); err != nil {
+		t.Errorf("Token() with accepted downgrade failed: %v", err)
+	}
+}
+
+func TestTokenRawResponse(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-www-form-urlencoded")
+		io.WriteString(w, "access_token=90d64460d14870c08c81352a05dedd3465940a7c&token_type=bearer&tenant=acme")
+	}))
+	defer ts.Close()
+	conf := newConf(ts.URL)
+	tok, err := conf.Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.IssuedAt.IsZero() {
+		t.Error("got a zero IssuedAt")
+	}
+	if got, want := tok.RawResponse()["tenant"], "acme"; got != want {
+		t.Errorf("got tenant %v but want %v", got, want)
+	}
+}
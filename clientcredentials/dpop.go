@@ -0,0 +1,225 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package clientcredentials
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// dpopNonceHeader carries the server-provided nonce of DPoP proofs, see RFC
+// 9449 section 8.
+const dpopNonceHeader = "DPoP-Nonce"
+
+// timeNow is time.Now but pulled out as a variable for tests.
+var timeNow = time.Now
+
+// dpopSigner creates DPoP proofs (RFC 9449) with a private key, remembering
+// the latest nonce required by the server.
+type dpopSigner struct {
+	key crypto.Signer
+	alg string
+	jwk map[string]string
+
+	mu    sync.Mutex // guards nonce
+	nonce string
+}
+
+func newDPoPSigner(key crypto.Signer) (*dpopSigner, error) {
+	s := &dpopSigner{key: key}
+	switch pub := key.Public().(type) {
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return nil, errors.New("oauth2: DPoP keys must be ECDSA P-256 or RSA keys")
+		}
+		s.alg = "ES256"
+		s.jwk = map[string]string{
+			"kty": "EC",
+			"crv": "P-256",
+			"x":   base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, 32))),
+			"y":   base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, 32))),
+		}
+	case *rsa.PublicKey:
+		s.alg = "RS256"
+		s.jwk = map[string]string{
+			"kty": "RSA",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}
+	default:
+		return nil, errors.New("oauth2: DPoP keys must be ECDSA P-256 or RSA keys")
+	}
+	return s, nil
+}
+
+// proof returns a DPoP proof for a request with the given method and URL.
+// If accessToken is not empty, the proof carries its hash.
+func (s *dpopSigner) proof(method, rawURL, accessToken string) (string, error) {
+	header, err := json.Marshal(struct {
+		Typ string            `json:"typ"`
+		Alg string            `json:"alg"`
+		JWK map[string]string `json:"jwk"`
+	}{"dpop+jwt", s.alg, s.jwk})
+	if err != nil {
+		return "", err
+	}
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+	claims := map[string]interface{}{
+		"jti": base64.RawURLEncoding.EncodeToString(jti),
+		"htm": method,
+		"htu": htu(rawURL),
+		"iat": timeNow().Unix(),
+	}
+	if nonce := s.currentNonce(); nonce != "" {
+		claims["nonce"] = nonce
+	}
+	if accessToken != "" {
+		ath := sha256.Sum256([]byte(accessToken))
+		claims["ath"] = base64.RawURLEncoding.EncodeToString(ath[:])
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	ss := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sig, err := s.sign([]byte(ss))
+	if err != nil {
+		return "", fmt.Errorf("oauth2: cannot sign DPoP proof: %v", err)
+	}
+	return ss + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// sign signs b with SHA-256, returning an ES256 signature in the JWS r || s
+// form rather than ASN.1.
+func (s *dpopSigner) sign(b []byte) ([]byte, error) {
+	h := sha256.Sum256(b)
+	sig, err := s.key.Sign(rand.Reader, h[:], crypto.SHA256)
+	if err != nil || s.alg != "ES256" {
+		return sig, err
+	}
+	var es struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(sig, &es); err != nil {
+		return nil, err
+	}
+	out := make([]byte, 64)
+	es.R.FillBytes(out[:32])
+	es.S.FillBytes(out[32:])
+	return out, nil
+}
+
+func (s *dpopSigner) currentNonce() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.nonce
+}
+
+// updateNonce records the nonce of resp, reporting whether it changed.
+func (s *dpopSigner) updateNonce(resp *http.Response) bool {
+	nonce := resp.Header.Get(dpopNonceHeader)
+	if nonce == "" {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := nonce != s.nonce
+	s.nonce = nonce
+	return changed
+}
+
+// htu returns the htu claim of a request URL, which excludes the query and
+// fragment.
+func htu(rawURL string) string {
+	if i := strings.IndexAny(rawURL, "?#"); i >= 0 {
+		return rawURL[:i]
+	}
+	return rawURL
+}
+
+// dpopTransport adds DPoP proofs to requests. With a Source, it also
+// authorizes requests with its tokens, as oauth2.Transport does; tokens that
+// are not of the DPoP type are sent as they would be by oauth2.Transport.
+// A request rejected for lacking the nonce the server now requires is sent
+// again once with it.
+type dpopTransport struct {
+	signer *dpopSigner
+	source oauth2.TokenSource
+	base   http.RoundTripper
+}
+
+func (t *dpopTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var tok *oauth2.Token
+	if t.source != nil {
+		var err error
+		if tok, err = t.source.Token(); err != nil {
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			return nil, err
+		}
+	}
+	resp, err := t.send(req, tok)
+	if err != nil {
+		return nil, err
+	}
+	retry := resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnauthorized
+	if t.signer.updateNonce(resp) && retry && (req.Body == nil || req.GetBody != nil) {
+		resp.Body.Close()
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+		return t.send(req, tok)
+	}
+	return resp, nil
+}
+
+func (t *dpopTransport) send(req *http.Request, tok *oauth2.Token) (*http.Response, error) {
+	req2 := req.Clone(req.Context()) // per RoundTripper contract
+	var accessToken string
+	if tok != nil {
+		tok.SetAuthHeader(req2)
+		if tok.Type() == "DPoP" {
+			accessToken = tok.AccessToken
+		}
+	}
+	if tok == nil || accessToken != "" {
+		proof, err := t.signer.proof(req.Method, req.URL.String(), accessToken)
+		if err != nil {
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			return nil, err
+		}
+		req2.Header.Set("DPoP", proof)
+	}
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req2)
+}
@@ -0,0 +1,143 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package clientcredentials
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// verifyProof checks the signature of an ES256 DPoP proof against its jwk
+// and returns its claims.
+func verifyProof(t *testing.T, proof string) map[string]interface{} {
+	t.Helper()
+	parts := strings.Split(proof, ".")
+	if len(parts) != 3 {
+		t.Fatalf("malformed DPoP proof %q", proof)
+	}
+	var header struct {
+		Typ string            `json:"typ"`
+		Alg string            `json:"alg"`
+		JWK map[string]string `json:"jwk"`
+	}
+	b, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	if err := json.Unmarshal(b, &header); err != nil {
+		t.Fatalf("cannot parse DPoP proof header: %v", err)
+	}
+	if header.Typ != "dpop+jwt" || header.Alg != "ES256" {
+		t.Errorf("got typ %q and alg %q but want dpop+jwt and ES256", header.Typ, header.Alg)
+	}
+	x, _ := base64.RawURLEncoding.DecodeString(header.JWK["x"])
+	y, _ := base64.RawURLEncoding.DecodeString(header.JWK["y"])
+	pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	h := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if len(sig) != 64 || !ecdsa.Verify(pub, h[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+		t.Errorf("DPoP proof signature does not verify")
+	}
+	var claims map[string]interface{}
+	b, _ = base64.RawURLEncoding.DecodeString(parts[1])
+	if err := json.Unmarshal(b, &claims); err != nil {
+		t.Fatalf("cannot parse DPoP proof claims: %v", err)
+	}
+	return claims
+}
+
+func TestDPoP(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tokenRequests int
+	var serverURL string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims := verifyProof(t, r.Header.Get("DPoP"))
+		if got, want := claims["htm"], r.Method; got != want {
+			t.Errorf("got htm %v but want %v", got, want)
+		}
+		if got, want := claims["htu"], serverURL+r.URL.Path; got != want {
+			t.Errorf("got htu %v but want %v", got, want)
+		}
+		switch r.URL.Path {
+		case "/token":
+			tokenRequests++
+			if claims["nonce"] != "server-nonce" {
+				w.Header().Set("DPoP-Nonce", "server-nonce")
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":"use_dpop_nonce"}`))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"access_token":"dpop-token","token_type":"DPoP","expires_in":3600}`))
+		case "/resource":
+			if got, want := r.Header.Get("Authorization"), "DPoP dpop-token"; got != want {
+				t.Errorf("got Authorization %v but want %v", got, want)
+			}
+			ath := sha256.Sum256([]byte("dpop-token"))
+			if got, want := claims["ath"], base64.RawURLEncoding.EncodeToString(ath[:]); got != want {
+				t.Errorf("got ath %v but want %v", got, want)
+			}
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer ts.Close()
+	serverURL = ts.URL
+
+	conf := newConf(ts.URL)
+	conf.DPoPKey = key
+	resp, err := conf.Client(context.Background()).Get(ts.URL + "/resource?q=1")
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusOK; got != want {
+		t.Errorf("got status code %v but want %v", got, want)
+	}
+	if got, want := tokenRequests, 2; got != want {
+		t.Errorf("got %v token requests but want %v, retrying with the nonce", got, want)
+	}
+}
+
+func TestDPoP_RSA(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := newDPoPSigner(key)
+	if err != nil {
+		t.Fatalf("newDPoPSigner() failed: %v", err)
+	}
+	proof, err := s.proof("POST", "https://example.com/token?x=1#y", "")
+	if err != nil {
+		t.Fatalf("proof() failed: %v", err)
+	}
+	parts := strings.Split(proof, ".")
+	b, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	var claims map[string]interface{}
+	json.Unmarshal(b, &claims)
+	if got, want := claims["htu"], "https://example.com/token"; got != want {
+		t.Errorf("got htu %v but want %v", got, want)
+	}
+	if _, ok := claims["ath"]; ok {
+		t.Errorf("proof of a token request has an ath claim")
+	}
+
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if _, err := newDPoPSigner(p384); err == nil {
+		t.Errorf("newDPoPSigner() accepted a P-384 key, want error")
+	}
+}
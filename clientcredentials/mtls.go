@@ -0,0 +1,47 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package clientcredentials
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+)
+
+// withCertificate returns a copy of base presenting cert for mutual TLS.
+func withCertificate(base *http.Client, cert *tls.Certificate) (*http.Client, error) {
+	client := &http.Client{}
+	if base != nil {
+		*client = *base
+	}
+	var transport *http.Transport
+	switch t := client.Transport.(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = t.Clone()
+	default:
+		return nil, errors.New("oauth2: mutual TLS client authentication requires an *http.Transport")
+	}
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	transport.TLSClientConfig.Certificates = []tls.Certificate{*cert}
+	client.Transport = transport
+	return client, nil
+}
+
+// errorTransport fails every request with err, for clients that could not be
+// configured.
+type errorTransport struct {
+	err error
+}
+
+func (t errorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	return nil, t.err
+}
@@ -0,0 +1,92 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package clientcredentials
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func testCertificate(t *testing.T) *tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestMutualTLS(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			t.Errorf("no client certificate was presented")
+		}
+		switch r.URL.Path {
+		case "/token":
+			if _, _, ok := r.BasicAuth(); ok {
+				t.Errorf("token request has basic authentication")
+			}
+			if got, want := r.FormValue("client_id"), "CLIENT_ID"; got != want {
+				t.Errorf("got client_id %v but want %v", got, want)
+			}
+			if got := r.FormValue("client_secret"); got != "" {
+				t.Errorf("got client_secret %v but want none", got)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"access_token":"bound-token","token_type":"Bearer","expires_in":3600}`))
+		case "/resource":
+			if got, want := r.Header.Get("Authorization"), "Bearer bound-token"; got != want {
+				t.Errorf("got Authorization %v but want %v", got, want)
+			}
+		}
+	}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	ts.StartTLS()
+	defer ts.Close()
+
+	conf := newConf(ts.URL)
+	conf.Certificate = testCertificate(t)
+	ctx := oauth2.WithHTTPClient(context.Background(), ts.Client())
+	resp, err := conf.Client(ctx).Get(ts.URL + "/resource")
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusOK; got != want {
+		t.Errorf("got status code %v but want %v", got, want)
+	}
+}
+
+func TestMutualTLS_UnsupportedTransport(t *testing.T) {
+	conf := newConf("https://example.com")
+	conf.Certificate = testCertificate(t)
+	ctx := oauth2.WithHTTPClient(context.Background(), &http.Client{Transport: &mockTransport{}})
+	if _, err := conf.Token(ctx); err == nil {
+		t.Error("Token() succeeded with a transport that cannot present a certificate, want error")
+	}
+}
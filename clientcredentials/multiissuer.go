@@ -0,0 +1,157 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package clientcredentials
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+// MultiIssuerTokenSource fetches and caches tokens from several issuers,
+// such as the tenant-specific authorization servers a gateway calls back
+// to. Each issuer gets its own Config and cached token, created on first
+// use.
+//
+// A MultiIssuerTokenSource is safe for concurrent use.
+type MultiIssuerTokenSource struct {
+	// MaxIssuers bounds the number of issuers whose Config and token are
+	// cached. Beyond it, the least recently used issuer is forgotten. If
+	// zero, the number is not bounded. It must be set before m is used.
+	MaxIssuers int
+
+	ctx    context.Context
+	config func(issuer string) (*Config, error)
+
+	mu      sync.Mutex
+	sources map[string]*list.Element // of *issuerSource, in lru
+	lru     *list.List               // most recently used first
+	pending map[string]*issuerCall
+}
+
+// issuerSource is the cached TokenSource of an issuer.
+type issuerSource struct {
+	issuer string
+	ts     oauth2.TokenSource
+}
+
+// issuerCall is a call of the config function in progress, which
+// concurrent callers for the same issuer wait for.
+type issuerCall struct {
+	done chan struct{}
+	ts   oauth2.TokenSource
+	err  error
+}
+
+// NewMultiIssuerTokenSource returns a MultiIssuerTokenSource obtaining the
+// Config of each issuer, typically its URL, from config. config is called
+// once at a time per issuer, and may be called for several issuers
+// concurrently. Its errors are returned by Token and not cached, so config
+// is called again for the next token of the issuer.
+//
+// The provided context optionally controls which HTTP client is used. See
+// the oauth2.HTTPClient variable.
+func NewMultiIssuerTokenSource(ctx context.Context, config func(issuer string) (*Config, error)) *MultiIssuerTokenSource {
+	return &MultiIssuerTokenSource{
+		ctx:     ctx,
+		config:  config,
+		sources: make(map[string]*list.Element),
+		lru:     list.New(),
+		pending: make(map[string]*issuerCall),
+	}
+}
+
+// Token returns a valid token of issuer, reusing its cached token if it is
+// still valid.
+func (m *MultiIssuerTokenSource) Token(issuer string) (*oauth2.Token, error) {
+	ts, err := m.source(issuer)
+	if err != nil {
+		return nil, err
+	}
+	return ts.Token()
+}
+
+// TokenSource returns a TokenSource of the tokens of issuer, sharing the
+// cache of m.
+func (m *MultiIssuerTokenSource) TokenSource(issuer string) oauth2.TokenSource {
+	return issuerTokenSource{m: m, issuer: issuer}
+}
+
+// Forget drops the Config and cached token of issuer, so that the next
+// token of issuer is fetched with a Config obtained anew, for instance
+// after its client credentials were rotated. A Config being obtained for
+// issuer is not cached either.
+func (m *MultiIssuerTokenSource) Forget(issuer string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.sources[issuer]; ok {
+		m.lru.Remove(e)
+		delete(m.sources, issuer)
+	}
+	delete(m.pending, issuer)
+}
+
+func (m *MultiIssuerTokenSource) source(issuer string) (oauth2.TokenSource, error) {
+	m.mu.Lock()
+	if e, ok := m.sources[issuer]; ok {
+		m.lru.MoveToFront(e)
+		m.mu.Unlock()
+		return e.Value.(*issuerSource).ts, nil
+	}
+	if call, ok := m.pending[issuer]; ok {
+		m.mu.Unlock()
+		<-call.done
+		return call.ts, call.err
+	}
+	call := &issuerCall{done: make(chan struct{})}
+	m.pending[issuer] = call
+	m.mu.Unlock()
+
+	// config is called without holding m.mu, so that a slow lookup of an
+	// issuer does not hold up the tokens of the others.
+	call.ts, call.err = m.newSource(issuer)
+	close(call.done)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.pending[issuer] != call {
+		// Forgotten in the meantime.
+		return call.ts, call.err
+	}
+	delete(m.pending, issuer)
+	if call.err == nil {
+		m.sources[issuer] = m.lru.PushFront(&issuerSource{issuer: issuer, ts: call.ts})
+		for m.MaxIssuers > 0 && m.lru.Len() > m.MaxIssuers {
+			oldest := m.lru.Back()
+			m.lru.Remove(oldest)
+			delete(m.sources, oldest.Value.(*issuerSource).issuer)
+		}
+	}
+	return call.ts, call.err
+}
+
+// newSource returns a new TokenSource of issuer.
+func (m *MultiIssuerTokenSource) newSource(issuer string) (oauth2.TokenSource, error) {
+	conf, err := m.config(issuer)
+	if err != nil {
+		return nil, err
+	}
+	if conf == nil {
+		return nil, errors.New("oauth2: no client credentials config for issuer " + issuer)
+	}
+	return conf.TokenSource(m.ctx), nil
+}
+
+type issuerTokenSource struct {
+	m      *MultiIssuerTokenSource
+	issuer string
+}
+
+func (s issuerTokenSource) Token() (*oauth2.Token, error) {
+	return s.m.Token(s.issuer)
+}
@@ -0,0 +1,143 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package clientcredentials
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMultiIssuerTokenSource(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		tenant := strings.TrimPrefix(r.URL.Path, "/")
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "%s-%d", "expires_in": 3600}`, tenant, requests)
+	}))
+	defer ts.Close()
+
+	configs := 0
+	m := NewMultiIssuerTokenSource(context.Background(), func(issuer string) (*Config, error) {
+		configs++
+		if issuer == "unknown" {
+			return nil, errors.New("unknown issuer")
+		}
+		return &Config{ClientID: "CLIENT_ID", ClientSecret: "CLIENT_SECRET", TokenURL: ts.URL + "/" + issuer}, nil
+	})
+
+	for _, tt := range []struct{ issuer, want string }{
+		{"tenant-a", "tenant-a-1"},
+		{"tenant-b", "tenant-b-2"},
+		{"tenant-a", "tenant-a-1"},
+	} {
+		tok, err := m.TokenSource(tt.issuer).Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tok.AccessToken != tt.want {
+			t.Errorf("got access token %v for %v but want %v", tok.AccessToken, tt.issuer, tt.want)
+		}
+	}
+	if configs != 2 {
+		t.Errorf("got %d configs but want 2", configs)
+	}
+
+	m.Forget("tenant-a")
+	tok, err := m.Token("tenant-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tok.AccessToken, "tenant-a-3"; got != want {
+		t.Errorf("got access token %v after Forget but want %v", got, want)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := m.Token("unknown"); err == nil {
+			t.Error("Token() succeeded for an unknown issuer, want error")
+		}
+	}
+	if configs != 5 {
+		t.Errorf("got %d configs but want 5, config errors should not be cached", configs)
+	}
+}
+
+func TestMultiIssuerTokenSource_ConcurrentConfig(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token": "token", "expires_in": 3600}`))
+	}))
+	defer ts.Close()
+
+	release := make(chan struct{})
+	var mu sync.Mutex
+	configs := make(map[string]int)
+	m := NewMultiIssuerTokenSource(context.Background(), func(issuer string) (*Config, error) {
+		mu.Lock()
+		configs[issuer]++
+		mu.Unlock()
+		if issuer == "slow" {
+			<-release
+		}
+		return &Config{ClientID: "CLIENT_ID", ClientSecret: "CLIENT_SECRET", TokenURL: ts.URL}, nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := m.Token("slow"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	// Other issuers are served while the config of slow is obtained.
+	if _, err := m.Token("fast"); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if got := configs["slow"]; got != 1 {
+		t.Errorf("got %d configs for concurrent calls but want 1", got)
+	}
+}
+
+func TestMultiIssuerTokenSource_MaxIssuers(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token": "token", "expires_in": 3600}`))
+	}))
+	defer ts.Close()
+
+	configs := make(map[string]int)
+	m := NewMultiIssuerTokenSource(context.Background(), func(issuer string) (*Config, error) {
+		configs[issuer]++
+		return &Config{ClientID: "CLIENT_ID", ClientSecret: "CLIENT_SECRET", TokenURL: ts.URL}, nil
+	})
+	m.MaxIssuers = 2
+
+	for _, issuer := range []string{"a", "b", "a", "c", "a", "b"} {
+		if _, err := m.Token(issuer); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// c evicted b, the least recently used issuer, so b is looked up again.
+	if got, want := configs, map[string]int{"a": 1, "b": 2, "c": 1}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got configs %v but want %v", got, want)
+	}
+	if got := len(m.sources); got != 2 {
+		t.Errorf("got %d cached issuers but want 2", got)
+	}
+}
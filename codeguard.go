@@ -0,0 +1,188 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth2
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrUnknownState is returned by CodeGuard when the state of a
+	// redirect was not issued by it, has expired or was already used.
+	ErrUnknownState = errors.New("oauth2: unknown or expired state")
+
+	// ErrCodeReplayed is returned by CodeGuard when an authorization code
+	// is presented more than once.
+	ErrCodeReplayed = errors.New("oauth2: authorization code already redeemed")
+
+	// ErrSessionMismatch is returned by CodeGuard when a redirect is
+	// presented with the session binding of another authorization
+	// request, e.g. a link crafted by an attacker.
+	ErrSessionMismatch = errors.New("oauth2: authorization request was not started by this session")
+)
+
+// defaultCodeGuardTTL is how long authorization requests stay redeemable
+// when CodeGuard.TTL is zero.
+const defaultCodeGuardTTL = 10 * time.Minute
+
+// defaultCodeGuardMaxPending is the number of pending authorization
+// requests kept when CodeGuard.MaxPending is zero.
+const defaultCodeGuardMaxPending = 10000
+
+// CodeGuard protects the redirect handler of a web application against
+// login CSRF, authorization code injection and replay. Every authorization
+// request is bound to a state value, a PKCE code verifier and a session
+// binding that the application stores in the session of the user, e.g. in a
+// cookie. A redirect is only accepted once per state, from the session that
+// began the request, and each authorization code only once, within TTL.
+//
+// A CodeGuard keeps its state in memory, so applications running several
+// replicas must route the redirect to the replica that began the request.
+// It is safe for concurrent use.
+type CodeGuard struct {
+	// TTL is how long an authorization request can be completed, and for
+	// how long redeemed codes are remembered. The default is 10 minutes.
+	TTL time.Duration
+
+	// MaxPending bounds the number of authorization requests awaiting
+	// their redirect. Beyond it, the oldest requests are forgotten. The
+	// default is 10000.
+	MaxPending int
+
+	mu       sync.Mutex
+	pending  map[string]pendingAuthorization
+	order    []string // states of pending, oldest first
+	redeemed map[[sha256.Size]byte]time.Time
+}
+
+type pendingAuthorization struct {
+	verifier CodeVerifier
+	binding  [sha256.Size]byte // hash of the session binding
+	created  time.Time
+}
+
+// AuthCodeURL begins an authorization request. It returns the URL to send
+// the user to, with a new state and the code challenge of a new verifier,
+// and the session binding of the request. The application must store the
+// binding in the session of the user, e.g. in an HttpOnly cookie, and pass
+// it to Redeem or Exchange when the user is redirected back.
+func (g *CodeGuard) AuthCodeURL(c *Config, opts ...AuthCodeOption) (authURL, binding string, err error) {
+	verifier := GenerateVerifier()
+	ch, err := c.Challenge(verifier)
+	if err != nil {
+		return "", "", err
+	}
+	challenge, err := c.ChallengeOption(ch)
+	if err != nil {
+		return "", "", err
+	}
+	state := string(GenerateVerifier())
+	binding = string(GenerateVerifier())
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.prune()
+	if g.pending == nil {
+		g.pending = make(map[string]pendingAuthorization)
+	}
+	g.evictOldest(g.maxPending() - 1)
+	g.pending[state] = pendingAuthorization{
+		verifier: verifier,
+		binding:  sha256.Sum256([]byte(binding)),
+		created:  timeNow(),
+	}
+	g.order = append(g.order, state)
+	return c.AuthCodeURL(state, append(opts, challenge)...), binding, nil
+}
+
+// Redeem consumes the authorization request of state, provided binding is
+// the session binding AuthCodeURL returned for it, and records code as
+// redeemed. It returns the code verifier to send with the token request.
+func (g *CodeGuard) Redeem(binding, state, code string) (CodeVerifier, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.prune()
+	p, ok := g.pending[state]
+	if !ok {
+		return "", ErrUnknownState
+	}
+	got := sha256.Sum256([]byte(binding))
+	if subtle.ConstantTimeCompare(got[:], p.binding[:]) != 1 {
+		return "", ErrSessionMismatch
+	}
+	delete(g.pending, state)
+	key := sha256.Sum256([]byte(code))
+	if _, ok := g.redeemed[key]; ok {
+		return "", ErrCodeReplayed
+	}
+	if g.redeemed == nil {
+		g.redeemed = make(map[[sha256.Size]byte]time.Time)
+	}
+	g.redeemed[key] = timeNow()
+	return p.verifier, nil
+}
+
+// Exchange redeems the state and code of the redirect request r for the
+// session binding of the user, and exchanges the code with c, sending the
+// bound code verifier.
+func (g *CodeGuard) Exchange(ctx context.Context, c *Config, r *http.Request, binding string, opts ...AuthCodeOption) (*Token, error) {
+	q := r.URL.Query()
+	code := q.Get("code")
+	if code == "" {
+		return nil, errors.New("oauth2: redirect is missing the code parameter")
+	}
+	verifier, err := g.Redeem(binding, q.Get("state"), code)
+	if err != nil {
+		return nil, err
+	}
+	return c.Exchange(ctx, code, append(opts, VerifierOption(verifier))...)
+}
+
+func (g *CodeGuard) maxPending() int {
+	if g.MaxPending > 0 {
+		return g.MaxPending
+	}
+	return defaultCodeGuardMaxPending
+}
+
+// evictOldest forgets the oldest pending requests until at most n remain.
+// g.mu must be held.
+func (g *CodeGuard) evictOldest(n int) {
+	for len(g.pending) > n && len(g.order) > 0 {
+		delete(g.pending, g.order[0])
+		g.order = g.order[1:]
+	}
+}
+
+// prune forgets expired requests and codes. g.mu must be held.
+func (g *CodeGuard) prune() {
+	ttl := g.TTL
+	if ttl == 0 {
+		ttl = defaultCodeGuardTTL
+	}
+	cutoff := timeNow().Add(-ttl)
+	for state, p := range g.pending {
+		if p.created.Before(cutoff) {
+			delete(g.pending, state)
+		}
+	}
+	for len(g.order) > 0 {
+		if _, ok := g.pending[g.order[0]]; ok {
+			break
+		}
+		g.order = g.order[1:]
+	}
+	for code, t := range g.redeemed {
+		if t.Before(cutoff) {
+			delete(g.redeemed, code)
+		}
+	}
+}
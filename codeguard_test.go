@@ -0,0 +1,131 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth2
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCodeGuard(t *testing.T) {
+	var gotVerifier string
+	ts := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		v, _ := url.ParseQuery(string(body))
+		gotVerifier = v.Get("code_verifier")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"token","token_type":"bearer"}`))
+	})
+	defer ts.Close()
+	conf := newConf(ts.URL)
+
+	var g CodeGuard
+	authURL, binding, err := g.AuthCodeURL(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := u.Query().Get("state")
+	challenge := u.Query().Get("code_challenge")
+
+	redirect := httptest.NewRequest("GET", "/callback?code=abc&state="+url.QueryEscape(state), nil)
+	if _, err := g.Exchange(context.Background(), conf, redirect, binding); err != nil {
+		t.Fatalf("Exchange() failed: %v", err)
+	}
+	if ch, _ := CodeVerifier(gotVerifier).Challenge("S256"); ch.Value != challenge {
+		t.Errorf("verifier %q does not match challenge %q", gotVerifier, challenge)
+	}
+
+	if _, err := g.Exchange(context.Background(), conf, redirect, binding); err != ErrUnknownState {
+		t.Errorf("second Exchange() error = %v; want %v", err, ErrUnknownState)
+	}
+}
+
+func TestCodeGuard_Redeem(t *testing.T) {
+	defer func(old func() time.Time) { timeNow = old }(timeNow)
+	current := time.Now()
+	timeNow = func() time.Time { return current }
+
+	g := &CodeGuard{TTL: time.Minute}
+	conf := newConf("https://example.com")
+	states := make([]string, 3)
+	bindings := make([]string, 3)
+	for i := range states {
+		authURL, binding, err := g.AuthCodeURL(conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, _ := url.Parse(authURL)
+		states[i], bindings[i] = u.Query().Get("state"), binding
+	}
+
+	if _, err := g.Redeem(bindings[0], states[0], "code"); err != nil {
+		t.Fatalf("Redeem() failed: %v", err)
+	}
+	if _, err := g.Redeem(bindings[1], states[1], "code"); err != ErrCodeReplayed {
+		t.Errorf("Redeem() of a replayed code error = %v; want %v", err, ErrCodeReplayed)
+	}
+	if _, err := g.Redeem(bindings[2], "forged", "other"); err != ErrUnknownState {
+		t.Errorf("Redeem() of a forged state error = %v; want %v", err, ErrUnknownState)
+	}
+
+	current = current.Add(2 * time.Minute)
+	if _, err := g.Redeem(bindings[2], states[2], "fresh"); err != ErrUnknownState {
+		t.Errorf("Redeem() of an expired state error = %v; want %v", err, ErrUnknownState)
+	}
+	if strings.Contains(states[0], "=") {
+		t.Errorf("state %q is not URL safe", states[0])
+	}
+}
+
+func TestCodeGuard_SessionBinding(t *testing.T) {
+	var g CodeGuard
+	conf := newConf("https://example.com")
+	// The attacker begins a request and sends its redirect to the victim.
+	attackerURL, _, err := g.AuthCodeURL(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, victimBinding, err := g.AuthCodeURL(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(attackerURL)
+	if _, err := g.Redeem(victimBinding, u.Query().Get("state"), "attacker-code"); err != ErrSessionMismatch {
+		t.Errorf("Redeem() with the binding of another session error = %v; want %v", err, ErrSessionMismatch)
+	}
+}
+
+func TestCodeGuard_MaxPending(t *testing.T) {
+	g := &CodeGuard{MaxPending: 2}
+	conf := newConf("https://example.com")
+	var states, bindings []string
+	for i := 0; i < 3; i++ {
+		authURL, binding, err := g.AuthCodeURL(conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, _ := url.Parse(authURL)
+		states, bindings = append(states, u.Query().Get("state")), append(bindings, binding)
+	}
+	if got := len(g.pending); got != 2 {
+		t.Errorf("got %d pending requests but want 2", got)
+	}
+	if _, err := g.Redeem(bindings[0], states[0], "a"); err != ErrUnknownState {
+		t.Errorf("Redeem() of an evicted request error = %v; want %v", err, ErrUnknownState)
+	}
+	if _, err := g.Redeem(bindings[2], states[2], "c"); err != nil {
+		t.Errorf("Redeem() of the newest request failed: %v", err)
+	}
+}
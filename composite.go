@@ -0,0 +1,74 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth2
+
+import (
+	"errors"
+	"strings"
+)
+
+// ChainError is returned by a ChainTokenSource when none of its sources
+// produced a token. Errs holds one error per source, in order.
+type ChainError struct {
+	Errs []error
+}
+
+func (e *ChainError) Error() string {
+	if len(e.Errs) == 0 {
+		return "oauth2: no token sources in chain"
+	}
+	msgs := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		msgs[i] = err.Error()
+	}
+	return "oauth2: all token sources in chain failed: " + strings.Join(msgs, "; ")
+}
+
+// ChainTokenSource returns a TokenSource that tries each of srcs in order and
+// returns the first token obtained. If every source fails, the returned error
+// is a *ChainError holding all the errors.
+//
+// Sources are tried again on every call, so ChainTokenSource is usually
+// wrapped with ReuseTokenSource.
+func ChainTokenSource(srcs ...TokenSource) TokenSource {
+	return chainTokenSource(append([]TokenSource(nil), srcs...))
+}
+
+type chainTokenSource []TokenSource
+
+func (c chainTokenSource) Token() (*Token, error) {
+	chainErr := &ChainError{}
+	for _, src := range c {
+		tok, err := src.Token()
+		if err == nil {
+			return tok, nil
+		}
+		chainErr.Errs = append(chainErr.Errs, err)
+	}
+	return nil, chainErr
+}
+
+// ConditionalTokenSource returns a TokenSource that calls pred on every call
+// and returns the token of then if pred reports true and the token of
+// otherwise if it reports false. A nil source is an error when chosen.
+func ConditionalTokenSource(pred func() bool, then, otherwise TokenSource) TokenSource {
+	return &conditionalTokenSource{pred: pred, then: then, otherwise: otherwise}
+}
+
+type conditionalTokenSource struct {
+	pred            func() bool
+	then, otherwise TokenSource
+}
+
+func (c *conditionalTokenSource) Token() (*Token, error) {
+	src := c.otherwise
+	if c.pred() {
+		src = c.then
+	}
+	if src == nil {
+		return nil, errors.New("oauth2: no token source for condition")
+	}
+	return src.Token()
+}
@@ -0,0 +1,67 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth2
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestChainTokenSource(t *testing.T) {
+	first := &countingTokenSource{err: errors.New("no metadata server")}
+	second := &countingTokenSource{tok: &Token{AccessToken: "second"}}
+	third := &countingTokenSource{tok: &Token{AccessToken: "third"}}
+
+	tok, err := ChainTokenSource(first, second, third).Token()
+	if err != nil {
+		t.Fatalf("Token() failed: %v", err)
+	}
+	if got, want := tok.AccessToken, "second"; got != want {
+		t.Errorf("got %v but want %v", got, want)
+	}
+	if third.calls != 0 {
+		t.Errorf("source after the first success was called")
+	}
+}
+
+func TestChainTokenSource_AllFail(t *testing.T) {
+	err1, err2 := errors.New("first"), errors.New("second")
+	_, err := ChainTokenSource(&countingTokenSource{err: err1}, &countingTokenSource{err: err2}).Token()
+	var chainErr *ChainError
+	if !errors.As(err, &chainErr) {
+		t.Fatalf("got %v but want a *ChainError", err)
+	}
+	if len(chainErr.Errs) != 2 || chainErr.Errs[0] != err1 || chainErr.Errs[1] != err2 {
+		t.Errorf("got %v but want [%v %v]", chainErr.Errs, err1, err2)
+	}
+	if got, want := err.Error(), "oauth2: all token sources in chain failed: first; second"; got != want {
+		t.Errorf("got %v but want %v", got, want)
+	}
+
+	if _, err := ChainTokenSource().Token(); err == nil {
+		t.Error("empty chain succeeded, want error")
+	}
+}
+
+func TestConditionalTokenSource(t *testing.T) {
+	useA := true
+	ts := ConditionalTokenSource(func() bool { return useA },
+		StaticTokenSource(&Token{AccessToken: "a"}),
+		StaticTokenSource(&Token{AccessToken: "b"}))
+	for _, want := range []string{"a", "b"} {
+		tok, err := ts.Token()
+		if err != nil {
+			t.Fatalf("Token() failed: %v", err)
+		}
+		if tok.AccessToken != want {
+			t.Errorf("got %v but want %v", tok.AccessToken, want)
+		}
+		useA = false
+	}
+
+	if _, err := ConditionalTokenSource(func() bool { return true }, nil, nil).Token(); err == nil {
+		t.Error("nil source succeeded, want error")
+	}
+}
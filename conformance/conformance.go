@@ -0,0 +1,330 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package conformance checks that an OAuth 2.0 authorization server, and
+// the client configuration used with it, behave as the specifications
+// mandate: PKCE, the state parameter, single-use authorization codes and
+// error responses. Run it against a staging or fake provider to validate an
+// integration before it reaches production.
+//
+// Example usage:
+//
+//	s := &conformance.Suite{Config: conf, Authorize: loginTestUser}
+//	for _, r := range conformance.Failed(s.Run(ctx)) {
+//		log.Print(r)
+//	}
+package conformance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/internal"
+)
+
+// Suite describes the setup to check.
+type Suite struct {
+	// Config is the client configuration to check. Required.
+	Config *oauth2.Config
+
+	// Authorize, if non-nil, completes the authorization request at
+	// authURL, for instance by signing in a test user, and returns the URL
+	// the authorization server redirected to. Checks requiring an
+	// authorization code are skipped if it is nil.
+	Authorize func(ctx context.Context, authURL string) (*url.URL, error)
+
+	// TokenSource, if non-nil, is checked to return valid tokens. It can
+	// be that of credentials obtained otherwise, e.g. of external account
+	// credentials of the google package.
+	TokenSource oauth2.TokenSource
+}
+
+// Result is the outcome of a check.
+type Result struct {
+	// Name identifies the check, e.g. "pkce/enforced".
+	Name string
+	// Spec is the section of the specification mandating the checked
+	// behavior.
+	Spec string
+	// Skipped reports whether the check was not run since the Suite lacks
+	// what it requires.
+	Skipped bool
+	// Err is why the check failed, or nil if it passed or was skipped.
+	Err error
+}
+
+func (r Result) String() string {
+	switch {
+	case r.Skipped:
+		return fmt.Sprintf("SKIP %s (%s)", r.Name, r.Spec)
+	case r.Err != nil:
+		return fmt.Sprintf("FAIL %s (%s): %v", r.Name, r.Spec, r.Err)
+	}
+	return fmt.Sprintf("PASS %s (%s)", r.Name, r.Spec)
+}
+
+// Failed returns the results of the failed checks.
+func Failed(results []Result) []Result {
+	var failed []Result
+	for _, r := range results {
+		if r.Err != nil {
+			failed = append(failed, r)
+		}
+	}
+	return failed
+}
+
+// errSkipped is returned by checks that cannot run.
+var errSkipped = errors.New("skipped")
+
+type check struct {
+	name, spec string
+	// local checks run without an authorization server.
+	local bool
+	run   func(ctx context.Context, s *Suite) error
+}
+
+// checks are the checks run by Suite.Run, in order.
+var checks = []check{
+	{"pkce/vectors", "RFC 7636 appendix B", true, checkPKCEVectors},
+	{"authorize/parameters", "RFC 6749 section 4.1.1", false, checkAuthorizeParameters},
+	{"authorize/state", "RFC 6749 section 4.1.2", false, checkState},
+	{"pkce/exchange", "RFC 7636 section 4.5", false, checkPKCEExchange},
+	{"pkce/enforced", "RFC 7636 section 4.6", false, checkPKCEEnforced},
+	{"token/code-reuse", "RFC 6749 section 4.1.2", false, checkCodeReuse},
+	{"token/invalid-grant", "RFC 6749 section 5.2", false, checkInvalidGrant},
+	{"token/invalid-client", "RFC 6749 section 5.2", false, checkInvalidClient},
+	{"token/unsupported-grant-type", "RFC 6749 section 5.2", false, checkUnsupportedGrantType},
+	{"token-source/valid", "RFC 6749 section 5.1", true, checkTokenSource},
+}
+
+// Run runs the checks against the authorization server of s.Config and
+// returns their results in order.
+//
+// The provided context optionally controls which HTTP client is used. See
+// the oauth2.HTTPClient variable.
+func (s *Suite) Run(ctx context.Context) []Result {
+	results := make([]Result, 0, len(checks))
+	for _, c := range checks {
+		r := Result{Name: c.name, Spec: c.spec}
+		if s.Config == nil && !c.local {
+			r.Skipped = true
+		} else if err := c.run(ctx, s); err == errSkipped {
+			r.Skipped = true
+		} else {
+			r.Err = err
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+func checkPKCEVectors(ctx context.Context, s *Suite) error {
+	for _, v := range PKCEVectors {
+		ch, err := oauth2.CodeVerifier(v.Verifier).Challenge(v.Method)
+		if err != nil {
+			return err
+		}
+		if ch.Value != v.Challenge {
+			return fmt.Errorf("got %s challenge %q for verifier %q, want %q", v.Method, ch.Value, v.Verifier, v.Challenge)
+		}
+	}
+	return nil
+}
+
+func checkAuthorizeParameters(ctx context.Context, s *Suite) error {
+	ch, err := s.Config.Challenge(oauth2.GenerateVerifier())
+	if err != nil {
+		return err
+	}
+	opt, err := s.Config.ChallengeOption(ch)
+	if err != nil {
+		return err
+	}
+	u, err := url.Parse(s.Config.AuthCodeURL("conformance-state", opt))
+	if err != nil {
+		return fmt.Errorf("invalid authorization URL: %v", err)
+	}
+	q := u.Query()
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             s.Config.ClientID,
+		"state":                 "conformance-state",
+		"code_challenge":        ch.Value,
+		"code_challenge_method": ch.Method,
+	}
+	if s.Config.RedirectURL != "" {
+		want["redirect_uri"] = s.Config.RedirectURL
+	}
+	for k, v := range want {
+		if got := q.Get(k); got != v {
+			return fmt.Errorf("got %s %q in the authorization URL, want %q", k, got, v)
+		}
+	}
+	if ch.Method == "plain" {
+		return errors.New("the plain code challenge method is used, S256 is required when the client can support it")
+	}
+	return nil
+}
+
+// authorize obtains an authorization code with a new state and verifier.
+func authorize(ctx context.Context, s *Suite) (code string, v oauth2.CodeVerifier, err error) {
+	if s.Authorize == nil {
+		return "", "", errSkipped
+	}
+	state := string(oauth2.GenerateVerifier())
+	v = oauth2.GenerateVerifier()
+	ch, err := s.Config.Challenge(v)
+	if err != nil {
+		return "", "", err
+	}
+	opt, err := s.Config.ChallengeOption(ch)
+	if err != nil {
+		return "", "", err
+	}
+	redirect, err := s.Authorize(ctx, s.Config.AuthCodeURL(state, opt))
+	if err != nil {
+		return "", "", fmt.Errorf("authorization failed: %v", err)
+	}
+	q := redirect.Query()
+	if e := q.Get("error"); e != "" {
+		return "", "", fmt.Errorf("authorization failed: %s: %s", e, q.Get("error_description"))
+	}
+	if got := q.Get("state"); got != state {
+		return "", "", fmt.Errorf("got state %q in the redirect, want %q", got, state)
+	}
+	code = q.Get("code")
+	if code == "" {
+		return "", "", errors.New("no code in the redirect")
+	}
+	return code, v, nil
+}
+
+func checkState(ctx context.Context, s *Suite) error {
+	_, _, err := authorize(ctx, s)
+	return err
+}
+
+func checkPKCEExchange(ctx context.Context, s *Suite) error {
+	code, v, err := authorize(ctx, s)
+	if err != nil {
+		return err
+	}
+	tok, err := s.Config.Exchange(ctx, code, oauth2.VerifierOption(v))
+	if err != nil {
+		return fmt.Errorf("exchange with the code verifier failed: %v", err)
+	}
+	return validToken(tok)
+}
+
+func checkPKCEEnforced(ctx context.Context, s *Suite) error {
+	code, _, err := authorize(ctx, s)
+	if err != nil {
+		return err
+	}
+	_, err = s.Config.Exchange(ctx, code, oauth2.VerifierOption(oauth2.GenerateVerifier()))
+	if err == nil {
+		return errors.New("exchange with a wrong code verifier succeeded")
+	}
+	return wantErrorCode(err, "invalid_grant")
+}
+
+func checkCodeReuse(ctx context.Context, s *Suite) error {
+	code, v, err := authorize(ctx, s)
+	if err != nil {
+		return err
+	}
+	if _, err := s.Config.Exchange(ctx, code, oauth2.VerifierOption(v)); err != nil {
+		return fmt.Errorf("first exchange failed: %v", err)
+	}
+	_, err = s.Config.Exchange(ctx, code, oauth2.VerifierOption(v))
+	if err == nil {
+		return errors.New("the authorization code was accepted twice")
+	}
+	return wantErrorCode(err, "invalid_grant")
+}
+
+func checkInvalidGrant(ctx context.Context, s *Suite) error {
+	_, err := s.Config.Exchange(ctx, "conformance-invalid-code", oauth2.VerifierOption(oauth2.GenerateVerifier()))
+	if err == nil {
+		return errors.New("exchange of an invalid code succeeded")
+	}
+	return wantErrorCode(err, "invalid_grant")
+}
+
+func checkInvalidClient(ctx context.Context, s *Suite) error {
+	if s.Config.ClientSecret == "" {
+		return errSkipped
+	}
+	conf := *s.Config
+	conf.ClientSecret += "-conformance-invalid"
+	_, err := conf.Exchange(ctx, "conformance-invalid-code")
+	if err == nil {
+		return errors.New("exchange with an invalid client secret succeeded")
+	}
+	return wantErrorCode(err, "invalid_client")
+}
+
+func checkUnsupportedGrantType(ctx context.Context, s *Suite) error {
+	v := url.Values{"grant_type": {"urn:example:conformance:unsupported"}}
+	_, err := internal.RetrieveToken(ctx, s.Config.ClientID, s.Config.ClientSecret, s.Config.Endpoint.TokenURL, v, internal.AuthStyle(s.Config.Endpoint.AuthStyle))
+	if err == nil {
+		return errors.New("token request with an unsupported grant type succeeded")
+	}
+	if rErr, ok := err.(*internal.RetrieveError); ok {
+		err = (*oauth2.RetrieveError)(rErr)
+	}
+	return wantErrorCode(err, "unsupported_grant_type")
+}
+
+func checkTokenSource(ctx context.Context, s *Suite) error {
+	if s.TokenSource == nil {
+		return errSkipped
+	}
+	tok, err := s.TokenSource.Token()
+	if err != nil {
+		return err
+	}
+	return validToken(tok)
+}
+
+// validToken checks the fields a token response must carry.
+func validToken(tok *oauth2.Token) error {
+	switch {
+	case tok.AccessToken == "":
+		return errors.New("no access_token in the token response")
+	case tok.TokenType == "":
+		return errors.New("no token_type in the token response")
+	case !tok.Valid():
+		return fmt.Errorf("the token expired at %v upon receipt", tok.Expiry)
+	}
+	return nil
+}
+
+// wantErrorCode checks that err is an error response of the token endpoint
+// with the error code want, a 400 status, or 401 for invalid_client, and a
+// JSON body.
+func wantErrorCode(err error, want string) error {
+	var rErr *oauth2.RetrieveError
+	if !errors.As(err, &rErr) {
+		return fmt.Errorf("got %v, want a %s error response", err, want)
+	}
+	if rErr.ErrorCode != want {
+		return fmt.Errorf("got error code %q, want %q", rErr.ErrorCode, want)
+	}
+	if rErr.Response == nil {
+		return nil
+	}
+	if c := rErr.Response.StatusCode; c != 400 && !(c == 401 && want == "invalid_client") {
+		return fmt.Errorf("got status %d for a %s error, want 400", c, want)
+	}
+	if ct, _, _ := mime.ParseMediaType(rErr.Response.Header.Get("Content-Type")); ct != "application/json" {
+		return fmt.Errorf("got Content-Type %q for an error response, want application/json", ct)
+	}
+	return nil
+}
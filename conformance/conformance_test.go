@@ -0,0 +1,151 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conformance
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+// fakeProvider is an authorization server granting codes without user
+// interaction. Its lax field disables PKCE enforcement.
+type fakeProvider struct {
+	lax bool
+
+	mu    sync.Mutex
+	codes map[string]string // code to challenge
+	n     int
+}
+
+func (p *fakeProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch r.URL.Path {
+	case "/auth":
+		q := r.URL.Query()
+		p.n++
+		code := "code-" + string(rune('a'+p.n))
+		p.codes[code] = q.Get("code_challenge")
+		redirect := q.Get("redirect_uri") + "?" + url.Values{"code": {code}, "state": {q.Get("state")}}.Encode()
+		http.Redirect(w, r, redirect, http.StatusFound)
+	case "/token":
+		fail := func(status int, code string) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(map[string]string{"error": code})
+		}
+		id, secret, _ := r.BasicAuth()
+		if id == "" {
+			id, secret = r.FormValue("client_id"), r.FormValue("client_secret")
+		}
+		if id != "client" || secret != "secret" {
+			fail(http.StatusUnauthorized, "invalid_client")
+			return
+		}
+		if r.FormValue("grant_type") != "authorization_code" {
+			fail(http.StatusBadRequest, "unsupported_grant_type")
+			return
+		}
+		challenge, ok := p.codes[r.FormValue("code")]
+		delete(p.codes, r.FormValue("code"))
+		h := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		if !ok || (!p.lax && base64.RawURLEncoding.EncodeToString(h[:]) != challenge) {
+			fail(http.StatusBadRequest, "invalid_grant")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token": "token", "token_type": "Bearer", "expires_in": 3600}`))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newSuite(t *testing.T, p *fakeProvider) *Suite {
+	p.codes = make(map[string]string)
+	ts := httptest.NewServer(p)
+	t.Cleanup(ts.Close)
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	return &Suite{
+		Config: &oauth2.Config{
+			ClientID:     "client",
+			ClientSecret: "secret",
+			RedirectURL:  "http://localhost/callback",
+			Endpoint: oauth2.Endpoint{
+				AuthURL:   ts.URL + "/auth",
+				TokenURL:  ts.URL + "/token",
+				AuthStyle: oauth2.AuthStyleInHeader,
+			},
+		},
+		Authorize: func(ctx context.Context, authURL string) (*url.URL, error) {
+			resp, err := client.Get(authURL)
+			if err != nil {
+				return nil, err
+			}
+			resp.Body.Close()
+			return resp.Location()
+		},
+		TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token", TokenType: "Bearer"}),
+	}
+}
+
+func TestRun(t *testing.T) {
+	results := newSuite(t, &fakeProvider{}).Run(context.Background())
+	if len(results) != len(checks) {
+		t.Fatalf("got %d results but want %d", len(results), len(checks))
+	}
+	for _, r := range results {
+		if r.Skipped || r.Err != nil {
+			t.Errorf("%v", r)
+		}
+	}
+}
+
+func TestRun_NonConformingProvider(t *testing.T) {
+	results := newSuite(t, &fakeProvider{lax: true}).Run(context.Background())
+	failed := Failed(results)
+	if len(failed) != 1 || failed[0].Name != "pkce/enforced" {
+		t.Errorf("got failed checks %v but want pkce/enforced", failed)
+	}
+}
+
+func TestRun_Skipped(t *testing.T) {
+	s := newSuite(t, &fakeProvider{})
+	s.Authorize = nil
+	s.TokenSource = oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"})
+	var skipped []string
+	for _, r := range s.Run(context.Background()) {
+		if r.Skipped {
+			skipped = append(skipped, r.Name)
+		}
+		if r.Name == "token-source/valid" && r.Err == nil {
+			t.Error("token-source/valid passed without a token type")
+		}
+	}
+	if got, want := strings.Join(skipped, " "), "authorize/state pkce/exchange pkce/enforced token/code-reuse"; got != want {
+		t.Errorf("got skipped checks %q but want %q", got, want)
+	}
+}
+
+func TestWantErrorCode(t *testing.T) {
+	if err := wantErrorCode(errors.New("network down"), "invalid_grant"); err == nil {
+		t.Error("wantErrorCode() accepted an error that is not an error response")
+	}
+	if err := wantErrorCode(&oauth2.RetrieveError{ErrorCode: "invalid_request"}, "invalid_grant"); err == nil {
+		t.Error("wantErrorCode() accepted the wrong error code")
+	}
+}
@@ -0,0 +1,20 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conformance
+
+// PKCEVector is a PKCE code verifier and the code challenge derived from it.
+type PKCEVector struct {
+	Method    string
+	Verifier  string
+	Challenge string
+}
+
+// PKCEVectors are well-known PKCE test vectors, usable to check other
+// implementations, such as that of a fake provider.
+var PKCEVectors = []PKCEVector{
+	// RFC 7636 appendix B.
+	{"S256", "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk", "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"},
+	{"plain", "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk", "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"},
+}
@@ -0,0 +1,62 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth2
+
+import (
+	"context"
+	"net/http"
+
+	"golang.org/x/oauth2/internal"
+)
+
+// WithHTTPClient returns a copy of ctx carrying c, the HTTP client used to
+// talk to token endpoints. It is equivalent to
+// context.WithValue(ctx, HTTPClient, c).
+func WithHTTPClient(ctx context.Context, c *http.Client) context.Context {
+	return context.WithValue(ctx, HTTPClient, c)
+}
+
+// HTTPClientFrom returns the HTTP client used for token requests made with
+// ctx: the client set with WithHTTPClient or the HTTPClient key, or
+// http.DefaultClient.
+func HTTPClientFrom(ctx context.Context) *http.Client {
+	return internal.ContextClient(ctx)
+}
+
+// Logger receives diagnostic messages, such as token endpoint failures. It is
+// implemented by *log.Logger. Messages do not include credentials.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// WithLogger returns a copy of ctx carrying l. Token requests made with the
+// returned context, or with TokenSources created with it, report to l.
+func WithLogger(ctx context.Context, l Logger) context.Context {
+	return internal.WithLogger(ctx, l)
+}
+
+// LoggerFrom returns the Logger carried by ctx, or nil.
+func LoggerFrom(ctx context.Context) Logger {
+	return internal.ContextLogger(ctx)
+}
+
+// Tracer adapts a tracing library, such as OpenTelemetry, to this package.
+// StartSpan starts a span named name as a child of any span in ctx. It
+// returns the context of the new span and a function that ends the span,
+// recording err if it is not nil.
+type Tracer interface {
+	StartSpan(ctx context.Context, name string) (context.Context, func(err error))
+}
+
+// WithTracer returns a copy of ctx carrying t. Token requests made with the
+// returned context, or with TokenSources created with it, are traced with t.
+func WithTracer(ctx context.Context, t Tracer) context.Context {
+	return internal.WithTracer(ctx, t)
+}
+
+// TracerFrom returns the Tracer carried by ctx, or nil.
+func TracerFrom(ctx context.Context) Tracer {
+	return internal.ContextTracer(ctx)
+}
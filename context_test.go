@@ -0,0 +1,84 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth2
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTPClientFrom(t *testing.T) {
+	if got := HTTPClientFrom(context.Background()); got != http.DefaultClient {
+		t.Errorf("got %v but want http.DefaultClient", got)
+	}
+	c := &http.Client{}
+	if got := HTTPClientFrom(WithHTTPClient(context.Background(), c)); got != c {
+		t.Errorf("got %v but want the client set with WithHTTPClient", got)
+	}
+	// The typed accessors are interchangeable with the HTTPClient key.
+	if got := HTTPClientFrom(context.WithValue(context.Background(), HTTPClient, c)); got != c {
+		t.Errorf("got %v but want the client set with the HTTPClient key", got)
+	}
+	if got, _ := WithHTTPClient(context.Background(), c).Value(HTTPClient).(*http.Client); got != c {
+		t.Errorf("got %v but want the client set with WithHTTPClient", got)
+	}
+}
+
+type testTracer struct {
+	spans []string
+	errs  []error
+}
+
+func (tr *testTracer) StartSpan(ctx context.Context, name string) (context.Context, func(error)) {
+	tr.spans = append(tr.spans, name)
+	return ctx, func(err error) { tr.errs = append(tr.errs, err) }
+}
+
+func TestLoggerAndTracer(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant","access_token":"secret-token"}`))
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	if LoggerFrom(ctx) != nil || TracerFrom(ctx) != nil {
+		t.Fatal("got a Logger or Tracer from an empty context")
+	}
+	var buf bytes.Buffer
+	logger := log.New(&buf, "", 0)
+	tracer := &testTracer{}
+	ctx = WithTracer(WithLogger(ctx, logger), tracer)
+	if LoggerFrom(ctx) != logger || TracerFrom(ctx) != tracer {
+		t.Fatal("LoggerFrom or TracerFrom did not return the value set")
+	}
+
+	conf := newConf(ts.URL)
+	_, err := conf.Exchange(ctx, "code")
+	var re *RetrieveError
+	if !errors.As(err, &re) {
+		t.Fatalf("got error %v but want a *RetrieveError", err)
+	}
+	if got, want := strings.Join(tracer.spans, ","), "oauth2.RetrieveToken"; got != want {
+		t.Errorf("got spans %v but want %v", got, want)
+	}
+	if len(tracer.errs) != 1 || tracer.errs[0] == nil {
+		t.Errorf("got span errors %v but want the token error", tracer.errs)
+	}
+	logged := buf.String()
+	if !strings.Contains(logged, `status 400, error "invalid_grant"`) {
+		t.Errorf("got log %q but want the error code", logged)
+	}
+	if strings.Contains(logged, "secret-token") {
+		t.Errorf("log %q includes the response body", logged)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth2
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/oauth2/internal"
+)
+
+// maxDescribeDepth bounds the length of the chains described by
+// DescribeTokenSource, in case of a cycle.
+const maxDescribeDepth = 16
+
+// A TokenSourceDescriber is a TokenSource that can describe itself to
+// DescribeTokenSource.
+type TokenSourceDescriber interface {
+	// DescribeTokenSource returns a short description of the source, with
+	// its key parameters but never secrets, and the source it obtains
+	// tokens from, or nil. An empty description leaves the source out of
+	// the chain, which suits wrappers that do not change how tokens are
+	// obtained.
+	DescribeTokenSource() (description string, next TokenSource)
+}
+
+// DescribeTokenSource returns the chain of token sources ts obtains its
+// tokens through, such as
+//
+//	reuse -> impersonate(url=https://iamcredentials.googleapis.com/...) -> external_account(source=file, ...)
+//
+// so that services can log exactly which authentication path they use.
+// Sources that do not implement TokenSourceDescriber are described by
+// their type.
+func DescribeTokenSource(ts TokenSource) string {
+	var parts []string
+	for i := 0; ts != nil; i++ {
+		if i == maxDescribeDepth {
+			parts = append(parts, "...")
+			break
+		}
+		d, ok := ts.(TokenSourceDescriber)
+		if !ok {
+			parts = append(parts, fmt.Sprintf("%T", ts))
+			break
+		}
+		var desc string
+		desc, ts = d.DescribeTokenSource()
+		if desc != "" {
+			parts = append(parts, desc)
+		}
+	}
+	if len(parts) == 0 {
+		return "<nil>"
+	}
+	return strings.Join(parts, " -> ")
+}
+
+func (s *reuseTokenSource) DescribeTokenSource() (string, TokenSource) {
+	return "reuse", s.new
+}
+
+func (tf *tokenRefresher) DescribeTokenSource() (string, TokenSource) {
+	return internal.DescribeParams("refresh", "client_id", tf.conf.ClientID, "token_url", internal.RedactURL(tf.conf.Endpoint.TokenURL)), nil
+}
+
+func (s staticTokenSource) DescribeTokenSource() (string, TokenSource) {
+	return "static", nil
+}
+
+func (s *expiringStaticTokenSource) DescribeTokenSource() (string, TokenSource) {
+	return "expiring_static", nil
+}
+
+func (s *rateLimitTokenSource) DescribeTokenSource() (string, TokenSource) {
+	return internal.DescribeParams("rate_limit", "interval", s.interval.String()), s.src
+}
+
+func (c chainTokenSource) DescribeTokenSource() (string, TokenSource) {
+	descs := make([]string, len(c))
+	for i, src := range c {
+		descs[i] = DescribeTokenSource(src)
+	}
+	return "chain(" + strings.Join(descs, " | ") + ")", nil
+}
+
+func (c *conditionalTokenSource) DescribeTokenSource() (string, TokenSource) {
+	return "conditional(" + DescribeTokenSource(c.then) + " | " + DescribeTokenSource(c.otherwise) + ")", nil
+}
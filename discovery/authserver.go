@@ -0,0 +1,208 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package discovery fetches OAuth 2.0 metadata documents so that clients
+// can find endpoints and capabilities at run time instead of hardcoding them.
+package discovery // import "golang.org/x/oauth2/discovery"
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/internal"
+)
+
+// authServerWellKnown is the well-known URI suffix of RFC 8414.
+const authServerWellKnown = "/.well-known/oauth-authorization-server"
+
+// defaultCacheTTL is how long fetched documents are cached when
+// Client.CacheTTL is not set.
+const defaultCacheTTL = time.Hour
+
+// AuthServerMetadata is an OAuth 2.0 authorization server metadata document,
+// as described by RFC 8414 section 2.
+type AuthServerMetadata struct {
+	Issuer                                     string   `json:"issuer"`
+	AuthorizationEndpoint                      string   `json:"authorization_endpoint"`
+	TokenEndpoint                              string   `json:"token_endpoint"`
+	JWKSURI                                    string   `json:"jwks_uri"`
+	RegistrationEndpoint                       string   `json:"registration_endpoint"`
+	ScopesSupported                            []string `json:"scopes_supported"`
+	ResponseTypesSupported                     []string `json:"response_types_supported"`
+	GrantTypesSupported                        []string `json:"grant_types_supported"`
+	TokenEndpointAuthMethodsSupported          []string `json:"token_endpoint_auth_methods_supported"`
+	RevocationEndpoint                         string   `json:"revocation_endpoint"`
+	IntrospectionEndpoint                      string   `json:"introspection_endpoint"`
+	CodeChallengeMethodsSupported              []string `json:"code_challenge_methods_supported"`
+	DeviceAuthorizationEndpoint                string   `json:"device_authorization_endpoint"`
+	DPoPSigningAlgValuesSupported              []string `json:"dpop_signing_alg_values_supported"`
+	PushedAuthorizationRequestEndpoint         string   `json:"pushed_authorization_request_endpoint"`
+	AuthorizationResponseIssParameterSupported bool     `json:"authorization_response_iss_parameter_supported"`
+}
+
+// SupportsPKCE reports whether the server advertises the PKCE code challenge
+// method, e.g. "S256".
+func (m *AuthServerMetadata) SupportsPKCE(method string) bool {
+	return contains(m.CodeChallengeMethodsSupported, method)
+}
+
+// SupportsRevocation reports whether the server has a token revocation
+// endpoint.
+func (m *AuthServerMetadata) SupportsRevocation() bool {
+	return m.RevocationEndpoint != ""
+}
+
+// SupportsDPoP reports whether the server accepts DPoP proofs signed with
+// alg, e.g. "ES256".
+func (m *AuthServerMetadata) SupportsDPoP(alg string) bool {
+	return contains(m.DPoPSigningAlgValuesSupported, alg)
+}
+
+// SupportsGrantType reports whether the server supports grantType. RFC 8414
+// specifies that "authorization_code" and "implicit" are supported when the
+// server omits grant_types_supported.
+func (m *AuthServerMetadata) SupportsGrantType(grantType string) bool {
+	if m.GrantTypesSupported == nil {
+		return grantType == "authorization_code" || grantType == "implicit"
+	}
+	return contains(m.GrantTypesSupported, grantType)
+}
+
+// Endpoint returns the server's endpoints for use in an oauth2.Config.
+func (m *AuthServerMetadata) Endpoint() oauth2.Endpoint {
+	return oauth2.Endpoint{
+		AuthURL:  m.AuthorizationEndpoint,
+		TokenURL: m.TokenEndpoint,
+	}
+}
+
+// Client fetches metadata documents and caches them. The zero value is ready
+// to use and is safe for concurrent use.
+type Client struct {
+	// HTTPClient is the client used to fetch documents. If nil, the client
+	// from the context (see oauth2.HTTPClient) or http.DefaultClient is used.
+	HTTPClient *http.Client
+
+	// CacheTTL is how long a fetched document is reused. The default is one
+	// hour. A negative value disables caching.
+	CacheTTL time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	doc     interface{}
+	expires time.Time
+}
+
+// AuthServerMetadata returns the metadata of the authorization server
+// identified by issuer. The document is fetched from the well-known URI
+// derived from issuer as described by RFC 8414 section 3.1, and its issuer
+// must match.
+func (c *Client) AuthServerMetadata(ctx context.Context, issuer string) (*AuthServerMetadata, error) {
+	u, err := wellKnownURL(issuer, authServerWellKnown)
+	if err != nil {
+		return nil, err
+	}
+	if doc, ok := c.cached(u); ok {
+		return doc.(*AuthServerMetadata), nil
+	}
+	m := &AuthServerMetadata{}
+	if err := c.fetch(ctx, u, m); err != nil {
+		return nil, err
+	}
+	if m.Issuer != issuer {
+		return nil, fmt.Errorf("oauth2/discovery: metadata issuer %q does not match %q", m.Issuer, issuer)
+	}
+	c.store(u, m)
+	return m, nil
+}
+
+// wellKnownURL inserts suffix between the host and the path of issuer.
+func wellKnownURL(issuer, suffix string) (string, error) {
+	u, err := url.Parse(issuer)
+	if err != nil {
+		return "", fmt.Errorf("oauth2/discovery: invalid identifier %q: %v", issuer, err)
+	}
+	if u.Scheme != "https" && u.Scheme != "http" || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("oauth2/discovery: identifier %q must be an absolute URL without query or fragment", issuer)
+	}
+	u.Path = suffix + strings.TrimSuffix(u.Path, "/")
+	u.RawPath = ""
+	return u.String(), nil
+}
+
+func (c *Client) fetch(ctx context.Context, u string, v interface{}) error {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = internal.ContextClient(ctx)
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return fmt.Errorf("oauth2/discovery: cannot fetch %s: %v", u, err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("oauth2/discovery: cannot fetch %s: %v", u, err)
+	}
+	if code := resp.StatusCode; code < 200 || code > 299 {
+		return fmt.Errorf("oauth2/discovery: cannot fetch %s: status code %d: %s", u, code, body)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("oauth2/discovery: cannot parse %s: %v", u, err)
+	}
+	return nil
+}
+
+func (c *Client) cached(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.cache[key]
+	if !ok || !time.Now().Before(e.expires) {
+		return nil, false
+	}
+	return e.doc, true
+}
+
+func (c *Client) store(key string, doc interface{}) {
+	ttl := c.CacheTTL
+	if ttl < 0 {
+		return
+	}
+	if ttl == 0 {
+		ttl = defaultCacheTTL
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cache == nil {
+		c.cache = make(map[string]cacheEntry)
+	}
+	c.cache[key] = cacheEntry{doc: doc, expires: time.Now().Add(ttl)}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWellKnownURL(t *testing.T) {
+	tests := []struct {
+		issuer string
+		want   string
+	}{
+		{"https://as.example.com", "https://as.example.com/.well-known/oauth-authorization-server"},
+		{"https://as.example.com/", "https://as.example.com/.well-known/oauth-authorization-server"},
+		{"https://as.example.com/tenant/1", "https://as.example.com/.well-known/oauth-authorization-server/tenant/1"},
+	}
+	for _, tt := range tests {
+		got, err := wellKnownURL(tt.issuer, authServerWellKnown)
+		if err != nil {
+			t.Errorf("wellKnownURL(%q) failed: %v", tt.issuer, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("wellKnownURL(%q) = %v, want %v", tt.issuer, got, tt.want)
+		}
+	}
+	for _, issuer := range []string{"as.example.com", "https://as.example.com?tenant=1", "ftp://as.example.com"} {
+		if _, err := wellKnownURL(issuer, authServerWellKnown); err == nil {
+			t.Errorf("wellKnownURL(%q) succeeded, want error", issuer)
+		}
+	}
+}
+
+func TestAuthServerMetadata(t *testing.T) {
+	var requests int
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if got, want := r.URL.Path, "/.well-known/oauth-authorization-server/tenant"; got != want {
+			t.Errorf("got path %v but want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{
+			"issuer": "%[1]s/tenant",
+			"authorization_endpoint": "%[1]s/authorize",
+			"token_endpoint": "%[1]s/token",
+			"revocation_endpoint": "%[1]s/revoke",
+			"code_challenge_methods_supported": ["S256"],
+			"dpop_signing_alg_values_supported": ["ES256"]
+		}`, server.URL)
+	}))
+	defer server.Close()
+
+	c := &Client{}
+	for i := 0; i < 2; i++ {
+		m, err := c.AuthServerMetadata(context.Background(), server.URL+"/tenant")
+		if err != nil {
+			t.Fatalf("AuthServerMetadata() failed: %v", err)
+		}
+		if got, want := m.Endpoint().TokenURL, server.URL+"/token"; got != want {
+			t.Errorf("got token URL %v but want %v", got, want)
+		}
+		if !m.SupportsPKCE("S256") || m.SupportsPKCE("plain") {
+			t.Error("wrong PKCE capabilities")
+		}
+		if !m.SupportsRevocation() {
+			t.Error("revocation endpoint not reported")
+		}
+		if !m.SupportsDPoP("ES256") || m.SupportsDPoP("RS256") {
+			t.Error("wrong DPoP capabilities")
+		}
+		if !m.SupportsGrantType("authorization_code") || m.SupportsGrantType("client_credentials") {
+			t.Error("wrong default grant types")
+		}
+	}
+	if requests != 1 {
+		t.Errorf("got %d requests but want 1", requests)
+	}
+}
+
+func TestAuthServerMetadata_IssuerMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"issuer": "https://attacker.example.com"}`))
+	}))
+	defer server.Close()
+
+	if _, err := (&Client{}).AuthServerMetadata(context.Background(), server.URL); err == nil {
+		t.Error("AuthServerMetadata() accepted a mismatched issuer")
+	}
+}
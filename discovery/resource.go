@@ -0,0 +1,204 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// resourceWellKnown is the well-known URI suffix of RFC 9728.
+const resourceWellKnown = "/.well-known/oauth-protected-resource"
+
+// ProtectedResourceMetadata is an OAuth 2.0 protected resource metadata
+// document, as described by RFC 9728 section 2.
+type ProtectedResourceMetadata struct {
+	Resource                          string   `json:"resource"`
+	AuthorizationServers              []string `json:"authorization_servers"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	BearerMethodsSupported            []string `json:"bearer_methods_supported"`
+	ResourceName                      string   `json:"resource_name"`
+	ResourceDocumentation             string   `json:"resource_documentation"`
+	DPoPSigningAlgValuesSupported     []string `json:"dpop_signing_alg_values_supported"`
+	DPoPBoundAccessTokensRequired     bool     `json:"dpop_bound_access_tokens_required"`
+	TLSClientCertificateBoundRequired bool     `json:"tls_client_certificate_bound_access_tokens"`
+}
+
+// ProtectedResourceMetadata returns the metadata of the protected resource
+// identified by resource, fetched from the well-known URI derived from it.
+// The document's resource must match.
+func (c *Client) ProtectedResourceMetadata(ctx context.Context, resource string) (*ProtectedResourceMetadata, error) {
+	u, err := wellKnownURL(resource, resourceWellKnown)
+	if err != nil {
+		return nil, err
+	}
+	m, err := c.protectedResourceMetadata(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	if m.Resource != resource {
+		return nil, fmt.Errorf("oauth2/discovery: metadata resource %q does not match %q", m.Resource, resource)
+	}
+	return m, nil
+}
+
+func (c *Client) protectedResourceMetadata(ctx context.Context, u string) (*ProtectedResourceMetadata, error) {
+	if doc, ok := c.cached(u); ok {
+		return doc.(*ProtectedResourceMetadata), nil
+	}
+	m := &ProtectedResourceMetadata{}
+	if err := c.fetch(ctx, u, m); err != nil {
+		return nil, err
+	}
+	c.store(u, m)
+	return m, nil
+}
+
+// Discover finds the authorization server protecting the resource that sent
+// resp, typically a 401 response. It follows the resource_metadata parameter
+// of the response's WWW-Authenticate challenge (RFC 9728 section 5) and
+// returns the resource metadata together with the metadata of its first
+// authorization server. The resource metadata must describe a resource on
+// the same origin as the request that produced resp.
+func (c *Client) Discover(ctx context.Context, resp *http.Response) (*ProtectedResourceMetadata, *AuthServerMetadata, error) {
+	metadataURL, ok := ResourceMetadataURL(resp.Header)
+	if !ok {
+		return nil, nil, errors.New("oauth2/discovery: response has no resource_metadata challenge")
+	}
+	prm, err := c.protectedResourceMetadata(ctx, metadataURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.Request != nil && !sameOrigin(prm.Resource, resp.Request.URL) {
+		return nil, nil, fmt.Errorf("oauth2/discovery: metadata resource %q does not match the requested URL", prm.Resource)
+	}
+	if len(prm.AuthorizationServers) == 0 {
+		return nil, nil, fmt.Errorf("oauth2/discovery: resource %q lists no authorization servers", prm.Resource)
+	}
+	asm, err := c.AuthServerMetadata(ctx, prm.AuthorizationServers[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	return prm, asm, nil
+}
+
+// ResourceMetadataURL returns the resource_metadata parameter of a Bearer or
+// DPoP challenge in the WWW-Authenticate headers of h.
+func ResourceMetadataURL(h http.Header) (string, bool) {
+	for _, v := range h.Values("WWW-Authenticate") {
+		for _, ch := range parseChallenges(v) {
+			if !strings.EqualFold(ch.scheme, "Bearer") && !strings.EqualFold(ch.scheme, "DPoP") {
+				continue
+			}
+			if u, ok := ch.params["resource_metadata"]; ok && u != "" {
+				return u, true
+			}
+		}
+	}
+	return "", false
+}
+
+func sameOrigin(resource string, u *url.URL) bool {
+	r, err := url.Parse(resource)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(r.Scheme, u.Scheme) && strings.EqualFold(r.Host, u.Host)
+}
+
+// challenge is a single authentication challenge, see RFC 9110 section 11.
+type challenge struct {
+	scheme string
+	params map[string]string
+}
+
+// parseChallenges parses the challenges of a WWW-Authenticate header value.
+// Parameter names are lowercased. token68 values are ignored.
+func parseChallenges(s string) []challenge {
+	var challenges []challenge
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return challenges
+		}
+		var tok string
+		tok, s = readToken(s)
+		if tok == "" {
+			// Unparsable; stop rather than misattribute parameters.
+			return challenges
+		}
+		rest := strings.TrimLeft(s, " \t")
+		if strings.HasPrefix(rest, "=") && len(challenges) > 0 {
+			// A parameter of the current challenge.
+			var val string
+			val, s = readValue(strings.TrimLeft(rest[1:], " \t"))
+			challenges[len(challenges)-1].params[strings.ToLower(tok)] = val
+			continue
+		}
+		challenges = append(challenges, challenge{scheme: tok, params: map[string]string{}})
+		s = skipToken68(rest)
+	}
+}
+
+// skipToken68 skips a token68 credential at the start of s, recognized by
+// being the only element before the next comma.
+func skipToken68(s string) string {
+	i := 0
+	for i < len(s) && (isTokenChar(s[i]) || s[i] == '/') {
+		i++
+	}
+	for i < len(s) && s[i] == '=' {
+		i++
+	}
+	if i == 0 {
+		return s
+	}
+	if after := strings.TrimLeft(s[i:], " \t"); after == "" || after[0] == ',' {
+		return after
+	}
+	return s
+}
+
+func readToken(s string) (tok, rest string) {
+	i := 0
+	for i < len(s) && isTokenChar(s[i]) {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+func readValue(s string) (val, rest string) {
+	if !strings.HasPrefix(s, `"`) {
+		return readToken(s)
+	}
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				b.WriteByte(s[i])
+			}
+		case '"':
+			return b.String(), s[i+1:]
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), ""
+}
+
+func isTokenChar(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
@@ -0,0 +1,97 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResourceMetadataURL(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{`Bearer resource_metadata="https://rs.example.com/.well-known/oauth-protected-resource"`, "https://rs.example.com/.well-known/oauth-protected-resource"},
+		{`Bearer realm="example", error="invalid_token", Resource_Metadata="https://rs.example.com/m"`, "https://rs.example.com/m"},
+		{`Basic dXNlcjpwYXNz==, DPoP algs="ES256", resource_metadata="https://rs.example.com/m"`, "https://rs.example.com/m"},
+		{`Basic realm="x", resource_metadata="https://ignored.example.com"`, ""},
+		{`Bearer realm="a \"quoted\" realm", error=invalid_token`, ""},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		h.Set("WWW-Authenticate", tt.header)
+		got, ok := ResourceMetadataURL(h)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("ResourceMetadataURL(%q) = %q, %v; want %q", tt.header, got, ok, tt.want)
+		}
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api":
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer resource_metadata="%s/.well-known/oauth-protected-resource/api"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+		case "/.well-known/oauth-protected-resource/api":
+			fmt.Fprintf(w, `{"resource": "%[1]s/api", "authorization_servers": ["%[1]s/as"], "scopes_supported": ["read"]}`, server.URL)
+		case "/.well-known/oauth-authorization-server/as":
+			fmt.Fprintf(w, `{"issuer": "%[1]s/as", "token_endpoint": "%[1]s/token"}`, server.URL)
+		default:
+			t.Errorf("unexpected request to %s", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	c := &Client{}
+	prm, asm, err := c.Discover(context.Background(), resp)
+	if err != nil {
+		t.Fatalf("Discover() failed: %v", err)
+	}
+	if got, want := prm.ScopesSupported, "read"; len(got) != 1 || got[0] != want {
+		t.Errorf("got scopes %v but want [%v]", got, want)
+	}
+	if got, want := asm.TokenEndpoint, server.URL+"/token"; got != want {
+		t.Errorf("got token endpoint %v but want %v", got, want)
+	}
+
+	if _, err := c.ProtectedResourceMetadata(context.Background(), server.URL+"/api"); err != nil {
+		t.Errorf("ProtectedResourceMetadata() failed: %v", err)
+	}
+}
+
+func TestDiscover_ForeignResource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api" {
+			w.Header().Set("WWW-Authenticate", `Bearer resource_metadata="http://`+r.Host+`/metadata"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"resource": "https://other.example.com/api", "authorization_servers": ["https://as.example.com"]}`))
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if _, _, err := (&Client{}).Discover(context.Background(), resp); err == nil {
+		t.Error("Discover() accepted metadata for another origin")
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth2
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/oauth2/internal"
+)
+
+// maxErrorResponseBody is the number of body bytes kept in a
+// TokenErrorResponse.
+const maxErrorResponseBody = 4 << 10
+
+// sensitiveResponseFields are the token response fields removed from the body
+// of a TokenErrorResponse. Some servers issue tokens along with an error.
+var sensitiveResponseFields = []string{"access_token", "refresh_token", "id_token"}
+
+// TokenErrorResponse is a token endpoint error response that is safe to log.
+// It lets operators record provider-specific diagnostics, such as Microsoft
+// Entra ID error codes or trace IDs, that RetrieveError does not parse.
+type TokenErrorResponse struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Header holds the response headers, without cookies and
+	// authentication challenges.
+	Header http.Header
+	// Body is the response body, truncated to 4 KiB, with any token fields
+	// redacted.
+	Body []byte
+}
+
+// TokenResponseMetadata describes a successful token endpoint response, so
+// that operators can correlate requests with the authorization server's logs,
+// e.g. by X-Request-Id, and monitor rate limit headers.
+type TokenResponseMetadata struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Header holds the response headers, without cookies and
+	// authentication challenges.
+	Header http.Header
+}
+
+// SanitizedResponse returns the response of r with credentials removed, for
+// logging.
+func (r *RetrieveError) SanitizedResponse() *TokenErrorResponse {
+	resp := &TokenErrorResponse{}
+	if r.Response != nil {
+		resp.StatusCode = r.Response.StatusCode
+		resp.Header = internal.SanitizeHeader(r.Response.Header)
+	} else {
+		resp.Header = make(http.Header)
+	}
+	body := redactBody(r.Body)
+	if len(body) > maxErrorResponseBody {
+		body = body[:maxErrorResponseBody]
+	}
+	resp.Body = body
+	return resp
+}
+
+// redactBody replaces the token fields of a JSON or form encoded response
+// body. Bodies in other formats are returned unchanged.
+func redactBody(body []byte) []byte {
+	var m map[string]interface{}
+	if err := json.Unmarshal(body, &m); err == nil {
+		redacted := false
+		for _, k := range sensitiveResponseFields {
+			if _, ok := m[k]; ok {
+				m[k] = "REDACTED"
+				redacted = true
+			}
+		}
+		if !redacted {
+			return body
+		}
+		b, err := json.Marshal(m)
+		if err != nil {
+			return nil
+		}
+		return b
+	}
+	if vals, err := url.ParseQuery(string(body)); err == nil {
+		redacted := false
+		for _, k := range sensitiveResponseFields {
+			if _, ok := vals[k]; ok {
+				vals.Set(k, "REDACTED")
+				redacted = true
+			}
+		}
+		if redacted {
+			return []byte(vals.Encode())
+		}
+	}
+	return body
+}
@@ -0,0 +1,77 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth2
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestErrorHook(t *testing.T) {
+	ts := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Header().Set("X-Ms-Request-Id", "trace-1")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant","error_codes":[70008],"refresh_token":"leaked"}`))
+	})
+	defer ts.Close()
+
+	var got *TokenErrorResponse
+	conf := newConf(ts.URL)
+	conf.ErrorHook = func(resp *TokenErrorResponse) { got = resp }
+	if _, err := conf.Exchange(context.Background(), "code"); err == nil {
+		t.Fatal("Exchange() succeeded, want error")
+	}
+	if got == nil {
+		t.Fatal("ErrorHook was not called")
+	}
+	if got.StatusCode != http.StatusBadRequest {
+		t.Errorf("StatusCode = %d; want %d", got.StatusCode, http.StatusBadRequest)
+	}
+	if got.Header.Get("X-Ms-Request-Id") != "trace-1" || got.Header.Get("Set-Cookie") != "" {
+		t.Errorf("Header = %v; want trace ID without cookies", got.Header)
+	}
+	body := string(got.Body)
+	if !strings.Contains(body, "70008") || strings.Contains(body, "leaked") {
+		t.Errorf("Body = %s; want error codes without token", body)
+	}
+}
+
+func TestSanitizedResponse_Truncated(t *testing.T) {
+	r := &RetrieveError{Body: []byte(strings.Repeat("x", maxErrorResponseBody+1))}
+	if got := len(r.SanitizedResponse().Body); got != maxErrorResponseBody {
+		t.Errorf("body length = %d; want %d", got, maxErrorResponseBody)
+	}
+}
+
+func TestResponseHook(t *testing.T) {
+	ts := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-Id", "req-1")
+		w.Header().Set("X-Ratelimit-Remaining", "41")
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Write([]byte(`{"access_token":"token","token_type":"bearer"}`))
+	})
+	defer ts.Close()
+
+	var got *TokenResponseMetadata
+	conf := newConf(ts.URL)
+	conf.ResponseHook = func(m *TokenResponseMetadata) { got = m }
+	if _, err := conf.Exchange(context.Background(), "code"); err != nil {
+		t.Fatalf("Exchange() failed: %v", err)
+	}
+	if got == nil {
+		t.Fatal("ResponseHook was not called")
+	}
+	if got.StatusCode != http.StatusOK || got.Header.Get("X-Request-Id") != "req-1" || got.Header.Get("X-Ratelimit-Remaining") != "41" {
+		t.Errorf("metadata = %+v; want status 200 with request ID and rate limit headers", got)
+	}
+	if got.Header.Get("Set-Cookie") != "" {
+		t.Errorf("metadata header contains cookies: %v", got.Header)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package google
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const defaultAWSSTSURL = "https://sts.amazonaws.com/"
+
+// AWSCredentialProcessConfig describes the AWS role assumed with a Google
+// token by AWSCredentialProcess.
+type AWSCredentialProcessConfig struct {
+	// RoleARN is the ARN of the AWS role to assume. Its trust policy must
+	// allow accounts.google.com as a web identity provider. Required.
+	RoleARN string
+	// RoleSessionName identifies the session in AWS CloudTrail logs.
+	// The default is "google-federated". Optional.
+	RoleSessionName string
+	// Duration is how long the AWS credentials are valid for. The default is
+	// set by the role, usually one hour. Optional.
+	Duration time.Duration
+	// Endpoint is the AWS Security Token Service endpoint. The default is
+	// https://sts.amazonaws.com/. Optional.
+	Endpoint string
+}
+
+// awsProcessCredentials is the output format of an AWS credential_process.
+type awsProcessCredentials struct {
+	Version         int    `json:"Version"`
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	SessionToken    string `json:"SessionToken"`
+	Expiration      string `json:"Expiration,omitempty"`
+}
+
+type assumeRoleWithWebIdentityResponse struct {
+	Credentials struct {
+		AccessKeyID     string `xml:"AccessKeyId"`
+		SecretAccessKey string `xml:"SecretAccessKey"`
+		SessionToken    string `xml:"SessionToken"`
+		Expiration      string `xml:"Expiration"`
+	} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+}
+
+// AWSCredentialProcess exchanges a Google-signed ID token from src for
+// temporary AWS credentials through AssumeRoleWithWebIdentity and returns
+// them in the JSON format AWS SDKs expect from a credential_process. This is
+// the reverse of the AWS credential source of external account credentials,
+// and lets a single credential broker built on this package serve both
+// clouds.
+//
+// src must return ID tokens, either in the AccessToken field, as
+// ImpersonateTokenSource does when IDTokenAudience is set, or in the
+// "id_token" extra field of the token.
+func AWSCredentialProcess(ctx context.Context, src oauth2.TokenSource, config AWSCredentialProcessConfig) ([]byte, error) {
+	if config.RoleARN == "" {
+		return nil, errors.New("oauth2/google: missing AWS role ARN")
+	}
+	tok, err := src.Token()
+	if err != nil {
+		return nil, err
+	}
+	idToken, _ := tok.Extra("id_token").(string)
+	if idToken == "" {
+		idToken = tok.AccessToken
+	}
+
+	sessionName := config.RoleSessionName
+	if sessionName == "" {
+		sessionName = "google-federated"
+	}
+	v := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {config.RoleARN},
+		"RoleSessionName":  {sessionName},
+		"WebIdentityToken": {idToken},
+	}
+	if config.Duration > 0 {
+		v.Set("DurationSeconds", fmt.Sprint(int(config.Duration/time.Second)))
+	}
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = defaultAWSSTSURL
+	}
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(v.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := oauth2.NewClient(ctx, nil).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oauth2/google: AWS role assumption request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("oauth2/google: unable to read AWS role assumption response: %v", err)
+	}
+	if c := resp.StatusCode; c < 200 || c > 299 {
+		return nil, fmt.Errorf("oauth2/google: AWS role assumption failed with status code %d: %s", c, body)
+	}
+	var result assumeRoleWithWebIdentityResponse
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("oauth2/google: unable to parse AWS role assumption response: %v", err)
+	}
+	creds := result.Credentials
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil, errors.New("oauth2/google: AWS role assumption response is missing credentials")
+	}
+	return json.Marshal(awsProcessCredentials{
+		Version:         1,
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+		Expiration:      creds.Expiration,
+	})
+}
@@ -0,0 +1,81 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package google
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestAWSCredentialProcess(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]string{
+			"Action":           "AssumeRoleWithWebIdentity",
+			"RoleArn":          "arn:aws:iam::123456789012:role/broker",
+			"RoleSessionName":  "google-federated",
+			"WebIdentityToken": "id-token",
+			"DurationSeconds":  "900",
+		}
+		for k, v := range want {
+			if got := r.Form.Get(k); got != v {
+				t.Errorf("got %s %v but want %v", k, got, v)
+			}
+		}
+		fmt.Fprint(w, `<AssumeRoleWithWebIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleWithWebIdentityResult>
+    <Credentials>
+      <AccessKeyId>AKID</AccessKeyId>
+      <SecretAccessKey>secret</SecretAccessKey>
+      <SessionToken>session</SessionToken>
+      <Expiration>2023-01-01T00:15:00Z</Expiration>
+    </Credentials>
+  </AssumeRoleWithWebIdentityResult>
+</AssumeRoleWithWebIdentityResponse>`)
+	}))
+	defer server.Close()
+
+	src := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "id-token"})
+	out, err := AWSCredentialProcess(context.Background(), src, AWSCredentialProcessConfig{
+		RoleARN:  "arn:aws:iam::123456789012:role/broker",
+		Duration: 15 * time.Minute,
+		Endpoint: server.URL,
+	})
+	if err != nil {
+		t.Fatalf("AWSCredentialProcess() failed: %v", err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("unable to parse output %s: %v", out, err)
+	}
+	want := map[string]interface{}{
+		"Version":         1.0,
+		"AccessKeyId":     "AKID",
+		"SecretAccessKey": "secret",
+		"SessionToken":    "session",
+		"Expiration":      "2023-01-01T00:15:00Z",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("got %s %v but want %v", k, got[k], v)
+		}
+	}
+}
+
+func TestAWSCredentialProcess_MissingRole(t *testing.T) {
+	src := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "id-token"})
+	if _, err := AWSCredentialProcess(context.Background(), src, AWSCredentialProcessConfig{}); err == nil {
+		t.Error("AWSCredentialProcess() succeeded, want error")
+	}
+}
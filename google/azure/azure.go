@@ -0,0 +1,101 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package azure
+
+import (
+	"context"
+	"os"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google/internal/externalaccount"
+)
+
+const (
+	// JWTTokenType is the subject token type of the tokens returned by
+	// SubjectToken.
+	JWTTokenType = externalaccount.JWTTokenType
+
+	// federatedTokenFileEnvVar is set by the AKS workload identity webhook to
+	// the path of the projected service account token.
+	federatedTokenFileEnvVar = "AZURE_FEDERATED_TOKEN_FILE"
+)
+
+// getenv aliases os.Getenv for testing.
+var getenv = os.Getenv
+
+// Config describes how an Azure workload obtains its subject token and the
+// workload identity pool provider it is exchanged with.
+//
+// When FederatedTokenFile is set, or the AZURE_FEDERATED_TOKEN_FILE
+// environment variable is set as it is for AKS pods using workload identity,
+// the token is read from that file. Otherwise a managed identity token for
+// Resource is requested from the Azure Instance Metadata Service.
+type Config struct {
+	// Audience is the workload identity pool provider resource name, e.g.
+	// //iam.googleapis.com/projects/PROJECT_NUMBER/locations/global/workloadIdentityPools/POOL_ID/providers/PROVIDER_ID.
+	// Required for TokenSource.
+	Audience string
+
+	// FederatedTokenFile is the path of the projected service account token.
+	// The default is the value of AZURE_FEDERATED_TOKEN_FILE. Optional.
+	FederatedTokenFile string
+
+	// Resource is the application ID URI requested from the Instance
+	// Metadata Service. It must match the allowed audience of the workload
+	// identity pool provider. Required when no federated token file is
+	// available.
+	Resource string
+
+	// ClientID selects a user-assigned managed identity. The system-assigned
+	// identity is used when it is empty. Optional.
+	ClientID string
+
+	// IMDSURL is the Instance Metadata Service token endpoint. The default is
+	// http://169.254.169.254/metadata/identity/oauth2/token. Optional.
+	IMDSURL string
+
+	// STSURL is the Security Token Service endpoint. The default is
+	// https://sts.googleapis.com/v1/token.
+	STSURL string
+
+	// ServiceAccountImpersonationURL is the URL of the generateAccessToken
+	// method of the service account to impersonate after the exchange.
+	// Optional.
+	ServiceAccountImpersonationURL string
+
+	// Scopes are the scopes of the returned Google Cloud access token. The
+	// default is https://www.googleapis.com/auth/cloud-platform.
+	Scopes []string
+}
+
+// SubjectToken returns a token for the workload's Azure identity, a subject
+// token of type JWTTokenType.
+func (c *Config) SubjectToken(ctx context.Context) (string, error) {
+	file := c.FederatedTokenFile
+	if file == "" {
+		file = getenv(federatedTokenFileEnvVar)
+	}
+	return externalaccount.AzureIdentity{
+		FederatedTokenFile: file,
+		Resource:           c.Resource,
+		ClientID:           c.ClientID,
+		IMDSURL:            c.IMDSURL,
+	}.SubjectToken(ctx)
+}
+
+// TokenSource returns a TokenSource of Google Cloud access tokens obtained by
+// exchanging the workload's Azure token with the Security Token Service.
+func (c *Config) TokenSource(ctx context.Context) (oauth2.TokenSource, error) {
+	cfg := &externalaccount.PlatformConfig{
+		Audience:                       c.Audience,
+		SubjectTokenType:               JWTTokenType,
+		STSURL:                         c.STSURL,
+		ServiceAccountImpersonationURL: c.ServiceAccountImpersonationURL,
+		Scopes:                         c.Scopes,
+		SubjectToken:                   c.SubjectToken,
+		MetricsSource:                  "azure",
+	}
+	return cfg.TokenSource(ctx)
+}
@@ -0,0 +1,121 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package azure
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestSubjectToken_FederatedTokenFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "token")
+	if err := ioutil.WriteFile(file, []byte("projected-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	defer func(old func(string) string) { getenv = old }(getenv)
+	getenv = func(key string) string {
+		if key == federatedTokenFileEnvVar {
+			return file
+		}
+		return ""
+	}
+
+	c := &Config{Resource: "api://AzureADTokenExchange", IMDSURL: "http://unused.invalid"}
+	tok, err := c.SubjectToken(context.Background())
+	if err != nil {
+		t.Fatalf("SubjectToken() failed: %v", err)
+	}
+	if got, want := tok, "projected-token"; got != want {
+		t.Errorf("got %v but want %v", got, want)
+	}
+}
+
+func TestSubjectToken_ManagedIdentity(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("Metadata"), "true"; got != want {
+			t.Errorf("got Metadata header %v but want %v", got, want)
+		}
+		q := r.URL.Query()
+		if got, want := q.Get("resource"), "api://AzureADTokenExchange"; got != want {
+			t.Errorf("got resource %v but want %v", got, want)
+		}
+		if got, want := q.Get("client_id"), "client"; got != want {
+			t.Errorf("got client_id %v but want %v", got, want)
+		}
+		if got, want := q.Get("api-version"), "2018-02-01"; got != want {
+			t.Errorf("got api-version %v but want %v", got, want)
+		}
+		fmt.Fprint(w, `{"access_token":"mi-token","token_type":"Bearer"}`)
+	}))
+	defer server.Close()
+	defer func(old func(string) string) { getenv = old }(getenv)
+	getenv = func(string) string { return "" }
+
+	c := &Config{Resource: "api://AzureADTokenExchange", ClientID: "client", IMDSURL: server.URL}
+	tok, err := c.SubjectToken(context.Background())
+	if err != nil {
+		t.Fatalf("SubjectToken() failed: %v", err)
+	}
+	if got, want := tok, "mi-token"; got != want {
+		t.Errorf("got %v but want %v", got, want)
+	}
+}
+
+func TestSubjectToken_NoSource(t *testing.T) {
+	defer func(old func(string) string) { getenv = old }(getenv)
+	getenv = func(string) string { return "" }
+
+	c := &Config{}
+	if _, err := c.SubjectToken(context.Background()); err == nil {
+		t.Error("SubjectToken() succeeded, want error")
+	}
+	c.FederatedTokenFile = filepath.Join(t.TempDir(), "missing")
+	if _, err := c.SubjectToken(context.Background()); err == nil {
+		t.Error("SubjectToken() with missing file succeeded, want error")
+	}
+}
+
+func TestTokenSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := r.Form.Get("subject_token"), "projected-token"; got != want {
+			t.Errorf("got subject_token %v but want %v", got, want)
+		}
+		if got, want := r.Form.Get("subject_token_type"), JWTTokenType; got != want {
+			t.Errorf("got subject_token_type %v but want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"google-token","issued_token_type":"urn:ietf:params:oauth:token-type:access_token","token_type":"Bearer","expires_in":3600}`)
+	}))
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "token")
+	if err := ioutil.WriteFile(file, []byte("projected-token"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c := &Config{
+		Audience:           "//iam.googleapis.com/projects/123/locations/global/workloadIdentityPools/pool/providers/azure",
+		FederatedTokenFile: file,
+		STSURL:             server.URL,
+	}
+	ts, err := c.TokenSource(context.Background())
+	if err != nil {
+		t.Fatalf("TokenSource() failed: %v", err)
+	}
+	tok, err := ts.Token()
+	if err != nil {
+		t.Fatalf("Token() failed: %v", err)
+	}
+	if got, want := tok.AccessToken, "google-token"; got != want {
+		t.Errorf("got %v but want %v", got, want)
+	}
+}
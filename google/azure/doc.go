@@ -0,0 +1,15 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package azure provides helpers for authenticating Azure workloads to
+// Google Cloud with workload identity federation.
+//
+// The helpers in this package obtain a token issued by Microsoft Entra ID
+// for the workload's identity, either the projected service account token of
+// AKS workload identity or a managed identity token from the Azure Instance
+// Metadata Service. The token is used as the subject token of a Security
+// Token Service exchange for a Google Cloud access token.
+// For more information on workload identity federation with Azure, refer to
+// https://cloud.google.com/iam/docs/workload-identity-federation-with-other-clouds.
+package azure // import "golang.org/x/oauth2/google/azure"
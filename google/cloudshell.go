@@ -0,0 +1,138 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package google
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/internal"
+)
+
+// cloudShellPortEnvVar names the local port on which Cloud Shell serves the
+// credentials of the signed-in user.
+const cloudShellPortEnvVar = "DEVSHELL_CLIENT_PORT"
+
+// cloudShellTimeout bounds a credential request to Cloud Shell.
+var cloudShellTimeout = 10 * time.Second
+
+// cloudShellCredentials is the response of Cloud Shell to a credential
+// request.
+type cloudShellCredentials struct {
+	email       string
+	projectID   string
+	accessToken string
+	expiresIn   int // seconds; zero if unknown
+}
+
+// fetchCloudShellCredentials requests credentials from Cloud Shell on port.
+// Requests and responses are JSON arrays preceded by their length and a
+// newline. The request is empty; the response holds the user's email, the
+// project ID, an access token and the number of seconds it expires in.
+func fetchCloudShellCredentials(ctx context.Context, port string) (*cloudShellCredentials, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort("localhost", port))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(cloudShellTimeout)
+	if dl, ok := ctx.Deadline(); ok && dl.Before(deadline) {
+		deadline = dl
+	}
+	conn.SetDeadline(deadline)
+
+	const request = "[]"
+	if _, err := fmt.Fprintf(conn, "%d\n%s", len(request), request); err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(conn)
+	header, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(header))
+	if err != nil || n < 0 || n > 1<<20 {
+		return nil, fmt.Errorf("invalid response length %q", strings.TrimSpace(header))
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	var fields []json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, fmt.Errorf("invalid response: %v", err)
+	}
+	var c cloudShellCredentials
+	for i, v := range []interface{}{&c.email, &c.projectID, &c.accessToken, &c.expiresIn} {
+		if i >= len(fields) || string(fields[i]) == "null" {
+			continue
+		}
+		if err := json.Unmarshal(fields[i], v); err != nil {
+			return nil, fmt.Errorf("invalid response: %v", err)
+		}
+	}
+	if c.accessToken == "" {
+		return nil, errors.New("no access token in response; sign in to Cloud Shell")
+	}
+	return &c, nil
+}
+
+// cloudShellTokenSource returns the access tokens of the user signed in to
+// Cloud Shell. They have the cloud-platform scope, whatever the requested
+// scopes.
+type cloudShellTokenSource struct {
+	ctx  context.Context
+	port string
+}
+
+// DescribeTokenSource describes ts, see oauth2.DescribeTokenSource.
+func (ts cloudShellTokenSource) DescribeTokenSource() (string, oauth2.TokenSource) {
+	return internal.DescribeParams("cloud_shell", "port", ts.port), nil
+}
+
+func (ts cloudShellTokenSource) Token() (*oauth2.Token, error) {
+	c, err := fetchCloudShellCredentials(ts.ctx, ts.port)
+	if err != nil {
+		return nil, fmt.Errorf("oauth2/google: cannot get Cloud Shell credentials: %v", err)
+	}
+	return c.token(), nil
+}
+
+func (c *cloudShellCredentials) token() *oauth2.Token {
+	tok := &oauth2.Token{AccessToken: c.accessToken, TokenType: "Bearer"}
+	if c.expiresIn > 0 {
+		tok.Expiry = time.Now().Add(time.Duration(c.expiresIn) * time.Second)
+	}
+	return tok
+}
+
+// cloudShellCredentialsFromEnv returns the credentials of the user signed in
+// to Cloud Shell, or nil if not running in Cloud Shell.
+func cloudShellCredentialsFromEnv(ctx context.Context, params CredentialsParams) (*Credentials, error) {
+	port := os.Getenv(cloudShellPortEnvVar)
+	if port == "" {
+		return nil, nil
+	}
+	c, err := fetchCloudShellCredentials(ctx, port)
+	if err != nil {
+		return nil, fmt.Errorf("google: error getting Cloud Shell credentials using %v environment variable: %v", cloudShellPortEnvVar, err)
+	}
+	return withMetrics(&Credentials{
+		ProjectID:     c.projectID,
+		TokenSource:   oauth2.ReuseTokenSource(c.token(), cloudShellTokenSource{ctx: ctx, port: port}),
+		RequestHeader: params.RequestHeader,
+	}, cloudShellType, params.Metrics), nil
+}
@@ -0,0 +1,98 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package google
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveCloudShell answers credential requests with response, returning the
+// port it listens on.
+func serveCloudShell(t *testing.T, response string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				header, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				n, _ := strconv.Atoi(strings.TrimSpace(header))
+				request := make([]byte, n)
+				if _, err := io.ReadFull(r, request); err != nil || string(request) != "[]" {
+					t.Errorf("got request %q but want %q", request, "[]")
+					return
+				}
+				fmt.Fprintf(conn, "%d\n%s", len(response), response)
+			}()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	return port
+}
+
+func TestFindDefaultCredentials_CloudShell(t *testing.T) {
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("APPDATA", t.TempDir())
+	t.Setenv("GOOGLE_WORKLOAD_IDENTITY_POOL", "")
+	t.Setenv(cloudShellPortEnvVar, serveCloudShell(t, `["user@example.com","my-project","ya29.token",3600]`))
+
+	creds, err := FindDefaultCredentials(context.Background(), ScopeCloudPlatform)
+	if err != nil {
+		t.Fatalf("FindDefaultCredentials() failed: %v", err)
+	}
+	if got, want := creds.ProjectID, "my-project"; got != want {
+		t.Errorf("got project ID %v but want %v", got, want)
+	}
+	tok, err := creds.TokenSource.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tok.AccessToken, "ya29.token"; got != want {
+		t.Errorf("got access token %v but want %v", got, want)
+	}
+	if d := time.Until(tok.Expiry); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("got token expiring in %v but want an hour", d)
+	}
+}
+
+func TestFetchCloudShellCredentials_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+	}{
+		{"signed out", `["user@example.com","my-project"]`},
+		{"malformed", `{"access_token":"ya29.token"}`},
+		{"wrong type", `["user@example.com","my-project",42]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := serveCloudShell(t, tt.response)
+			if _, err := fetchCloudShellCredentials(context.Background(), port); err == nil {
+				t.Error("fetchCloudShellCredentials() succeeded, want error")
+			}
+		})
+	}
+}
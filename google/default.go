@@ -5,18 +5,23 @@
 package google
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"time"
 
 	"cloud.google.com/go/compute/metadata"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/authhandler"
+	"golang.org/x/oauth2/google/internal/externalaccount"
 )
 
 const adcSetupURL = "https://cloud.google.com/docs/authentication/external/set-up-adc"
@@ -37,6 +42,37 @@ type Credentials struct {
 	// environment and not with a credentials file, e.g. when code is
 	// running on Google Cloud Platform.
 	JSON []byte
+
+	// RequestHeader optionally returns headers that clients returned by
+	// Client set on each request, e.g. x-goog-request-params or audit
+	// tags. It is called with the credentials, the token authorizing the
+	// request and the request. It is set from
+	// CredentialsParams.RequestHeader. Optional.
+	RequestHeader func(c *Credentials, t *oauth2.Token, req *http.Request) http.Header
+
+	// APIKey optionally is an API key that clients returned by Client send
+	// with the access token, for APIs that require both on some endpoints.
+	// It is sent in the X-Goog-Api-Key header, or in the query parameter
+	// named by APIKeyParam if set. Optional.
+	APIKey      string
+	APIKeyParam string
+}
+
+// Client returns an HTTP client authorizing requests with c.TokenSource and
+// setting the headers returned by c.RequestHeader and the API key of
+// c.APIKey. The client's transport wraps the one of the HTTP client of ctx,
+// as oauth2.NewClient does.
+func (c *Credentials) Client(ctx context.Context) *http.Client {
+	hc := oauth2.NewClient(ctx, c.TokenSource)
+	if t, ok := hc.Transport.(*oauth2.Transport); ok {
+		if c.RequestHeader != nil {
+			t.RequestHeader = func(tok *oauth2.Token, req *http.Request) http.Header {
+				return c.RequestHeader(c, tok, req)
+			}
+		}
+		t.APIKey, t.APIKeyParam = c.APIKey, c.APIKeyParam
+	}
+	return hc
 }
 
 // DefaultCredentials is the old name of Credentials.
@@ -47,10 +83,18 @@ type DefaultCredentials = Credentials
 // CredentialsParams holds user supplied parameters that are used together
 // with a credentials file for building a Credentials object.
 type CredentialsParams struct {
-	// Scopes is the list OAuth scopes. Required.
+	// Scopes is the list OAuth scopes. Required. They can be checked for
+	// common mistakes with ValidateScopes.
 	// Example: https://www.googleapis.com/auth/cloud-platform
 	Scopes []string
 
+	// ImpersonationScope adds ScopeCloudPlatform to the scopes requested
+	// for the source credentials of impersonated_service_account
+	// credentials, which need it to call the IAM Credentials API even when
+	// Scopes, the scopes of the impersonated tokens, do not include it.
+	// Optional.
+	ImpersonationScope bool
+
 	// Subject is the user email used for domain wide delegation (see
 	// https://developers.google.com/identity/protocols/oauth2/service-account#delegatingauthority).
 	// Optional.
@@ -76,12 +120,172 @@ type CredentialsParams struct {
 	// Note: This option is currently only respected when using credentials
 	// fetched from the GCE metadata server.
 	EarlyTokenRefresh time.Duration
+
+	// TokenRefreshJitter is the upper bound of a random duration added to
+	// the early refresh of every token, so that processes started together
+	// do not refresh in lockstep. Optional.
+	//
+	// Note: This option is currently only respected when using credentials
+	// fetched from the GCE metadata server and external account credentials.
+	TokenRefreshJitter time.Duration
+
+	// BackgroundRefreshWindow, when positive, makes credentials renew
+	// their tokens this long before they expire, in a goroutine that stops
+	// when the context the credentials were created with is done. Optional.
+	//
+	// Note: This option is currently only respected by external account
+	// credentials.
+	BackgroundRefreshWindow time.Duration
+
+	// ImpersonationMinLifetime, when positive, makes credentials impersonating
+	// a service account request tokens that expire at the deadline of the
+	// context the credentials were created with, if it is sooner than the
+	// configured lifetime, but that live at least ImpersonationMinLifetime.
+	// Optional.
+	//
+	// Note: This option is currently only respected by external account
+	// credentials.
+	ImpersonationMinLifetime time.Duration
+
+	// SubjectTokenTLSConfig is the TLS configuration of the subject token
+	// requests of URL-sourced credentials, e.g. to present a client
+	// certificate to an endpoint requiring mutual TLS. Optional.
+	//
+	// Note: This option is currently only respected by external account
+	// credentials.
+	SubjectTokenTLSConfig *tls.Config
+
+	// ScopeDowngrade, if non-nil, is called with the requested and granted
+	// scopes when the Security Token Service grants fewer scopes than
+	// requested. The narrower token is used regardless. Optional.
+	//
+	// Note: This option is currently only respected by external account
+	// credentials.
+	ScopeDowngrade func(requested, granted []string)
+
+	// Metrics, if non-nil, receives the expiry of the tokens of the
+	// credentials and the outcome of their refreshes. Optional.
+	Metrics CredentialsMetrics
+
+	// MetricsProducts are product identifiers of the form "name/version",
+	// e.g. "terraform-provider-google/4.80.0", that SDKs embedding this
+	// package append to the x-goog-api-client metrics header. Malformed
+	// identifiers are ignored.
+	//
+	// Note: This option is currently only respected by external account
+	// credentials.
+	MetricsProducts []string
+
+	// SubjectTokenReuseMargin lets external account credentials reuse a
+	// subject token across token exchanges for as long as it expires at
+	// least this far in the future, instead of reading it again from the
+	// file, URL or executable on every refresh. The expiry is taken from the
+	// executable's expiration_time or from the exp claim of JWT subject
+	// tokens. Zero, the default, disables reuse. Optional.
+	//
+	// Note: This option is currently only respected by external account
+	// credentials.
+	SubjectTokenReuseMargin time.Duration
+
+	// WatchSubjectTokenFile makes external account credentials with a
+	// SubjectTokenReuseMargin stop reusing a subject token read from a file
+	// as soon as the file is modified, e.g. by the refresher rotating the
+	// token. Optional.
+	//
+	// Note: This option is currently only respected by external account
+	// credentials.
+	WatchSubjectTokenFile bool
+
+	// IDTokenAudience, when set, makes the returned credentials carry
+	// Google-signed ID tokens for this audience, e.g. the URL of a Cloud Run
+	// service, instead of access tokens. The ID tokens are minted for the
+	// service account impersonated by the configuration, which must set
+	// service_account_impersonation_url. Scopes are ignored. Optional.
+	//
+	// Note: This option is currently only respected by external account
+	// credentials.
+	IDTokenAudience string
+
+	// AudienceVariables are the values of ${NAME} placeholders in the
+	// audience of an external account configuration, e.g. PROJECT_NUMBER,
+	// POOL_ID or PROVIDER_ID, so that one configuration file can be deployed
+	// across environments. Placeholders missing from it are resolved from
+	// environment variables of the same name. Optional.
+	//
+	// Note: This option is currently only respected by external account
+	// credentials.
+	AudienceVariables map[string]string
+
+	// Interactive lets external account credentials with an executable
+	// credential source prompt the user to sign in: when the executable's
+	// output_file holds no valid response, the executable is run with
+	// GOOGLE_EXTERNAL_ACCOUNT_INTERACTIVE=1, connected to the terminal, and
+	// writes its response to the output_file. Optional.
+	//
+	// Note: This option is currently only respected by external account
+	// credentials.
+	Interactive bool
+
+	// CredentialName selects a credential configuration from a JSON array
+	// of configurations by the value of its "name" field. When empty, the
+	// first configuration of the array that can be loaded is used. Optional.
+	CredentialName string
+
+	// ServiceAccountFlow selects how tokens are obtained for service account
+	// keys. Optional.
+	ServiceAccountFlow ServiceAccountFlow
+
+	// HTTPClient is the client used to obtain tokens, e.g. to go through a
+	// proxy or trust a TLS intercepting certificate. Optional.
+	//
+	// Note: This option is currently only respected by external account
+	// credentials.
+	HTTPClient *http.Client
+
+	// RequestHeader is copied to the RequestHeader field of the returned
+	// Credentials, so that clients created with Credentials.Client set the
+	// headers it returns on each request, whatever the credential type.
+	// Optional.
+	RequestHeader func(c *Credentials, t *oauth2.Token, req *http.Request) http.Header
 }
 
+// RegisterMetricsProduct registers a product identifier of the form
+// "name/version", e.g. "terraform-provider-google/4.80.0", that is appended
+// to the x-goog-api-client metrics header of all credentials created by this
+// package. SDKs embedding this package typically call it from an init
+// function. Unlike CredentialsParams.MetricsProducts, malformed identifiers
+// are reported as errors.
+//
+// Note: The metrics header is currently only sent by external account
+// credentials.
+func RegisterMetricsProduct(product string) error {
+	return externalaccount.RegisterMetricsProduct(product)
+}
+
+// ServiceAccountFlow is a way of obtaining tokens for a service account key.
+type ServiceAccountFlow int
+
+const (
+	// ServiceAccountFlowDefault lets the package choose the flow. It
+	// currently uses the OAuth 2.0 token endpoint.
+	ServiceAccountFlowDefault ServiceAccountFlow = iota
+
+	// ServiceAccountFlowOAuth always exchanges a signed assertion for an
+	// access token at the OAuth 2.0 token endpoint, so that every token is
+	// minted, and logged, by Google.
+	ServiceAccountFlowOAuth
+
+	// ServiceAccountFlowSelfSignedJWT sends self-signed JWTs carrying the
+	// requested scopes as access tokens, without contacting the token
+	// endpoint. It cannot be combined with Subject.
+	ServiceAccountFlowSelfSignedJWT
+)
+
 func (params CredentialsParams) deepCopy() CredentialsParams {
 	paramsCopy := params
 	paramsCopy.Scopes = make([]string, len(params.Scopes))
 	copy(paramsCopy.Scopes, params.Scopes)
+	paramsCopy.MetricsProducts = append([]string(nil), params.MetricsProducts...)
 	return paramsCopy
 }
 
@@ -120,9 +324,17 @@ func DefaultTokenSource(ctx context.Context, scope ...string) (oauth2.TokenSourc
 //  2. A JSON file in a location known to the gcloud command-line tool.
 //     On Windows, this is %APPDATA%/gcloud/application_default_credentials.json.
 //     On other systems, $HOME/.config/gcloud/application_default_credentials.json.
-//  3. On Google App Engine standard first generation runtimes (<= Go 1.9) it uses
+//  3. On GKE clusters without a metadata server, workload identity federation
+//     with the projected Kubernetes service account token, if the
+//     GOOGLE_WORKLOAD_IDENTITY_POOL and GOOGLE_WORKLOAD_IDENTITY_PROVIDER
+//     environment variables are set. The token is read from
+//     /var/run/secrets/tokens/gcp-ksa/token, or the file named by
+//     GOOGLE_WORKLOAD_IDENTITY_TOKEN_FILE.
+//  4. In Cloud Shell, the credentials of the signed-in user, obtained from
+//     the local port named by the DEVSHELL_CLIENT_PORT environment variable.
+//  5. On Google App Engine standard first generation runtimes (<= Go 1.9) it uses
 //     the appengine.AccessToken function.
-//  4. On Google Compute Engine, Google App Engine standard second generation runtimes
+//  6. On Google Compute Engine, Google App Engine standard second generation runtimes
 //     (>= Go 1.11), and Google App Engine flexible environment, it fetches
 //     credentials from the metadata server.
 func FindDefaultCredentialsWithParams(ctx context.Context, params CredentialsParams) (*Credentials, error) {
@@ -145,24 +357,42 @@ func FindDefaultCredentialsWithParams(ctx context.Context, params CredentialsPar
 		return CredentialsFromJSONWithParams(ctx, b, params)
 	}
 
-	// Third, if we're on a Google App Engine standard first generation runtime (<= Go 1.9)
+	// Third, try GKE workload identity federation configured through the
+	// environment.
+	if f := gkeWorkloadIdentityFile(); f != nil {
+		b, err := json.Marshal(f)
+		if err != nil {
+			return nil, err
+		}
+		return CredentialsFromJSONWithParams(ctx, b, params)
+	}
+
+	// Fourth, try the user signed in to Cloud Shell, which would otherwise
+	// get the credentials of the Cloud Shell VM from the metadata server.
+	if creds, err := cloudShellCredentialsFromEnv(ctx, params); creds != nil || err != nil {
+		return creds, err
+	}
+
+	// Fifth, if we're on a Google App Engine standard first generation runtime (<= Go 1.9)
 	// use those credentials. App Engine standard second generation runtimes (>= Go 1.11)
 	// and App Engine flexible use ComputeTokenSource and the metadata server.
 	if appengineTokenFunc != nil {
-		return &Credentials{
-			ProjectID:   appengineAppIDFunc(ctx),
-			TokenSource: AppEngineTokenSource(ctx, params.Scopes...),
-		}, nil
+		return withMetrics(&Credentials{
+			ProjectID:     appengineAppIDFunc(ctx),
+			TokenSource:   AppEngineTokenSource(ctx, params.Scopes...),
+			RequestHeader: params.RequestHeader,
+		}, appEngineType, params.Metrics), nil
 	}
 
-	// Fourth, if we're on Google Compute Engine, an App Engine standard second generation runtime,
+	// Sixth, if we're on Google Compute Engine, an App Engine standard second generation runtime,
 	// or App Engine flexible, use the metadata server.
 	if metadata.OnGCE() {
 		id, _ := metadata.ProjectID()
-		return &Credentials{
-			ProjectID:   id,
-			TokenSource: computeTokenSource("", params.EarlyTokenRefresh, params.Scopes...),
-		}, nil
+		return withMetrics(&Credentials{
+			ProjectID:     id,
+			TokenSource:   computeTokenSource("", params.EarlyTokenRefresh, params.TokenRefreshJitter, params.Scopes...),
+			RequestHeader: params.RequestHeader,
+		}, computeMetadataType, params.Metrics), nil
 	}
 
 	// None are found; return helpful error.
@@ -181,6 +411,10 @@ func FindDefaultCredentials(ctx context.Context, scopes ...string) (*Credentials
 // a Google Developers service account key file, a gcloud user credentials file (a.k.a. refresh
 // token JSON), or the JSON configuration file for workload identity federation in non-Google cloud
 // platforms (see https://cloud.google.com/iam/docs/how-to#using-workload-identity-federation).
+//
+// The JSON can also be an array of such credentials files, of which the one
+// selected by params.CredentialName, or else the first one that can be loaded,
+// is used.
 func CredentialsFromJSONWithParams(ctx context.Context, jsonData []byte, params CredentialsParams) (*Credentials, error) {
 	// Make defensive copy of the slices in params.
 	params = params.deepCopy()
@@ -188,11 +422,17 @@ func CredentialsFromJSONWithParams(ctx context.Context, jsonData []byte, params
 	// First, attempt to parse jsonData as a Google Developers Console client_credentials.json.
 	config, _ := ConfigFromJSON(jsonData, params.Scopes...)
 	if config != nil {
-		return &Credentials{
-			ProjectID:   "",
-			TokenSource: authhandler.TokenSourceWithPKCE(ctx, config, params.State, params.AuthHandler, params.PKCE),
-			JSON:        jsonData,
-		}, nil
+		return withMetrics(&Credentials{
+			ProjectID:     "",
+			TokenSource:   authhandler.TokenSourceWithPKCE(ctx, config, params.State, params.AuthHandler, params.PKCE),
+			JSON:          jsonData,
+			RequestHeader: params.RequestHeader,
+		}, clientSecretType, params.Metrics), nil
+	}
+
+	// A JSON array holds several configurations to choose from.
+	if trimmed := bytes.TrimSpace(jsonData); len(trimmed) > 0 && trimmed[0] == '[' {
+		return credentialsFromJSONArray(ctx, trimmed, params)
 	}
 
 	// Otherwise, parse jsonData as one of the other supported credentials files.
@@ -205,11 +445,49 @@ func CredentialsFromJSONWithParams(ctx context.Context, jsonData []byte, params
 		return nil, err
 	}
 	ts = newErrWrappingTokenSource(ts)
-	return &Credentials{
-		ProjectID:   f.ProjectID,
-		TokenSource: ts,
-		JSON:        jsonData,
-	}, nil
+	return withMetrics(&Credentials{
+		ProjectID:     f.ProjectID,
+		TokenSource:   ts,
+		JSON:          jsonData,
+		RequestHeader: params.RequestHeader,
+	}, f.Type, params.Metrics), nil
+}
+
+// credentialsFromJSONArray loads the configuration of a JSON array selected by
+// params.CredentialName, or else the first one that can be loaded. This lets
+// a single mounted secret hold the identities of several environments.
+func credentialsFromJSONArray(ctx context.Context, jsonData []byte, params CredentialsParams) (*Credentials, error) {
+	var configs []json.RawMessage
+	if err := json.Unmarshal(jsonData, &configs); err != nil {
+		return nil, err
+	}
+	var errs []string
+	for i, config := range configs {
+		if trimmed := bytes.TrimSpace(config); len(trimmed) == 0 || trimmed[0] != '{' {
+			return nil, fmt.Errorf("google: credential configuration %d is not a JSON object", i)
+		}
+		if params.CredentialName != "" {
+			var named struct {
+				Name string `json:"name"`
+			}
+			if err := json.Unmarshal(config, &named); err != nil || named.Name != params.CredentialName {
+				continue
+			}
+			return CredentialsFromJSONWithParams(ctx, config, params)
+		}
+		creds, err := CredentialsFromJSONWithParams(ctx, config, params)
+		if err == nil {
+			return creds, nil
+		}
+		errs = append(errs, fmt.Sprintf("%d: %v", i, err))
+	}
+	if params.CredentialName != "" {
+		return nil, fmt.Errorf("google: no credential configuration named %q", params.CredentialName)
+	}
+	if len(errs) == 0 {
+		return nil, errors.New("google: empty list of credential configurations")
+	}
+	return nil, fmt.Errorf("google: no valid credential configuration: %s", strings.Join(errs, "; "))
 }
 
 // CredentialsFromJSON invokes CredentialsFromJSONWithParams with the specified scopes.
@@ -0,0 +1,145 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package google
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+const credentialsArrayJSON = `[
+	{"name": "broken", "type": "unknown"},
+	{"name": "staging", "type": "authorized_user", "client_id": "staging-id", "client_secret": "secret", "refresh_token": "refresh"},
+	{"name": "prod", "type": "authorized_user", "client_id": "prod-id", "client_secret": "secret", "refresh_token": "refresh"}
+]`
+
+func TestCredentialsFromJSONWithParams_Array(t *testing.T) {
+	tests := []struct {
+		name         string
+		credName     string
+		wantClientID string
+	}{
+		{name: "first valid", wantClientID: "staging-id"},
+		{name: "by name", credName: "prod", wantClientID: "prod-id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creds, err := CredentialsFromJSONWithParams(context.Background(), []byte(credentialsArrayJSON), CredentialsParams{CredentialName: tt.credName})
+			if err != nil {
+				t.Fatalf("CredentialsFromJSONWithParams() failed: %v", err)
+			}
+			var f credentialsFile
+			if err := json.Unmarshal(creds.JSON, &f); err != nil {
+				t.Fatal(err)
+			}
+			if got := f.ClientID; got != tt.wantClientID {
+				t.Errorf("got client ID %v but want %v", got, tt.wantClientID)
+			}
+		})
+	}
+}
+
+func TestCredentialsFromJSONWithParams_ArrayErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		json     string
+		credName string
+	}{
+		{name: "unknown name", json: credentialsArrayJSON, credName: "dev"},
+		{name: "no valid", json: `[{"type": "unknown"}]`},
+		{name: "empty", json: `[]`},
+		{name: "nested", json: `[[{"type": "authorized_user"}]]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := CredentialsFromJSONWithParams(context.Background(), []byte(tt.json), CredentialsParams{CredentialName: tt.credName}); err == nil {
+				t.Error("CredentialsFromJSONWithParams() succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestFindDefaultCredentials_GKEWorkloadIdentity(t *testing.T) {
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("APPDATA", t.TempDir())
+	t.Setenv("GOOGLE_WORKLOAD_IDENTITY_POOL", "project.svc.id.goog")
+	t.Setenv("GOOGLE_WORKLOAD_IDENTITY_PROVIDER", "https://container.googleapis.com/v1/projects/project/locations/us-central1/clusters/cluster")
+	t.Setenv("GOOGLE_WORKLOAD_IDENTITY_TOKEN_FILE", "/var/run/secrets/custom/token")
+
+	creds, err := FindDefaultCredentials(context.Background(), ScopeCloudPlatform)
+	if err != nil {
+		t.Fatalf("FindDefaultCredentials() failed: %v", err)
+	}
+	var f credentialsFile
+	if err := json.Unmarshal(creds.JSON, &f); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := f.Audience, "identitynamespace:project.svc.id.goog:https://container.googleapis.com/v1/projects/project/locations/us-central1/clusters/cluster"; got != want {
+		t.Errorf("got audience %v but want %v", got, want)
+	}
+	if got, want := f.CredentialSource.File, "/var/run/secrets/custom/token"; got != want {
+		t.Errorf("got token file %v but want %v", got, want)
+	}
+}
+
+func TestCredentialsClient_RequestHeader(t *testing.T) {
+	hook := func(c *Credentials, tok *oauth2.Token, req *http.Request) http.Header {
+		return http.Header{"X-Goog-Request-Params": {"project=" + c.ProjectID}}
+	}
+	creds, err := CredentialsFromJSONWithParams(context.Background(), []byte(`{"type": "authorized_user", "client_id": "id", "client_secret": "secret", "refresh_token": "refresh"}`), CredentialsParams{RequestHeader: hook})
+	if err != nil {
+		t.Fatalf("CredentialsFromJSONWithParams() failed: %v", err)
+	}
+	if creds.RequestHeader == nil {
+		t.Fatal("got nil RequestHeader but want the one of the params")
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("X-Goog-Request-Params"), "project=my-project"; got != want {
+			t.Errorf("got %v but want %v", got, want)
+		}
+		if got, want := r.Header.Get("Authorization"), "Bearer token"; got != want {
+			t.Errorf("got %v but want %v", got, want)
+		}
+	}))
+	defer server.Close()
+	creds = &Credentials{
+		ProjectID:     "my-project",
+		TokenSource:   oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"}),
+		RequestHeader: hook,
+	}
+	res, err := creds.Client(context.Background()).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+}
+
+func TestCredentialsClient_APIKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("X-Goog-Api-Key"), "api-key"; got != want {
+			t.Errorf("got %v but want %v", got, want)
+		}
+		if got, want := r.Header.Get("Authorization"), "Bearer token"; got != want {
+			t.Errorf("got %v but want %v", got, want)
+		}
+	}))
+	defer server.Close()
+	creds := &Credentials{
+		TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"}),
+		APIKey:      "api-key",
+	}
+	res, err := creds.Client(context.Background()).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+}
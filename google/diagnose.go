@@ -0,0 +1,184 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package google
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"cloud.google.com/go/compute/metadata"
+)
+
+// DiagnosisStatus is the outcome of one step of the Application Default
+// Credentials search.
+type DiagnosisStatus int
+
+const (
+	// DiagnosisNotFound means the step found no credentials and the search
+	// moved on.
+	DiagnosisNotFound DiagnosisStatus = iota
+	// DiagnosisFound means the step found the credentials that are used.
+	DiagnosisFound
+	// DiagnosisFailed means the step found credentials that could not be
+	// loaded. The search stops there, as FindDefaultCredentials does.
+	DiagnosisFailed
+	// DiagnosisSkipped means the step was not reached.
+	DiagnosisSkipped
+)
+
+func (s DiagnosisStatus) String() string {
+	switch s {
+	case DiagnosisNotFound:
+		return "not found"
+	case DiagnosisFound:
+		return "found"
+	case DiagnosisFailed:
+		return "failed"
+	case DiagnosisSkipped:
+		return "skipped"
+	}
+	return fmt.Sprintf("DiagnosisStatus(%d)", int(s))
+}
+
+// DiagnosisStep describes one place searched for credentials.
+type DiagnosisStep struct {
+	// Source names the place, e.g. "GOOGLE_APPLICATION_CREDENTIALS".
+	Source string
+	// Location is the file path or other detail of what was checked, if
+	// any.
+	Location string
+	// Status is the outcome of the step.
+	Status DiagnosisStatus
+	// CredentialType is the type of the credentials found, e.g.
+	// "service_account" or "external_account".
+	CredentialType string
+	// Err explains a DiagnosisFailed status.
+	Err error
+}
+
+// Diagnosis is a report of the Application Default Credentials search.
+type Diagnosis struct {
+	// Steps lists the places searched, in the order FindDefaultCredentials
+	// searches them.
+	Steps []DiagnosisStep
+	// TokenErr is the error of retrieving a token from the credentials
+	// found, or nil if a token was retrieved or no credentials were found.
+	TokenErr error
+}
+
+// Credentials returns the step whose credentials are used, or nil if none
+// were found.
+func (d *Diagnosis) Credentials() *DiagnosisStep {
+	for i := range d.Steps {
+		if d.Steps[i].Status == DiagnosisFound {
+			return &d.Steps[i]
+		}
+	}
+	return nil
+}
+
+// OK reports whether credentials were found and produced a token.
+func (d *Diagnosis) OK() bool {
+	return d.Credentials() != nil && d.TokenErr == nil
+}
+
+// Diagnose runs the search of FindDefaultCredentialsWithParams and reports
+// what was checked, what was found and where it failed, then tries to
+// retrieve a token from the credentials found. It is meant for command-line
+// tools that help users troubleshoot their credentials setup.
+func Diagnose(ctx context.Context, params CredentialsParams) *Diagnosis {
+	params = params.deepCopy()
+	d := &Diagnosis{}
+	var creds *Credentials
+	done := false
+	step := func(s DiagnosisStep, find func(s *DiagnosisStep) *Credentials) {
+		if done {
+			s.Status = DiagnosisSkipped
+		} else {
+			creds = find(&s)
+			done = s.Status != DiagnosisNotFound
+		}
+		d.Steps = append(d.Steps, s)
+	}
+
+	const envVar = "GOOGLE_APPLICATION_CREDENTIALS"
+	step(DiagnosisStep{Source: envVar, Location: os.Getenv(envVar)}, func(s *DiagnosisStep) *Credentials {
+		if s.Location == "" {
+			return nil
+		}
+		b, err := os.ReadFile(s.Location)
+		if err != nil {
+			s.Status, s.Err = DiagnosisFailed, err
+			return nil
+		}
+		return diagnoseJSON(ctx, s, b, params)
+	})
+	step(DiagnosisStep{Source: "gcloud well-known file", Location: wellKnownFile()}, func(s *DiagnosisStep) *Credentials {
+		b, err := os.ReadFile(s.Location)
+		if err != nil {
+			return nil
+		}
+		return diagnoseJSON(ctx, s, b, params)
+	})
+	step(DiagnosisStep{Source: "GKE workload identity environment"}, func(s *DiagnosisStep) *Credentials {
+		f := gkeWorkloadIdentityFile()
+		if f == nil {
+			return nil
+		}
+		s.Location = f.CredentialSource.File
+		b, err := json.Marshal(f)
+		if err != nil {
+			s.Status, s.Err = DiagnosisFailed, err
+			return nil
+		}
+		return diagnoseJSON(ctx, s, b, params)
+	})
+	step(DiagnosisStep{Source: "Cloud Shell", Location: os.Getenv(cloudShellPortEnvVar)}, func(s *DiagnosisStep) *Credentials {
+		creds, err := cloudShellCredentialsFromEnv(ctx, params)
+		switch {
+		case err != nil:
+			s.Status, s.Err = DiagnosisFailed, err
+		case creds != nil:
+			s.Status = DiagnosisFound
+		}
+		return creds
+	})
+	step(DiagnosisStep{Source: "App Engine first generation runtime"}, func(s *DiagnosisStep) *Credentials {
+		if appengineTokenFunc == nil {
+			return nil
+		}
+		s.Status = DiagnosisFound
+		return &Credentials{TokenSource: AppEngineTokenSource(ctx, params.Scopes...)}
+	})
+	step(DiagnosisStep{Source: "metadata server"}, func(s *DiagnosisStep) *Credentials {
+		if !metadata.OnGCE() {
+			return nil
+		}
+		s.Status = DiagnosisFound
+		return &Credentials{TokenSource: computeTokenSource("", params.EarlyTokenRefresh, params.TokenRefreshJitter, params.Scopes...)}
+	})
+
+	if creds != nil {
+		_, d.TokenErr = creds.TokenSource.Token()
+	}
+	return d
+}
+
+// diagnoseJSON loads the credentials file b and records the outcome in s.
+func diagnoseJSON(ctx context.Context, s *DiagnosisStep, b []byte, params CredentialsParams) *Credentials {
+	var f credentialsFile
+	if err := json.Unmarshal(b, &f); err == nil {
+		s.CredentialType = f.Type
+	}
+	creds, err := CredentialsFromJSONWithParams(ctx, b, params)
+	if err != nil {
+		s.Status, s.Err = DiagnosisFailed, err
+		return nil
+	}
+	s.Status = DiagnosisFound
+	return creds
+}
@@ -0,0 +1,59 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package google
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiagnose_InvalidFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "credentials.json")
+	if err := os.WriteFile(file, []byte(`{"type": "unknown"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", file)
+
+	d := Diagnose(context.Background(), CredentialsParams{})
+	if d.OK() || d.Credentials() != nil {
+		t.Fatalf("Diagnose() = %+v, want no credentials", d)
+	}
+	first := d.Steps[0]
+	if first.Status != DiagnosisFailed || first.Err == nil || first.CredentialType != "unknown" {
+		t.Errorf("first step = %+v, want failed unknown credentials", first)
+	}
+	for _, s := range d.Steps[1:] {
+		if s.Status != DiagnosisSkipped {
+			t.Errorf("step %q status = %v, want %v", s.Source, s.Status, DiagnosisSkipped)
+		}
+	}
+}
+
+func TestDiagnose_WellKnownFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	t.Setenv("HOME", home)
+	t.Setenv("APPDATA", home)
+	if err := os.MkdirAll(filepath.Dir(wellKnownFile()), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(wellKnownFile(), []byte(`{"type": "authorized_user", "client_id": "id", "client_secret": "secret", "refresh_token": "refresh"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	d := Diagnose(context.Background(), CredentialsParams{TokenURL: "http://127.0.0.1:0/token"})
+	if got := d.Steps[0].Status; got != DiagnosisNotFound {
+		t.Errorf("environment variable step status = %v, want %v", got, DiagnosisNotFound)
+	}
+	found := d.Credentials()
+	if found == nil || found.CredentialType != "authorized_user" {
+		t.Fatalf("Credentials() = %+v, want authorized_user credentials", found)
+	}
+	if d.TokenErr == nil {
+		t.Error("TokenErr = nil, want error from unreachable token endpoint")
+	}
+}
@@ -138,6 +138,12 @@ func NewTokenSource(ctx context.Context, conf DownscopingConfig) (oauth2.TokenSo
 	return downscopingTokenSource{ctx: ctx, config: conf}, nil
 }
 
+// DescribeTokenSource describes dts and its root source, see
+// oauth2.DescribeTokenSource.
+func (dts downscopingTokenSource) DescribeTokenSource() (string, oauth2.TokenSource) {
+	return "downscope", dts.config.RootSource
+}
+
 // Token() uses a downscopingTokenSource to generate an oauth2 Token.
 // Do note that the returned TokenSource is an oauth2.StaticTokenSource. If you wish
 // to refresh this token automatically, then initialize a locally defined
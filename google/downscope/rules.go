@@ -0,0 +1,36 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package downscope
+
+import (
+	"fmt"
+	"strings"
+)
+
+// storageObjectViewer is the predefined role granting read access to
+// Cloud Storage objects.
+const storageObjectViewer = "inRole:roles/storage.objectViewer"
+
+// BucketPrefixRule returns an AccessBoundaryRule that limits a token to the
+// objects of bucket whose names start with prefix, with the given
+// permissions, e.g. "inRole:roles/storage.objectAdmin". The default
+// permission is read access. Listing the bucket is allowed as long as the
+// list request is restricted to prefix.
+func BucketPrefixRule(bucket, prefix string, permissions ...string) AccessBoundaryRule {
+	if len(permissions) == 0 {
+		permissions = []string{storageObjectViewer}
+	}
+	quoted := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	objects := quoted.Replace(fmt.Sprintf("projects/_/buckets/%s/objects/%s", bucket, prefix))
+	return AccessBoundaryRule{
+		AvailableResource:    "//storage.googleapis.com/projects/_/buckets/" + bucket,
+		AvailablePermissions: permissions,
+		Condition: &AvailabilityCondition{
+			Title: "Objects under " + prefix,
+			Expression: fmt.Sprintf("resource.name.startsWith('%s') || api.getAttribute('storage.googleapis.com/objectListPrefix', '').startsWith('%s')",
+				objects, quoted.Replace(prefix)),
+		},
+	}
+}
@@ -0,0 +1,30 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package downscope
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBucketPrefixRule(t *testing.T) {
+	got := BucketPrefixRule("my-bucket", "tenant's/")
+	want := AccessBoundaryRule{
+		AvailableResource:    "//storage.googleapis.com/projects/_/buckets/my-bucket",
+		AvailablePermissions: []string{"inRole:roles/storage.objectViewer"},
+		Condition: &AvailabilityCondition{
+			Title:      "Objects under tenant's/",
+			Expression: `resource.name.startsWith('projects/_/buckets/my-bucket/objects/tenant\'s/') || api.getAttribute('storage.googleapis.com/objectListPrefix', '').startsWith('tenant\'s/')`,
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v but want %+v", got, want)
+	}
+
+	got = BucketPrefixRule("b", "p/", "inRole:roles/storage.objectAdmin")
+	if !reflect.DeepEqual(got.AvailablePermissions, []string{"inRole:roles/storage.objectAdmin"}) {
+		t.Errorf("got permissions %v", got.AvailablePermissions)
+	}
+}
@@ -13,6 +13,9 @@ import (
 // AuthenticationError indicates there was an error in the authentication flow.
 //
 // Use (*AuthenticationError).Temporary to check if the error can be retried.
+// It wraps an *oauth2.RetrieveError, which holds the RFC 6749 error code of
+// the response, e.g. invalid_grant for a subject token rejected by the
+// Security Token Service.
 type AuthenticationError struct {
 	err *oauth2.RetrieveError
 }
@@ -52,6 +55,12 @@ func newErrWrappingTokenSource(ts oauth2.TokenSource) oauth2.TokenSource {
 	return &errWrappingTokenSource{src: ts}
 }
 
+// DescribeTokenSource leaves s out of descriptions, see
+// oauth2.DescribeTokenSource.
+func (s *errWrappingTokenSource) DescribeTokenSource() (string, oauth2.TokenSource) {
+	return "", s.src
+}
+
 // Token returns the current token if it's still valid, else will
 // refresh the current token (using r.Context for HTTP client
 // information) and return the new one.
@@ -0,0 +1,82 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package google
+
+import (
+	"context"
+	"errors"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google/downscope"
+)
+
+// ExchangeOptions describes the token ExchangeToken derives from a source
+// credential.
+type ExchangeOptions struct {
+	// TargetPrincipal is the email address of a service account to
+	// impersonate. Required with IDTokenAudience. Optional otherwise.
+	TargetPrincipal string
+	// Scopes are the scopes of the impersonated access token. The default is
+	// https://www.googleapis.com/auth/cloud-platform.
+	Scopes []string
+	// Delegates is the delegation chain used for impersonation. Optional.
+	Delegates []string
+	// IDTokenAudience, when set, requests an ID token for this audience from
+	// the IAM Credentials API. It cannot be combined with Rules.
+	IDTokenAudience string
+	// Rules downscope the resulting access token with a Credential Access
+	// Boundary through the Security Token Service, e.g. with
+	// downscope.BucketPrefixRule. Optional.
+	Rules []downscope.AccessBoundaryRule
+	// Endpoint overrides the base URL of the IAM Credentials API. Optional.
+	Endpoint string
+}
+
+// ExchangeToken derives a single token from the credentials of src, such as
+// those found by FindDefaultCredentials. Depending on opts it impersonates a
+// service account through the IAM Credentials API, requests an ID token for
+// another audience, and downscopes the access token through the Security
+// Token Service, in that order. With empty opts it returns src's token.
+//
+// For example, a workload can hand out a token that only reads the objects
+// of one bucket prefix:
+//
+//	tok, err := google.ExchangeToken(ctx, creds.TokenSource, google.ExchangeOptions{
+//		Rules: []downscope.AccessBoundaryRule{downscope.BucketPrefixRule("bucket", "tenant-1/")},
+//	})
+func ExchangeToken(ctx context.Context, src oauth2.TokenSource, opts ExchangeOptions) (*oauth2.Token, error) {
+	if opts.IDTokenAudience != "" && len(opts.Rules) > 0 {
+		return nil, errors.New("oauth2/google: ID tokens cannot be downscoped")
+	}
+	if opts.IDTokenAudience != "" && opts.TargetPrincipal == "" {
+		return nil, errors.New("oauth2/google: a target principal is required to exchange for an ID token")
+	}
+	ts := src
+	if opts.TargetPrincipal != "" {
+		scopes := opts.Scopes
+		if len(scopes) == 0 {
+			scopes = []string{ScopeCloudPlatform}
+		}
+		var err error
+		ts, err = ImpersonateTokenSource(ctx, src, ImpersonateConfig{
+			TargetPrincipal: opts.TargetPrincipal,
+			Scopes:          scopes,
+			Delegates:       opts.Delegates,
+			IDTokenAudience: opts.IDTokenAudience,
+			Endpoint:        opts.Endpoint,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(opts.Rules) > 0 {
+		var err error
+		ts, err = downscope.NewTokenSource(ctx, downscope.DownscopingConfig{RootSource: ts, Rules: opts.Rules})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ts.Token()
+}
@@ -0,0 +1,64 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package google
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google/downscope"
+)
+
+func TestExchangeToken_Impersonation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Scope []string `json:"scope"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("unable to decode request: %v", err)
+		}
+		if got, want := body.Scope, []string{ScopeCloudPlatform}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got scopes %v but want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"accessToken":"impersonated","expireTime":%q}`, time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	}))
+	defer server.Close()
+
+	src := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "adc"})
+	tok, err := ExchangeToken(context.Background(), src, ExchangeOptions{
+		TargetPrincipal: "sa@project.iam.gserviceaccount.com",
+		Endpoint:        server.URL,
+	})
+	if err != nil {
+		t.Fatalf("ExchangeToken() failed: %v", err)
+	}
+	if got, want := tok.AccessToken, "impersonated"; got != want {
+		t.Errorf("got %v but want %v", got, want)
+	}
+}
+
+func TestExchangeToken_InvalidOptions(t *testing.T) {
+	src := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "adc"})
+	for _, opts := range []ExchangeOptions{
+		{IDTokenAudience: "https://service.example.com"},
+		{
+			TargetPrincipal: "sa@project.iam.gserviceaccount.com",
+			IDTokenAudience: "https://service.example.com",
+			Rules:           []downscope.AccessBoundaryRule{downscope.BucketPrefixRule("bucket", "prefix/")},
+		},
+	} {
+		if _, err := ExchangeToken(context.Background(), src, opts); err == nil {
+			t.Errorf("ExchangeToken(%+v) succeeded, want error", opts)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package google
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// externalAccountConfigVersion is the newest version of the external account
+// configuration format understood by this package. Files without a version
+// field are version 1.
+const externalAccountConfigVersion = 1
+
+// ConfigParseMode controls how ParseExternalAccountConfig treats fields and
+// format versions it does not know.
+type ConfigParseMode int
+
+const (
+	// ParseStrict rejects unknown fields and newer format versions, so
+	// that configurations relying on features this package lacks fail
+	// early.
+	ParseStrict ConfigParseMode = iota
+
+	// ParseLenient accepts newer format versions and keeps unknown fields
+	// in ExternalAccountConfig.Unknown, so that configurations produced by
+	// newer tools can be loaded, edited and written back without loss.
+	ParseLenient
+)
+
+// ExternalAccountConfig is an external account (workload or workforce
+// identity federation) credential configuration file, as generated by
+// "gcloud iam workload-identity-pools create-cred-config". It can be built
+// in code and marshaled to JSON to generate configuration files, or parsed
+// with ParseExternalAccountConfig to inspect and rewrite them.
+type ExternalAccountConfig struct {
+	// Version is the format version of the file. Zero stands for 1 and is
+	// omitted from the JSON encoding.
+	Version int `json:"version,omitempty"`
+
+	Audience                       string `json:"audience"`
+	SubjectTokenType               string `json:"subject_token_type"`
+	TokenURL                       string `json:"token_url,omitempty"`
+	TokenInfoURL                   string `json:"token_info_url,omitempty"`
+	ServiceAccountImpersonationURL string `json:"service_account_impersonation_url,omitempty"`
+	ClientID                       string `json:"client_id,omitempty"`
+	ClientSecret                   string `json:"client_secret,omitempty"`
+	QuotaProjectID                 string `json:"quota_project_id,omitempty"`
+	WorkforcePoolUserProject       string `json:"workforce_pool_user_project,omitempty"`
+	UniverseDomain                 string `json:"universe_domain,omitempty"`
+
+	// Name identifies the configuration within a JSON array of
+	// configurations, see CredentialsParams.CredentialName.
+	Name string `json:"name,omitempty"`
+
+	// STSOptionsEncoding is how additional options are sent to the
+	// Security Token Service: "json", the default, or "form".
+	STSOptionsEncoding string `json:"sts_options_encoding,omitempty"`
+
+	// ServiceAccountImpersonationLifetimeSeconds is the lifetime of
+	// impersonated tokens, stored as the token_lifetime_seconds field of
+	// the service_account_impersonation object. It takes precedence over
+	// that field of ServiceAccountImpersonation.
+	ServiceAccountImpersonationLifetimeSeconds int `json:"-"`
+
+	// ServiceAccountImpersonation is the raw service_account_impersonation
+	// object. Like CredentialSource, it is kept as is so that fields
+	// unknown to this package survive a round trip.
+	ServiceAccountImpersonation json.RawMessage `json:"service_account_impersonation,omitempty"`
+
+	// CredentialSource is the raw credential_source object. It is kept as
+	// is so that credential source fields unknown to this package survive a
+	// round trip.
+	CredentialSource json.RawMessage `json:"credential_source,omitempty"`
+
+	// Unknown holds the top-level fields not known to this package, as
+	// parsed with ParseLenient. They are written back by MarshalJSON.
+	Unknown map[string]json.RawMessage `json:"-"`
+}
+
+// ParseExternalAccountConfig parses an external account credential
+// configuration file.
+func ParseExternalAccountConfig(b []byte, mode ConfigParseMode) (*ExternalAccountConfig, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, fmt.Errorf("google: invalid external account configuration: %v", err)
+	}
+	var w struct {
+		Type string `json:"type"`
+		ExternalAccountConfig
+	}
+	if err := json.Unmarshal(b, &w); err != nil {
+		return nil, fmt.Errorf("google: invalid external account configuration: %v", err)
+	}
+	if w.Type != externalAccountKey {
+		return nil, fmt.Errorf("google: credential configuration type is %q, want %q", w.Type, externalAccountKey)
+	}
+	if w.Version > externalAccountConfigVersion && mode == ParseStrict {
+		return nil, fmt.Errorf("google: external account configuration version %d is newer than the supported version %d", w.Version, externalAccountConfigVersion)
+	}
+	c := w.ExternalAccountConfig
+	if len(c.ServiceAccountImpersonation) > 0 {
+		var info serviceAccountImpersonationInfo
+		if err := json.Unmarshal(c.ServiceAccountImpersonation, &info); err != nil {
+			return nil, fmt.Errorf("google: invalid external account configuration: %v", err)
+		}
+		c.ServiceAccountImpersonationLifetimeSeconds = info.TokenLifetimeSeconds
+	}
+
+	known, err := c.fields()
+	if err != nil {
+		return nil, err
+	}
+	var unknown []string
+	for key, value := range fields {
+		if _, ok := known[key]; ok || isKnownConfigField(key) {
+			continue
+		}
+		if c.Unknown == nil {
+			c.Unknown = make(map[string]json.RawMessage)
+		}
+		c.Unknown[key] = value
+		unknown = append(unknown, key)
+	}
+	if len(unknown) > 0 && mode == ParseStrict {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("google: unknown external account configuration fields: %s", strings.Join(unknown, ", "))
+	}
+	return &c, nil
+}
+
+// ExternalAccountConfigFromJSON parses an external account credential
+// configuration file, as ParseExternalAccountConfig does in strict mode, and
+// validates it as CredentialsFromJSONWithParams does, so that mistakes such
+// as a missing credential source or a malformed executable section are
+// reported with a descriptive error before the credentials are used.
+func ExternalAccountConfigFromJSON(b []byte) (*ExternalAccountConfig, error) {
+	c, err := ParseExternalAccountConfig(b, ParseStrict)
+	if err != nil {
+		return nil, err
+	}
+	var f credentialsFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("google: invalid external account configuration: %v", err)
+	}
+	if _, err := f.externalAccountConfig(CredentialsParams{}); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// isKnownConfigField reports whether key is a field of
+// ExternalAccountConfig that may be omitted from its encoding.
+func isKnownConfigField(key string) bool {
+	switch key {
+	case "type", "version", "token_url", "token_info_url", "service_account_impersonation_url",
+		"service_account_impersonation", "client_id", "client_secret", "quota_project_id",
+		"workforce_pool_user_project", "universe_domain", "name", "sts_options_encoding",
+		"credential_source":
+		return true
+	}
+	return false
+}
+
+// fields returns the JSON encoding of the known fields of c.
+func (c ExternalAccountConfig) fields() (map[string]json.RawMessage, error) {
+	type plain ExternalAccountConfig // drops the MarshalJSON method
+	w := struct {
+		Type string `json:"type"`
+		plain
+	}{Type: externalAccountKey, plain: plain(c)}
+	impersonation, err := c.serviceAccountImpersonation()
+	if err != nil {
+		return nil, err
+	}
+	w.ServiceAccountImpersonation = impersonation
+	b, err := json.Marshal(w)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// serviceAccountImpersonation returns c.ServiceAccountImpersonation with its
+// token_lifetime_seconds field set to c.ServiceAccountImpersonationLifetimeSeconds,
+// or removed if that is zero.
+func (c ExternalAccountConfig) serviceAccountImpersonation() (json.RawMessage, error) {
+	fields := make(map[string]json.RawMessage)
+	if len(c.ServiceAccountImpersonation) > 0 {
+		if err := json.Unmarshal(c.ServiceAccountImpersonation, &fields); err != nil {
+			return nil, fmt.Errorf("google: invalid service_account_impersonation: %v", err)
+		}
+	}
+	if c.ServiceAccountImpersonationLifetimeSeconds != 0 {
+		fields["token_lifetime_seconds"] = json.RawMessage(strconv.Itoa(c.ServiceAccountImpersonationLifetimeSeconds))
+	} else {
+		delete(fields, "token_lifetime_seconds")
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(fields)
+}
+
+// MarshalJSON encodes c as a credential configuration file, including the
+// fields of c.Unknown that do not collide with known fields.
+func (c ExternalAccountConfig) MarshalJSON() ([]byte, error) {
+	fields, err := c.fields()
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range c.Unknown {
+		if _, ok := fields[key]; !ok && !isKnownConfigField(key) {
+			fields[key] = value
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// Credentials returns the credentials described by c, as
+// CredentialsFromJSONWithParams does for its JSON encoding.
+func (c *ExternalAccountConfig) Credentials(ctx context.Context, params CredentialsParams) (*Credentials, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return CredentialsFromJSONWithParams(ctx, b, params)
+}
@@ -0,0 +1,194 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package google
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const futureExternalAccountJSON = `{
+	"type": "external_account",
+	"version": 2,
+	"audience": "//iam.googleapis.com/projects/123/locations/global/workloadIdentityPools/pool/providers/provider",
+	"subject_token_type": "urn:ietf:params:oauth:token-type:jwt",
+	"token_url": "https://sts.googleapis.com/v1/token",
+	"service_account_impersonation": {"token_lifetime_seconds": 600, "future_option": true},
+	"credential_source": {"file": "/var/run/token", "future_option": true},
+	"future_field": {"nested": [1, 2]}
+}`
+
+func TestParseExternalAccountConfig(t *testing.T) {
+	if _, err := ParseExternalAccountConfig([]byte(futureExternalAccountJSON), ParseStrict); err == nil {
+		t.Error("ParseExternalAccountConfig() in strict mode succeeded, want error")
+	}
+	c, err := ParseExternalAccountConfig([]byte(futureExternalAccountJSON), ParseLenient)
+	if err != nil {
+		t.Fatalf("ParseExternalAccountConfig() failed: %v", err)
+	}
+	if got, want := c.ServiceAccountImpersonationLifetimeSeconds, 600; got != want {
+		t.Errorf("got lifetime %v but want %v", got, want)
+	}
+	if got, want := string(c.Unknown["future_field"]), `{"nested": [1, 2]}`; got != want {
+		t.Errorf("got unknown field %v but want %v", got, want)
+	}
+
+	// Round trip the configuration, including the fields of its objects
+	// unknown to this package.
+	b, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got, want map[string]interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(futureExternalAccountJSON), &want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v but want %v", got, want)
+	}
+
+	// Clearing the lifetime keeps the other impersonation fields.
+	c.ServiceAccountImpersonationLifetimeSeconds = 0
+	if b, err = json.Marshal(c); err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := got["service_account_impersonation"], map[string]interface{}{"future_option": true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got service_account_impersonation %v but want %v", got, want)
+	}
+}
+
+func TestExternalAccountConfig_Generate(t *testing.T) {
+	c := &ExternalAccountConfig{
+		Audience:         "//iam.googleapis.com/projects/123/locations/global/workloadIdentityPools/pool/providers/provider",
+		SubjectTokenType: "urn:ietf:params:oauth:token-type:jwt",
+		TokenURL:         "https://sts.googleapis.com/v1/token",
+		CredentialSource: json.RawMessage(`{"file":"/var/run/token"}`),
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseExternalAccountConfig(b, ParseStrict)
+	if err != nil {
+		t.Fatalf("ParseExternalAccountConfig() of a generated file failed: %v", err)
+	}
+	if parsed.Audience != c.Audience || parsed.Unknown != nil {
+		t.Errorf("got %+v but want %+v", parsed, c)
+	}
+	if _, err := c.Credentials(context.Background(), CredentialsParams{Scopes: []string{"https://www.googleapis.com/auth/cloud-platform"}}); err != nil {
+		t.Errorf("Credentials() failed: %v", err)
+	}
+}
+
+func TestParseExternalAccountConfig_OptionalFields(t *testing.T) {
+	b := []byte(`{
+		"type": "external_account",
+		"name": "prod",
+		"audience": "//iam.googleapis.com/projects/123/locations/global/workloadIdentityPools/pool/providers/provider",
+		"subject_token_type": "urn:ietf:params:oauth:token-type:jwt",
+		"sts_options_encoding": "form",
+		"credential_source": {"file": "/var/run/token"}
+	}`)
+	c, err := ParseExternalAccountConfig(b, ParseStrict)
+	if err != nil {
+		t.Fatalf("ParseExternalAccountConfig() failed: %v", err)
+	}
+	if c.Name != "prod" || c.STSOptionsEncoding != "form" {
+		t.Errorf("got name %q and options encoding %q but want prod and form", c.Name, c.STSOptionsEncoding)
+	}
+	out, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), `"name":"prod"`) || !strings.Contains(string(out), `"sts_options_encoding":"form"`) {
+		t.Errorf("got %s, want the name and options encoding kept", out)
+	}
+}
+
+const workloadAudience = "//iam.googleapis.com/projects/123/locations/global/workloadIdentityPools/pool/providers/provider"
+
+func TestExternalAccountConfigFromJSON(t *testing.T) {
+	b := []byte(`{
+		"type": "external_account",
+		"audience": "` + workloadAudience + `",
+		"subject_token_type": "urn:ietf:params:oauth:token-type:jwt",
+		"token_url": "https://sts.googleapis.com/v1/token",
+		"service_account_impersonation_url": "https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/sa@project.iam.gserviceaccount.com:generateAccessToken",
+		"service_account_impersonation": {"token_lifetime_seconds": 2800},
+		"credential_source": {
+			"executable": {
+				"command": "/usr/bin/token-helper --audience=pool",
+				"timeout_millis": 5000,
+				"output_file": "/tmp/token.json"
+			},
+			"format": {"type": "json", "subject_token_field_name": "id_token"}
+		},
+		"quota_project_id": "project"
+	}`)
+	c, err := ExternalAccountConfigFromJSON(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Audience != workloadAudience {
+		t.Errorf("got audience %q but want %q", c.Audience, workloadAudience)
+	}
+	if got, want := c.ServiceAccountImpersonationLifetimeSeconds, 2800; got != want {
+		t.Errorf("got lifetime %d but want %d", got, want)
+	}
+	if got, want := c.QuotaProjectID, "project"; got != want {
+		t.Errorf("got quota project %q but want %q", got, want)
+	}
+}
+
+func TestExternalAccountConfigFromJSON_Errors(t *testing.T) {
+	base := `"type": "external_account", "audience": "` + workloadAudience + `", "subject_token_type": "urn:ietf:params:oauth:token-type:jwt", "token_url": "https://sts.googleapis.com/v1/token"`
+	tests := []struct {
+		name    string
+		json    string
+		wantErr string
+	}{
+		{"malformed", `{`, "invalid external account configuration"},
+		{"wrong type", `{"type": "service_account"}`, `type is "service_account"`},
+		{"no audience", `{"type": "external_account", "subject_token_type": "jwt", "token_url": "https://sts.googleapis.com/v1/token", "credential_source": {"file": "/token"}}`, "`audience`"},
+		{"no token URL", `{"type": "external_account", "audience": "aud", "subject_token_type": "jwt", "credential_source": {"file": "/token"}}`, "`token_url`"},
+		{"no credential source", `{` + base + `}`, "must set one of"},
+		{"two credential sources", `{` + base + `, "credential_source": {"file": "/token", "url": "http://localhost/token"}}`, "more than one of url, file"},
+		{"unknown format", `{` + base + `, "credential_source": {"file": "/token", "format": {"type": "xml"}}}`, "format.type"},
+		{"JSON format without field", `{` + base + `, "credential_source": {"file": "/token", "format": {"type": "json"}}}`, "subject_token_field_name"},
+		{"executable without command", `{` + base + `, "credential_source": {"executable": {}}}`, "`command`"},
+		{"executable timeout", `{` + base + `, "credential_source": {"executable": {"command": "helper", "timeout_millis": 1}}}`, "`timeout_millis`"},
+		{"unknown environment", `{` + base + `, "credential_source": {"environment_id": "gcp1"}}`, "`environment_id`"},
+		{"client secret without ID", `{` + base + `, "client_secret": "secret", "credential_source": {"file": "/token"}}`, "`client_id`"},
+		{"workforce project", `{` + base + `, "workforce_pool_user_project": "project", "credential_source": {"file": "/token"}}`, "workforce_pool_user_project"},
+		{"body without URL", `{` + base + `, "credential_source": {"file": "/token", "body": "{}"}}`, "`method` and `body`"},
+		{"unknown method", `{` + base + `, "credential_source": {"url": "http://localhost/token", "method": "DELETE"}}`, "`method`"},
+		{"client certificate without URL", `{` + base + `, "credential_source": {"file": "/token", "client_certificate": {"cert_path": "/cert.pem", "key_path": "/key.pem"}}}`, "`client_certificate`"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ExternalAccountConfigFromJSON([]byte(tt.json))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got %v but want an error containing %q", err, tt.wantErr)
+			}
+			// Application default credentials are validated alike.
+			if !strings.Contains(tt.json, externalAccountKey) {
+				return
+			}
+			if _, err := CredentialsFromJSONWithParams(context.Background(), []byte(tt.json), CredentialsParams{}); err == nil {
+				t.Error("CredentialsFromJSONWithParams() succeeded, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package google
+
+import "golang.org/x/oauth2/google/internal/externalaccount"
+
+// gkeWorkloadIdentityFile returns the external account configuration
+// described by the GOOGLE_WORKLOAD_IDENTITY_* environment variables, or nil
+// if they are not set. It lets GKE workloads use workload identity federation
+// with their projected service account token in clusters where the GKE
+// metadata server is disabled.
+func gkeWorkloadIdentityFile() *credentialsFile {
+	audience, tokenFile, ok := externalaccount.GKEWorkloadIdentityFromEnv()
+	if !ok {
+		return nil
+	}
+	if tokenFile == "" {
+		tokenFile = externalaccount.DefaultGKETokenFile
+	}
+	return &credentialsFile{
+		Type:             externalAccountKey,
+		Audience:         audience,
+		SubjectTokenType: externalaccount.JWTTokenType,
+		TokenURLExternal: externalaccount.DefaultSTSURL,
+		CredentialSource: externalaccount.CredentialSource{File: tokenFile},
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"cloud.google.com/go/compute/metadata"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google/internal/externalaccount"
+	"golang.org/x/oauth2/internal"
 	"golang.org/x/oauth2/jwt"
 )
 
@@ -130,6 +131,8 @@ type credentialsFile struct {
 	CredentialSource               externalaccount.CredentialSource `json:"credential_source"`
 	QuotaProjectID                 string                           `json:"quota_project_id"`
 	WorkforcePoolUserProject       string                           `json:"workforce_pool_user_project"`
+	UniverseDomain                 string                           `json:"universe_domain"`
+	STSOptionsEncoding             string                           `json:"sts_options_encoding"`
 
 	// Service account impersonation
 	SourceCredentials *credentialsFile `json:"source_credentials"`
@@ -155,9 +158,59 @@ func (f *credentialsFile) jwtConfig(scopes []string, subject string) *jwt.Config
 	return cfg
 }
 
+// externalAccountConfig returns the validated configuration of the
+// external_account credentials f, with the options of params.
+func (f *credentialsFile) externalAccountConfig(params CredentialsParams) (*externalaccount.Config, error) {
+	c := &externalaccount.Config{
+		Audience:                       f.Audience,
+		AudienceVariables:              params.AudienceVariables,
+		SubjectTokenType:               f.SubjectTokenType,
+		TokenURL:                       f.TokenURLExternal,
+		TokenInfoURL:                   f.TokenInfoURL,
+		ServiceAccountImpersonationURL: f.ServiceAccountImpersonationURL,
+		ServiceAccountImpersonationLifetimeSeconds:    f.ServiceAccountImpersonation.TokenLifetimeSeconds,
+		ServiceAccountImpersonationMinLifetimeSeconds: int(params.ImpersonationMinLifetime / time.Second),
+		IDTokenAudience:          params.IDTokenAudience,
+		ClientSecret:             f.ClientSecret,
+		ClientID:                 f.ClientID,
+		CredentialSource:         f.CredentialSource,
+		QuotaProjectID:           f.QuotaProjectID,
+		Scopes:                   params.Scopes,
+		WorkforcePoolUserProject: f.WorkforcePoolUserProject,
+		MetricsProducts:          params.MetricsProducts,
+		HTTPClient:               params.HTTPClient,
+		SubjectTokenReuseMargin:  params.SubjectTokenReuseMargin,
+		WatchSubjectTokenFile:    params.WatchSubjectTokenFile,
+		Interactive:              params.Interactive,
+		UniverseDomain:           f.UniverseDomain,
+		RefreshJitter:            params.TokenRefreshJitter,
+		BackgroundRefreshWindow:  params.BackgroundRefreshWindow,
+		OptionsEncoding:          externalaccount.OptionsEncoding(f.STSOptionsEncoding),
+		SubjectTokenTLSConfig:    params.SubjectTokenTLSConfig,
+		ScopeDowngrade:           params.ScopeDowngrade,
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
 func (f *credentialsFile) tokenSource(ctx context.Context, params CredentialsParams) (oauth2.TokenSource, error) {
 	switch f.Type {
 	case serviceAccountKey:
+		if params.ServiceAccountFlow == ServiceAccountFlowSelfSignedJWT {
+			if params.Subject != "" {
+				return nil, errors.New("self-signed JWTs cannot impersonate a subject")
+			}
+			if len(params.Scopes) == 0 {
+				return nil, errors.New("self-signed JWTs require scopes")
+			}
+			ts, err := newJWTAccessTokenSource(f.ClientEmail, []byte(f.PrivateKey), f.PrivateKeyID, "", params.Scopes)
+			if err != nil {
+				return nil, err
+			}
+			return oauth2.ReuseTokenSource(nil, ts), nil
+		}
 		cfg := f.jwtConfig(params.Scopes, params.Subject)
 		return cfg.TokenSource(ctx), nil
 	case userCredentialsKey:
@@ -184,36 +237,31 @@ func (f *credentialsFile) tokenSource(ctx context.Context, params CredentialsPar
 		tok := &oauth2.Token{RefreshToken: f.RefreshToken}
 		return cfg.TokenSource(ctx, tok), nil
 	case externalAccountKey:
-		cfg := &externalaccount.Config{
-			Audience:                       f.Audience,
-			SubjectTokenType:               f.SubjectTokenType,
-			TokenURL:                       f.TokenURLExternal,
-			TokenInfoURL:                   f.TokenInfoURL,
-			ServiceAccountImpersonationURL: f.ServiceAccountImpersonationURL,
-			ServiceAccountImpersonationLifetimeSeconds: f.ServiceAccountImpersonation.TokenLifetimeSeconds,
-			ClientSecret:             f.ClientSecret,
-			ClientID:                 f.ClientID,
-			CredentialSource:         f.CredentialSource,
-			QuotaProjectID:           f.QuotaProjectID,
-			Scopes:                   params.Scopes,
-			WorkforcePoolUserProject: f.WorkforcePoolUserProject,
+		c, err := f.externalAccountConfig(params)
+		if err != nil {
+			return nil, err
 		}
-		return cfg.TokenSource(ctx)
+		return c.TokenSource(ctx)
 	case impersonatedServiceAccount:
 		if f.ServiceAccountImpersonationURL == "" || f.SourceCredentials == nil {
 			return nil, errors.New("missing 'source_credentials' field or 'service_account_impersonation_url' in credentials")
 		}
 
-		ts, err := f.SourceCredentials.tokenSource(ctx, params)
+		sourceParams := params
+		if params.ImpersonationScope {
+			sourceParams.Scopes = withScope(params.Scopes, ScopeCloudPlatform)
+		}
+		ts, err := f.SourceCredentials.tokenSource(ctx, sourceParams)
 		if err != nil {
 			return nil, err
 		}
 		imp := externalaccount.ImpersonateTokenSource{
-			Ctx:       ctx,
-			URL:       f.ServiceAccountImpersonationURL,
-			Scopes:    params.Scopes,
-			Ts:        ts,
-			Delegates: f.Delegates,
+			Ctx:            ctx,
+			URL:            f.ServiceAccountImpersonationURL,
+			Scopes:         params.Scopes,
+			Ts:             ts,
+			Delegates:      f.Delegates,
+			QuotaProjectID: f.QuotaProjectID,
 		}
 		return oauth2.ReuseTokenSource(nil, imp), nil
 	case "":
@@ -231,11 +279,11 @@ func (f *credentialsFile) tokenSource(ctx context.Context, params CredentialsPar
 // Further information about retrieving access tokens from the GCE metadata
 // server can be found at https://cloud.google.com/compute/docs/authentication.
 func ComputeTokenSource(account string, scope ...string) oauth2.TokenSource {
-	return computeTokenSource(account, 0, scope...)
+	return computeTokenSource(account, 0, 0, scope...)
 }
 
-func computeTokenSource(account string, earlyExpiry time.Duration, scope ...string) oauth2.TokenSource {
-	return oauth2.ReuseTokenSourceWithExpiry(nil, computeSource{account: account, scopes: scope}, earlyExpiry)
+func computeTokenSource(account string, earlyExpiry, jitter time.Duration, scope ...string) oauth2.TokenSource {
+	return oauth2.ReuseTokenSourceWithJitter(nil, computeSource{account: account, scopes: scope}, earlyExpiry, jitter)
 }
 
 type computeSource struct {
@@ -243,6 +291,11 @@ type computeSource struct {
 	scopes  []string
 }
 
+// DescribeTokenSource describes cs, see oauth2.DescribeTokenSource.
+func (cs computeSource) DescribeTokenSource() (string, oauth2.TokenSource) {
+	return internal.DescribeParams("compute_metadata", "account", cs.account, "scopes", strings.Join(cs.scopes, ",")), nil
+}
+
 func (cs computeSource) Token() (*oauth2.Token, error) {
 	if !metadata.OnGCE() {
 		return nil, errors.New("oauth2/google: can't get a token from the metadata service; not running on GCE")
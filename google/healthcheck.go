@@ -0,0 +1,77 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package google
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"golang.org/x/oauth2/google/internal/externalaccount"
+)
+
+// HealthCheckStage identifies a step of the external account token flow.
+type HealthCheckStage string
+
+const (
+	// HealthCheckCredentialSource is the retrieval of the subject token from
+	// the credential source.
+	HealthCheckCredentialSource HealthCheckStage = HealthCheckStage(externalaccount.StageCredentialSource)
+	// HealthCheckTokenExchange is the exchange of the subject token with the
+	// Security Token Service.
+	HealthCheckTokenExchange HealthCheckStage = HealthCheckStage(externalaccount.StageTokenExchange)
+	// HealthCheckImpersonation is the service account impersonation.
+	HealthCheckImpersonation HealthCheckStage = HealthCheckStage(externalaccount.StageImpersonation)
+)
+
+// HealthCheckError is returned by HealthCheckExternalAccount when a step of
+// the token flow fails.
+type HealthCheckError struct {
+	// Stage is the step that failed.
+	Stage HealthCheckStage
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *HealthCheckError) Error() string {
+	return fmt.Sprintf("oauth2/google: external account health check failed during %s: %v", e.Stage, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *HealthCheckError) Unwrap() error {
+	return e.Err
+}
+
+// HealthCheckOptions configures HealthCheckExternalAccount.
+type HealthCheckOptions struct {
+	// SkipImpersonation stops the check after the token exchange even when
+	// service account impersonation is configured.
+	SkipImpersonation bool
+}
+
+// HealthCheckExternalAccount verifies that the external_account credentials
+// in jsonData work end to end: the credential source is reachable, the
+// security token service accepts its subject token and, unless
+// opts.SkipImpersonation is true, the service account can be impersonated.
+// opts may be nil. No tokens are cached, so every call contacts all
+// endpoints. A failing step is reported as a *HealthCheckError naming it.
+//
+// It is meant for startup probes of workloads that must fail fast when
+// workload or workforce identity federation is misconfigured.
+func HealthCheckExternalAccount(ctx context.Context, jsonData []byte, params CredentialsParams, opts *HealthCheckOptions) error {
+	config, err := externalAccountFromJSON(jsonData, params, "health check")
+	if err != nil {
+		return err
+	}
+	if opts == nil {
+		opts = &HealthCheckOptions{}
+	}
+	err = config.HealthCheck(ctx, opts.SkipImpersonation)
+	var hcErr *externalaccount.HealthCheckError
+	if errors.As(err, &hcErr) {
+		return &HealthCheckError{Stage: HealthCheckStage(hcErr.Stage), Err: hcErr.Err}
+	}
+	return err
+}
@@ -0,0 +1,45 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package google
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHealthCheckExternalAccount(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	jsonData := []byte(fmt.Sprintf(`{
+		"type": "external_account",
+		"audience": "//iam.googleapis.com/projects/123/locations/global/workloadIdentityPools/pool/providers/provider",
+		"subject_token_type": "urn:ietf:params:oauth:token-type:jwt",
+		"token_url": %q,
+		"credential_source": {"file": "internal/externalaccount/testdata/3pi_cred.txt"}
+	}`, server.URL))
+	err := HealthCheckExternalAccount(context.Background(), jsonData, CredentialsParams{}, nil)
+	var hcErr *HealthCheckError
+	if !errors.As(err, &hcErr) {
+		t.Fatalf("got error %v but want a *HealthCheckError", err)
+	}
+	if got, want := hcErr.Stage, HealthCheckTokenExchange; got != want {
+		t.Errorf("got stage %v but want %v", got, want)
+	}
+	if !strings.Contains(err.Error(), "token exchange") {
+		t.Errorf("got %v but want a token exchange failure", err)
+	}
+
+	if err := HealthCheckExternalAccount(context.Background(), jwtJSONKey, CredentialsParams{}, &HealthCheckOptions{SkipImpersonation: true}); err == nil {
+		t.Error("HealthCheckExternalAccount() accepted service account credentials")
+	}
+}
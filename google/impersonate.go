@@ -0,0 +1,146 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package google
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google/internal/externalaccount"
+)
+
+const defaultIAMCredentialsURL = "https://iamcredentials.googleapis.com/v1/"
+
+// maxImpersonationLifetime is the longest lifetime the IAM Credentials API
+// accepts for impersonated access tokens.
+const maxImpersonationLifetime = 12 * time.Hour
+
+// ImpersonateConfig describes the service account to impersonate and the
+// tokens to request on its behalf.
+type ImpersonateConfig struct {
+	// TargetPrincipal is the email address of the service account to
+	// impersonate. Required.
+	TargetPrincipal string
+	// Scopes are the scopes of the impersonated access token. Required
+	// unless IDTokenAudience is set.
+	Scopes []string
+	// Delegates are the service account email addresses in a delegation
+	// chain. Each service account must be granted
+	// roles/iam.serviceAccountTokenCreator on the next service account in the
+	// chain. Optional.
+	Delegates []string
+	// Lifetime is how long the impersonated access token is valid for. The
+	// default is one hour; lifetimes over one hour require the
+	// iam.allowServiceAccountCredentialLifetimeExtension organization policy.
+	// Optional.
+	Lifetime time.Duration
+	// IDTokenAudience, when set, makes the token source return Google-signed
+	// ID tokens for this audience instead of access tokens. The ID token is
+	// returned in the AccessToken field of the token. Optional.
+	IDTokenAudience string
+	// IncludeEmail adds the email and email_verified claims of the service
+	// account to ID tokens. Requires IDTokenAudience. Optional.
+	IncludeEmail bool
+	// IncludeOrganizationNumber adds the number of the organization the
+	// service account belongs to to ID tokens, in the
+	// google.organization_number claim. Requires IDTokenAudience. Optional.
+	IncludeOrganizationNumber bool
+	// QuotaProjectID is the project billed for the impersonation requests.
+	// Optional.
+	QuotaProjectID string
+	// Endpoint is the base URL of the IAM Credentials API. The default is
+	// https://iamcredentials.googleapis.com/v1/. Optional.
+	Endpoint string
+	// Cache optionally shares impersonated tokens with the other token
+	// sources using the same cache, which are only served a cached token if
+	// they request it with the same configuration. A cache must only be
+	// shared between token sources whose base represents the same principal.
+	// Optional.
+	Cache *ImpersonationCache
+}
+
+// ImpersonationCache holds impersonated tokens shared between several
+// impersonating token sources. It is safe for concurrent use.
+type ImpersonationCache struct {
+	c *externalaccount.ImpersonationCache
+}
+
+// NewImpersonationCache returns an empty ImpersonationCache.
+func NewImpersonationCache() *ImpersonationCache {
+	return &ImpersonationCache{c: externalaccount.NewImpersonationCache()}
+}
+
+// ImpersonationCacheStats reports the usage of an ImpersonationCache.
+type ImpersonationCacheStats struct {
+	// Hits is the number of tokens served from the cache.
+	Hits uint64
+	// Misses is the number of tokens that had to be requested.
+	Misses uint64
+	// Size is the number of tokens currently held, including expired ones
+	// that have not been replaced yet.
+	Size int
+}
+
+// Stats returns a snapshot of the cache's usage.
+func (c *ImpersonationCache) Stats() ImpersonationCacheStats {
+	s := c.c.Stats()
+	return ImpersonationCacheStats{Hits: s.Hits, Misses: s.Misses, Size: s.Size}
+}
+
+// ImpersonateTokenSource returns a TokenSource that impersonates the service
+// account described by config, authenticating with the tokens of base. base
+// can be any TokenSource whose principal has been granted
+// roles/iam.serviceAccountTokenCreator on the target service account.
+//
+// The returned TokenSource caches tokens until they expire.
+func ImpersonateTokenSource(ctx context.Context, base oauth2.TokenSource, config ImpersonateConfig) (oauth2.TokenSource, error) {
+	if base == nil {
+		return nil, errors.New("oauth2/google: a base TokenSource is required for impersonation")
+	}
+	if config.TargetPrincipal == "" {
+		return nil, errors.New("oauth2/google: missing impersonation target principal")
+	}
+	if config.IDTokenAudience == "" && len(config.Scopes) == 0 {
+		return nil, errors.New("oauth2/google: scopes are required to impersonate an access token")
+	}
+	if config.IDTokenAudience == "" && (config.IncludeEmail || config.IncludeOrganizationNumber) {
+		return nil, errors.New("oauth2/google: IncludeEmail and IncludeOrganizationNumber require an ID token audience")
+	}
+	if config.IDTokenAudience != "" && config.Lifetime != 0 {
+		return nil, errors.New("oauth2/google: the lifetime of impersonated ID tokens cannot be set")
+	}
+	if config.Lifetime < 0 || config.Lifetime > maxImpersonationLifetime {
+		return nil, fmt.Errorf("oauth2/google: impersonation lifetime must be between 0 and %v", maxImpersonationLifetime)
+	}
+
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = defaultIAMCredentialsURL
+	}
+	method := "generateAccessToken"
+	if config.IDTokenAudience != "" {
+		method = "generateIdToken"
+	}
+	imp := externalaccount.ImpersonateTokenSource{
+		Ctx:                       ctx,
+		Ts:                        base,
+		URL:                       fmt.Sprintf("%s/projects/-/serviceAccounts/%s:%s", strings.TrimSuffix(endpoint, "/"), config.TargetPrincipal, method),
+		Scopes:                    config.Scopes,
+		Delegates:                 config.Delegates,
+		TokenLifetimeSeconds:      int(config.Lifetime / time.Second),
+		IDTokenAudience:           config.IDTokenAudience,
+		IncludeEmail:              config.IncludeEmail,
+		IncludeOrganizationNumber: config.IncludeOrganizationNumber,
+		QuotaProjectID:            config.QuotaProjectID,
+	}
+	if config.Cache != nil {
+		imp.Cache = config.Cache.c
+	}
+	return oauth2.ReuseTokenSource(nil, imp), nil
+}
@@ -0,0 +1,151 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package google
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestImpersonateTokenSource(t *testing.T) {
+	expiry := time.Now().Add(time.Hour).Truncate(time.Second)
+	idToken := "header." + base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d}`, expiry.Unix()))) + ".sig"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("Authorization"), "Bearer base"; got != want {
+			t.Errorf("got %v but want %v", got, want)
+		}
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("unable to decode request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/projects/-/serviceAccounts/sa@project.iam.gserviceaccount.com:generateAccessToken":
+			if got, want := body["lifetime"], "600s"; got != want {
+				t.Errorf("got %v but want %v", got, want)
+			}
+			fmt.Fprintf(w, `{"accessToken":"impersonated","expireTime":%q}`, expiry.UTC().Format(time.RFC3339))
+		case "/projects/-/serviceAccounts/sa@project.iam.gserviceaccount.com:generateIdToken":
+			if got, want := body["audience"], "https://service.example.com"; got != want {
+				t.Errorf("got %v but want %v", got, want)
+			}
+			if got, want := body["includeEmail"], true; got != want {
+				t.Errorf("got includeEmail %v but want %v", got, want)
+			}
+			fmt.Fprintf(w, `{"token":%q}`, idToken)
+		default:
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	base := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "base"})
+	tests := []struct {
+		name   string
+		config ImpersonateConfig
+		want   string
+	}{
+		{
+			name: "access token",
+			config: ImpersonateConfig{
+				TargetPrincipal: "sa@project.iam.gserviceaccount.com",
+				Scopes:          []string{"https://www.googleapis.com/auth/cloud-platform"},
+				Lifetime:        10 * time.Minute,
+				Endpoint:        server.URL,
+			},
+			want: "impersonated",
+		},
+		{
+			name: "ID token",
+			config: ImpersonateConfig{
+				TargetPrincipal: "sa@project.iam.gserviceaccount.com",
+				IDTokenAudience: "https://service.example.com",
+				IncludeEmail:    true,
+				Endpoint:        server.URL + "/",
+			},
+			want: idToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, err := ImpersonateTokenSource(context.Background(), base, tt.config)
+			if err != nil {
+				t.Fatalf("ImpersonateTokenSource() failed: %v", err)
+			}
+			tok, err := ts.Token()
+			if err != nil {
+				t.Fatalf("Token() failed: %v", err)
+			}
+			if got := tok.AccessToken; got != tt.want {
+				t.Errorf("got %v but want %v", got, tt.want)
+			}
+			if !tok.Expiry.Equal(expiry) {
+				t.Errorf("got expiry %v but want %v", tok.Expiry, expiry)
+			}
+		})
+	}
+}
+
+func TestImpersonateTokenSource_Cache(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"accessToken":"impersonated","expireTime":%q}`, time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	}))
+	defer server.Close()
+
+	base := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "base"})
+	cache := NewImpersonationCache()
+	config := ImpersonateConfig{
+		TargetPrincipal: "sa@project.iam.gserviceaccount.com",
+		Scopes:          []string{"https://www.googleapis.com/auth/cloud-platform"},
+		Endpoint:        server.URL,
+		Cache:           cache,
+	}
+	for i := 0; i < 2; i++ {
+		ts, err := ImpersonateTokenSource(context.Background(), base, config)
+		if err != nil {
+			t.Fatalf("ImpersonateTokenSource() failed: %v", err)
+		}
+		if _, err := ts.Token(); err != nil {
+			t.Fatalf("Token() failed: %v", err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("got %d impersonation requests but want 1", requests)
+	}
+	if got, want := cache.Stats(), (ImpersonationCacheStats{Hits: 1, Misses: 1, Size: 1}); got != want {
+		t.Errorf("got stats %+v but want %+v", got, want)
+	}
+}
+
+func TestImpersonateTokenSource_InvalidConfig(t *testing.T) {
+	base := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "base"})
+	configs := []ImpersonateConfig{
+		{Scopes: []string{"scope"}},
+		{TargetPrincipal: "sa@project.iam.gserviceaccount.com"},
+		{TargetPrincipal: "sa@project.iam.gserviceaccount.com", Scopes: []string{"scope"}, Lifetime: 13 * time.Hour},
+		{TargetPrincipal: "sa@project.iam.gserviceaccount.com", Scopes: []string{"scope"}, IncludeEmail: true},
+		{TargetPrincipal: "sa@project.iam.gserviceaccount.com", IDTokenAudience: "aud", Lifetime: time.Hour},
+	}
+	for _, config := range configs {
+		if _, err := ImpersonateTokenSource(context.Background(), base, config); err == nil {
+			t.Errorf("ImpersonateTokenSource(%+v) succeeded, want error", config)
+		}
+	}
+	if _, err := ImpersonateTokenSource(context.Background(), nil, ImpersonateConfig{TargetPrincipal: "sa", Scopes: []string{"scope"}}); err == nil {
+		t.Error("ImpersonateTokenSource() with nil base succeeded, want error")
+	}
+}
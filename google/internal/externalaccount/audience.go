@@ -0,0 +1,95 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package externalaccount
+
+import (
+	"fmt"
+	"strings"
+)
+
+// expandAudience replaces the ${NAME} placeholders of audience with the
+// value of NAME in vars or, if absent there, in the environment. This lets
+// one configuration file name the workload identity pool provider of every
+// environment, e.g.
+//
+//	//iam.googleapis.com/projects/${PROJECT_NUMBER}/locations/global/workloadIdentityPools/${POOL_ID}/providers/${PROVIDER_ID}
+//
+// Undefined or empty variables are reported as errors rather than producing
+// an audience the security token service would reject.
+func expandAudience(audience string, vars map[string]string) (string, error) {
+	return expandVariables(audience, "audience", vars, nil)
+}
+
+// expandVariables replaces the ${NAME} placeholders of s, the what of the
+// configuration, as expandAudience does. If escape is non-nil, the values
+// are escaped with it before they are inserted.
+func expandVariables(s, what string, vars map[string]string, escape func(string) string) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+	var b strings.Builder
+	rest := s
+	for {
+		i := strings.Index(rest, "${")
+		if i < 0 {
+			b.WriteString(rest)
+			return b.String(), nil
+		}
+		b.WriteString(rest[:i])
+		rest = rest[i+2:]
+		j := strings.IndexByte(rest, '}')
+		if j < 0 {
+			return "", fmt.Errorf("oauth2/google: unterminated variable in %s %q", what, s)
+		}
+		name := rest[:j]
+		if !validVariableName(name) {
+			return "", fmt.Errorf("oauth2/google: invalid variable name %q in %s %q", name, what, s)
+		}
+		value, ok := vars[name]
+		if !ok {
+			value = getenv(name)
+		}
+		if value == "" {
+			return "", fmt.Errorf("oauth2/google: %s variable %q is not set", what, name)
+		}
+		if escape != nil {
+			value = escape(value)
+		}
+		b.WriteString(value)
+		rest = rest[j+1:]
+	}
+}
+
+// validVariableName reports whether name is a valid environment variable
+// name: letters, digits and underscores, not starting with a digit.
+func validVariableName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_', 'A' <= r && r <= 'Z', 'a' <= r && r <= 'z':
+		case '0' <= r && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// resolve returns c with the placeholders of its audience expanded, or c
+// itself if there are none.
+func (c *Config) resolve() (*Config, error) {
+	audience, err := expandAudience(c.Audience, c.AudienceVariables)
+	if err != nil {
+		return nil, err
+	}
+	if audience == c.Audience {
+		return c, nil
+	}
+	resolved := *c
+	resolved.Audience = audience
+	return &resolved, nil
+}
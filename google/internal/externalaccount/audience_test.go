@@ -0,0 +1,123 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package externalaccount
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const templatedAudience = "//iam.googleapis.com/projects/${PROJECT_NUMBER}/locations/global/workloadIdentityPools/${POOL_ID}/providers/${PROVIDER_ID}"
+
+func TestExpandAudience(t *testing.T) {
+	oldGetenv := getenv
+	defer func() { getenv = oldGetenv }()
+	getenv = setEnvironment(map[string]string{
+		"PROJECT_NUMBER": "123",
+		"POOL_ID":        "env-pool",
+		"PROVIDER_ID":    "env-provider",
+	})
+
+	tests := []struct {
+		name     string
+		audience string
+		vars     map[string]string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "no placeholders",
+			audience: "//iam.googleapis.com/locations/global/workforcePools/pool/providers/provider",
+			want:     "//iam.googleapis.com/locations/global/workforcePools/pool/providers/provider",
+		},
+		{
+			name:     "environment",
+			audience: templatedAudience,
+			want:     "//iam.googleapis.com/projects/123/locations/global/workloadIdentityPools/env-pool/providers/env-provider",
+		},
+		{
+			name:     "variables override environment",
+			audience: templatedAudience,
+			vars:     map[string]string{"POOL_ID": "prod-pool"},
+			want:     "//iam.googleapis.com/projects/123/locations/global/workloadIdentityPools/prod-pool/providers/env-provider",
+		},
+		{
+			name:     "undefined variable",
+			audience: "//iam.googleapis.com/projects/${MISSING}/locations/global",
+			wantErr:  true,
+		},
+		{
+			name:     "empty variable",
+			audience: templatedAudience,
+			vars:     map[string]string{"POOL_ID": ""},
+			wantErr:  true,
+		},
+		{
+			name:     "unterminated placeholder",
+			audience: "//iam.googleapis.com/projects/${PROJECT_NUMBER",
+			wantErr:  true,
+		},
+		{
+			name:     "invalid name",
+			audience: "//iam.googleapis.com/projects/${1PROJECT}",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandAudience(tt.audience, tt.vars)
+			if gotErr := err != nil; gotErr != tt.wantErr {
+				t.Fatalf("expandAudience() returned error %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %v but want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToken_TemplatedAudience(t *testing.T) {
+	var gotAudience string
+	config := Config{
+		Audience:             templatedAudience,
+		AudienceVariables:    map[string]string{"PROJECT_NUMBER": "123", "POOL_ID": "pool", "PROVIDER_ID": "provider"},
+		SubjectTokenType:     "urn:ietf:params:oauth:token-type:jwt",
+		TokenURL:             "https://sts.example.invalid/v1/token",
+		SubjectTokenSupplier: testSubjectTokenSupplier{subjectToken: "subjectToken"},
+		HTTPClient: &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			body, err := ioutil.ReadAll(req.Body)
+			if err != nil {
+				return nil, err
+			}
+			form, err := url.ParseQuery(string(body))
+			if err != nil {
+				return nil, err
+			}
+			gotAudience = form.Get("audience")
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       ioutil.NopCloser(strings.NewReader(`{"access_token":"Sample.Access.Token","token_type":"Bearer","expires_in":3600}`)),
+			}, nil
+		})},
+	}
+	ts, err := config.TokenSource(context.Background())
+	if err != nil {
+		t.Fatalf("TokenSource() failed: %v", err)
+	}
+	if _, err := ts.Token(); err != nil {
+		t.Fatalf("Token() failed: %v", err)
+	}
+	if want := "//iam.googleapis.com/projects/123/locations/global/workloadIdentityPools/pool/providers/provider"; gotAudience != want {
+		t.Errorf("got audience %v but want %v", gotAudience, want)
+	}
+	if config.Audience != templatedAudience {
+		t.Errorf("config audience modified to %v", config.Audience)
+	}
+}
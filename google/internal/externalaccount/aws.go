@@ -30,6 +30,8 @@ type awsSecurityCredentials struct {
 	AccessKeyID     string `json:"AccessKeyID"`
 	SecretAccessKey string `json:"SecretAccessKey"`
 	SecurityToken   string `json:"Token"`
+	// Expiration is when temporary credentials expire, in RFC 3339 format.
+	Expiration string `json:"Expiration"`
 }
 
 // awsRequestSigner is a utility class to sign http requests using a AWS V4 signature.
@@ -62,6 +64,10 @@ const (
 	// The AWS authorization header name for the auto-generated date.
 	awsDateHeader = "x-amz-date"
 
+	// The names of the signed headers carrying the optional session metadata.
+	awsSessionNameHeader = "x-goog-aws-session-name"
+	awsSessionTagsHeader = "x-goog-aws-session-tags"
+
 	// Supported AWS configuration environment variables.
 	awsAccessKeyId     = "AWS_ACCESS_KEY_ID"
 	awsDefaultRegion   = "AWS_DEFAULT_REGION"
@@ -254,10 +260,18 @@ type awsCredentialSource struct {
 	EnvironmentID               string
 	RegionURL                   string
 	RegionalCredVerificationURL string
+	Partition                   string
 	CredVerificationURL         string
 	IMDSv2SessionTokenURL       string
 	TargetResource              string
+	SessionName                 string
+	SessionTags                 map[string]string
+	ExtraSignedHeaders          map[string]string
+	ExtraQueryParams            map[string]string
+	supplier                    AwsSecurityCredentialsSupplier
+	supplierOptions             SupplierOptions
 	requestSigner               *awsRequestSigner
+	cache                       *awsCredentialsCache
 	region                      string
 	ctx                         context.Context
 	client                      *http.Client
@@ -336,10 +350,82 @@ func canRetrieveSecurityCredentialFromEnvironment() bool {
 }
 
 func shouldUseMetadataServer() bool {
-	return !canRetrieveRegionFromEnvironment() || !canRetrieveSecurityCredentialFromEnvironment()
+	return !canRetrieveRegionFromEnvironment() ||
+		!canRetrieveSecurityCredentialFromEnvironment() && !canRetrieveWebIdentityCredentials() && !canRetrieveContainerCredentials()
+}
+
+// defaultRegionalCredentialVerificationURL is the GetCallerIdentity endpoint
+// used without a configured one, {domain} being the DNS suffix of the AWS
+// partition.
+const defaultRegionalCredentialVerificationURL = "https://sts.{region}.{domain}?Action=GetCallerIdentity&Version=2011-06-15"
+
+// awsPartitionDomains maps the AWS partitions to their DNS suffixes.
+var awsPartitionDomains = map[string]string{
+	"aws":        "amazonaws.com",
+	"aws-cn":     "amazonaws.com.cn",
+	"aws-us-gov": "amazonaws.com",
+	"aws-iso":    "c2s.ic.gov",
+	"aws-iso-b":  "sc2s.sgov.gov",
+}
+
+// partitionOfRegion returns the AWS partition of region.
+func partitionOfRegion(region string) string {
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		return "aws-cn"
+	case strings.HasPrefix(region, "us-gov-"):
+		return "aws-us-gov"
+	case strings.HasPrefix(region, "us-isob-"):
+		return "aws-iso-b"
+	case strings.HasPrefix(region, "us-iso-"):
+		return "aws-iso"
+	}
+	return "aws"
+}
+
+// verificationURL returns the GetCallerIdentity endpoint of cs.region.
+func (cs awsCredentialSource) verificationURL() string {
+	if cs.RegionalCredVerificationURL != "" {
+		return strings.Replace(cs.RegionalCredVerificationURL, "{region}", cs.region, 1)
+	}
+	partition := cs.Partition
+	if partition == "" {
+		partition = partitionOfRegion(cs.region)
+	}
+	return strings.NewReplacer("{region}", cs.region, "{domain}", awsPartitionDomains[partition]).Replace(defaultRegionalCredentialVerificationURL)
 }
 
 func (cs awsCredentialSource) subjectToken() (string, error) {
+	if cs.requestSigner == nil && cs.supplier != nil {
+		creds, err := cs.supplier.AwsSecurityCredentials(cs.ctx, cs.supplierOptions)
+		if err != nil {
+			return "", err
+		}
+		if creds == nil || creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+			return "", errors.New("oauth2/google: AWS security credentials supplier returned incomplete credentials")
+		}
+		if cs.region, err = cs.supplier.AwsRegion(cs.ctx, cs.supplierOptions); err != nil {
+			return "", err
+		}
+		if cs.region == "" {
+			return "", errors.New("oauth2/google: AWS security credentials supplier returned an empty region")
+		}
+		cs.requestSigner = &awsRequestSigner{
+			RegionName: cs.region,
+			AwsSecurityCredentials: awsSecurityCredentials{
+				AccessKeyID:     creds.AccessKeyID,
+				SecretAccessKey: creds.SecretAccessKey,
+				SecurityToken:   creds.SessionToken,
+			},
+		}
+	}
+	if creds, region, ok := cs.cache.get(); ok && cs.requestSigner == nil {
+		cs.region = region
+		cs.requestSigner = &awsRequestSigner{
+			RegionName:             region,
+			AwsSecurityCredentials: creds,
+		}
+	}
 	if cs.requestSigner == nil {
 		headers := make(map[string]string)
 		if shouldUseMetadataServer() {
@@ -366,11 +452,12 @@ func (cs awsCredentialSource) subjectToken() (string, error) {
 			RegionName:             cs.region,
 			AwsSecurityCredentials: awsSecurityCredentials,
 		}
+		cs.cache.put(awsSecurityCredentials, cs.region)
 	}
 
 	// Generate the signed request to AWS STS GetCallerIdentity API.
 	// Use the required regional endpoint. Otherwise, the request will fail.
-	req, err := http.NewRequest("POST", strings.Replace(cs.RegionalCredVerificationURL, "{region}", cs.region, 1), nil)
+	req, err := http.NewRequest("POST", cs.verificationURL(), nil)
 	if err != nil {
 		return "", err
 	}
@@ -381,7 +468,12 @@ func (cs awsCredentialSource) subjectToken() (string, error) {
 	if cs.TargetResource != "" {
 		req.Header.Add("x-goog-cloud-target-resource", cs.TargetResource)
 	}
-	cs.requestSigner.SignRequest(req)
+	cs.addSessionHeaders(req)
+	cs.addExtras(req)
+	if err := cs.requestSigner.SignRequest(req); err != nil {
+		cs.cache.invalidate()
+		return "", err
+	}
 
 	/*
 	   The GCP STS endpoint expects the headers to be formatted as:
@@ -425,6 +517,68 @@ func (cs awsCredentialSource) subjectToken() (string, error) {
 	return url.QueryEscape(string(result)), nil
 }
 
+// addSessionHeaders adds the configured session name and tags to req so that
+// they are covered by the request signature.
+func (cs awsCredentialSource) addSessionHeaders(req *http.Request) {
+	if cs.SessionName != "" {
+		req.Header.Add(awsSessionNameHeader, cs.SessionName)
+	}
+	if len(cs.SessionTags) > 0 {
+		tags := url.Values{}
+		for key, value := range cs.SessionTags {
+			tags.Set(key, value)
+		}
+		// Encode sorts by key, which keeps the signed value stable.
+		req.Header.Add(awsSessionTagsHeader, tags.Encode())
+	}
+}
+
+// reservedAWSHeaders are the headers of the GetCallerIdentity request that
+// ExtraSignedHeaders cannot set, in canonical form.
+var reservedAWSHeaders = map[string]bool{
+	"Authorization":                true,
+	"Host":                         true,
+	"X-Amz-Date":                   true,
+	"X-Amz-Security-Token":         true,
+	"X-Goog-Cloud-Target-Resource": true,
+	"X-Goog-Aws-Session-Name":      true,
+	"X-Goog-Aws-Session-Tags":      true,
+}
+
+// validateExtras reports headers and query parameters that would override
+// the ones the GetCallerIdentity request depends on, and unknown partitions.
+func (cs awsCredentialSource) validateExtras() error {
+	if _, ok := awsPartitionDomains[cs.Partition]; cs.Partition != "" && !ok {
+		return fmt.Errorf("oauth2/google: unknown AWS partition %q", cs.Partition)
+	}
+	for key := range cs.ExtraSignedHeaders {
+		if reservedAWSHeaders[http.CanonicalHeaderKey(key)] {
+			return fmt.Errorf("oauth2/google: extra signed header %q is reserved", key)
+		}
+	}
+	for key := range cs.ExtraQueryParams {
+		if key == "Action" {
+			return fmt.Errorf("oauth2/google: extra query parameter %q is reserved", key)
+		}
+	}
+	return nil
+}
+
+// addExtras adds the configured extra headers and query parameters to req
+// so that they are covered by the request signature.
+func (cs awsCredentialSource) addExtras(req *http.Request) {
+	for key, value := range cs.ExtraSignedHeaders {
+		req.Header.Set(key, value)
+	}
+	if len(cs.ExtraQueryParams) > 0 {
+		query := req.URL.Query()
+		for key, value := range cs.ExtraQueryParams {
+			query.Set(key, value)
+		}
+		req.URL.RawQuery = query.Encode()
+	}
+}
+
 func (cs *awsCredentialSource) getAWSSessionToken() (string, error) {
 	if cs.IMDSv2SessionTokenURL == "" {
 		return "", nil
@@ -509,12 +663,18 @@ func (cs *awsCredentialSource) getSecurityCredentials(headers map[string]string)
 		}, nil
 	}
 
-	roleName, err := cs.getMetadataRoleName(headers)
-	if err != nil {
-		return
+	var credentials awsSecurityCredentials
+	switch {
+	case canRetrieveWebIdentityCredentials():
+		credentials, err = cs.getWebIdentitySecurityCredentials()
+	case canRetrieveContainerCredentials():
+		credentials, err = cs.getContainerSecurityCredentials()
+	default:
+		var roleName string
+		if roleName, err = cs.getMetadataRoleName(headers); err == nil {
+			credentials, err = cs.getMetadataSecurityCredentials(roleName, headers)
+		}
 	}
-
-	credentials, err := cs.getMetadataSecurityCredentials(roleName, headers)
 	if err != nil {
 		return
 	}
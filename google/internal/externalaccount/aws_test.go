@@ -1358,6 +1358,126 @@ func TestAWSCredential_ShouldCallMetadataEndpointWhenNoSecretAccessKey(t *testin
 	}
 }
 
+func decodeAwsSubjectToken(t *testing.T, subjectToken string) awsRequest {
+	t.Helper()
+	unescaped, err := neturl.QueryUnescape(subjectToken)
+	if err != nil {
+		t.Fatalf("QueryUnescape() failed: %v", err)
+	}
+	var req awsRequest
+	if err := json.Unmarshal([]byte(unescaped), &req); err != nil {
+		t.Fatalf("json.Unmarshal() failed: %v", err)
+	}
+	return req
+}
+
+func TestAWSCredential_SessionMetadata(t *testing.T) {
+	tfc := testFileConfig
+	tfc.CredentialSource = CredentialSource{
+		EnvironmentID:               "aws1",
+		RegionalCredVerificationURL: "https://sts.{region}.amazonaws.com?Action=GetCallerIdentity&Version=2011-06-15",
+		SessionName:                 "build-agent",
+		SessionTags: map[string]string{
+			"team": "infra",
+			"env":  "prod",
+		},
+	}
+
+	oldGetenv := getenv
+	oldNow := now
+	defer func() {
+		getenv = oldGetenv
+		now = oldNow
+	}()
+	getenv = setEnvironment(map[string]string{
+		"AWS_ACCESS_KEY_ID":     "AKIDEXAMPLE",
+		"AWS_SECRET_ACCESS_KEY": "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		"AWS_REGION":            "us-west-1",
+	})
+	now = setTime(defaultTime)
+
+	base, err := tfc.parse(context.Background())
+	if err != nil {
+		t.Fatalf("parse() failed %v", err)
+	}
+
+	out, err := base.subjectToken()
+	if err != nil {
+		t.Fatalf("retrieveSubjectToken() failed: %v", err)
+	}
+
+	headers := make(map[string]string)
+	for _, header := range decodeAwsSubjectToken(t, out).Headers {
+		headers[header.Key] = header.Value
+	}
+	if got, want := headers["X-Goog-Aws-Session-Name"], "build-agent"; got != want {
+		t.Errorf("session name header = %q, want %q", got, want)
+	}
+	if got, want := headers["X-Goog-Aws-Session-Tags"], "env=prod&team=infra"; got != want {
+		t.Errorf("session tags header = %q, want %q", got, want)
+	}
+	if got, want := headers["Authorization"], "x-goog-aws-session-name;x-goog-aws-session-tags"; !strings.Contains(got, want) {
+		t.Errorf("Authorization = %q, want signed headers to contain %q", got, want)
+	}
+}
+
+func TestAWSCredential_ExtraSignedHeadersAndQueryParams(t *testing.T) {
+	tfc := testFileConfig
+	tfc.CredentialSource = CredentialSource{
+		EnvironmentID:               "aws1",
+		RegionalCredVerificationURL: "https://sts.{region}.amazonaws.com?Action=GetCallerIdentity&Version=2011-06-15",
+		ExtraSignedHeaders:          map[string]string{"x-example-pin": "v2"},
+		ExtraQueryParams:            map[string]string{"Version": "2020-01-01"},
+	}
+
+	oldGetenv := getenv
+	oldNow := now
+	defer func() {
+		getenv = oldGetenv
+		now = oldNow
+	}()
+	getenv = setEnvironment(map[string]string{
+		"AWS_ACCESS_KEY_ID":     "AKIDEXAMPLE",
+		"AWS_SECRET_ACCESS_KEY": "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		"AWS_REGION":            "us-west-1",
+	})
+	now = setTime(defaultTime)
+
+	base, err := tfc.parse(context.Background())
+	if err != nil {
+		t.Fatalf("parse() failed %v", err)
+	}
+	out, err := base.subjectToken()
+	if err != nil {
+		t.Fatalf("retrieveSubjectToken() failed: %v", err)
+	}
+
+	req := decodeAwsSubjectToken(t, out)
+	if got, want := req.URL, "https://sts.us-west-1.amazonaws.com?Action=GetCallerIdentity&Version=2020-01-01"; got != want {
+		t.Errorf("got URL %v but want %v", got, want)
+	}
+	headers := make(map[string]string)
+	for _, header := range req.Headers {
+		headers[header.Key] = header.Value
+	}
+	if got, want := headers["X-Example-Pin"], "v2"; got != want {
+		t.Errorf("got header %v but want %v", got, want)
+	}
+	if got, want := headers["Authorization"], "x-example-pin"; !strings.Contains(got, want) {
+		t.Errorf("Authorization = %q, want signed headers to contain %q", got, want)
+	}
+
+	for _, cs := range []CredentialSource{
+		{EnvironmentID: "aws1", ExtraSignedHeaders: map[string]string{"x-amz-date": "now"}},
+		{EnvironmentID: "aws1", ExtraQueryParams: map[string]string{"Action": "AssumeRole"}},
+	} {
+		tfc.CredentialSource = cs
+		if _, err := tfc.parse(context.Background()); err == nil {
+			t.Errorf("parse() with %+v succeeded, want error", cs)
+		}
+	}
+}
+
 func TestAWSCredential_Validations(t *testing.T) {
 	var metadataServerValidityTests = []struct {
 		name       string
@@ -1442,3 +1562,101 @@ func TestAWSCredential_Validations(t *testing.T) {
 		})
 	}
 }
+
+type testAwsSupplier struct {
+	region string
+	creds  *AwsSecurityCredentials
+}
+
+func (s testAwsSupplier) AwsRegion(ctx context.Context, options SupplierOptions) (string, error) {
+	return s.region, nil
+}
+
+func (s testAwsSupplier) AwsSecurityCredentials(ctx context.Context, options SupplierOptions) (*AwsSecurityCredentials, error) {
+	return s.creds, nil
+}
+
+func TestAWSCredential_Supplier(t *testing.T) {
+	tfc := testFileConfig
+	tfc.CredentialSource = CredentialSource{}
+	tfc.AwsSecurityCredentialsSupplier = testAwsSupplier{
+		region: "us-east-2",
+		creds:  &AwsSecurityCredentials{AccessKeyID: accessKeyID, SecretAccessKey: secretAccessKey, SessionToken: securityToken},
+	}
+
+	oldNow := now
+	defer func() { now = oldNow }()
+	now = setTime(defaultTime)
+
+	base, err := tfc.parse(context.Background())
+	if err != nil {
+		t.Fatalf("parse() failed %v", err)
+	}
+	out, err := base.subjectToken()
+	if err != nil {
+		t.Fatalf("retrieveSubjectToken() failed: %v", err)
+	}
+	expected := getExpectedSubjectToken(
+		"https://sts.us-east-2.amazonaws.com?Action=GetCallerIdentity&Version=2011-06-15",
+		"us-east-2",
+		accessKeyID,
+		secretAccessKey,
+		securityToken,
+	)
+	if got, want := out, expected; !reflect.DeepEqual(got, want) {
+		t.Errorf("subjectToken = %q, want %q", got, want)
+	}
+
+	tfc.AwsSecurityCredentialsSupplier = testAwsSupplier{region: "us-east-2", creds: &AwsSecurityCredentials{}}
+	base, err = tfc.parse(context.Background())
+	if err != nil {
+		t.Fatalf("parse() failed %v", err)
+	}
+	if _, err := base.subjectToken(); err == nil {
+		t.Error("subjectToken() with incomplete credentials succeeded, want error")
+	}
+}
+
+func TestAWSCredential_Partition(t *testing.T) {
+	tests := []struct {
+		name      string
+		region    string
+		partition string
+		url       string
+		want      string
+	}{
+		{"default", "us-east-2", "", "", "https://sts.us-east-2.amazonaws.com?Action=GetCallerIdentity&Version=2011-06-15"},
+		{"China inferred", "cn-north-1", "", "", "https://sts.cn-north-1.amazonaws.com.cn?Action=GetCallerIdentity&Version=2011-06-15"},
+		{"GovCloud", "us-gov-west-1", "aws-us-gov", "", "https://sts.us-gov-west-1.amazonaws.com?Action=GetCallerIdentity&Version=2011-06-15"},
+		{"ISO", "us-iso-east-1", "aws-iso", "", "https://sts.us-iso-east-1.c2s.ic.gov?Action=GetCallerIdentity&Version=2011-06-15"},
+		{"configured URL", "cn-north-1", "aws-cn", "https://sts.{region}.example.com", "https://sts.cn-north-1.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tfc := testFileConfig
+			tfc.CredentialSource = CredentialSource{Partition: tt.partition, RegionalCredVerificationURL: tt.url}
+			tfc.AwsSecurityCredentialsSupplier = testAwsSupplier{
+				region: tt.region,
+				creds:  &AwsSecurityCredentials{AccessKeyID: accessKeyID, SecretAccessKey: secretAccessKey},
+			}
+			base, err := tfc.parse(context.Background())
+			if err != nil {
+				t.Fatalf("parse() failed %v", err)
+			}
+			out, err := base.subjectToken()
+			if err != nil {
+				t.Fatalf("subjectToken() failed: %v", err)
+			}
+			if got := decodeAwsSubjectToken(t, out).URL; got != tt.want {
+				t.Errorf("got URL %v but want %v", got, tt.want)
+			}
+		})
+	}
+
+	tfc := testFileConfig
+	tfc.CredentialSource = CredentialSource{Partition: "aws-mars"}
+	tfc.AwsSecurityCredentialsSupplier = testAwsSupplier{}
+	if _, err := tfc.parse(context.Background()); err == nil {
+		t.Error("parse() succeeded with an unknown partition, want error")
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package externalaccount
+
+import (
+	"sync"
+	"time"
+)
+
+// awsCredentialsRefreshMargin is how long before their expiration cached
+// AWS security credentials are fetched again.
+const awsCredentialsRefreshMargin = 5 * time.Minute
+
+// awsCredentialsCache holds the AWS security credentials and region last
+// retrieved by a tokenSource, so that temporary credentials, valid for hours,
+// are not fetched from the metadata server for every token. Only credentials
+// reporting their expiration are cached. A nil cache holds nothing.
+type awsCredentialsCache struct {
+	mu     sync.Mutex
+	creds  awsSecurityCredentials
+	region string
+	expiry time.Time
+}
+
+// get returns the cached credentials and region if they remain valid for
+// awsCredentialsRefreshMargin.
+func (c *awsCredentialsCache) get() (awsSecurityCredentials, string, bool) {
+	if c == nil {
+		return awsSecurityCredentials{}, "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.expiry.IsZero() || !now().Add(awsCredentialsRefreshMargin).Before(c.expiry) {
+		return awsSecurityCredentials{}, "", false
+	}
+	return c.creds, c.region, true
+}
+
+// put caches creds and region until creds expire.
+func (c *awsCredentialsCache) put(creds awsSecurityCredentials, region string) {
+	if c == nil {
+		return
+	}
+	expiry, err := time.Parse(time.RFC3339, creds.Expiration)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		c.creds, c.region, c.expiry = awsSecurityCredentials{}, "", time.Time{}
+		return
+	}
+	c.creds, c.region, c.expiry = creds, region, expiry
+}
+
+// invalidate drops the cached credentials, for instance after the request
+// they signed was rejected.
+func (c *awsCredentialsCache) invalidate() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.creds, c.region, c.expiry = awsSecurityCredentials{}, "", time.Time{}
+}
@@ -0,0 +1,75 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package externalaccount
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	neturl "net/url"
+	"testing"
+	"time"
+)
+
+func TestAWSCredential_CachedCredentials(t *testing.T) {
+	server := createDefaultAwsTestServer()
+	var fetches int
+	writeCreds := server.WriteSecurityCredentials
+	server.WriteSecurityCredentials = func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		writeCreds(w, r)
+	}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	tsURL, err := neturl.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldGetenv, oldNow, oldValidHostnames := getenv, now, validHostnames
+	defer func() {
+		getenv, now, validHostnames = oldGetenv, oldNow, oldValidHostnames
+	}()
+	getenv = setEnvironment(map[string]string{})
+	now = setTime(defaultTime)
+	validHostnames = []string{tsURL.Hostname()}
+
+	tfc := testFileConfig
+	tfc.CredentialSource = server.getCredentialSource(ts.URL)
+	src := tokenSource{ctx: context.Background(), conf: &tfc, awsCreds: &awsCredentialsCache{}}
+
+	tests := []struct {
+		name       string
+		expiration time.Time
+		want       int
+	}{
+		{"no expiration", time.Time{}, 2},
+		{"expiring later", defaultTime.Add(time.Hour), 1},
+		{"expiring soon", defaultTime.Add(time.Minute), 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetches = 0
+			src.awsCreds.invalidate()
+			delete(server.Credentials, "Expiration")
+			if !tt.expiration.IsZero() {
+				server.Credentials["Expiration"] = tt.expiration.Format(time.RFC3339)
+			}
+			for i := 0; i < 2; i++ {
+				if _, err := src.subjectToken(); err != nil {
+					t.Fatalf("subjectToken() failed: %v", err)
+				}
+			}
+			if fetches != tt.want {
+				t.Errorf("got %d credential fetches but want %d", fetches, tt.want)
+			}
+		})
+	}
+
+	src.awsCreds.invalidate()
+	if _, _, ok := src.awsCreds.get(); ok {
+		t.Error("got cached credentials after invalidate")
+	}
+}
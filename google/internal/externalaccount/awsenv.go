@@ -0,0 +1,295 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package externalaccount
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ECS and EKS container credentials environment variables.
+	awsContainerCredentialsRelativeURI = "AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"
+	awsContainerCredentialsFullURI     = "AWS_CONTAINER_CREDENTIALS_FULL_URI"
+	awsContainerAuthorizationToken     = "AWS_CONTAINER_AUTHORIZATION_TOKEN"
+	awsContainerAuthorizationTokenFile = "AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"
+
+	// Web identity (IAM roles for service accounts) environment variables.
+	awsWebIdentityTokenFile = "AWS_WEB_IDENTITY_TOKEN_FILE"
+	awsRoleARN              = "AWS_ROLE_ARN"
+	awsRoleSessionName      = "AWS_ROLE_SESSION_NAME"
+
+	// awsSTSRegionalEndpoints selects the AWS STS endpoint web identity
+	// tokens are exchanged at, "regional" (the default) or "legacy" for
+	// the global endpoint.
+	awsSTSRegionalEndpoints = "AWS_STS_REGIONAL_ENDPOINTS"
+
+	// EC2 instance metadata service (IMDS) environment variables.
+	awsEC2MetadataServiceEndpoint     = "AWS_EC2_METADATA_SERVICE_ENDPOINT"
+	awsEC2MetadataServiceEndpointMode = "AWS_EC2_METADATA_SERVICE_ENDPOINT_MODE"
+
+	// The IMDS endpoints of each endpoint mode and the paths served by
+	// them.
+	awsIMDSEndpointIPv4 = "http://169.254.169.254"
+	awsIMDSEndpointIPv6 = "http://[fd00:ec2::254]"
+	awsIMDSRegionPath   = "/latest/meta-data/placement/availability-zone"
+	awsIMDSCredsPath    = "/latest/meta-data/iam/security-credentials"
+	awsIMDSTokenPath    = "/latest/api/token"
+)
+
+var (
+	// awsContainerCredentialsHost is the ECS endpoint the relative URI of
+	// container credentials is resolved against.
+	awsContainerCredentialsHost = "http://169.254.170.2"
+
+	// awsSTSURL is the regional AWS STS endpoint web identity tokens are
+	// exchanged at, {domain} being the DNS suffix of the partition of
+	// {region}.
+	awsSTSURL = "https://sts.{region}.{domain}/"
+
+	// awsGlobalSTSURL is the global AWS STS endpoint, used when the region
+	// is not set in the environment or AWS_STS_REGIONAL_ENDPOINTS is
+	// legacy.
+	awsGlobalSTSURL = "https://sts.amazonaws.com/"
+)
+
+// canRetrieveContainerCredentials reports whether the ECS or EKS container
+// credentials endpoint is configured.
+func canRetrieveContainerCredentials() bool {
+	return getenv(awsContainerCredentialsRelativeURI) != "" || getenv(awsContainerCredentialsFullURI) != ""
+}
+
+// canRetrieveWebIdentityCredentials reports whether a web identity token
+// and role are configured, as for EKS IAM roles for service accounts.
+func canRetrieveWebIdentityCredentials() bool {
+	return getenv(awsWebIdentityTokenFile) != "" && getenv(awsRoleARN) != ""
+}
+
+// containerCredentialsURL returns the container credentials endpoint. Full
+// URIs must use HTTPS, or a loopback or the ECS or EKS link-local address,
+// since the authorization token is sent to them.
+func containerCredentialsURL() (string, error) {
+	if rel := getenv(awsContainerCredentialsRelativeURI); rel != "" {
+		return awsContainerCredentialsHost + rel, nil
+	}
+	full := getenv(awsContainerCredentialsFullURI)
+	u, err := url.Parse(full)
+	if err != nil {
+		return "", fmt.Errorf("oauth2/google: invalid %s: %v", awsContainerCredentialsFullURI, err)
+	}
+	if u.Scheme == "https" {
+		return full, nil
+	}
+	switch host := u.Hostname(); {
+	case u.Scheme != "http":
+	case host == "localhost", host == "169.254.170.2", host == "169.254.170.23", host == "fd00:ec2::23":
+		return full, nil
+	default:
+		if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+			return full, nil
+		}
+	}
+	return "", fmt.Errorf("oauth2/google: %s %q must use HTTPS or a loopback or container metadata host", awsContainerCredentialsFullURI, full)
+}
+
+// getContainerSecurityCredentials retrieves the credentials of the task or
+// pod from the ECS or EKS container credentials endpoint.
+func (cs *awsCredentialSource) getContainerSecurityCredentials() (awsSecurityCredentials, error) {
+	var result awsSecurityCredentials
+	endpoint, err := containerCredentialsURL()
+	if err != nil {
+		return result, err
+	}
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return result, err
+	}
+	token := getenv(awsContainerAuthorizationToken)
+	if file := getenv(awsContainerAuthorizationTokenFile); file != "" {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return result, fmt.Errorf("oauth2/google: unable to read AWS container authorization token: %v", err)
+		}
+		token = strings.TrimSpace(string(b))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	resp, err := cs.doRequest(req)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return result, err
+	}
+	if resp.StatusCode != 200 {
+		return result, fmt.Errorf("oauth2/google: unable to retrieve AWS container credentials - %s", string(respBody))
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// assumeRoleWithWebIdentityResponse is the response of the AWS STS
+// AssumeRoleWithWebIdentity action.
+type assumeRoleWithWebIdentityResponse struct {
+	Credentials struct {
+		AccessKeyID     string `xml:"AccessKeyId"`
+		SecretAccessKey string `xml:"SecretAccessKey"`
+		SessionToken    string `xml:"SessionToken"`
+		Expiration      string `xml:"Expiration"`
+	} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+}
+
+// webIdentitySTSURL returns the AWS STS endpoint of the region set in the
+// environment, following AWS_STS_REGIONAL_ENDPOINTS. The global endpoint
+// only serves the aws partition.
+func (cs *awsCredentialSource) webIdentitySTSURL() (string, error) {
+	region := getenv(awsRegion)
+	if region == "" {
+		region = getenv(awsDefaultRegion)
+	}
+	partition := cs.Partition
+	if partition == "" && region != "" {
+		partition = partitionOfRegion(region)
+	}
+	global := partition == "" || partition == "aws"
+	switch mode := getenv(awsSTSRegionalEndpoints); {
+	case mode == "", strings.EqualFold(mode, "regional"):
+	case strings.EqualFold(mode, "legacy"):
+		if global {
+			return awsGlobalSTSURL, nil
+		}
+	default:
+		return "", fmt.Errorf("oauth2/google: invalid %s %q, want regional or legacy", awsSTSRegionalEndpoints, mode)
+	}
+	if region == "" {
+		if global {
+			return awsGlobalSTSURL, nil
+		}
+		return "", fmt.Errorf("oauth2/google: %s must be set to assume an AWS role with web identity in the %s partition", awsRegion, partition)
+	}
+	return strings.NewReplacer("{region}", region, "{domain}", awsPartitionDomains[partition]).Replace(awsSTSURL), nil
+}
+
+// getWebIdentitySecurityCredentials exchanges the web identity token for
+// credentials of the configured role with AWS STS.
+func (cs *awsCredentialSource) getWebIdentitySecurityCredentials() (awsSecurityCredentials, error) {
+	var result awsSecurityCredentials
+	token, err := ioutil.ReadFile(getenv(awsWebIdentityTokenFile))
+	if err != nil {
+		return result, fmt.Errorf("oauth2/google: unable to read AWS web identity token: %v", err)
+	}
+	sessionName := getenv(awsRoleSessionName)
+	if sessionName == "" {
+		sessionName = "google-oauth2-" + strconv.FormatInt(now().Unix(), 10)
+	}
+	form := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {getenv(awsRoleARN)},
+		"RoleSessionName":  {sessionName},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+	stsURL, err := cs.webIdentitySTSURL()
+	if err != nil {
+		return result, err
+	}
+	req, err := http.NewRequest("POST", stsURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return result, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := cs.doRequest(req)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return result, err
+	}
+	if resp.StatusCode != 200 {
+		return result, fmt.Errorf("oauth2/google: unable to assume AWS role with web identity - %s", string(respBody))
+	}
+	var r assumeRoleWithWebIdentityResponse
+	if err := xml.Unmarshal(respBody, &r); err != nil {
+		return result, fmt.Errorf("oauth2/google: invalid AssumeRoleWithWebIdentity response: %v", err)
+	}
+	if r.Credentials.AccessKeyID == "" || r.Credentials.SecretAccessKey == "" {
+		return result, errors.New("oauth2/google: AssumeRoleWithWebIdentity response lacks credentials")
+	}
+	return awsSecurityCredentials{
+		AccessKeyID:     r.Credentials.AccessKeyID,
+		SecretAccessKey: r.Credentials.SecretAccessKey,
+		SecurityToken:   r.Credentials.SessionToken,
+		Expiration:      r.Credentials.Expiration,
+	}, nil
+}
+
+// metadataEndpoint returns the IMDS endpoint set by
+// AWS_EC2_METADATA_SERVICE_ENDPOINT, else the endpoint of the mode set by
+// AWS_EC2_METADATA_SERVICE_ENDPOINT_MODE, else "".
+func metadataEndpoint() (string, error) {
+	if endpoint := getenv(awsEC2MetadataServiceEndpoint); endpoint != "" {
+		u, err := url.Parse(endpoint)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return "", fmt.Errorf("oauth2/google: invalid %s %q", awsEC2MetadataServiceEndpoint, endpoint)
+		}
+		return strings.TrimSuffix(endpoint, "/"), nil
+	}
+	switch mode := getenv(awsEC2MetadataServiceEndpointMode); {
+	case mode == "":
+		return "", nil
+	case strings.EqualFold(mode, "IPv4"):
+		return awsIMDSEndpointIPv4, nil
+	case strings.EqualFold(mode, "IPv6"):
+		return awsIMDSEndpointIPv6, nil
+	default:
+		return "", fmt.Errorf("oauth2/google: invalid %s %q, want IPv4 or IPv6", awsEC2MetadataServiceEndpointMode, mode)
+	}
+}
+
+// applyMetadataEndpoint points the metadata server URLs of cs at the IMDS
+// endpoint configured in the environment, if any. Configured URLs keep
+// their paths; the region, credentials and IMDSv2 session token URLs
+// default to the standard IMDS paths, so that the credential configuration
+// needs no URLs and IMDSv2 is used as on the default endpoints. It is
+// applied after validateMetadataServers since the environment, unlike the
+// configuration, is trusted.
+func (cs *awsCredentialSource) applyMetadataEndpoint() error {
+	endpoint, err := metadataEndpoint()
+	if err != nil || endpoint == "" {
+		return err
+	}
+	rehost := func(configured, defaultPath string) string {
+		if configured == "" {
+			return endpoint + defaultPath
+		}
+		u, err := url.Parse(configured)
+		if err != nil {
+			return configured
+		}
+		return endpoint + u.EscapedPath()
+	}
+	cs.RegionURL = rehost(cs.RegionURL, awsIMDSRegionPath)
+	cs.CredVerificationURL = rehost(cs.CredVerificationURL, awsIMDSCredsPath)
+	cs.IMDSv2SessionTokenURL = rehost(cs.IMDSv2SessionTokenURL, awsIMDSTokenPath)
+	return nil
+}
@@ -0,0 +1,334 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package externalaccount
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// awsEnvCredentialSource is an AWS credential source relying on the
+// environment alone.
+var awsEnvCredentialSource = CredentialSource{
+	EnvironmentID:               "aws1",
+	RegionalCredVerificationURL: "https://sts.{region}.amazonaws.com?Action=GetCallerIdentity&Version=2011-06-15",
+}
+
+func awsEnvSubjectToken(t *testing.T, env map[string]string) (string, error) {
+	t.Helper()
+	defer func(old func(string) string) { getenv = old }(getenv)
+	getenv = setEnvironment(env)
+
+	tfc := testFileConfig
+	tfc.CredentialSource = awsEnvCredentialSource
+	base, err := tfc.parse(context.Background())
+	if err != nil {
+		t.Fatalf("parse() failed %v", err)
+	}
+	return base.subjectToken()
+}
+
+func TestAWSCredential_ContainerCredentials(t *testing.T) {
+	defer func(old func() time.Time) { now = old }(now)
+	now = setTime(defaultTime)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.URL.Path, "/v2/credentials/task"; got != want {
+			t.Errorf("got path %v but want %v", got, want)
+		}
+		if got, want := r.Header.Get("Authorization"), "container-token"; got != want {
+			t.Errorf("got authorization %v but want %v", got, want)
+		}
+		w.Write([]byte(`{"AccessKeyId":"` + accessKeyID + `","SecretAccessKey":"` + secretAccessKey + `","Token":"` + securityToken + `","Expiration":"2011-09-10T00:36:00Z"}`))
+	}))
+	defer ts.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("container-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{"full URI", map[string]string{
+			"AWS_CONTAINER_CREDENTIALS_FULL_URI":     ts.URL + "/v2/credentials/task",
+			"AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE": tokenFile,
+		}},
+		{"relative URI", map[string]string{
+			"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI": "/v2/credentials/task",
+			"AWS_CONTAINER_AUTHORIZATION_TOKEN":      "container-token",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(old string) { awsContainerCredentialsHost = old }(awsContainerCredentialsHost)
+			awsContainerCredentialsHost = ts.URL
+			tt.env["AWS_REGION"] = "us-east-2"
+
+			out, err := awsEnvSubjectToken(t, tt.env)
+			if err != nil {
+				t.Fatalf("subjectToken() failed: %v", err)
+			}
+			want := getExpectedSubjectToken(
+				"https://sts.us-east-2.amazonaws.com?Action=GetCallerIdentity&Version=2011-06-15",
+				"us-east-2",
+				accessKeyID,
+				secretAccessKey,
+				securityToken,
+			)
+			if out != want {
+				t.Errorf("subjectToken = \n%q\n want \n%q", out, want)
+			}
+		})
+	}
+}
+
+func TestAWSCredential_ContainerCredentialsInsecureURI(t *testing.T) {
+	_, err := awsEnvSubjectToken(t, map[string]string{
+		"AWS_REGION":                         "us-east-2",
+		"AWS_CONTAINER_CREDENTIALS_FULL_URI": "http://example.com/credentials",
+	})
+	if err == nil {
+		t.Error("subjectToken() succeeded with a plain HTTP remote URI, want error")
+	}
+}
+
+// newWebIdentityServer returns an AWS STS server answering
+// AssumeRoleWithWebIdentity requests for the test role.
+func newWebIdentityServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		for key, want := range map[string]string{
+			"Action":           "AssumeRoleWithWebIdentity",
+			"RoleArn":          "arn:aws:iam::123456789012:role/workload",
+			"RoleSessionName":  "session",
+			"WebIdentityToken": "oidc-token",
+		} {
+			if got := r.PostForm.Get(key); got != want {
+				t.Errorf("got %s %q but want %q", key, got, want)
+			}
+		}
+		w.Header().Set("Content-Type", "text/xml")
+		w.Write([]byte(`<AssumeRoleWithWebIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleWithWebIdentityResult>
+    <Credentials>
+      <AccessKeyId>` + accessKeyID + `</AccessKeyId>
+      <SecretAccessKey>` + secretAccessKey + `</SecretAccessKey>
+      <SessionToken>` + securityToken + `</SessionToken>
+      <Expiration>2011-09-10T00:36:00Z</Expiration>
+    </Credentials>
+  </AssumeRoleWithWebIdentityResult>
+</AssumeRoleWithWebIdentityResponse>`))
+	}))
+}
+
+func TestAWSCredential_WebIdentity(t *testing.T) {
+	defer func(old func() time.Time) { now = old }(now)
+	now = setTime(defaultTime)
+
+	ts := newWebIdentityServer(t)
+	defer ts.Close()
+	defer func(old string) { awsSTSURL = old }(awsSTSURL)
+	awsSTSURL = ts.URL
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("oidc-token"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	out, err := awsEnvSubjectToken(t, map[string]string{
+		"AWS_REGION":                  "us-east-2",
+		"AWS_WEB_IDENTITY_TOKEN_FILE": tokenFile,
+		"AWS_ROLE_ARN":                "arn:aws:iam::123456789012:role/workload",
+		"AWS_ROLE_SESSION_NAME":       "session",
+	})
+	if err != nil {
+		t.Fatalf("subjectToken() failed: %v", err)
+	}
+	want := getExpectedSubjectToken(
+		"https://sts.us-east-2.amazonaws.com?Action=GetCallerIdentity&Version=2011-06-15",
+		"us-east-2",
+		accessKeyID,
+		secretAccessKey,
+		securityToken,
+	)
+	if out != want {
+		t.Errorf("subjectToken = \n%q\n want \n%q", out, want)
+	}
+}
+
+func TestAWSCredential_WebIdentityExpiration(t *testing.T) {
+	ts := newWebIdentityServer(t)
+	defer ts.Close()
+	defer func(old string) { awsSTSURL = old }(awsSTSURL)
+	awsSTSURL = ts.URL
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("oidc-token"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	defer func(old func(string) string) { getenv = old }(getenv)
+	getenv = setEnvironment(map[string]string{
+		"AWS_REGION":                  "us-east-2",
+		"AWS_WEB_IDENTITY_TOKEN_FILE": tokenFile,
+		"AWS_ROLE_ARN":                "arn:aws:iam::123456789012:role/workload",
+		"AWS_ROLE_SESSION_NAME":       "session",
+	})
+	cs := &awsCredentialSource{ctx: context.Background()}
+	creds, err := cs.getWebIdentitySecurityCredentials()
+	if err != nil {
+		t.Fatalf("getWebIdentitySecurityCredentials() failed: %v", err)
+	}
+	if got, want := creds.Expiration, "2011-09-10T00:36:00Z"; got != want {
+		t.Errorf("got expiration %v but want %v", got, want)
+	}
+}
+
+func TestAWSCredential_WebIdentitySTSURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		partition string
+		env       map[string]string
+		want      string
+	}{
+		{
+			name: "regional",
+			env:  map[string]string{"AWS_REGION": "eu-west-1"},
+			want: "https://sts.eu-west-1.amazonaws.com/",
+		},
+		{
+			name: "default region",
+			env:  map[string]string{"AWS_DEFAULT_REGION": "cn-north-1"},
+			want: "https://sts.cn-north-1.amazonaws.com.cn/",
+		},
+		{
+			name: "legacy",
+			env:  map[string]string{"AWS_REGION": "eu-west-1", "AWS_STS_REGIONAL_ENDPOINTS": "legacy"},
+			want: "https://sts.amazonaws.com/",
+		},
+		{
+			name: "legacy outside the aws partition",
+			env:  map[string]string{"AWS_REGION": "us-gov-west-1", "AWS_STS_REGIONAL_ENDPOINTS": "legacy"},
+			want: "https://sts.us-gov-west-1.amazonaws.com/",
+		},
+		{
+			name: "no region",
+			want: "https://sts.amazonaws.com/",
+		},
+		{
+			name:      "no region outside the aws partition",
+			partition: "aws-cn",
+		},
+		{
+			name: "invalid mode",
+			env:  map[string]string{"AWS_REGION": "eu-west-1", "AWS_STS_REGIONAL_ENDPOINTS": "global"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(old func(string) string) { getenv = old }(getenv)
+			getenv = setEnvironment(tt.env)
+			cs := &awsCredentialSource{Partition: tt.partition}
+			got, err := cs.webIdentitySTSURL()
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("webIdentitySTSURL() = %v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("webIdentitySTSURL() failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v but want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAWSCredential_MetadataServiceEndpoint(t *testing.T) {
+	defer func(old func() time.Time) { now = old }(now)
+	now = setTime(defaultTime)
+
+	// The IMDSv2 session token is requested without a configured URL too.
+	server := createDefaultAwsTestServerWithImdsv2(t)
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	tests := []struct {
+		name   string
+		source CredentialSource
+	}{
+		{"no URLs", awsEnvCredentialSource},
+		{"IPv4 URLs", CredentialSource{
+			EnvironmentID:               "aws1",
+			RegionURL:                   "http://169.254.169.254/latest/meta-data/placement/availability-zone",
+			URL:                         "http://169.254.169.254/latest/meta-data/iam/security-credentials",
+			RegionalCredVerificationURL: awsEnvCredentialSource.RegionalCredVerificationURL,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(old func(string) string) { getenv = old }(getenv)
+			getenv = setEnvironment(map[string]string{awsEC2MetadataServiceEndpoint: ts.URL + "/"})
+
+			tfc := testFileConfig
+			tfc.CredentialSource = tt.source
+			base, err := tfc.parse(context.Background())
+			if err != nil {
+				t.Fatalf("parse() failed %v", err)
+			}
+			out, err := base.subjectToken()
+			if err != nil {
+				t.Fatalf("subjectToken() failed: %v", err)
+			}
+			want := getExpectedSubjectToken(
+				"https://sts.us-east-2.amazonaws.com?Action=GetCallerIdentity&Version=2011-06-15",
+				"us-east-2",
+				accessKeyID,
+				secretAccessKey,
+				securityToken,
+			)
+			if out != want {
+				t.Errorf("subjectToken = \n%q\n want \n%q", out, want)
+			}
+		})
+	}
+}
+
+func TestAWSCredential_MetadataServiceEndpointMode(t *testing.T) {
+	defer func(old func(string) string) { getenv = old }(getenv)
+
+	getenv = setEnvironment(map[string]string{awsEC2MetadataServiceEndpointMode: "IPv6"})
+	tfc := testFileConfig
+	tfc.CredentialSource = awsEnvCredentialSource
+	tfc.CredentialSource.IMDSv2SessionTokenURL = "http://169.254.169.254/latest/api/token"
+	base, err := tfc.parse(context.Background())
+	if err != nil {
+		t.Fatalf("parse() failed %v", err)
+	}
+	cs := base.(awsCredentialSource)
+	for _, u := range []struct{ got, want string }{
+		{cs.RegionURL, "http://[fd00:ec2::254]/latest/meta-data/placement/availability-zone"},
+		{cs.CredVerificationURL, "http://[fd00:ec2::254]/latest/meta-data/iam/security-credentials"},
+		{cs.IMDSv2SessionTokenURL, "http://[fd00:ec2::254]/latest/api/token"},
+	} {
+		if u.got != u.want {
+			t.Errorf("got URL %v but want %v", u.got, u.want)
+		}
+	}
+
+	getenv = setEnvironment(map[string]string{awsEC2MetadataServiceEndpointMode: "IPv5"})
+	if _, err := tfc.parse(context.Background()); err == nil {
+		t.Error("parse() succeeded with an invalid endpoint mode, want error")
+	}
+}
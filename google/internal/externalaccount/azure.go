@@ -0,0 +1,142 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package externalaccount
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	// azureFederatedTokenFileEnvVar is set by the AKS workload identity
+	// webhook to the path of the projected service account token.
+	azureFederatedTokenFileEnvVar = "AZURE_FEDERATED_TOKEN_FILE"
+
+	defaultAzureIMDSURL = "http://169.254.169.254/metadata/identity/oauth2/token"
+	azureIMDSAPIVersion = "2018-02-01"
+)
+
+// AzureIdentity obtains the subject token of an Azure workload: the
+// projected token of AKS workload identity if FederatedTokenFile is set, or
+// else a managed identity token from the Azure Instance Metadata Service
+// (IMDS). It is shared by the azure1 credential source and package
+// google/azure, which decide where the token file is taken from.
+type AzureIdentity struct {
+	// FederatedTokenFile is the path of the projected service account
+	// token.
+	FederatedTokenFile string
+	// Resource is the application ID URI the managed identity token is
+	// requested for.
+	Resource string
+	// ClientID selects a user-assigned managed identity.
+	ClientID string
+	// IMDSURL is the IMDS token endpoint. The default is the well-known
+	// address.
+	IMDSURL string
+}
+
+// SubjectToken returns the token of the workload's Azure identity.
+func (id AzureIdentity) SubjectToken(ctx context.Context) (string, error) {
+	if id.FederatedTokenFile != "" {
+		b, err := ioutil.ReadFile(id.FederatedTokenFile)
+		if err != nil {
+			return "", fmt.Errorf("oauth2/google: unable to read azure federated token file: %v", err)
+		}
+		token := strings.TrimSpace(string(b))
+		if token == "" {
+			return "", fmt.Errorf("oauth2/google: azure federated token file %q is empty", id.FederatedTokenFile)
+		}
+		return token, nil
+	}
+	if id.Resource == "" {
+		return "", errors.New("oauth2/google: no azure federated token file found and no managed identity resource configured")
+	}
+	endpoint := id.IMDSURL
+	if endpoint == "" {
+		endpoint = defaultAzureIMDSURL
+	}
+	v := url.Values{
+		"api-version": {azureIMDSAPIVersion},
+		"resource":    {id.Resource},
+	}
+	if id.ClientID != "" {
+		v.Set("client_id", id.ClientID)
+	}
+	req, err := http.NewRequest("GET", endpoint+"?"+v.Encode(), nil)
+	if err != nil {
+		return "", fmt.Errorf("oauth2/google: unable to create azure metadata request: %v", err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Metadata", "true")
+	resp, err := oauth2.NewClient(ctx, nil).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("oauth2/google: request to azure metadata service failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("oauth2/google: unable to read azure metadata service response: %v", err)
+	}
+	if c := resp.StatusCode; c < 200 || c > 299 {
+		return "", fmt.Errorf("oauth2/google: status code %d: %s", c, body)
+	}
+	var tok struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(body, &tok); err != nil {
+		return "", fmt.Errorf("oauth2/google: unable to parse azure metadata service response: %v", err)
+	}
+	if tok.AccessToken == "" {
+		return "", errors.New("oauth2/google: azure metadata service response is missing access_token")
+	}
+	return tok.AccessToken, nil
+}
+
+// azureCredentialSource obtains the subject token of environment ID azure1.
+type azureCredentialSource struct {
+	AzureIdentity
+	ctx context.Context
+}
+
+// newAzureCredentialSource returns the credential source for environment
+// ID azure1. The projected token of AKS workload identity named by
+// AZURE_FEDERATED_TOKEN_FILE is only used when no resource is configured,
+// so that an explicitly configured managed identity is never bypassed.
+// Otherwise the resource defaults to the https: form of the audience, as
+// generated by gcloud.
+func newAzureCredentialSource(ctx context.Context, c *Config) (azureCredentialSource, error) {
+	if c.CredentialSource.EnvironmentID != "azure1" {
+		return azureCredentialSource{}, fmt.Errorf("oauth2/google: azure environment ID %q is not supported in the current build", c.CredentialSource.EnvironmentID)
+	}
+	cs := azureCredentialSource{
+		AzureIdentity: AzureIdentity{
+			IMDSURL:  c.CredentialSource.URL,
+			Resource: c.CredentialSource.Resource,
+			ClientID: c.CredentialSource.ClientID,
+		},
+		ctx: ctx,
+	}
+	if cs.Resource == "" {
+		cs.FederatedTokenFile = getenv(azureFederatedTokenFileEnvVar)
+		if !strings.HasPrefix(c.Audience, "//") {
+			return azureCredentialSource{}, errors.New("oauth2/google: azure credential source requires a resource")
+		}
+		cs.Resource = "https:" + c.Audience
+	}
+	return cs, nil
+}
+
+func (cs azureCredentialSource) subjectToken() (string, error) {
+	return cs.SubjectToken(cs.ctx)
+}
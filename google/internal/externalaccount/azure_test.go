@@ -0,0 +1,112 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package externalaccount
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const azureAudience = "//iam.googleapis.com/projects/123/locations/global/workloadIdentityPools/pool/providers/azure"
+
+func TestAzureCredentialSource_IMDS(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("Metadata"), "true"; got != want {
+			t.Errorf("got Metadata header %v but want %v", got, want)
+		}
+		q := r.URL.Query()
+		if got, want := q.Get("api-version"), azureIMDSAPIVersion; got != want {
+			t.Errorf("got api-version %v but want %v", got, want)
+		}
+		if got, want := q.Get("resource"), "https:"+azureAudience; got != want {
+			t.Errorf("got resource %v but want %v", got, want)
+		}
+		if got, want := q.Get("client_id"), "user-assigned"; got != want {
+			t.Errorf("got client_id %v but want %v", got, want)
+		}
+		w.Write([]byte(`{"access_token":"azure-token","token_type":"Bearer"}`))
+	}))
+	defer server.Close()
+	oldGetenv := getenv
+	defer func() { getenv = oldGetenv }()
+	getenv = setEnvironment(map[string]string{})
+
+	config := Config{
+		Audience: azureAudience,
+		CredentialSource: CredentialSource{
+			EnvironmentID: "azure1",
+			URL:           server.URL,
+			ClientID:      "user-assigned",
+		},
+	}
+	base, err := config.parse(context.Background())
+	if err != nil {
+		t.Fatalf("parse() failed: %v", err)
+	}
+	if _, ok := base.(azureCredentialSource); !ok {
+		t.Fatalf("got credential source %T but want azureCredentialSource", base)
+	}
+	got, err := base.subjectToken()
+	if err != nil {
+		t.Fatalf("subjectToken() failed: %v", err)
+	}
+	if want := "azure-token"; got != want {
+		t.Errorf("got %v but want %v", got, want)
+	}
+	if got, want := config.credentialSourceType(), "azure"; got != want {
+		t.Errorf("got source %v but want %v", got, want)
+	}
+}
+
+func TestAzureCredentialSource_FederatedTokenFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(file, []byte("aks-token"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	oldGetenv := getenv
+	defer func() { getenv = oldGetenv }()
+	getenv = setEnvironment(map[string]string{azureFederatedTokenFileEnvVar: file})
+
+	config := Config{
+		Audience:         azureAudience,
+		CredentialSource: CredentialSource{EnvironmentID: "azure1", URL: "http://unused.invalid"},
+	}
+	base, err := config.parse(context.Background())
+	if err != nil {
+		t.Fatalf("parse() failed: %v", err)
+	}
+	got, err := base.subjectToken()
+	if err != nil {
+		t.Fatalf("subjectToken() failed: %v", err)
+	}
+	if want := "aks-token"; got != want {
+		t.Errorf("got %v but want %v", got, want)
+	}
+
+	// An explicitly configured resource selects the managed identity.
+	config.CredentialSource.Resource = "api://AzureADTokenExchange"
+	base, err = config.parse(context.Background())
+	if err != nil {
+		t.Fatalf("parse() failed: %v", err)
+	}
+	if file := base.(azureCredentialSource).FederatedTokenFile; file != "" {
+		t.Errorf("got federated token file %v but want none with a configured resource", file)
+	}
+}
+
+func TestAzureCredentialSource_Invalid(t *testing.T) {
+	for _, config := range []Config{
+		{Audience: azureAudience, CredentialSource: CredentialSource{EnvironmentID: "azure2"}},
+		{Audience: "api://AzureADTokenExchange", CredentialSource: CredentialSource{EnvironmentID: "azure1"}},
+	} {
+		if _, err := config.parse(context.Background()); err == nil {
+			t.Errorf("parse() succeeded for %+v, want error", config.CredentialSource)
+		}
+	}
+}
@@ -6,6 +6,8 @@ package externalaccount
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -15,6 +17,7 @@ import (
 	"time"
 
 	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/internal"
 )
 
 // now aliases time.Now for testing
@@ -26,7 +29,13 @@ var now = func() time.Time {
 type Config struct {
 	// Audience is the Secure Token Service (STS) audience which contains the resource name for the workload
 	// identity pool or the workforce pool and the provider identifier in that pool.
+	// It may contain ${NAME} placeholders, resolved from AudienceVariables or the
+	// environment when the token source is created.
 	Audience string
+	// AudienceVariables are the values of the ${NAME} placeholders of Audience,
+	// e.g. PROJECT_NUMBER, POOL_ID or PROVIDER_ID. Placeholders missing from it
+	// are resolved from environment variables of the same name. Optional.
+	AudienceVariables map[string]string
 	// SubjectTokenType is the STS token type based on the Oauth2.0 token exchange spec
 	// e.g. `urn:ietf:params:oauth:token-type:jwt`.
 	SubjectTokenType string
@@ -42,6 +51,15 @@ type Config struct {
 	// ServiceAccountImpersonationLifetimeSeconds is the number of seconds the service account impersonation
 	// token will be valid for.
 	ServiceAccountImpersonationLifetimeSeconds int
+	// ServiceAccountImpersonationMinLifetimeSeconds, when positive, shortens the lifetime of the
+	// service account impersonation token to the deadline of the context of the token source, but
+	// not below this number of seconds. Optional.
+	ServiceAccountImpersonationMinLifetimeSeconds int
+	// IDTokenAudience, when set, makes the token source return Google-signed ID tokens for this
+	// audience, e.g. the URL of a Cloud Run service, instead of access tokens. The ID tokens are
+	// minted with the generateIdToken method of the impersonated service account, so
+	// ServiceAccountImpersonationURL is required. Optional.
+	IDTokenAudience string
 	// ClientSecret is currently only required if token_info endpoint also
 	// needs to be called with the generated GCP access token. When provided, STS will be
 	// called with additional basic authentication using client_id as username and client_secret as password.
@@ -53,6 +71,7 @@ type Config struct {
 	CredentialSource CredentialSource
 	// QuotaProjectID is injected by gCloud. If the value is non-empty, the Auth libraries
 	// will set the x-goog-user-project which overrides the project associated with the credentials.
+	// It is also sent on service account impersonation requests.
 	QuotaProjectID string
 	// Scopes contains the desired scopes for the returned access token.
 	Scopes []string
@@ -61,15 +80,155 @@ type Config struct {
 	// The underlying principal must still have serviceusage.services.use IAM
 	// permission to use the project for billing/quota.
 	WorkforcePoolUserProject string
+	// SubjectTokenSupplier is an optional token supplier for OIDC/SAML
+	// credentials. When set, it is used instead of CredentialSource to
+	// retrieve the subject token.
+	SubjectTokenSupplier SubjectTokenSupplier
+	// AwsSecurityCredentialsSupplier is an optional supplier of AWS
+	// security credentials and region. When set, the subject token is a
+	// signed AWS GetCallerIdentity request, as with an AWS CredentialSource,
+	// but the credentials and region are not read from the environment or
+	// the metadata server.
+	AwsSecurityCredentialsSupplier AwsSecurityCredentialsSupplier
+	// SubjectTokenReuseMargin enables reusing subject tokens across token
+	// exchanges. When positive, a subject token is not retrieved again from
+	// the credential source as long as it expires at least this far in the
+	// future. The expiry is the expiration_time reported by executables, or
+	// else the exp claim of JWT subject tokens; other tokens are not reused.
+	// Zero, the default, retrieves a new subject token for every exchange.
+	SubjectTokenReuseMargin time.Duration
+	// WatchSubjectTokenFile makes file credential sources with a
+	// SubjectTokenReuseMargin check the modification time and size of the
+	// token file before reusing a subject token read from it. If the file
+	// changed, e.g. because a refresher rotated the token, the reused token
+	// is discarded and the file read again, so that a revoked token is not
+	// exchanged.
+	WatchSubjectTokenFile bool
+	// MetricsProducts are additional product identifiers of the form
+	// "name/version", e.g. "terraform-provider-google/4.80.0", appended to
+	// the x-goog-api-client header of token exchange requests. Malformed
+	// identifiers are ignored. Optional.
+	MetricsProducts []string
+	// UniverseDomain is the domain of the Google Cloud universe the audience
+	// belongs to. The default is googleapis.com.
+	UniverseDomain string
+	// RefreshJitter is the upper bound of a random duration by which tokens
+	// are refreshed early, so that processes started together do not refresh
+	// in lockstep. Optional.
+	RefreshJitter time.Duration
+	// BackgroundRefreshWindow, when positive, makes the token source renew
+	// its tokens this long before they expire, in a goroutine that stops
+	// when the context passed to TokenSource is done, so that callers do
+	// not wait for token exchanges. With service account impersonation,
+	// both the STS token and the impersonated token are renewed. Optional.
+	BackgroundRefreshWindow time.Duration
+	// OptionsEncoding controls how additional options are sent to the
+	// security token service. The default is OptionsEncodingJSON.
+	OptionsEncoding OptionsEncoding
+	// Interactive runs the executable of an executable credential source in
+	// interactive mode when its output file holds no valid response: the
+	// executable is connected to the terminal so that it can prompt the user
+	// to sign in, and writes its response to the output file, which is
+	// required. Optional.
+	Interactive bool
+	// HTTPClient is used for all requests made by the token source: the
+	// token exchange, service account impersonation and the requests of
+	// URL and AWS credential sources. If nil, the client of the context
+	// (see oauth2.HTTPClient) or http.DefaultClient is used. With an X.509
+	// credential source, a copy presenting the client certificate is used.
+	HTTPClient *http.Client
+	// ScopeDowngrade, if non-nil, is called with the requested and granted
+	// scopes when the security token service grants fewer scopes than
+	// requested. The narrower token is used regardless. Optional.
+	ScopeDowngrade func(requested, granted []string)
+	// SubjectTokenTLSConfig, if non-nil, is the TLS configuration of the
+	// subject token requests of URL credential sources, e.g. to present a
+	// client certificate or trust a private certificate authority. A
+	// ClientCertificate of the CredentialSource replaces its certificates.
+	// The other requests are unaffected.
+	SubjectTokenTLSConfig *tls.Config
+
+	// subjectClient is the client of the subject token requests of URL
+	// credential sources, built once by tokenSource.
+	subjectClient *http.Client
+}
+
+// SupplierOptions describes the token exchange a supplier is called for.
+// Like the supplier interfaces, it is only used by the packages of this
+// module wrapping this internal package; see MetricsSource.
+type SupplierOptions struct {
+	// Audience is the requested audience of the exchange, Config.Audience.
+	Audience string
+	// SubjectTokenType is the type of the expected subject token,
+	// Config.SubjectTokenType.
+	SubjectTokenType string
+	// Scopes are the scopes requested from the security token service. With
+	// service account impersonation, they are the cloud-platform scope, the
+	// impersonated scopes being requested separately.
+	Scopes []string
+}
+
+// SubjectTokenSupplier can be used to supply a subject token to exchange for a
+// GCP access token.
+type SubjectTokenSupplier interface {
+	// SubjectToken should return a valid subject token or an error. ctx is
+	// the context the token source was created with; its deadline and
+	// cancellation should be honored.
+	// The external account token source does not cache the returned subject
+	// token, so caching logic should be implemented in the supplier to
+	// prevent multiple requests for the same subject token.
+	SubjectToken(ctx context.Context, options SupplierOptions) (string, error)
+}
+
+// AwsSecurityCredentials are the AWS credentials used to sign the
+// GetCallerIdentity request.
+type AwsSecurityCredentials struct {
+	// AccessKeyID is the AWS access key ID. Required.
+	AccessKeyID string
+	// SecretAccessKey is the AWS secret access key. Required.
+	SecretAccessKey string
+	// SessionToken is the session token of temporary credentials.
+	// Optional.
+	SessionToken string
+}
+
+// AwsSecurityCredentialsSupplier can be used to supply the AWS credentials
+// and region of an AWS workload, e.g. from the AWS SDK.
+type AwsSecurityCredentialsSupplier interface {
+	// AwsRegion returns the AWS region of the workload, e.g. "us-east-2".
+	AwsRegion(ctx context.Context, options SupplierOptions) (string, error)
+	// AwsSecurityCredentials returns valid AWS credentials. As with
+	// SubjectTokenSupplier, they are not cached by the token source.
+	AwsSecurityCredentials(ctx context.Context, options SupplierOptions) (*AwsSecurityCredentials, error)
+}
+
+// supplierOptions returns the options passed to the suppliers of c.
+func (c *Config) supplierOptions() SupplierOptions {
+	return SupplierOptions{
+		Audience:         c.Audience,
+		SubjectTokenType: c.SubjectTokenType,
+		Scopes:           internal.NormalizeScopes(c.Scopes),
+	}
 }
 
 // Each element consists of a list of patterns.  validateURLs checks for matches
 // that include all elements in a given list, in that order.
 
-var (
-	validWorkforceAudiencePattern *regexp.Regexp = regexp.MustCompile(`//iam\.googleapis\.com/locations/[^/]+/workforcePools/`)
+// defaultUniverseDomain is the universe domain of the Google Cloud public
+// cloud.
+const defaultUniverseDomain = "googleapis.com"
+
+// Audience formats, with %s standing for the quoted universe domain.
+const (
+	workforceAudienceFormat     = `^//iam\.%s/locations/[^/]+/workforcePools/`
+	fullWorkforceAudienceFormat = `^//iam\.%s/locations/[^/]+/workforcePools/[^/]+/providers/[^/]+$`
+	fullWorkloadAudienceFormat  = `^//iam\.%s/projects/[^/]+/locations/[^/]+/workloadIdentityPools/[^/]+/providers/[^/]+$`
 )
 
+func audiencePattern(format, universeDomain string) *regexp.Regexp {
+	return regexp.MustCompile(fmt.Sprintf(format, regexp.QuoteMeta(universeDomain)))
+}
+
 func validateURL(input string, patterns []*regexp.Regexp, scheme string) bool {
 	parsed, err := url.Parse(input)
 	if err != nil {
@@ -88,8 +247,39 @@ func validateURL(input string, patterns []*regexp.Regexp, scheme string) bool {
 	return false
 }
 
-func validateWorkforceAudience(input string) bool {
-	return validWorkforceAudiencePattern.MatchString(input)
+func validateWorkforceAudience(input, universeDomain string) bool {
+	return audiencePattern(workforceAudienceFormat, universeDomain).MatchString(input)
+}
+
+// validateAudience checks that an audience naming an IAM workload or
+// workforce pool provider is well-formed and belongs to universeDomain, so
+// that misconfigurations are reported before calling the security token
+// service. Audiences that are not IAM resource names are passed through
+// unchanged.
+func validateAudience(audience, universeDomain string) error {
+	if audience == "" {
+		return errors.New("oauth2/google: missing audience")
+	}
+	if !strings.HasPrefix(audience, "//iam.") {
+		return nil
+	}
+	if !strings.HasPrefix(audience, "//iam."+universeDomain+"/") {
+		return fmt.Errorf("oauth2/google: audience %q does not belong to universe domain %q", audience, universeDomain)
+	}
+	if audiencePattern(fullWorkloadAudienceFormat, universeDomain).MatchString(audience) ||
+		audiencePattern(fullWorkforceAudienceFormat, universeDomain).MatchString(audience) {
+		return nil
+	}
+	return fmt.Errorf("oauth2/google: invalid audience %q, expected //iam.%s/projects/PROJECT_NUMBER/locations/LOCATION/workloadIdentityPools/POOL_ID/providers/PROVIDER_ID or //iam.%s/locations/LOCATION/workforcePools/POOL_ID/providers/PROVIDER_ID", audience, universeDomain, universeDomain)
+}
+
+// universeDomain returns the universe domain of c, defaulting to
+// googleapis.com.
+func (c *Config) universeDomain() string {
+	if c.UniverseDomain == "" {
+		return defaultUniverseDomain
+	}
+	return c.UniverseDomain
 }
 
 // TokenSource Returns an external account TokenSource struct. This is to be called by package google to construct a google.Credentials.
@@ -101,30 +291,117 @@ func (c *Config) TokenSource(ctx context.Context) (oauth2.TokenSource, error) {
 // because the unit test URLs are mocked, and would otherwise fail the
 // validity check.
 func (c *Config) tokenSource(ctx context.Context, scheme string) (oauth2.TokenSource, error) {
+	ts, err := c.newTokenSource(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if c.ServiceAccountImpersonationURL == "" {
+		return c.reuseTokenSource(ts.ctx, ts), nil
+	}
+	imp := c.impersonateTokenSource(ts.ctx, c.reuseTokenSource(ts.ctx, ts))
+	return c.reuseTokenSource(ts.ctx, imp), nil
+}
+
+// newTokenSource validates c and returns the uncached token source of its
+// token exchange, with the environment variables of c resolved and the HTTP
+// clients of c set up. The exchange requests the cloud-platform scope if c
+// impersonates a service account.
+func (c *Config) newTokenSource(ctx context.Context) (tokenSource, error) {
+	c, err := c.resolve()
+	if err != nil {
+		return tokenSource{}, err
+	}
+	if err := validateAudience(c.Audience, c.universeDomain()); err != nil {
+		return tokenSource{}, err
+	}
+	if e := c.OptionsEncoding; e != "" && e != OptionsEncodingJSON && e != OptionsEncodingForm {
+		return tokenSource{}, fmt.Errorf("oauth2/google: unsupported options encoding %q", e)
+	}
+	if c.CredentialSource.Certificate != nil && c.SubjectTokenSupplier == nil && c.AwsSecurityCredentialsSupplier == nil {
+		cs, err := newX509CredentialSource(c.CredentialSource.Certificate)
+		if err != nil {
+			return tokenSource{}, err
+		}
+		client, err := cs.httpClient(c.HTTPClient)
+		if err != nil {
+			return tokenSource{}, err
+		}
+		ctx = oauth2.WithHTTPClient(ctx, client)
+	} else if c.HTTPClient != nil {
+		ctx = oauth2.WithHTTPClient(ctx, c.HTTPClient)
+	}
 	if c.WorkforcePoolUserProject != "" {
-		valid := validateWorkforceAudience(c.Audience)
+		valid := validateWorkforceAudience(c.Audience, c.universeDomain())
 		if !valid {
-			return nil, fmt.Errorf("oauth2/google: workforce_pool_user_project should not be set for non-workforce pool credentials")
+			return tokenSource{}, fmt.Errorf("oauth2/google: workforce_pool_user_project should not be set for non-workforce pool credentials")
 		}
 	}
 
-	ts := tokenSource{
-		ctx:  ctx,
-		conf: c,
+	if c.IDTokenAudience != "" {
+		if c.ServiceAccountImpersonationURL == "" {
+			return tokenSource{}, errors.New("oauth2/google: ID tokens require service account impersonation")
+		}
+		if _, err := idTokenURL(c.ServiceAccountImpersonationURL); err != nil {
+			return tokenSource{}, err
+		}
 	}
-	if c.ServiceAccountImpersonationURL == "" {
-		return oauth2.ReuseTokenSource(nil, ts), nil
+
+	conf := *c
+	if c.CredentialSource.URL != "" && c.SubjectTokenSupplier == nil {
+		// The client is shared by all subject token requests, so that
+		// their connections are reused.
+		if conf.subjectClient, err = c.subjectTokenClient(ctx); err != nil {
+			return tokenSource{}, err
+		}
+	}
+	if c.ServiceAccountImpersonationURL != "" {
+		conf.Scopes = []string{CloudPlatformScope}
 	}
-	scopes := c.Scopes
-	ts.conf.Scopes = []string{"https://www.googleapis.com/auth/cloud-platform"}
+	return tokenSource{
+		ctx:      ctx,
+		conf:     &conf,
+		subject:  &reusableSubjectToken{},
+		awsCreds: &awsCredentialsCache{},
+	}, nil
+}
+
+// impersonateTokenSource returns the token source impersonating the service
+// account of c with the tokens of ts.
+func (c *Config) impersonateTokenSource(ctx context.Context, ts oauth2.TokenSource) ImpersonateTokenSource {
 	imp := ImpersonateTokenSource{
 		Ctx:                  ctx,
 		URL:                  c.ServiceAccountImpersonationURL,
-		Scopes:               scopes,
-		Ts:                   oauth2.ReuseTokenSource(nil, ts),
+		Scopes:               c.Scopes,
+		Ts:                   ts,
 		TokenLifetimeSeconds: c.ServiceAccountImpersonationLifetimeSeconds,
+		MinLifetimeSeconds:   c.ServiceAccountImpersonationMinLifetimeSeconds,
+		QuotaProjectID:       c.QuotaProjectID,
+	}
+	if c.IDTokenAudience != "" {
+		// The URL was checked by newTokenSource.
+		imp.URL, _ = idTokenURL(c.ServiceAccountImpersonationURL)
+		imp.IDTokenAudience = c.IDTokenAudience
 	}
-	return oauth2.ReuseTokenSource(nil, imp), nil
+	return imp
+}
+
+// reuseTokenSource caches the tokens of ts, refreshing them in the
+// background if c.BackgroundRefreshWindow is set.
+func (c *Config) reuseTokenSource(ctx context.Context, ts oauth2.TokenSource) oauth2.TokenSource {
+	rts := oauth2.ReuseTokenSourceWithJitter(nil, ts, 0, c.RefreshJitter)
+	if c.BackgroundRefreshWindow > 0 {
+		rts = oauth2.ReuseTokenSourceWithBackgroundRefresh(ctx, rts, c.BackgroundRefreshWindow)
+	}
+	return rts
+}
+
+// idTokenURL returns the generateIdToken URL of the service account whose
+// generateAccessToken URL is impersonationURL.
+func idTokenURL(impersonationURL string) (string, error) {
+	if !strings.HasSuffix(impersonationURL, ":generateAccessToken") {
+		return "", fmt.Errorf("oauth2/google: ID tokens require a service account impersonation URL ending in :generateAccessToken, got %q", impersonationURL)
+	}
+	return strings.TrimSuffix(impersonationURL, ":generateAccessToken") + ":generateIdToken", nil
 }
 
 // Subject token file types.
@@ -137,9 +414,39 @@ type format struct {
 	// Type is either "text" or "json". When not provided "text" type is assumed.
 	Type string `json:"type"`
 	// SubjectTokenFieldName is only required for JSON format. This would be "access_token" for azure.
+	// It may be a dot-separated path, e.g. "data.attributes.id_token", to
+	// extract a token nested in objects, or arrays with numeric indices.
 	SubjectTokenFieldName string `json:"subject_token_field_name"`
 }
 
+// field returns the value of the field named by f.SubjectTokenFieldName in
+// data. A top-level field whose name contains dots takes precedence over a
+// nested one.
+func (f format) field(data map[string]interface{}) (interface{}, bool) {
+	if val, ok := data[f.SubjectTokenFieldName]; ok {
+		return val, true
+	}
+	var val interface{} = data
+	for _, name := range strings.Split(f.SubjectTokenFieldName, ".") {
+		switch v := val.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if val, ok = v[name]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(name)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			val = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return val, true
+}
+
 // CredentialSource stores the information necessary to retrieve the credentials for the STS exchange.
 // One field amongst File, URL, and Executable should be filled, depending on the kind of credential in question.
 // The EnvironmentID should start with AWS if being used for an AWS credential.
@@ -149,24 +456,112 @@ type CredentialSource struct {
 	URL     string            `json:"url"`
 	Headers map[string]string `json:"headers"`
 
+	// Method and Body are only used by URL credential sources. Method is
+	// the HTTP method of the subject token request, GET by default, and
+	// Body its body. ${NAME} placeholders of Body are expanded like those
+	// of the audience, so that it can carry environment-specific values.
+	// Unless set in Headers, the Content-Type of the body is
+	// application/json if it is a JSON object, and
+	// application/x-www-form-urlencoded otherwise.
+	Method string `json:"method"`
+	Body   string `json:"body"`
+
+	// ClientCertificate is only used by URL credential sources. It is
+	// presented in the TLS handshake of the subject token request, for
+	// endpoints requiring mutual TLS, but not to the security token
+	// service.
+	ClientCertificate *ClientCertificateConfig `json:"client_certificate"`
+
 	Executable *ExecutableConfig `json:"executable"`
 
+	// Certificate makes the credential source authenticate with a client
+	// certificate over mutual TLS instead of exchanging an OIDC or SAML
+	// subject token.
+	Certificate *CertificateConfig `json:"certificate"`
+
 	EnvironmentID               string `json:"environment_id"`
 	RegionURL                   string `json:"region_url"`
 	RegionalCredVerificationURL string `json:"regional_cred_verification_url"`
 	CredVerificationURL         string `json:"cred_verification_url"`
 	IMDSv2SessionTokenURL       string `json:"imdsv2_session_token_url"`
 	Format                      format `json:"format"`
+
+	// SessionName and SessionTags are only used by AWS credential sources.
+	// They are added as signed headers to the GetCallerIdentity request so
+	// that workload identity pool attribute mappings can key off stable
+	// session metadata.
+	SessionName string            `json:"session_name"`
+	SessionTags map[string]string `json:"session_tags"`
+
+	// ExtraSignedHeaders and ExtraQueryParams are only used by AWS
+	// credential sources. They are added to the GetCallerIdentity request
+	// before it is signed, e.g. to pin the API version or pass
+	// partition-specific parameters. Headers and parameters set by the
+	// signer cannot be overridden.
+	ExtraSignedHeaders map[string]string `json:"extra_signed_headers"`
+	ExtraQueryParams   map[string]string `json:"extra_query_params"`
+
+	// Partition is only used by AWS credential sources without a
+	// RegionalCredVerificationURL. It names the AWS partition of the STS
+	// endpoint of GetCallerIdentity, such as aws-us-gov or aws-cn, and is
+	// otherwise inferred from the region.
+	Partition string `json:"partition"`
+
+	// Resource and ClientID are only used by Azure credential sources, with
+	// an EnvironmentID of azure1. Resource is the application ID URI of the
+	// managed identity token requested from the Azure Instance Metadata
+	// Service, by default the audience with an https: scheme, and ClientID
+	// optionally selects a user-assigned managed identity. URL optionally
+	// overrides the metadata service endpoint. The projected token named by
+	// AZURE_FEDERATED_TOKEN_FILE is preferred only when Resource is unset.
+	Resource string `json:"resource"`
+	ClientID string `json:"client_id"`
 }
 
 type ExecutableConfig struct {
 	Command       string `json:"command"`
 	TimeoutMillis *int   `json:"timeout_millis"`
-	OutputFile    string `json:"output_file"`
+	// InteractiveTimeoutMillis bounds interactive runs of the executable,
+	// which wait for the user to sign in. The default is 5 minutes.
+	InteractiveTimeoutMillis *int   `json:"interactive_timeout_millis"`
+	OutputFile               string `json:"output_file"`
+	// OutputPipe is the path of a named pipe or Unix domain socket through
+	// which the executable writes its response instead of standard output,
+	// so that the response is not persisted. An existing named pipe is read
+	// from; otherwise a Unix domain socket private to the user is created
+	// while the executable runs, at the path followed by a suffix unique to
+	// the run. The path of the pipe or socket is passed to the executable in
+	// GOOGLE_EXTERNAL_ACCOUNT_OUTPUT_PIPE.
+	OutputPipe string `json:"output_pipe"`
+	// HelperSocket is the path of a Unix domain socket on which a long-running
+	// credential helper serves the executable response format over HTTP. When
+	// set, the helper is queried instead of running Command.
+	HelperSocket string `json:"helper_socket"`
 }
 
 // parse determines the type of CredentialSource needed.
 func (c *Config) parse(ctx context.Context) (baseCredentialSource, error) {
+	if c.SubjectTokenSupplier != nil {
+		return programmaticRefreshCredentialSource{subjectTokenSupplier: c.SubjectTokenSupplier, ctx: ctx, options: c.supplierOptions()}, nil
+	}
+	if c.AwsSecurityCredentialsSupplier != nil {
+		awsCredSource := awsCredentialSource{
+			RegionalCredVerificationURL: c.CredentialSource.RegionalCredVerificationURL,
+			Partition:                   c.CredentialSource.Partition,
+			TargetResource:              c.Audience,
+			SessionName:                 c.CredentialSource.SessionName,
+			SessionTags:                 c.CredentialSource.SessionTags,
+			ExtraSignedHeaders:          c.CredentialSource.ExtraSignedHeaders,
+			ExtraQueryParams:            c.CredentialSource.ExtraQueryParams,
+			supplier:                    c.AwsSecurityCredentialsSupplier,
+			supplierOptions:             c.supplierOptions(),
+			ctx:                         ctx,
+		}
+		if err := awsCredSource.validateExtras(); err != nil {
+			return nil, err
+		}
+		return awsCredSource, nil
+	}
 	if len(c.CredentialSource.EnvironmentID) > 3 && c.CredentialSource.EnvironmentID[:3] == "aws" {
 		if awsVersion, err := strconv.Atoi(c.CredentialSource.EnvironmentID[3:]); err == nil {
 			if awsVersion != 1 {
@@ -178,7 +573,12 @@ func (c *Config) parse(ctx context.Context) (baseCredentialSource, error) {
 				RegionURL:                   c.CredentialSource.RegionURL,
 				RegionalCredVerificationURL: c.CredentialSource.RegionalCredVerificationURL,
 				CredVerificationURL:         c.CredentialSource.URL,
+				Partition:                   c.CredentialSource.Partition,
 				TargetResource:              c.Audience,
+				SessionName:                 c.CredentialSource.SessionName,
+				SessionTags:                 c.CredentialSource.SessionTags,
+				ExtraSignedHeaders:          c.CredentialSource.ExtraSignedHeaders,
+				ExtraQueryParams:            c.CredentialSource.ExtraQueryParams,
 				ctx:                         ctx,
 			}
 			if c.CredentialSource.IMDSv2SessionTokenURL != "" {
@@ -188,19 +588,71 @@ func (c *Config) parse(ctx context.Context) (baseCredentialSource, error) {
 			if err := awsCredSource.validateMetadataServers(); err != nil {
 				return nil, err
 			}
+			if err := awsCredSource.applyMetadataEndpoint(); err != nil {
+				return nil, err
+			}
+			if err := awsCredSource.validateExtras(); err != nil {
+				return nil, err
+			}
 
 			return awsCredSource, nil
 		}
+	} else if strings.HasPrefix(c.CredentialSource.EnvironmentID, "azure") {
+		return newAzureCredentialSource(ctx, c)
+	} else if c.CredentialSource.Certificate != nil {
+		return newX509CredentialSource(c.CredentialSource.Certificate)
 	} else if c.CredentialSource.File != "" {
 		return fileCredentialSource{File: c.CredentialSource.File, Format: c.CredentialSource.Format}, nil
 	} else if c.CredentialSource.URL != "" {
-		return urlCredentialSource{URL: c.CredentialSource.URL, Headers: c.CredentialSource.Headers, Format: c.CredentialSource.Format, ctx: ctx}, nil
+		body, err := expandBody(c.CredentialSource.Body, c.CredentialSource.Headers, c.AudienceVariables)
+		if err != nil {
+			return nil, err
+		}
+		return urlCredentialSource{URL: c.CredentialSource.URL, Headers: c.CredentialSource.Headers, Method: c.CredentialSource.Method, Body: body, Format: c.CredentialSource.Format, ctx: ctx, client: c.subjectClient}, nil
 	} else if c.CredentialSource.Executable != nil {
 		return CreateExecutableCredential(ctx, c.CredentialSource.Executable, c)
 	}
 	return nil, fmt.Errorf("oauth2/google: unable to parse credential source")
 }
 
+// Revoke signs the user out by running the executable of an executable
+// credential source in revoke mode. It returns an error for other credential
+// sources.
+func (c *Config) Revoke(ctx context.Context) error {
+	if c.CredentialSource.Executable == nil {
+		return errors.New("oauth2/google: revoke is only supported for executable credential sources")
+	}
+	c, err := c.resolve()
+	if err != nil {
+		return err
+	}
+	cs, err := CreateExecutableCredential(ctx, c.CredentialSource.Executable, c)
+	if err != nil {
+		return err
+	}
+	return cs.revoke()
+}
+
+// Login signs the user in by running the executable of an executable
+// credential source in interactive mode, connected to the terminal. The
+// response is cached in the executable's output file, which is required, and
+// used by later token exchanges. It returns an error for other credential
+// sources.
+func (c *Config) Login(ctx context.Context) error {
+	if c.CredentialSource.Executable == nil {
+		return errors.New("oauth2/google: login is only supported for executable credential sources")
+	}
+	c, err := c.resolve()
+	if err != nil {
+		return err
+	}
+	cs, err := CreateExecutableCredential(ctx, c.CredentialSource.Executable, c)
+	if err != nil {
+		return err
+	}
+	return cs.login()
+}
+
 type baseCredentialSource interface {
 	subjectToken() (string, error)
 }
@@ -209,21 +661,83 @@ type baseCredentialSource interface {
 type tokenSource struct {
 	ctx  context.Context
 	conf *Config
+	// subject holds the last subject token when
+	// conf.SubjectTokenReuseMargin is set.
+	subject *reusableSubjectToken
+	// awsCreds holds the last temporary credentials of AWS credential
+	// sources.
+	awsCreds *awsCredentialsCache
 }
 
-// Token allows tokenSource to conform to the oauth2.TokenSource interface.
-func (ts tokenSource) Token() (*oauth2.Token, error) {
+// subjectToken retrieves the subject token from the credential source, or
+// reuses the previous one when allowed by conf.SubjectTokenReuseMargin.
+func (ts tokenSource) subjectToken() (string, error) {
+	reuse := ts.subject != nil && ts.conf.SubjectTokenReuseMargin > 0
+	if reuse && ts.conf.WatchSubjectTokenFile && ts.conf.CredentialSource.File != "" {
+		ts.subject.setVersion(statFileVersion(ts.conf.CredentialSource.File))
+	}
+	if reuse {
+		if token := ts.subject.get(ts.conf.SubjectTokenReuseMargin); token != "" {
+			return token, nil
+		}
+	}
+	credSource, err := ts.conf.parse(ts.ctx)
+	if err != nil {
+		return "", err
+	}
+	if aws, ok := credSource.(awsCredentialSource); ok {
+		aws.cache = ts.awsCreds
+		credSource = aws
+	}
+	if !reuse {
+		return credSource.subjectToken()
+	}
+	var token string
+	var expiry time.Time
+	if es, ok := credSource.(expiringCredentialSource); ok {
+		token, expiry, err = es.subjectTokenWithExpiry()
+	} else {
+		token, err = credSource.subjectToken()
+	}
+	if err != nil {
+		return "", err
+	}
+	ts.subject.put(token, expiry)
+	return token, nil
+}
+
+// DescribeTokenSource describes the exchange performed by ts, see
+// oauth2.DescribeTokenSource.
+func (ts tokenSource) DescribeTokenSource() (string, oauth2.TokenSource) {
 	conf := ts.conf
+	return internal.DescribeParams("external_account",
+		"audience", conf.Audience,
+		"source", conf.credentialSourceType(),
+		"file", conf.CredentialSource.File,
+		"url", internal.RedactURL(conf.CredentialSource.URL),
+		"token_url", internal.RedactURL(conf.TokenURL),
+	), nil
+}
 
-	credSource, err := conf.parse(ts.ctx)
+// Token allows tokenSource to conform to the oauth2.TokenSource interface.
+func (ts tokenSource) Token() (*oauth2.Token, error) {
+	subjectToken, err := ts.subjectToken()
 	if err != nil {
 		return nil, err
 	}
-	subjectToken, err := credSource.subjectToken()
-
+	tok, err := ts.exchange(subjectToken)
 	if err != nil {
+		// The cached AWS credentials may have been revoked.
+		ts.awsCreds.invalidate()
 		return nil, err
 	}
+	return tok, nil
+}
+
+// exchange exchanges subjectToken for a GCP access token with the security
+// token service.
+func (ts tokenSource) exchange(subjectToken string) (*oauth2.Token, error) {
+	conf := ts.conf
 	stsRequest := stsTokenExchangeRequest{
 		GrantType:          "urn:ietf:params:oauth:grant-type:token-exchange",
 		Audience:           conf.Audience,
@@ -231,9 +745,11 @@ func (ts tokenSource) Token() (*oauth2.Token, error) {
 		RequestedTokenType: "urn:ietf:params:oauth:token-type:access_token",
 		SubjectToken:       subjectToken,
 		SubjectTokenType:   conf.SubjectTokenType,
+		OptionsEncoding:    conf.OptionsEncoding,
 	}
 	header := make(http.Header)
 	header.Add("Content-Type", "application/x-www-form-urlencoded")
+	header.Add(apiClientHeader, getMetricsHeaderValue(conf))
 	clientAuth := clientAuthentication{
 		AuthStyle:    oauth2.AuthStyleInHeader,
 		ClientID:     conf.ClientID,
@@ -251,6 +767,9 @@ func (ts tokenSource) Token() (*oauth2.Token, error) {
 	if err != nil {
 		return nil, err
 	}
+	if conf.ScopeDowngrade != nil && scopesDowngraded(stsRequest.Scope, stsResp.Scope) {
+		conf.ScopeDowngrade(stsRequest.Scope, strings.Fields(stsResp.Scope))
+	}
 
 	accessToken := &oauth2.Token{
 		AccessToken: stsResp.AccessToken,
@@ -9,6 +9,9 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -68,6 +71,9 @@ func run(t *testing.T, config *Config, tets *testExchangeTokenServer) (*oauth2.T
 		if got, want := headerContentType, tets.contentType; got != want {
 			t.Errorf("got %v but want %v", got, want)
 		}
+		if got, want := r.Header.Get("x-goog-api-client"), getMetricsHeaderValue(config); got != want {
+			t.Errorf("got %v but want %v", got, want)
+		}
 		body, err := ioutil.ReadAll(r.Body)
 		if err != nil {
 			t.Fatalf("Failed reading request body: %s.", err)
@@ -244,3 +250,151 @@ func TestWorkforcePoolCreation(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateAudience(t *testing.T) {
+	var audienceTests = []struct {
+		audience       string
+		universeDomain string
+		expectSuccess  bool
+	}{
+		{"//iam.googleapis.com/projects/123456/locations/global/workloadIdentityPools/pool-id/providers/provider-id", "", true},
+		{"//iam.googleapis.com/locations/global/workforcePools/pool-id/providers/provider-id", "", true},
+		{"//iam.example.goog/locations/global/workforcePools/pool-id/providers/provider-id", "example.goog", true},
+		{"32555940559.apps.googleusercontent.com", "", true},
+		{"", "", false},
+		{"//iam.googleapis.com/projects/123456/locations/global/workloadIdentityPools/pool-id", "", false},
+		{"//iam.googleapis.com/locations/global/workforcePools/pool-id/providers/", "", false},
+		{"//iam.googleapis.com/locations/global/workforcePools/pool-id/providers/provider-id", "example.goog", false},
+		{"//iam.example.goog/projects/123456/locations/global/workloadIdentityPools/pool-id/providers/provider-id", "", false},
+	}
+	for _, tt := range audienceTests {
+		t.Run(" "+tt.audience, func(t *testing.T) {
+			config := testConfig
+			config.Audience = tt.audience
+			config.UniverseDomain = tt.universeDomain
+			_, err := config.TokenSource(context.Background())
+			if tt.expectSuccess && err != nil {
+				t.Errorf("got %v but want nil", err)
+			} else if !tt.expectSuccess && err == nil {
+				t.Errorf("got nil but expected an error")
+			}
+		})
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestToken_HTTPClient(t *testing.T) {
+	var requests int
+	config := Config{
+		Audience:             "32555940559.apps.googleusercontent.com",
+		SubjectTokenType:     "urn:ietf:params:oauth:token-type:jwt",
+		TokenURL:             "https://sts.example.invalid/v1/token",
+		SubjectTokenSupplier: testSubjectTokenSupplier{subjectToken: "subjectToken"},
+		HTTPClient: &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			requests++
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       ioutil.NopCloser(strings.NewReader(`{"access_token":"Sample.Access.Token","token_type":"Bearer","expires_in":3600}`)),
+			}, nil
+		})},
+	}
+	ts, err := config.TokenSource(context.Background())
+	if err != nil {
+		t.Fatalf("TokenSource() failed: %v", err)
+	}
+	tok, err := ts.Token()
+	if err != nil {
+		t.Fatalf("Token() failed: %v", err)
+	}
+	if got, want := tok.AccessToken, "Sample.Access.Token"; got != want {
+		t.Errorf("got %v but want %v", got, want)
+	}
+	if requests != 1 {
+		t.Errorf("got %d requests through the configured client but want 1", requests)
+	}
+}
+
+func TestToken_ScopeDowngrade(t *testing.T) {
+	var requested, granted []string
+	config := Config{
+		Audience:             "32555940559.apps.googleusercontent.com",
+		SubjectTokenType:     "urn:ietf:params:oauth:token-type:jwt",
+		TokenURL:             "https://sts.example.invalid/v1/token",
+		Scopes:               []string{"https://www.googleapis.com/auth/devstorage.full_control"},
+		SubjectTokenSupplier: testSubjectTokenSupplier{subjectToken: "subjectToken"},
+		HTTPClient: &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       ioutil.NopCloser(strings.NewReader(`{"access_token":"Sample.Access.Token","token_type":"Bearer","expires_in":3600,"scope":"https://www.googleapis.com/auth/devstorage.read_only"}`)),
+			}, nil
+		})},
+		ScopeDowngrade: func(r, g []string) { requested, granted = r, g },
+	}
+	ts, err := config.TokenSource(context.Background())
+	if err != nil {
+		t.Fatalf("TokenSource() failed: %v", err)
+	}
+	if _, err := ts.Token(); err != nil {
+		t.Fatalf("Token() failed: %v", err)
+	}
+	if got, want := granted, []string{"https://www.googleapis.com/auth/devstorage.read_only"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got granted scopes %q but want %q", got, want)
+	}
+	if got, want := requested, config.Scopes; !reflect.DeepEqual(got, want) {
+		t.Errorf("got requested scopes %q but want %q", got, want)
+	}
+}
+
+func TestToken_BackgroundRefresh(t *testing.T) {
+	var mu sync.Mutex
+	var requests int
+	config := Config{
+		Audience:             "32555940559.apps.googleusercontent.com",
+		SubjectTokenType:     "urn:ietf:params:oauth:token-type:jwt",
+		TokenURL:             "https://sts.example.invalid/v1/token",
+		SubjectTokenSupplier: testSubjectTokenSupplier{subjectToken: "subjectToken"},
+		HTTPClient: &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			requests++
+			mu.Unlock()
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       ioutil.NopCloser(strings.NewReader(`{"access_token":"Sample.Access.Token","token_type":"Bearer","expires_in":3600}`)),
+			}, nil
+		})},
+		BackgroundRefreshWindow: time.Minute,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ts, err := config.TokenSource(ctx)
+	if err != nil {
+		t.Fatalf("TokenSource() failed: %v", err)
+	}
+	requestCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for requestCount() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("no token exchange was made in the background")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	tok, err := ts.Token()
+	if err != nil {
+		t.Fatalf("Token() failed: %v", err)
+	}
+	if got, want := tok.AccessToken, "Sample.Access.Token"; got != want {
+		t.Errorf("got %v but want %v", got, want)
+	}
+}
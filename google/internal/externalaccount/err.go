@@ -4,7 +4,13 @@
 
 package externalaccount
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/oauth2"
+)
 
 // Error for handling OAuth related error responses as stated in rfc6749#5.2.
 type Error struct {
@@ -16,3 +22,38 @@ type Error struct {
 func (err *Error) Error() string {
 	return fmt.Sprintf("got error code %s from %s: %s", err.Code, err.URI, err.Description)
 }
+
+// ResponseMismatchError is returned when a field of the Security Token Service
+// response does not match what was requested.
+type ResponseMismatchError struct {
+	// Field is the name of the mismatched response field, e.g. "token_type".
+	Field string
+	// Requested is the value that was requested or expected.
+	Requested string
+	// Returned is the value found in the response.
+	Returned string
+}
+
+func (err *ResponseMismatchError) Error() string {
+	return fmt.Sprintf("oauth2/google: security token service returned %s %q, but %q was requested", err.Field, err.Returned, err.Requested)
+}
+
+// newSTSError returns the *oauth2.RetrieveError of a failed Security Token
+// Service exchange, with the error, error_description and error_uri fields
+// of the RFC 6749 error response when the body has them. Callers can use
+// errors.As to tell, for example, an invalid_grant from a server error.
+func newSTSError(resp *http.Response, body []byte) error {
+	var e struct {
+		Code        string `json:"error"`
+		Description string `json:"error_description"`
+		URI         string `json:"error_uri"`
+	}
+	json.Unmarshal(body, &e) // the fields are optional
+	return &oauth2.RetrieveError{
+		Response:         resp,
+		Body:             body,
+		ErrorCode:        e.Code,
+		ErrorDescription: e.Description,
+		ErrorURI:         e.URI,
+	}
+}
@@ -17,3 +17,15 @@ func TestError(t *testing.T) {
 		t.Errorf("Got error message %q; want %q", got, want)
 	}
 }
+
+func TestResponseMismatchError(t *testing.T) {
+	e := ResponseMismatchError{
+		Field:     "token_type",
+		Requested: "Bearer",
+		Returned:  "MAC",
+	}
+	want := `oauth2/google: security token service returned token_type "MAC", but "Bearer" was requested`
+	if got := e.Error(); got != want {
+		t.Errorf("Got error message %q; want %q", got, want)
+	}
+}
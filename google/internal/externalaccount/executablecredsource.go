@@ -26,6 +26,9 @@ const (
 	defaultTimeout                = 30 * time.Second
 	timeoutMinimum                = 5 * time.Second
 	timeoutMaximum                = 120 * time.Second
+	defaultInteractiveTimeout     = 5 * time.Minute
+	interactiveTimeoutMinimum     = 30 * time.Second
+	interactiveTimeoutMaximum     = 30 * time.Minute
 	executableSource              = "response"
 	outputFileSource              = "output file"
 )
@@ -82,6 +85,18 @@ func timeoutRangeError() error {
 	return errors.New("oauth2/google: invalid `timeout_millis` field — executable timeout must be between 5 and 120 seconds")
 }
 
+func interactiveTimeoutRangeError() error {
+	return errors.New("oauth2/google: invalid `interactive_timeout_millis` field — executable interactive timeout must be between 30 seconds and 30 minutes")
+}
+
+func interactiveOutputFileError() error {
+	return errors.New("oauth2/google: interactive mode requires the executable to set an `output_file`")
+}
+
+func missingInteractiveResponseError() error {
+	return errors.New("oauth2/google: executable did not write a response to its output file in interactive mode")
+}
+
 func commandMissingError() error {
 	return errors.New("oauth2/google: missing `command` field — executable command must be provided")
 }
@@ -90,6 +105,7 @@ type environment interface {
 	existingEnv() []string
 	getenv(string) string
 	run(ctx context.Context, command string, env []string) ([]byte, error)
+	runInteractive(ctx context.Context, command string, env []string) error
 	now() time.Time
 }
 
@@ -135,24 +151,51 @@ func (r runtimeEnvironment) run(ctx context.Context, command string, env []strin
 	return bytes.TrimSpace(stderr.Bytes()), nil
 }
 
+// runInteractive runs command connected to the terminal of this process, so
+// that it can prompt the user. Its response is read from the output file.
+func (r runtimeEnvironment) runInteractive(ctx context.Context, command string, env []string) error {
+	splitCommand := strings.Fields(command)
+	cmd := exec.CommandContext(ctx, splitCommand[0], splitCommand[1:]...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return context.DeadlineExceeded
+		}
+		if exitError, ok := err.(*exec.ExitError); ok {
+			return exitCodeError(exitError.ExitCode())
+		}
+		return executableError(err)
+	}
+	return nil
+}
+
 type executableCredentialSource struct {
-	Command    string
-	Timeout    time.Duration
-	OutputFile string
-	ctx        context.Context
-	config     *Config
-	env        environment
+	Command            string
+	Timeout            time.Duration
+	InteractiveTimeout time.Duration
+	Interactive        bool
+	OutputFile         string
+	OutputPipe         string
+	HelperSocket       string
+	ctx                context.Context
+	config             *Config
+	env                environment
 }
 
 // CreateExecutableCredential creates an executableCredentialSource given an ExecutableConfig.
 // It also performs defaulting and type conversions.
 func CreateExecutableCredential(ctx context.Context, ec *ExecutableConfig, config *Config) (executableCredentialSource, error) {
-	if ec.Command == "" {
+	if ec.Command == "" && ec.HelperSocket == "" {
 		return executableCredentialSource{}, commandMissingError()
 	}
 
 	result := executableCredentialSource{}
 	result.Command = ec.Command
+	result.HelperSocket = ec.HelperSocket
 	if ec.TimeoutMillis == nil {
 		result.Timeout = defaultTimeout
 	} else {
@@ -161,7 +204,20 @@ func CreateExecutableCredential(ctx context.Context, ec *ExecutableConfig, confi
 			return executableCredentialSource{}, timeoutRangeError()
 		}
 	}
+	if ec.InteractiveTimeoutMillis == nil {
+		result.InteractiveTimeout = defaultInteractiveTimeout
+	} else {
+		result.InteractiveTimeout = time.Duration(*ec.InteractiveTimeoutMillis) * time.Millisecond
+		if result.InteractiveTimeout < interactiveTimeoutMinimum || result.InteractiveTimeout > interactiveTimeoutMaximum {
+			return executableCredentialSource{}, interactiveTimeoutRangeError()
+		}
+	}
 	result.OutputFile = ec.OutputFile
+	result.OutputPipe = ec.OutputPipe
+	result.Interactive = config != nil && config.Interactive
+	if result.Interactive && result.OutputFile == "" {
+		return executableCredentialSource{}, interactiveOutputFileError()
+	}
 	result.ctx = ctx
 	result.config = config
 	result.env = runtimeEnvironment{}
@@ -179,131 +235,289 @@ type executableResponse struct {
 	Message        string `json:"message,omitempty"`
 }
 
-func (cs executableCredentialSource) parseSubjectTokenFromSource(response []byte, source string, now int64) (string, error) {
+// parseSubjectTokenFromSource returns the subject token of an executable
+// response and its expiration_time, which is zero if the response has none.
+func (cs executableCredentialSource) parseSubjectTokenFromSource(response []byte, source string, now int64) (string, int64, error) {
 	var result executableResponse
 	if err := json.Unmarshal(response, &result); err != nil {
-		return "", jsonParsingError(source, string(response))
+		return "", 0, jsonParsingError(source, string(response))
 	}
 
 	if result.Version == 0 {
-		return "", missingFieldError(source, "version")
+		return "", 0, missingFieldError(source, "version")
 	}
 
 	if result.Success == nil {
-		return "", missingFieldError(source, "success")
+		return "", 0, missingFieldError(source, "success")
 	}
 
 	if !*result.Success {
 		if result.Code == "" || result.Message == "" {
-			return "", malformedFailureError()
+			return "", 0, malformedFailureError()
 		}
-		return "", userDefinedError(result.Code, result.Message)
+		return "", 0, userDefinedError(result.Code, result.Message)
 	}
 
 	if result.Version > executableSupportedMaxVersion || result.Version < 0 {
-		return "", unsupportedVersionError(source, result.Version)
+		return "", 0, unsupportedVersionError(source, result.Version)
 	}
 
 	if result.ExpirationTime == 0 && cs.OutputFile != "" {
-		return "", missingFieldError(source, "expiration_time")
+		return "", 0, missingFieldError(source, "expiration_time")
 	}
 
 	if result.TokenType == "" {
-		return "", missingFieldError(source, "token_type")
+		return "", 0, missingFieldError(source, "token_type")
 	}
 
 	if result.ExpirationTime != 0 && result.ExpirationTime < now {
-		return "", tokenExpiredError()
+		return "", 0, tokenExpiredError()
 	}
 
 	if result.TokenType == "urn:ietf:params:oauth:token-type:jwt" || result.TokenType == "urn:ietf:params:oauth:token-type:id_token" {
 		if result.IdToken == "" {
-			return "", missingFieldError(source, "id_token")
+			return "", 0, missingFieldError(source, "id_token")
 		}
-		return result.IdToken, nil
+		return result.IdToken, result.ExpirationTime, nil
 	}
 
 	if result.TokenType == "urn:ietf:params:oauth:token-type:saml2" {
 		if result.SamlResponse == "" {
-			return "", missingFieldError(source, "saml_response")
+			return "", 0, missingFieldError(source, "saml_response")
 		}
-		return result.SamlResponse, nil
+		return result.SamlResponse, result.ExpirationTime, nil
 	}
 
-	return "", tokenTypeError(source)
+	return "", 0, tokenTypeError(source)
 }
 
 func (cs executableCredentialSource) subjectToken() (string, error) {
-	if token, err := cs.getTokenFromOutputFile(); token != "" || err != nil {
-		return token, err
-	}
+	token, _, err := cs.subjectTokenWithExpiry()
+	return token, err
+}
 
-	return cs.getTokenFromExecutableCommand()
+// subjectTokenWithExpiry returns the subject token and the expiration_time
+// reported by the executable, or the zero time if it reported none.
+func (cs executableCredentialSource) subjectTokenWithExpiry() (string, time.Time, error) {
+	token, expiration, err := cs.getTokenFromOutputFile()
+	if token == "" && err == nil {
+		if cs.HelperSocket != "" {
+			token, expiration, err = cs.getTokenFromHelper()
+		} else if cs.Interactive {
+			token, expiration, err = cs.getTokenFromInteractiveCommand()
+		} else {
+			token, expiration, err = cs.getTokenFromExecutableCommand()
+		}
+	}
+	if err != nil || expiration == 0 {
+		return token, time.Time{}, err
+	}
+	return token, time.Unix(expiration, 0), nil
 }
 
-func (cs executableCredentialSource) getTokenFromOutputFile() (token string, err error) {
+func (cs executableCredentialSource) getTokenFromOutputFile() (token string, expiration int64, err error) {
 	if cs.OutputFile == "" {
 		// This ExecutableCredentialSource doesn't use an OutputFile.
-		return "", nil
+		return "", 0, nil
 	}
 
 	file, err := os.Open(cs.OutputFile)
 	if err != nil {
 		// No OutputFile found. Hasn't been created yet, so skip it.
-		return "", nil
+		return "", 0, nil
 	}
 	defer file.Close()
 
 	data, err := ioutil.ReadAll(io.LimitReader(file, 1<<20))
 	if err != nil || len(data) == 0 {
 		// Cachefile exists, but no data found. Get new credential.
-		return "", nil
+		return "", 0, nil
 	}
 
-	token, err = cs.parseSubjectTokenFromSource(data, outputFileSource, cs.env.now().Unix())
+	token, expiration, err = cs.parseSubjectTokenFromSource(data, outputFileSource, cs.env.now().Unix())
 	if err != nil {
 		if _, ok := err.(nonCacheableError); ok {
 			// If the cached token is expired we need a new token,
 			// and if the cache contains a failure, we need to try again.
-			return "", nil
+			return "", 0, nil
 		}
 
 		// There was an error in the cached token, and the developer should be aware of it.
-		return "", err
+		return "", 0, err
 	}
 	// Token parsing succeeded.  Use found token.
-	return token, nil
+	return token, expiration, nil
 }
 
 func (cs executableCredentialSource) executableEnvironment() []string {
 	result := cs.env.existingEnv()
 	result = append(result, fmt.Sprintf("GOOGLE_EXTERNAL_ACCOUNT_AUDIENCE=%v", cs.config.Audience))
 	result = append(result, fmt.Sprintf("GOOGLE_EXTERNAL_ACCOUNT_TOKEN_TYPE=%v", cs.config.SubjectTokenType))
-	result = append(result, "GOOGLE_EXTERNAL_ACCOUNT_INTERACTIVE=0")
-	if cs.config.ServiceAccountImpersonationURL != "" {
-		matches := serviceAccountImpersonationRE.FindStringSubmatch(cs.config.ServiceAccountImpersonationURL)
-		if matches != nil {
-			result = append(result, fmt.Sprintf("GOOGLE_EXTERNAL_ACCOUNT_IMPERSONATED_EMAIL=%v", matches[1]))
-		}
+	if cs.Interactive {
+		result = append(result, "GOOGLE_EXTERNAL_ACCOUNT_INTERACTIVE=1")
+	} else {
+		result = append(result, "GOOGLE_EXTERNAL_ACCOUNT_INTERACTIVE=0")
+	}
+	if email := cs.impersonatedEmail(); email != "" {
+		result = append(result, fmt.Sprintf("GOOGLE_EXTERNAL_ACCOUNT_IMPERSONATED_EMAIL=%v", email))
 	}
 	if cs.OutputFile != "" {
 		result = append(result, fmt.Sprintf("GOOGLE_EXTERNAL_ACCOUNT_OUTPUT_FILE=%v", cs.OutputFile))
 	}
+	if cs.OutputPipe != "" {
+		result = append(result, fmt.Sprintf("GOOGLE_EXTERNAL_ACCOUNT_OUTPUT_PIPE=%v", cs.OutputPipe))
+	}
 	return result
 }
 
-func (cs executableCredentialSource) getTokenFromExecutableCommand() (string, error) {
+// impersonatedEmail returns the service account email extracted from the
+// impersonation URL, or "" if there is none.
+func (cs executableCredentialSource) impersonatedEmail() string {
+	if cs.config.ServiceAccountImpersonationURL == "" {
+		return ""
+	}
+	matches := serviceAccountImpersonationRE.FindStringSubmatch(cs.config.ServiceAccountImpersonationURL)
+	if matches == nil {
+		return ""
+	}
+	return matches[1]
+}
+
+func (cs executableCredentialSource) getTokenFromExecutableCommand() (string, int64, error) {
 	// For security reasons, we need our consumers to set this environment variable to allow executables to be run.
 	if cs.env.getenv("GOOGLE_EXTERNAL_ACCOUNT_ALLOW_EXECUTABLES") != "1" {
-		return "", executablesDisallowedError()
+		return "", 0, executablesDisallowedError()
 	}
 
 	ctx, cancel := context.WithDeadline(cs.ctx, cs.env.now().Add(cs.Timeout))
 	defer cancel()
 
-	output, err := cs.env.run(ctx, cs.Command, cs.executableEnvironment())
+	output, err := cs.runForResponse(ctx, cs.executableEnvironment())
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 	return cs.parseSubjectTokenFromSource(output, executableSource, cs.env.now().Unix())
 }
+
+// runForResponse runs the executable and returns its response, read from
+// the output pipe if there is one and from its output otherwise.
+func (cs executableCredentialSource) runForResponse(ctx context.Context, env []string) ([]byte, error) {
+	if cs.OutputPipe == "" {
+		return cs.env.run(ctx, cs.Command, env)
+	}
+	pipe, err := openOutputPipe(cs.OutputPipe)
+	if err != nil {
+		return nil, err
+	}
+	defer pipe.close()
+	env = append([]string(nil), env...)
+	for i, kv := range env {
+		if strings.HasPrefix(kv, "GOOGLE_EXTERNAL_ACCOUNT_OUTPUT_PIPE=") {
+			env[i] = "GOOGLE_EXTERNAL_ACCOUNT_OUTPUT_PIPE=" + pipe.path
+		}
+	}
+	if _, err := cs.env.run(ctx, cs.Command, env); err != nil {
+		return nil, err
+	}
+	return pipe.response()
+}
+
+// getTokenFromInteractiveCommand runs the executable in interactive mode, in
+// which it may prompt the user to sign in, and reads its response from the
+// output file.
+func (cs executableCredentialSource) getTokenFromInteractiveCommand() (string, int64, error) {
+	if cs.env.getenv("GOOGLE_EXTERNAL_ACCOUNT_ALLOW_EXECUTABLES") != "1" {
+		return "", 0, executablesDisallowedError()
+	}
+	if cs.OutputFile == "" {
+		return "", 0, interactiveOutputFileError()
+	}
+
+	ctx, cancel := context.WithDeadline(cs.ctx, cs.env.now().Add(cs.InteractiveTimeout))
+	defer cancel()
+
+	if err := cs.env.runInteractive(ctx, cs.Command, cs.executableEnvironment()); err != nil {
+		return "", 0, err
+	}
+	data, err := ioutil.ReadFile(cs.OutputFile)
+	if err != nil || len(bytes.TrimSpace(data)) == 0 {
+		return "", 0, missingInteractiveResponseError()
+	}
+	return cs.parseSubjectTokenFromSource(data, outputFileSource, cs.env.now().Unix())
+}
+
+// login signs the user in by running the executable in interactive mode, even
+// if its output file holds a valid response.
+func (cs executableCredentialSource) login() error {
+	if cs.Command == "" {
+		return errors.New("oauth2/google: login requires an executable command")
+	}
+	cs.Interactive = true
+	_, _, err := cs.getTokenFromInteractiveCommand()
+	return err
+}
+
+// revoke runs the executable in revoke mode so that it signs the user out of
+// the identity provider. The executable only reports whether it succeeded.
+// On success, the response cached in the output file is removed.
+func (cs executableCredentialSource) revoke() error {
+	if cs.Command == "" {
+		return errors.New("oauth2/google: revoke requires an executable command")
+	}
+	if cs.env.getenv("GOOGLE_EXTERNAL_ACCOUNT_ALLOW_EXECUTABLES") != "1" {
+		return executablesDisallowedError()
+	}
+
+	ctx, cancel := context.WithDeadline(cs.ctx, cs.env.now().Add(cs.Timeout))
+	defer cancel()
+
+	output, err := cs.runForResponse(ctx, cs.revokeEnvironment())
+	if err != nil {
+		return err
+	}
+	if err := parseRevokeResponse(output); err != nil {
+		return err
+	}
+	if cs.OutputFile != "" {
+		if err := os.Remove(cs.OutputFile); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("oauth2/google: unable to remove output file after revoke: %v", err)
+		}
+	}
+	return nil
+}
+
+// revokeEnvironment returns the environment of a revoke invocation. Revoking
+// is always interactive since the executable may need to confirm the sign-out
+// with the user.
+func (cs executableCredentialSource) revokeEnvironment() []string {
+	env := cs.executableEnvironment()
+	for i, kv := range env {
+		if kv == "GOOGLE_EXTERNAL_ACCOUNT_INTERACTIVE=0" {
+			env[i] = "GOOGLE_EXTERNAL_ACCOUNT_INTERACTIVE=1"
+		}
+	}
+	return append(env, "GOOGLE_EXTERNAL_ACCOUNT_REVOKE=1")
+}
+
+func parseRevokeResponse(response []byte) error {
+	var result executableResponse
+	if err := json.Unmarshal(response, &result); err != nil {
+		return jsonParsingError(executableSource, string(response))
+	}
+	if result.Version == 0 {
+		return missingFieldError(executableSource, "version")
+	}
+	if result.Success == nil {
+		return missingFieldError(executableSource, "success")
+	}
+	if !*result.Success {
+		if result.Code == "" || result.Message == "" {
+			return malformedFailureError()
+		}
+		return userDefinedError(result.Code, result.Message)
+	}
+	if result.Version > executableSupportedMaxVersion || result.Version < 0 {
+		return unsupportedVersionError(executableSource, result.Version)
+	}
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"sort"
 	"testing"
 	"time"
@@ -23,6 +24,13 @@ type testEnvironment struct {
 	deadlineSet  bool
 	byteResponse []byte
 	jsonResponse *executableResponse
+	runEnv       []string
+	// interactiveRuns counts the calls of runInteractive, which writes
+	// jsonResponse to outputFile.
+	interactiveRuns int
+	outputFile      string
+	// onRun, if set, is called by run with the executable environment.
+	onRun func(env []string) error
 }
 
 var executablesAllowed = map[string]string{
@@ -43,12 +51,29 @@ func (t *testEnvironment) getenv(key string) string {
 
 func (t *testEnvironment) run(ctx context.Context, command string, env []string) ([]byte, error) {
 	t.deadline, t.deadlineSet = ctx.Deadline()
+	t.runEnv = env
+	if t.onRun != nil {
+		if err := t.onRun(env); err != nil {
+			return nil, err
+		}
+	}
 	if t.jsonResponse != nil {
 		return json.Marshal(t.jsonResponse)
 	}
 	return t.byteResponse, nil
 }
 
+func (t *testEnvironment) runInteractive(ctx context.Context, command string, env []string) error {
+	t.deadline, t.deadlineSet = ctx.Deadline()
+	t.runEnv = env
+	t.interactiveRuns++
+	b, err := json.Marshal(t.jsonResponse)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(t.outputFile, b, 0600)
+}
+
 func (t *testEnvironment) getDeadline() (time.Time, bool) {
 	return t.deadline, t.deadlineSet
 }
@@ -1006,10 +1031,15 @@ func TestRetrieveOutputFileSubjectTokenJwt(t *testing.T) {
 				return
 			}
 
-			if out, err := ecs.subjectToken(); err != nil {
+			if out, expiry, err := ecs.subjectTokenWithExpiry(); err != nil {
 				t.Errorf("retrieveSubjectToken() failed: %v", err)
-			} else if got, want := out, "tokentokentoken"; got != want {
-				t.Errorf("Incorrect token received.\nExpected: %s\nRecieved: %s", want, got)
+			} else {
+				if got, want := out, "tokentokentoken"; got != want {
+					t.Errorf("Incorrect token received.\nExpected: %s\nRecieved: %s", want, got)
+				}
+				if got, want := expiry, time.Unix(tt.outputFileContents.ExpirationTime, 0); !got.Equal(want) {
+					t.Errorf("got expiry %v but want %v", got, want)
+				}
 			}
 
 			if _, deadlineSet := te.getDeadline(); deadlineSet {
@@ -1018,3 +1048,225 @@ func TestRetrieveOutputFileSubjectTokenJwt(t *testing.T) {
 		})
 	}
 }
+
+func TestExecutableCredentialRevoke(t *testing.T) {
+	tfc := testFileConfig
+	tfc.CredentialSource = CredentialSource{
+		Executable: &ExecutableConfig{Command: "blarg"},
+	}
+	base, err := tfc.parse(context.Background())
+	if err != nil {
+		t.Fatalf("parse() failed %v", err)
+	}
+	ecs := base.(executableCredentialSource)
+
+	tests := []struct {
+		name     string
+		response *executableResponse
+		wantErr  bool
+	}{
+		{
+			name:     "success",
+			response: &executableResponse{Version: 1, Success: Bool(true)},
+		},
+		{
+			name:     "failure",
+			response: &executableResponse{Version: 1, Success: Bool(false), Code: "404", Message: "not signed in"},
+			wantErr:  true,
+		},
+		{
+			name:     "missing success",
+			response: &executableResponse{Version: 1},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := &testEnvironment{envVars: executablesAllowed, jsonResponse: tt.response}
+			ecs.env = env
+			err := ecs.revoke()
+			if gotErr := err != nil; gotErr != tt.wantErr {
+				t.Fatalf("got error %v but want error %v", err, tt.wantErr)
+			}
+			for _, want := range []string{"GOOGLE_EXTERNAL_ACCOUNT_REVOKE=1", "GOOGLE_EXTERNAL_ACCOUNT_INTERACTIVE=1"} {
+				found := false
+				for _, kv := range env.runEnv {
+					found = found || kv == want
+				}
+				if !found {
+					t.Errorf("executable environment is missing %v", want)
+				}
+			}
+		})
+	}
+
+	ecs.env = &testEnvironment{}
+	if err := ecs.revoke(); err == nil {
+		t.Error("revoke() succeeded without GOOGLE_EXTERNAL_ACCOUNT_ALLOW_EXECUTABLES, want error")
+	}
+}
+
+func hasEnv(env []string, want string) bool {
+	for _, kv := range env {
+		if kv == want {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCreateExecutableCredentialInteractive(t *testing.T) {
+	tests := []struct {
+		name        string
+		ec          ExecutableConfig
+		interactive bool
+		wantErr     error
+		wantTimeout time.Duration
+	}{
+		{
+			name:        "default interactive timeout",
+			ec:          ExecutableConfig{Command: "blarg", OutputFile: "out.json"},
+			interactive: true,
+			wantTimeout: defaultInteractiveTimeout,
+		},
+		{
+			name:        "interactive timeout",
+			ec:          ExecutableConfig{Command: "blarg", OutputFile: "out.json", InteractiveTimeoutMillis: Int(60000)},
+			interactive: true,
+			wantTimeout: time.Minute,
+		},
+		{
+			name:    "interactive timeout too low",
+			ec:      ExecutableConfig{Command: "blarg", InteractiveTimeoutMillis: Int(29999)},
+			wantErr: interactiveTimeoutRangeError(),
+		},
+		{
+			name:    "interactive timeout too high",
+			ec:      ExecutableConfig{Command: "blarg", InteractiveTimeoutMillis: Int(1800001)},
+			wantErr: interactiveTimeoutRangeError(),
+		},
+		{
+			name:        "interactive without output file",
+			ec:          ExecutableConfig{Command: "blarg"},
+			interactive: true,
+			wantErr:     interactiveOutputFileError(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testFileConfig
+			config.Interactive = tt.interactive
+			ecs, err := CreateExecutableCredential(context.Background(), &tt.ec, &config)
+			if tt.wantErr != nil {
+				if err == nil || err.Error() != tt.wantErr.Error() {
+					t.Errorf("got error %v but want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateExecutableCredential() failed: %v", err)
+			}
+			if ecs.InteractiveTimeout != tt.wantTimeout {
+				t.Errorf("got interactive timeout %v but want %v", ecs.InteractiveTimeout, tt.wantTimeout)
+			}
+		})
+	}
+}
+
+func newInteractiveTestSource(t *testing.T, interactive bool) (executableCredentialSource, *testEnvironment) {
+	t.Helper()
+	outputFile := filepath.Join(t.TempDir(), "result.json")
+	tfc := testFileConfig
+	tfc.Interactive = interactive
+	tfc.CredentialSource = CredentialSource{
+		Executable: &ExecutableConfig{Command: "blarg", OutputFile: outputFile},
+	}
+	base, err := tfc.parse(context.Background())
+	if err != nil {
+		t.Fatalf("parse() failed %v", err)
+	}
+	te := &testEnvironment{
+		envVars:    executablesAllowed,
+		outputFile: outputFile,
+		jsonResponse: &executableResponse{
+			Success:        Bool(true),
+			Version:        1,
+			ExpirationTime: defaultTime.Unix() + 3600,
+			TokenType:      "urn:ietf:params:oauth:token-type:id_token",
+			IdToken:        "interactive-token",
+		},
+	}
+	ecs := base.(executableCredentialSource)
+	ecs.env = te
+	return ecs, te
+}
+
+func TestRetrieveExecutableSubjectTokenInteractive(t *testing.T) {
+	ecs, te := newInteractiveTestSource(t, true)
+
+	out, err := ecs.subjectToken()
+	if err != nil {
+		t.Fatalf("subjectToken() failed: %v", err)
+	}
+	if got, want := out, "interactive-token"; got != want {
+		t.Errorf("got %v but want %v", got, want)
+	}
+	if deadline, _ := te.getDeadline(); deadline != defaultTime.Add(defaultInteractiveTimeout) {
+		t.Errorf("got deadline %v but want %v", deadline, defaultTime.Add(defaultInteractiveTimeout))
+	}
+	if !hasEnv(te.runEnv, "GOOGLE_EXTERNAL_ACCOUNT_INTERACTIVE=1") {
+		t.Errorf("executable environment is missing GOOGLE_EXTERNAL_ACCOUNT_INTERACTIVE=1")
+	}
+
+	// The response cached in the output file is used without prompting again.
+	if _, err := ecs.subjectToken(); err != nil {
+		t.Fatalf("subjectToken() failed: %v", err)
+	}
+	if got, want := te.interactiveRuns, 1; got != want {
+		t.Errorf("got %v interactive runs but want %v", got, want)
+	}
+
+	// A failure written to the output file is reported.
+	os.Remove(te.outputFile)
+	te.jsonResponse = &executableResponse{Version: 1, Success: Bool(false), Code: "401", Message: "sign-in cancelled"}
+	if _, err := ecs.subjectToken(); err == nil || err.Error() != userDefinedError("401", "sign-in cancelled").Error() {
+		t.Errorf("got error %v but want the executable's error", err)
+	}
+}
+
+func TestExecutableCredentialLogin(t *testing.T) {
+	ecs, te := newInteractiveTestSource(t, false)
+	// A valid cached response does not prevent signing in again.
+	cached := *te.jsonResponse
+	cached.IdToken = "cached-token"
+	b, _ := json.Marshal(cached)
+	if err := ioutil.WriteFile(te.outputFile, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ecs.login(); err != nil {
+		t.Fatalf("login() failed: %v", err)
+	}
+	if got, want := te.interactiveRuns, 1; got != want {
+		t.Errorf("got %v interactive runs but want %v", got, want)
+	}
+	if !hasEnv(te.runEnv, "GOOGLE_EXTERNAL_ACCOUNT_INTERACTIVE=1") {
+		t.Errorf("executable environment is missing GOOGLE_EXTERNAL_ACCOUNT_INTERACTIVE=1")
+	}
+	out, err := ecs.subjectToken()
+	if err != nil {
+		t.Fatalf("subjectToken() failed: %v", err)
+	}
+	if got, want := out, "interactive-token"; got != want {
+		t.Errorf("got %v but want %v", got, want)
+	}
+
+	// Revoking removes the cached response.
+	te.jsonResponse = &executableResponse{Version: 1, Success: Bool(true)}
+	if err := ecs.revoke(); err != nil {
+		t.Fatalf("revoke() failed: %v", err)
+	}
+	if _, err := os.Stat(te.outputFile); !os.IsNotExist(err) {
+		t.Errorf("output file still exists after revoke: %v", err)
+	}
+}
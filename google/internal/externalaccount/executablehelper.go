@@ -0,0 +1,90 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package externalaccount
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+)
+
+const (
+	helperSource = "credential helper response"
+
+	// helperTokenURL is the URL requested from the credential helper. The host
+	// is ignored since the connection is always made to the helper socket.
+	helperTokenURL = "http://localhost/token"
+)
+
+// helperRequest is the JSON body sent to a credential helper. It carries the
+// same information that is passed to executables through the environment.
+type helperRequest struct {
+	Audience          string `json:"audience"`
+	SubjectTokenType  string `json:"subject_token_type"`
+	ImpersonatedEmail string `json:"impersonated_email,omitempty"`
+	Interactive       bool   `json:"interactive"`
+}
+
+func helperError(err error) error {
+	return fmt.Errorf("oauth2/google: credential helper request failed: %v", err)
+}
+
+// getTokenFromHelper retrieves the subject token from a long-running
+// credential helper listening on cs.HelperSocket. The helper responds with
+// the same JSON document an executable would print to stdout.
+func (cs executableCredentialSource) getTokenFromHelper() (string, int64, error) {
+	ctx, cancel := context.WithTimeout(cs.ctx, cs.Timeout)
+	defer cancel()
+
+	reqBody, err := json.Marshal(helperRequest{
+		Audience:          cs.config.Audience,
+		SubjectTokenType:  cs.config.SubjectTokenType,
+		ImpersonatedEmail: cs.impersonatedEmail(),
+		Interactive:       cs.Interactive,
+	})
+	if err != nil {
+		return "", 0, helperError(err)
+	}
+	req, err := http.NewRequest("POST", helperTokenURL, bytes.NewReader(reqBody))
+	if err != nil {
+		return "", 0, helperError(err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	// Subject tokens are fetched rarely, so the connection is closed after
+	// each request rather than kept idle by a transport that is not reused.
+	client := &http.Client{
+		Transport: &http.Transport{
+			DisableKeepAlives: true,
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", cs.HelperSocket)
+			},
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", 0, context.DeadlineExceeded
+		}
+		return "", 0, helperError(err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", 0, helperError(err)
+	}
+	if c := resp.StatusCode; c < 200 || c > 299 {
+		return "", 0, fmt.Errorf("oauth2/google: credential helper returned status code %d: %s", c, respBody)
+	}
+	return cs.parseSubjectTokenFromSource(respBody, helperSource, cs.env.now().Unix())
+}
@@ -0,0 +1,128 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package externalaccount
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func createHelperServer(t *testing.T, handler http.HandlerFunc) (*httptest.Server, string) {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "helper.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	server := httptest.NewUnstartedServer(handler)
+	server.Listener = l
+	server.Start()
+	return server, socket
+}
+
+func TestRetrieveHelperSubjectToken(t *testing.T) {
+	var got helperRequest
+	server, socket := createHelperServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/token" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if !r.Close {
+			t.Error("helper request keeps the connection alive")
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("failed to decode helper request: %v", err)
+		}
+		json.NewEncoder(w).Encode(executableResponse{
+			Success:   Bool(true),
+			Version:   1,
+			TokenType: "urn:ietf:params:oauth:token-type:jwt",
+			IdToken:   "tokentokentoken",
+		})
+	})
+	defer server.Close()
+
+	tfc := testFileConfig
+	tfc.ServiceAccountImpersonationURL = "https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/test@project.iam.gserviceaccount.com:generateAccessToken"
+	tfc.CredentialSource = CredentialSource{
+		Executable: &ExecutableConfig{
+			HelperSocket: socket,
+		},
+	}
+
+	base, err := tfc.parse(context.Background())
+	if err != nil {
+		t.Fatalf("parse() failed %v", err)
+	}
+	ecs, ok := base.(executableCredentialSource)
+	if !ok {
+		t.Fatalf("Wrong credential type created.")
+	}
+	// Helpers do not require executables to be allowed.
+	ecs.env = &testEnvironment{}
+
+	out, err := ecs.subjectToken()
+	if err != nil {
+		t.Fatalf("subjectToken() failed: %v", err)
+	}
+	if want := "tokentokentoken"; out != want {
+		t.Errorf("Incorrect token received.\nReceived: %s\nExpected: %s", out, want)
+	}
+	want := helperRequest{
+		Audience:          tfc.Audience,
+		SubjectTokenType:  tfc.SubjectTokenType,
+		ImpersonatedEmail: "test@project.iam.gserviceaccount.com",
+	}
+	if got != want {
+		t.Errorf("Incorrect helper request.\nReceived: %+v\nExpected: %+v", got, want)
+	}
+}
+
+func TestRetrieveHelperSubjectTokenFailure(t *testing.T) {
+	server, socket := createHelperServer(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(executableResponse{
+			Success: Bool(false),
+			Version: 1,
+			Code:    "401",
+			Message: "Permission denied. Caller not authorized.",
+		})
+	})
+	defer server.Close()
+
+	ecs, err := CreateExecutableCredential(context.Background(), &ExecutableConfig{HelperSocket: socket}, &testFileConfig)
+	if err != nil {
+		t.Fatalf("creation failed %v", err)
+	}
+	ecs.env = &testEnvironment{}
+
+	_, err = ecs.subjectToken()
+	if err == nil {
+		t.Fatalf("Expected error but found none")
+	}
+	if got, want := err.Error(), userDefinedError("401", "Permission denied. Caller not authorized.").Error(); got != want {
+		t.Errorf("Incorrect error received.\nReceived: %s\nExpected: %s", got, want)
+	}
+}
+
+func TestRetrieveHelperSubjectTokenStatusError(t *testing.T) {
+	server, socket := createHelperServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+	defer server.Close()
+
+	ecs, err := CreateExecutableCredential(context.Background(), &ExecutableConfig{HelperSocket: socket}, &testFileConfig)
+	if err != nil {
+		t.Fatalf("creation failed %v", err)
+	}
+	ecs.env = &testEnvironment{}
+
+	if _, err := ecs.subjectToken(); err == nil {
+		t.Fatalf("Expected error but found none")
+	}
+}
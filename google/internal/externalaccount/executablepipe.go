@@ -0,0 +1,131 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package externalaccount
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// outputPipeGrace is how long the response is awaited on the output pipe
+// after the executable exits.
+var outputPipeGrace = time.Second
+
+func outputPipeError(err error) error {
+	return fmt.Errorf("oauth2/google: unable to read executable response from `output_pipe`: %v", err)
+}
+
+// outputPipe receives an executable response through a named pipe or a Unix
+// domain socket, so that it is never written to disk. The response is a
+// single JSON document; the executable does not need to close the pipe
+// after writing it.
+type outputPipe struct {
+	// path is where the executable writes the response, passed to it in
+	// GOOGLE_EXTERNAL_ACCOUNT_OUTPUT_PIPE.
+	path   string
+	result chan pipeResult
+
+	mu     sync.Mutex // guards closer
+	closer io.Closer  // the named pipe, or the accepted connection
+	ln     net.Listener
+}
+
+type pipeResult struct {
+	response []byte
+	err      error
+}
+
+// openOutputPipe starts reading the response from path. If path is an
+// existing named pipe, the response is read from it. Otherwise a Unix domain
+// socket only accessible to the current user is created next to path, with
+// a name unique to this run so that concurrent runs never remove each
+// other's socket. The executable connects to it to write the response, and
+// the socket is removed by close.
+func openOutputPipe(path string) (*outputPipe, error) {
+	p := &outputPipe{path: path, result: make(chan pipeResult, 1)}
+	fi, err := os.Lstat(path)
+	switch {
+	case err == nil && fi.Mode()&os.ModeNamedPipe != 0:
+		// Opening the named pipe for writing too keeps the open from
+		// blocking until the executable opens it.
+		f, err := os.OpenFile(path, os.O_RDWR, 0)
+		if err != nil {
+			return nil, outputPipeError(err)
+		}
+		p.closer = f
+		go p.read(f)
+		return p, nil
+	case err == nil && fi.Mode()&os.ModeSocket == 0:
+		return nil, fmt.Errorf("oauth2/google: `output_pipe` %q exists and is neither a named pipe nor a socket", path)
+	}
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return nil, outputPipeError(err)
+	}
+	p.path = path + "." + hex.EncodeToString(b)
+	ln, err := net.Listen("unix", p.path)
+	if err != nil {
+		return nil, outputPipeError(err)
+	}
+	if err := os.Chmod(p.path, 0600); err != nil {
+		ln.Close()
+		return nil, outputPipeError(err)
+	}
+	p.ln = ln
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			p.result <- pipeResult{err: err}
+			return
+		}
+		p.mu.Lock()
+		p.closer = conn
+		p.mu.Unlock()
+		p.read(conn)
+	}()
+	return p, nil
+}
+
+func (p *outputPipe) read(r io.Reader) {
+	var response json.RawMessage
+	err := json.NewDecoder(io.LimitReader(r, 1<<20)).Decode(&response)
+	p.result <- pipeResult{response: response, err: err}
+}
+
+// response returns the response written to the pipe. It is called once the
+// executable has exited, and waits at most outputPipeGrace.
+func (p *outputPipe) response() ([]byte, error) {
+	timer := time.NewTimer(outputPipeGrace)
+	defer timer.Stop()
+	select {
+	case r := <-p.result:
+		if r.err != nil {
+			return nil, outputPipeError(r.err)
+		}
+		return r.response, nil
+	case <-timer.C:
+		return nil, errors.New("oauth2/google: executable did not write a response to `output_pipe`")
+	}
+}
+
+// close stops reading from the pipe and removes the socket it created.
+func (p *outputPipe) close() {
+	if p.ln != nil {
+		p.ln.Close()
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closer != nil {
+		p.closer.Close()
+	}
+}
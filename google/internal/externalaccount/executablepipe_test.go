@@ -0,0 +1,113 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package externalaccount
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pipePath returns the value of GOOGLE_EXTERNAL_ACCOUNT_OUTPUT_PIPE in env.
+func pipePath(env []string) string {
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "GOOGLE_EXTERNAL_ACCOUNT_OUTPUT_PIPE=") {
+			return strings.TrimPrefix(kv, "GOOGLE_EXTERNAL_ACCOUNT_OUTPUT_PIPE=")
+		}
+	}
+	return ""
+}
+
+func newPipeTestSource(t *testing.T, outputPipe string, onRun func(env []string) error) executableCredentialSource {
+	t.Helper()
+	tfc := testFileConfig
+	tfc.CredentialSource = CredentialSource{
+		Executable: &ExecutableConfig{Command: "blarg", OutputPipe: outputPipe},
+	}
+	base, err := tfc.parse(context.Background())
+	if err != nil {
+		t.Fatalf("parse() failed %v", err)
+	}
+	ecs := base.(executableCredentialSource)
+	ecs.env = &testEnvironment{
+		envVars: executablesAllowed,
+		// Standard output is ignored when there is an output pipe.
+		byteResponse: []byte("not a response"),
+		onRun:        onRun,
+	}
+	return ecs
+}
+
+var pipeResponse = executableResponse{
+	Success:   Bool(true),
+	Version:   1,
+	TokenType: "urn:ietf:params:oauth:token-type:id_token",
+	IdToken:   "piped-token",
+}
+
+func TestRetrieveExecutableSubjectTokenOutputSocket(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.sock")
+	// A socket of a concurrent run at the configured path is left alone.
+	other, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	ecs := newPipeTestSource(t, path, func(env []string) error {
+		fi, err := os.Stat(pipePath(env))
+		if err != nil {
+			return err
+		}
+		if got, want := fi.Mode().Perm(), os.FileMode(0600); got != want {
+			t.Errorf("got socket mode %v but want %v", got, want)
+		}
+		conn, err := net.Dial("unix", pipePath(env))
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		return json.NewEncoder(conn).Encode(pipeResponse)
+	})
+
+	out, err := ecs.subjectToken()
+	if err != nil {
+		t.Fatalf("subjectToken() failed: %v", err)
+	}
+	if got, want := out, "piped-token"; got != want {
+		t.Errorf("got %v but want %v", got, want)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("socket of the concurrent run was removed: %v", err)
+	}
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 1 {
+		t.Errorf("output socket was not removed: %v", entries)
+	}
+}
+
+func TestRetrieveExecutableSubjectTokenOutputPipeNoResponse(t *testing.T) {
+	defer func(old time.Duration) { outputPipeGrace = old }(outputPipeGrace)
+	outputPipeGrace = 10 * time.Millisecond
+
+	ecs := newPipeTestSource(t, filepath.Join(t.TempDir(), "out.sock"), nil)
+	if _, err := ecs.subjectToken(); err == nil || !strings.Contains(err.Error(), "did not write a response") {
+		t.Errorf("got error %v but want a missing response error", err)
+	}
+}
+
+func TestOpenOutputPipeRegularFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.json")
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openOutputPipe(path); err == nil {
+		t.Error("openOutputPipe() succeeded for a regular file, want error")
+	}
+}
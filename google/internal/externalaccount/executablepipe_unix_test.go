@@ -0,0 +1,38 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux || darwin
+
+package externalaccount
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestRetrieveExecutableSubjectTokenOutputNamedPipe(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.fifo")
+	if err := syscall.Mkfifo(path, 0600); err != nil {
+		t.Fatalf("Mkfifo() failed: %v", err)
+	}
+	ecs := newPipeTestSource(t, path, func(env []string) error {
+		f, err := os.OpenFile(pipePath(env), os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return json.NewEncoder(f).Encode(pipeResponse)
+	})
+
+	out, err := ecs.subjectToken()
+	if err != nil {
+		t.Fatalf("subjectToken() failed: %v", err)
+	}
+	if got, want := out, "piped-token"; got != want {
+		t.Errorf("got %v but want %v", got, want)
+	}
+}
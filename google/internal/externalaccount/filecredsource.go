@@ -37,7 +37,7 @@ func (cs fileCredentialSource) subjectToken() (string, error) {
 		if err != nil {
 			return "", fmt.Errorf("oauth2/google: failed to unmarshal subject token file: %v", err)
 		}
-		val, ok := jsonData[cs.Format.SubjectTokenFieldName]
+		val, ok := cs.Format.field(jsonData)
 		if !ok {
 			return "", errors.New("oauth2/google: provided subject_token_field_name not found in credentials")
 		}
@@ -55,3 +55,21 @@ func (cs fileCredentialSource) subjectToken() (string, error) {
 	}
 
 }
+
+// fileVersion identifies the content of a file by its modification time and
+// size, which change when the file is rewritten or replaced.
+type fileVersion struct {
+	modTime int64
+	size    int64
+}
+
+// statFileVersion returns the version of the file at path, or the zero
+// version if it cannot be read, so that the file is read again and the error
+// reported.
+func statFileVersion(path string) fileVersion {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileVersion{}
+	}
+	return fileVersion{modTime: fi.ModTime().UnixNano(), size: fi.Size()}
+}
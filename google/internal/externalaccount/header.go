@@ -0,0 +1,139 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package externalaccount
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// apiClientHeader is the header used to report the library and credential
+// configuration for metrics purposes.
+const apiClientHeader = "x-goog-api-client"
+
+// validMetricsProduct matches a single "name/version" product identifier.
+var validMetricsProduct = regexp.MustCompile(`^[A-Za-z0-9._-]+/[A-Za-z0-9._+-]+$`)
+
+var (
+	goVersionOnce sync.Once
+	goVersionStr  string
+)
+
+// goVersion returns the Go version without the "go" prefix, e.g. "1.20.3".
+// It is computed once.
+func goVersion() string {
+	goVersionOnce.Do(func() {
+		goVersionStr = strings.TrimPrefix(runtime.Version(), "go")
+	})
+	return goVersionStr
+}
+
+var (
+	metricsProductsMu sync.RWMutex
+	metricsProducts   []string
+)
+
+// RegisterMetricsProduct registers a product identifier of the form
+// "name/version" that is appended to the metrics header of every request.
+// Registering the same identifier again has no effect.
+func RegisterMetricsProduct(product string) error {
+	if !validMetricsProduct.MatchString(product) {
+		return fmt.Errorf("oauth2/google: invalid metrics product %q, want name/version", product)
+	}
+	metricsProductsMu.Lock()
+	defer metricsProductsMu.Unlock()
+	for _, p := range metricsProducts {
+		if p == product {
+			return nil
+		}
+	}
+	metricsProducts = append(metricsProducts, product)
+	return nil
+}
+
+// validMetricsSource matches the source labels of MetricsSource.
+var validMetricsSource = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// MetricsSource can be implemented by a SubjectTokenSupplier or an
+// AwsSecurityCredentialsSupplier to tell its token exchanges apart in the
+// metrics header, e.g. "github-actions" or "spiffe". The header then
+// reports the source as "programmatic-" followed by the label; malformed
+// labels are ignored.
+//
+// As this package is internal, suppliers and their labels are only
+// implemented by the packages of this module wrapping it, such as
+// google/azure, google/kubernetes, google/spiffe and google/workforce.
+// Applications cannot plug in their own suppliers.
+type MetricsSource interface {
+	MetricsSource() string
+}
+
+// credentialSourceType returns the metrics label of the credential source
+// described by c.
+func (c *Config) credentialSourceType() string {
+	switch {
+	case c.SubjectTokenSupplier != nil:
+		return programmaticSourceType(c.SubjectTokenSupplier)
+	case c.AwsSecurityCredentialsSupplier != nil:
+		return programmaticSourceType(c.AwsSecurityCredentialsSupplier)
+	case strings.HasPrefix(c.CredentialSource.EnvironmentID, "aws"):
+		return "aws"
+	case strings.HasPrefix(c.CredentialSource.EnvironmentID, "azure"):
+		return "azure"
+	case c.CredentialSource.Certificate != nil:
+		return "x509"
+	case c.CredentialSource.File != "":
+		return "file"
+	case c.CredentialSource.URL != "":
+		return "url"
+	case c.CredentialSource.Executable != nil:
+		return "executable"
+	}
+	return "unknown"
+}
+
+// programmaticSourceType returns the metrics label of a supplier.
+func programmaticSourceType(supplier interface{}) string {
+	if ms, ok := supplier.(MetricsSource); ok {
+		if label := ms.MetricsSource(); validMetricsSource.MatchString(label) {
+			return "programmatic-" + label
+		}
+	}
+	return "programmatic"
+}
+
+// getMetricsHeaderValue returns the x-goog-api-client value sent to the
+// security token service. Product identifiers registered with
+// RegisterMetricsProduct follow the identifiers from c.MetricsProducts; those
+// that are not of the form "name/version" are dropped rather than failing
+// the request.
+func getMetricsHeaderValue(c *Config) string {
+	var b strings.Builder
+	b.WriteString("gl-go/")
+	b.WriteString(goVersion())
+	b.WriteString(" auth/unknown google-byoid-sdk source/")
+	b.WriteString(c.credentialSourceType())
+	b.WriteString(" sa-impersonation/")
+	b.WriteString(strconv.FormatBool(c.ServiceAccountImpersonationURL != ""))
+	b.WriteString(" config-lifetime/")
+	b.WriteString(strconv.FormatBool(c.ServiceAccountImpersonationLifetimeSeconds != 0))
+	for _, p := range c.MetricsProducts {
+		if validMetricsProduct.MatchString(p) {
+			b.WriteString(" ")
+			b.WriteString(p)
+		}
+	}
+	metricsProductsMu.RLock()
+	for _, p := range metricsProducts {
+		b.WriteString(" ")
+		b.WriteString(p)
+	}
+	metricsProductsMu.RUnlock()
+	return b.String()
+}
@@ -0,0 +1,91 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package externalaccount
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestGetMetricsHeaderValue(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   string
+	}{
+		{
+			name: "file",
+			config: Config{
+				CredentialSource: testBaseCredSource,
+			},
+			want: fmt.Sprintf("gl-go/%s auth/unknown google-byoid-sdk source/file sa-impersonation/false config-lifetime/false", goVersion()),
+		},
+		{
+			name: "aws with impersonation",
+			config: Config{
+				CredentialSource:                           CredentialSource{EnvironmentID: "aws1"},
+				ServiceAccountImpersonationURL:             "https://iamcredentials.googleapis.com",
+				ServiceAccountImpersonationLifetimeSeconds: 600,
+			},
+			want: fmt.Sprintf("gl-go/%s auth/unknown google-byoid-sdk source/aws sa-impersonation/true config-lifetime/true", goVersion()),
+		},
+		{
+			name: "programmatic with products",
+			config: Config{
+				SubjectTokenSupplier: testSubjectTokenSupplier{},
+				MetricsProducts:      []string{"terraform-provider-google/4.80.0", "not a product", "operator/v1+dev"},
+			},
+			want: fmt.Sprintf("gl-go/%s auth/unknown google-byoid-sdk source/programmatic sa-impersonation/false config-lifetime/false terraform-provider-google/4.80.0 operator/v1+dev", goVersion()),
+		},
+		{
+			name: "programmatic with source label",
+			config: Config{
+				SubjectTokenSupplier: labeledSupplier{label: "github-actions"},
+			},
+			want: fmt.Sprintf("gl-go/%s auth/unknown google-byoid-sdk source/programmatic-github-actions sa-impersonation/false config-lifetime/false", goVersion()),
+		},
+		{
+			name: "programmatic with malformed source label",
+			config: Config{
+				SubjectTokenSupplier: labeledSupplier{label: "github actions"},
+			},
+			want: fmt.Sprintf("gl-go/%s auth/unknown google-byoid-sdk source/programmatic sa-impersonation/false config-lifetime/false", goVersion()),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getMetricsHeaderValue(&tt.config); got != tt.want {
+				t.Errorf("got %v but want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterMetricsProduct(t *testing.T) {
+	defer func(old []string) { metricsProducts = old }(metricsProducts)
+	if err := RegisterMetricsProduct("not a product"); err == nil {
+		t.Error("RegisterMetricsProduct() with a malformed identifier succeeded, want error")
+	}
+	for i := 0; i < 2; i++ {
+		if err := RegisterMetricsProduct("my-sdk/1.2.3"); err != nil {
+			t.Fatalf("RegisterMetricsProduct() failed: %v", err)
+		}
+	}
+	config := Config{CredentialSource: testBaseCredSource, MetricsProducts: []string{"tool/1.0"}}
+	want := fmt.Sprintf("gl-go/%s auth/unknown google-byoid-sdk source/file sa-impersonation/false config-lifetime/false tool/1.0 my-sdk/1.2.3", goVersion())
+	if got := getMetricsHeaderValue(&config); got != want {
+		t.Errorf("got %v but want %v", got, want)
+	}
+}
+
+// labeledSupplier is a SubjectTokenSupplier declaring a metrics source.
+type labeledSupplier struct {
+	testSubjectTokenSupplier
+	label string
+}
+
+func (s labeledSupplier) MetricsSource() string {
+	return s.label
+}
@@ -0,0 +1,76 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package externalaccount
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/oauth2"
+)
+
+// HealthCheckStage identifies a step of the external account token flow.
+type HealthCheckStage string
+
+const (
+	// StageCredentialSource is the retrieval of the subject token from the
+	// credential source.
+	StageCredentialSource HealthCheckStage = "credential source"
+	// StageTokenExchange is the exchange of the subject token with the
+	// security token service.
+	StageTokenExchange HealthCheckStage = "token exchange"
+	// StageImpersonation is the service account impersonation.
+	StageImpersonation HealthCheckStage = "service account impersonation"
+)
+
+// HealthCheckError is returned by HealthCheck when a step of the token flow
+// fails.
+type HealthCheckError struct {
+	// Stage is the step that failed.
+	Stage HealthCheckStage
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *HealthCheckError) Error() string {
+	return fmt.Sprintf("oauth2/google: external account health check failed during %s: %v", e.Stage, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *HealthCheckError) Unwrap() error {
+	return e.Err
+}
+
+// HealthCheck runs the token flow described by c once, bypassing any cached
+// tokens, and reports the first step that fails as a *HealthCheckError. It is
+// meant for startup probes of workloads that must fail fast when federation
+// is misconfigured. The token flow is set up as by TokenSource, so that
+// HTTPClient, the client certificate of X.509 credential sources and the
+// ${NAME} placeholders of c apply; an invalid configuration is returned as
+// is. If skipImpersonation is true, the check stops after the token exchange
+// even when service account impersonation is configured.
+func (c *Config) HealthCheck(ctx context.Context, skipImpersonation bool) error {
+	ts, err := c.newTokenSource(ctx)
+	if err != nil {
+		return err
+	}
+
+	subjectToken, err := ts.subjectToken()
+	if err != nil {
+		return &HealthCheckError{Stage: StageCredentialSource, Err: err}
+	}
+	tok, err := ts.exchange(subjectToken)
+	if err != nil {
+		return &HealthCheckError{Stage: StageTokenExchange, Err: err}
+	}
+	if skipImpersonation || c.ServiceAccountImpersonationURL == "" {
+		return nil
+	}
+	imp := c.impersonateTokenSource(ts.ctx, oauth2.StaticTokenSource(tok))
+	if _, err := imp.Token(); err != nil {
+		return &HealthCheckError{Stage: StageImpersonation, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package externalaccount
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealthCheck(t *testing.T) {
+	targetServer := createTargetServer(t)
+	defer targetServer.Close()
+	impersonateServer := createImpersonationServer("/", "Bearer Sample.Access.Token", impersonationTests[0].expectedImpersonationBody, baseImpersonateCredsRespBody, t)
+	defer impersonateServer.Close()
+	failingServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer failingServer.Close()
+
+	tests := []struct {
+		name              string
+		tokenURL          string
+		impersonationURL  string
+		file              string
+		skipImpersonation bool
+		wantStage         HealthCheckStage
+	}{
+		{
+			name:             "healthy",
+			tokenURL:         targetServer.URL,
+			impersonationURL: impersonateServer.URL,
+		},
+		{
+			name:              "impersonation skipped",
+			tokenURL:          targetServer.URL,
+			impersonationURL:  failingServer.URL,
+			skipImpersonation: true,
+		},
+		{
+			name:             "impersonation fails",
+			tokenURL:         targetServer.URL,
+			impersonationURL: failingServer.URL,
+			wantStage:        StageImpersonation,
+		},
+		{
+			name:      "exchange fails",
+			tokenURL:  failingServer.URL,
+			wantStage: StageTokenExchange,
+		},
+		{
+			name:      "credential source fails",
+			tokenURL:  targetServer.URL,
+			file:      "testdata/does-not-exist.txt",
+			wantStage: StageCredentialSource,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := impersonationTests[0].config
+			config.TokenURL = tt.tokenURL
+			config.ServiceAccountImpersonationURL = tt.impersonationURL
+			if tt.file != "" {
+				config.CredentialSource.File = tt.file
+			}
+
+			err := config.HealthCheck(context.Background(), tt.skipImpersonation)
+			if tt.wantStage == "" {
+				if err != nil {
+					t.Fatalf("HealthCheck() failed: %v", err)
+				}
+				return
+			}
+			var hcErr *HealthCheckError
+			if !errors.As(err, &hcErr) {
+				t.Fatalf("got error %v but want a *HealthCheckError", err)
+			}
+			if got, want := hcErr.Stage, tt.wantStage; got != want {
+				t.Errorf("got stage %v but want %v", got, want)
+			}
+		})
+	}
+}
+
+type countingTransport struct {
+	requests int
+}
+
+func (t *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.requests++
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestHealthCheck_HTTPClient(t *testing.T) {
+	targetServer := createTargetServer(t)
+	defer targetServer.Close()
+
+	transport := &countingTransport{}
+	config := impersonationTests[0].config
+	config.TokenURL = targetServer.URL
+	config.ServiceAccountImpersonationURL = "http://unused.invalid"
+	config.HTTPClient = &http.Client{Transport: transport}
+	if err := config.HealthCheck(context.Background(), true); err != nil {
+		t.Fatalf("HealthCheck() failed: %v", err)
+	}
+	if transport.requests != 1 {
+		t.Errorf("got %d requests with the configured client but want 1", transport.requests)
+	}
+}
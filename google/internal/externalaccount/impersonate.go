@@ -11,12 +11,22 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"net/http"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/internal"
+	"golang.org/x/oauth2/jws"
 )
 
+// quotaProjectHeader is the header naming the project that is billed for a
+// request.
+const quotaProjectHeader = "x-goog-user-project"
+
 // generateAccesstokenReq is used for service account impersonation
 type generateAccessTokenReq struct {
 	Delegates []string `json:"delegates,omitempty"`
@@ -29,6 +39,19 @@ type impersonateTokenResponse struct {
 	ExpireTime  string `json:"expireTime"`
 }
 
+// generateIDTokenReq is used for service account impersonation when an ID
+// token is requested.
+type generateIDTokenReq struct {
+	Delegates                  []string `json:"delegates,omitempty"`
+	Audience                   string   `json:"audience"`
+	IncludeEmail               bool     `json:"includeEmail,omitempty"`
+	OrganizationNumberIncluded bool     `json:"organizationNumberIncluded,omitempty"`
+}
+
+type impersonateIDTokenResponse struct {
+	Token string `json:"token"`
+}
+
 // ImpersonateTokenSource uses a source credential, stored in Ts, to request an access token to the provided URL.
 // Scopes can be defined when the access token is requested.
 type ImpersonateTokenSource struct {
@@ -51,18 +74,185 @@ type ImpersonateTokenSource struct {
 	// TokenLifetimeSeconds is the number of seconds the impersonation token will
 	// be valid for.
 	TokenLifetimeSeconds int
+	// MinLifetimeSeconds, when positive, shortens the lifetime requested
+	// for access tokens to the time left before the deadline of Ctx, if it
+	// has one, but not below MinLifetimeSeconds. This keeps tokens minted
+	// for short jobs from outliving them. Optional.
+	MinLifetimeSeconds int
+	// IDTokenAudience, when set, requests a Google-signed ID token for this
+	// audience instead of an access token. URL must then point to the
+	// generateIdToken endpoint, and Scopes and TokenLifetimeSeconds are
+	// ignored. Optional.
+	IDTokenAudience string
+	// IncludeEmail adds the email and email_verified claims of the service
+	// account to the ID token. Only used with IDTokenAudience. Optional.
+	IncludeEmail bool
+	// IncludeOrganizationNumber adds the number of the organization the
+	// service account belongs to to the ID token. Only used with
+	// IDTokenAudience. Optional.
+	IncludeOrganizationNumber bool
+	// QuotaProjectID, when set, is sent in the x-goog-user-project header of
+	// the impersonation request so that it is billed to this project.
+	// Optional.
+	QuotaProjectID string
+	// Cache optionally shares impersonated tokens with other
+	// ImpersonateTokenSources using the same cache. Sources are only served a
+	// cached token if their URL, Scopes, Delegates and TokenLifetimeSeconds
+	// match. A cache must only be shared between sources whose Ts represent
+	// the same principal. Optional.
+	Cache *ImpersonationCache
+}
+
+// ImpersonationCache holds impersonated tokens shared between several
+// ImpersonateTokenSources. It is safe for concurrent use.
+type ImpersonationCache struct {
+	mu     sync.Mutex
+	tokens map[impersonationCacheKey]*oauth2.Token
+	hits   uint64
+	misses uint64
+}
+
+// ImpersonationCacheStats reports the usage of an ImpersonationCache.
+type ImpersonationCacheStats struct {
+	// Hits is the number of tokens served from the cache.
+	Hits uint64
+	// Misses is the number of tokens that had to be requested.
+	Misses uint64
+	// Size is the number of tokens currently held, including expired ones
+	// that have not been replaced yet.
+	Size int
+}
+
+// impersonationCacheKey identifies the parameters an impersonated token was
+// requested with.
+type impersonationCacheKey struct {
+	url       string
+	scopes    string
+	delegates string
+	lifetime  int
+	audience  string
+	email     bool
+	orgNumber bool
+}
+
+// NewImpersonationCache returns an empty ImpersonationCache.
+func NewImpersonationCache() *ImpersonationCache {
+	return &ImpersonationCache{tokens: make(map[impersonationCacheKey]*oauth2.Token)}
+}
+
+// Stats returns a snapshot of the cache's usage.
+func (c *ImpersonationCache) Stats() ImpersonationCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return ImpersonationCacheStats{Hits: c.hits, Misses: c.misses, Size: len(c.tokens)}
+}
+
+func (c *ImpersonationCache) get(key impersonationCacheKey) *oauth2.Token {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if tok := c.tokens[key]; tok.Valid() {
+		c.hits++
+		return tok
+	}
+	c.misses++
+	return nil
+}
+
+func (c *ImpersonationCache) put(key impersonationCacheKey, tok *oauth2.Token) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tokens == nil {
+		c.tokens = make(map[impersonationCacheKey]*oauth2.Token)
+	}
+	c.tokens[key] = tok
+}
+
+func (its ImpersonateTokenSource) cacheKey() impersonationCacheKey {
+	scopes := internal.NormalizeScopes(its.Scopes)
+	scopes = append([]string(nil), scopes...)
+	sort.Strings(scopes)
+	return impersonationCacheKey{
+		url:       its.URL,
+		scopes:    strings.Join(scopes, " "),
+		delegates: strings.Join(its.Delegates, " "),
+		lifetime:  its.TokenLifetimeSeconds,
+		audience:  its.IDTokenAudience,
+		email:     its.IncludeEmail,
+		orgNumber: its.IncludeOrganizationNumber,
+	}
+}
+
+// DescribeTokenSource describes its and the source of the impersonating
+// credential, see oauth2.DescribeTokenSource.
+func (its ImpersonateTokenSource) DescribeTokenSource() (string, oauth2.TokenSource) {
+	return internal.DescribeParams("impersonate",
+		"url", internal.RedactURL(its.URL),
+		"delegates", strings.Join(its.Delegates, ","),
+		"id_token_audience", its.IDTokenAudience,
+	), its.Ts
 }
 
 // Token performs the exchange to get a temporary service account token to allow access to GCP.
 func (its ImpersonateTokenSource) Token() (*oauth2.Token, error) {
-	lifetimeString := "3600s"
-	if its.TokenLifetimeSeconds != 0 {
-		lifetimeString = fmt.Sprintf("%ds", its.TokenLifetimeSeconds)
+	if its.Cache == nil {
+		return its.generateToken()
 	}
-	reqBody := generateAccessTokenReq{
-		Lifetime:  lifetimeString,
-		Scope:     its.Scopes,
-		Delegates: its.Delegates,
+	key := its.cacheKey()
+	if tok := its.Cache.get(key); tok != nil {
+		return tok, nil
+	}
+	tok, err := its.generateToken()
+	if err != nil {
+		return nil, err
+	}
+	if _, clamped := its.lifetime(); !clamped {
+		// Tokens shortened to the deadline of its.Ctx are not shared.
+		its.Cache.put(key, tok)
+	}
+	return tok, nil
+}
+
+// lifetime returns the lifetime in seconds to request access tokens with,
+// and whether it was shortened to the deadline of its.Ctx.
+func (its ImpersonateTokenSource) lifetime() (int, bool) {
+	lifetime := its.TokenLifetimeSeconds
+	if lifetime == 0 {
+		lifetime = 3600
+	}
+	if its.MinLifetimeSeconds <= 0 || its.Ctx == nil {
+		return lifetime, false
+	}
+	deadline, ok := its.Ctx.Deadline()
+	if !ok {
+		return lifetime, false
+	}
+	left := int(math.Ceil(deadline.Sub(now()).Seconds()))
+	if left < its.MinLifetimeSeconds {
+		left = its.MinLifetimeSeconds
+	}
+	if left >= lifetime {
+		return lifetime, false
+	}
+	return left, true
+}
+
+// generateToken requests a new impersonated token from its.URL.
+func (its ImpersonateTokenSource) generateToken() (*oauth2.Token, error) {
+	var reqBody interface{}
+	if its.IDTokenAudience != "" {
+		reqBody = generateIDTokenReq{
+			Audience:                   its.IDTokenAudience,
+			Delegates:                  its.Delegates,
+			IncludeEmail:               its.IncludeEmail,
+			OrganizationNumberIncluded: its.IncludeOrganizationNumber,
+		}
+	} else {
+		lifetime, _ := its.lifetime()
+		reqBody = generateAccessTokenReq{
+			Lifetime:  fmt.Sprintf("%ds", lifetime),
+			Scope:     internal.NormalizeScopes(its.Scopes),
+			Delegates: its.Delegates,
+		}
 	}
 	b, err := json.Marshal(reqBody)
 	if err != nil {
@@ -75,6 +265,9 @@ func (its ImpersonateTokenSource) Token() (*oauth2.Token, error) {
 	}
 	req = req.WithContext(its.Ctx)
 	req.Header.Set("Content-Type", "application/json")
+	if its.QuotaProjectID != "" {
+		req.Header.Set(quotaProjectHeader, its.QuotaProjectID)
+	}
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -89,6 +282,10 @@ func (its ImpersonateTokenSource) Token() (*oauth2.Token, error) {
 		return nil, fmt.Errorf("oauth2/google: status code %d: %s", c, body)
 	}
 
+	if its.IDTokenAudience != "" {
+		return parseIDTokenResponse(body)
+	}
+
 	var accessTokenResp impersonateTokenResponse
 	if err := json.Unmarshal(body, &accessTokenResp); err != nil {
 		return nil, fmt.Errorf("oauth2/google: unable to parse response: %v", err)
@@ -103,3 +300,22 @@ func (its ImpersonateTokenSource) Token() (*oauth2.Token, error) {
 		TokenType:   "Bearer",
 	}, nil
 }
+
+// parseIDTokenResponse converts a generateIdToken response into a token. The
+// ID token is returned as the access token so that it is sent as a bearer
+// token, and its expiry is taken from the exp claim.
+func parseIDTokenResponse(body []byte) (*oauth2.Token, error) {
+	var idTokenResp impersonateIDTokenResponse
+	if err := json.Unmarshal(body, &idTokenResp); err != nil {
+		return nil, fmt.Errorf("oauth2/google: unable to parse response: %v", err)
+	}
+	claims, err := jws.Decode(idTokenResp.Token)
+	if err != nil {
+		return nil, fmt.Errorf("oauth2/google: unable to decode ID token: %v", err)
+	}
+	return &oauth2.Token{
+		AccessToken: idTokenResp.Token,
+		Expiry:      time.Unix(claims.Exp, 0),
+		TokenType:   "Bearer",
+	}, nil
+}
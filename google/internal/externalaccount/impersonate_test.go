@@ -6,10 +6,14 @@ package externalaccount
 
 import (
 	"context"
+	"encoding/json"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
 )
 
 var (
@@ -135,3 +139,178 @@ func TestImpersonation(t *testing.T) {
 		})
 	}
 }
+
+func TestImpersonateTokenSource_Cache(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		expiry := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"accessToken":"Impersonated.Token","expireTime":"` + expiry + `"}`))
+	}))
+	defer server.Close()
+
+	cache := NewImpersonationCache()
+	newSource := func(scopes []string, lifetime int) ImpersonateTokenSource {
+		return ImpersonateTokenSource{
+			Ctx:                  context.Background(),
+			Ts:                   oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "source"}),
+			URL:                  server.URL,
+			Scopes:               scopes,
+			TokenLifetimeSeconds: lifetime,
+			Cache:                cache,
+		}
+	}
+
+	sources := []ImpersonateTokenSource{
+		newSource([]string{"a", "b"}, 3600),
+		newSource([]string{"b", "a"}, 3600), // same scopes, different order
+		newSource([]string{"a", "b"}, 600),
+	}
+	for _, ts := range sources {
+		tok, err := ts.Token()
+		if err != nil {
+			t.Fatalf("Token() failed: %v", err)
+		}
+		if got, want := tok.AccessToken, "Impersonated.Token"; got != want {
+			t.Errorf("got %v but want %v", got, want)
+		}
+	}
+	if got, want := requests, 2; got != want {
+		t.Errorf("got %v impersonation requests but want %v", got, want)
+	}
+	if got, want := cache.Stats(), (ImpersonationCacheStats{Hits: 1, Misses: 2, Size: 2}); got != want {
+		t.Errorf("got %+v but want %+v", got, want)
+	}
+}
+
+func TestImpersonateTokenSource_MinLifetime(t *testing.T) {
+	var lifetime string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req generateAccessTokenReq
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		lifetime = req.Lifetime
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(baseImpersonateCredsRespBody))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		deadline time.Duration // zero for none
+		want     string
+	}{
+		{"no deadline", 0, "3600s"},
+		{"short deadline", 10 * time.Minute, "600s"},
+		{"below minimum", time.Minute, "300s"},
+		{"long deadline", 2 * time.Hour, "3600s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.deadline != 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.deadline)
+				defer cancel()
+			}
+			cache := NewImpersonationCache()
+			its := ImpersonateTokenSource{
+				Ctx:                ctx,
+				Ts:                 oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "source"}),
+				URL:                server.URL,
+				Scopes:             []string{"https://www.googleapis.com/auth/cloud-platform"},
+				MinLifetimeSeconds: 300,
+				Cache:              cache,
+			}
+			if _, err := its.Token(); err != nil {
+				t.Fatalf("Token() failed: %v", err)
+			}
+			if lifetime != tt.want {
+				t.Errorf("got lifetime %v but want %v", lifetime, tt.want)
+			}
+			if got, want := cache.Stats().Size == 1, tt.want == "3600s"; got != want {
+				t.Errorf("got token cached %v but want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestImpersonateTokenSource_QuotaProject(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("x-goog-user-project"), "quota-project"; got != want {
+			t.Errorf("got %v but want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(baseImpersonateCredsRespBody))
+	}))
+	defer server.Close()
+
+	its := ImpersonateTokenSource{
+		Ctx:            context.Background(),
+		Ts:             oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "source"}),
+		URL:            server.URL,
+		Scopes:         []string{"https://www.googleapis.com/auth/cloud-platform"},
+		QuotaProjectID: "quota-project",
+	}
+	if _, err := its.Token(); err != nil {
+		t.Fatalf("Token() failed: %v", err)
+	}
+}
+
+func TestImpersonation_IDToken(t *testing.T) {
+	idToken := testJWT(time.Now().Add(time.Hour))
+	impersonateServer := createImpersonationServer("/v1/projects/-/serviceAccounts/sa@project.iam.gserviceaccount.com:generateIdToken", "Bearer Sample.Access.Token", `{"audience":"https://service-abc.a.run.app"}`, `{"token":"`+idToken+`"}`, t)
+	defer impersonateServer.Close()
+	targetServer := createTargetServer(t)
+	defer targetServer.Close()
+
+	config := impersonationTests[0].config
+	config.TokenURL = targetServer.URL
+	config.ServiceAccountImpersonationURL = impersonateServer.URL + "/v1/projects/-/serviceAccounts/sa@project.iam.gserviceaccount.com:generateAccessToken"
+	config.IDTokenAudience = "https://service-abc.a.run.app"
+
+	oldNow := now
+	defer func() { now = oldNow }()
+	now = testNow
+
+	ts, err := config.tokenSource(context.Background(), "http")
+	if err != nil {
+		t.Fatalf("Failed to create TokenSource: %v", err)
+	}
+	tok, err := ts.Token()
+	if err != nil {
+		t.Fatalf("Token() failed: %v", err)
+	}
+	if got, want := tok.AccessToken, idToken; got != want {
+		t.Errorf("got %v but want %v", got, want)
+	}
+
+	config.ServiceAccountImpersonationURL = impersonateServer.URL + "/v1/projects/-/serviceAccounts/sa@project.iam.gserviceaccount.com"
+	if _, err := config.tokenSource(context.Background(), "http"); err == nil {
+		t.Error("tokenSource() succeeded with an impersonation URL lacking :generateAccessToken, want error")
+	}
+
+	config.ServiceAccountImpersonationURL = ""
+	if _, err := config.tokenSource(context.Background(), "http"); err == nil {
+		t.Error("tokenSource() succeeded without service account impersonation, want error")
+	}
+}
+
+func TestDescribeTokenSource(t *testing.T) {
+	config := impersonationTests[0].config
+	config.Audience = "//iam.googleapis.com/projects/123/locations/global/workloadIdentityPools/pool/providers/provider"
+	config.TokenURL = "https://sts.googleapis.com/v1/token"
+	config.ServiceAccountImpersonationURL = "https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/sa@project.iam.gserviceaccount.com:generateAccessToken"
+	config.CredentialSource = CredentialSource{File: "/var/run/token"}
+	ts, err := config.TokenSource(context.Background())
+	if err != nil {
+		t.Fatalf("TokenSource() failed: %v", err)
+	}
+	want := "reuse -> impersonate(url=https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/sa@project.iam.gserviceaccount.com:generateAccessToken) -> " +
+		"reuse -> external_account(audience=//iam.googleapis.com/projects/123/locations/global/workloadIdentityPools/pool/providers/provider, source=file, file=/var/run/token, token_url=https://sts.googleapis.com/v1/token)"
+	if got := oauth2.DescribeTokenSource(ts); got != want {
+		t.Errorf("got %v but want %v", got, want)
+	}
+}
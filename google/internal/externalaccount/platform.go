@@ -0,0 +1,130 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package externalaccount
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	// DefaultSTSURL is the Security Token Service endpoint of the packages
+	// wrapping this one when they are not given another.
+	DefaultSTSURL = "https://sts.googleapis.com/v1/token"
+
+	// JWTTokenType is the subject token type of JWTs, such as projected
+	// Kubernetes service account tokens and JWT-SVIDs.
+	JWTTokenType = "urn:ietf:params:oauth:token-type:jwt"
+
+	// DefaultGKETokenFile is the path of the token projected by the
+	// serviceAccountToken volume source of the GKE workload identity
+	// federation documentation.
+	DefaultGKETokenFile = "/var/run/secrets/tokens/gcp-ksa/token"
+
+	// The environment variables naming the GKE workload identity pool,
+	// e.g. PROJECT_ID.svc.id.goog, its identity provider, e.g.
+	// https://container.googleapis.com/v1/projects/PROJECT_ID/locations/LOCATION/clusters/CLUSTER,
+	// and optionally the path of the projected service account token.
+	workloadIdentityPoolEnvVar      = "GOOGLE_WORKLOAD_IDENTITY_POOL"
+	workloadIdentityProviderEnvVar  = "GOOGLE_WORKLOAD_IDENTITY_PROVIDER"
+	workloadIdentityTokenFileEnvVar = "GOOGLE_WORKLOAD_IDENTITY_TOKEN_FILE"
+)
+
+// PlatformConfig describes the token exchange of a package of this module
+// that supplies the subject tokens of a particular platform, such as
+// google/azure or google/kubernetes.
+type PlatformConfig struct {
+	// Audience is the workload or workforce identity pool provider
+	// resource name.
+	Audience string
+	// SubjectTokenType is the type of the tokens returned by SubjectToken.
+	SubjectTokenType string
+	// STSURL is the Security Token Service endpoint. The default is
+	// DefaultSTSURL.
+	STSURL string
+	// ServiceAccountImpersonationURL is the URL of the generateAccessToken
+	// method of the service account to impersonate after the exchange.
+	ServiceAccountImpersonationURL string
+	// WorkforcePoolUserProject is the project used for quota and billing of
+	// workforce exchanges.
+	WorkforcePoolUserProject string
+	// Scopes are the scopes of the returned access tokens. The default is
+	// the cloud-platform scope.
+	Scopes []string
+	// SubjectToken returns the subject token to exchange.
+	SubjectToken func(ctx context.Context) (string, error)
+	// MetricsSource labels the exchanges in the metrics header, see
+	// MetricsSource.
+	MetricsSource string
+}
+
+// TokenURL returns the Security Token Service endpoint of c.
+func (c *PlatformConfig) TokenURL() string {
+	if c.STSURL == "" {
+		return DefaultSTSURL
+	}
+	return c.STSURL
+}
+
+// TokenSource returns a TokenSource of access tokens obtained by exchanging
+// the subject tokens of c with the Security Token Service.
+func (c *PlatformConfig) TokenSource(ctx context.Context) (oauth2.TokenSource, error) {
+	scopes := c.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{CloudPlatformScope}
+	}
+	cfg := &Config{
+		Audience:                       c.Audience,
+		SubjectTokenType:               c.SubjectTokenType,
+		TokenURL:                       c.TokenURL(),
+		ServiceAccountImpersonationURL: c.ServiceAccountImpersonationURL,
+		WorkforcePoolUserProject:       c.WorkforcePoolUserProject,
+		Scopes:                         scopes,
+		SubjectTokenSupplier: &platformSupplier{
+			subjectToken:  c.SubjectToken,
+			metricsSource: c.MetricsSource,
+		},
+	}
+	return cfg.TokenSource(ctx)
+}
+
+// platformSupplier supplies the subject tokens of a PlatformConfig.
+type platformSupplier struct {
+	subjectToken  func(ctx context.Context) (string, error)
+	metricsSource string
+}
+
+func (s *platformSupplier) SubjectToken(ctx context.Context, options SupplierOptions) (string, error) {
+	return s.subjectToken(ctx)
+}
+
+// MetricsSource implements MetricsSource.
+func (s *platformSupplier) MetricsSource() string {
+	return s.metricsSource
+}
+
+// IdentityNamespaceAudience returns the audience of the GKE workload
+// identity pool and provider, which stand in for a workload identity pool
+// provider resource name.
+func IdentityNamespaceAudience(pool, provider string) string {
+	return fmt.Sprintf("identitynamespace:%s:%s", pool, provider)
+}
+
+// GKEWorkloadIdentityFromEnv returns the audience of the GKE workload
+// identity pool and provider named by the GOOGLE_WORKLOAD_IDENTITY_POOL and
+// GOOGLE_WORKLOAD_IDENTITY_PROVIDER environment variables, and the token
+// file named by GOOGLE_WORKLOAD_IDENTITY_TOKEN_FILE, which may be empty. ok
+// reports whether both the pool and provider are set; the token file is
+// returned either way.
+func GKEWorkloadIdentityFromEnv() (audience, tokenFile string, ok bool) {
+	tokenFile = getenv(workloadIdentityTokenFileEnvVar)
+	pool, provider := getenv(workloadIdentityPoolEnvVar), getenv(workloadIdentityProviderEnvVar)
+	if pool == "" || provider == "" {
+		return "", tokenFile, false
+	}
+	return IdentityNamespaceAudience(pool, provider), tokenFile, true
+}
@@ -0,0 +1,74 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package externalaccount
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPlatformConfig_TokenSource(t *testing.T) {
+	sts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for key, want := range map[string]string{
+			"subject_token":      "platform-token",
+			"subject_token_type": JWTTokenType,
+			"scope":              CloudPlatformScope,
+		} {
+			if got := r.FormValue(key); got != want {
+				t.Errorf("got %s %v but want %v", key, got, want)
+			}
+		}
+		if got, want := r.Header.Get("x-goog-api-client"), "source/programmatic-test"; !strings.Contains(got, want) {
+			t.Errorf("got metrics header %v but want it to contain %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"google-token","issued_token_type":"urn:ietf:params:oauth:token-type:access_token","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer sts.Close()
+
+	c := &PlatformConfig{
+		Audience:         "//iam.googleapis.com/projects/123/locations/global/workloadIdentityPools/pool/providers/provider",
+		SubjectTokenType: JWTTokenType,
+		STSURL:           sts.URL,
+		SubjectToken: func(context.Context) (string, error) {
+			return "platform-token", nil
+		},
+		MetricsSource: "test",
+	}
+	ts, err := c.TokenSource(context.Background())
+	if err != nil {
+		t.Fatalf("TokenSource() failed: %v", err)
+	}
+	tok, err := ts.Token()
+	if err != nil {
+		t.Fatalf("Token() failed: %v", err)
+	}
+	if got, want := tok.AccessToken, "google-token"; got != want {
+		t.Errorf("got %v but want %v", got, want)
+	}
+	if got, want := (&PlatformConfig{}).TokenURL(), DefaultSTSURL; got != want {
+		t.Errorf("got default token URL %v but want %v", got, want)
+	}
+}
+
+func TestGKEWorkloadIdentityFromEnv(t *testing.T) {
+	defer func(old func(string) string) { getenv = old }(getenv)
+	getenv = setEnvironment(map[string]string{
+		"GOOGLE_WORKLOAD_IDENTITY_POOL":     "project.svc.id.goog",
+		"GOOGLE_WORKLOAD_IDENTITY_PROVIDER": "https://container.googleapis.com/v1/projects/project/locations/l/clusters/c",
+	})
+	audience, tokenFile, ok := GKEWorkloadIdentityFromEnv()
+	if want := "identitynamespace:project.svc.id.goog:https://container.googleapis.com/v1/projects/project/locations/l/clusters/c"; !ok || audience != want || tokenFile != "" {
+		t.Errorf("got %v, %q, %v but want %v, \"\", true", audience, tokenFile, ok, want)
+	}
+
+	getenv = setEnvironment(map[string]string{"GOOGLE_WORKLOAD_IDENTITY_POOL": "project.svc.id.goog"})
+	if _, _, ok := GKEWorkloadIdentityFromEnv(); ok {
+		t.Error("got ok without a provider, want false")
+	}
+}
@@ -0,0 +1,19 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package externalaccount
+
+import "context"
+
+// programmaticRefreshCredentialSource retrieves subject tokens from a
+// caller-provided SubjectTokenSupplier.
+type programmaticRefreshCredentialSource struct {
+	subjectTokenSupplier SubjectTokenSupplier
+	options              SupplierOptions
+	ctx                  context.Context
+}
+
+func (cs programmaticRefreshCredentialSource) subjectToken() (string, error) {
+	return cs.subjectTokenSupplier.SubjectToken(cs.ctx, cs.options)
+}
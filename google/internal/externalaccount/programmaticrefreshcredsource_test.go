@@ -0,0 +1,91 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package externalaccount
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type testSubjectTokenSupplier struct {
+	subjectToken string
+	err          error
+}
+
+func (supp testSubjectTokenSupplier) SubjectToken(ctx context.Context, options SupplierOptions) (string, error) {
+	return supp.subjectToken, supp.err
+}
+
+func TestRetrieveSubjectToken_ProgrammaticAuth(t *testing.T) {
+	tfc := testConfig
+	tfc.SubjectTokenSupplier = testSubjectTokenSupplier{subjectToken: "subjectToken"}
+
+	base, err := tfc.parse(context.Background())
+	if err != nil {
+		t.Fatalf("parse() failed %v", err)
+	}
+
+	out, err := base.subjectToken()
+	if err != nil {
+		t.Fatalf("subjectToken() failed: %v", err)
+	}
+	if got, want := out, "subjectToken"; got != want {
+		t.Errorf("subjectToken = %q, want %q", got, want)
+	}
+}
+
+func TestRetrieveSubjectToken_ProgrammaticAuthFails(t *testing.T) {
+	testError := errors.New("test error")
+	tfc := testConfig
+	tfc.SubjectTokenSupplier = testSubjectTokenSupplier{err: testError}
+
+	base, err := tfc.parse(context.Background())
+	if err != nil {
+		t.Fatalf("parse() failed %v", err)
+	}
+
+	_, err = base.subjectToken()
+	if err != testError {
+		t.Errorf("subjectToken() error = %v, want %v", err, testError)
+	}
+}
+
+type contextKey struct{}
+
+// recordingSubjectTokenSupplier records the context value and options it is
+// called with.
+type recordingSubjectTokenSupplier struct {
+	value   interface{}
+	options SupplierOptions
+}
+
+func (s *recordingSubjectTokenSupplier) SubjectToken(ctx context.Context, options SupplierOptions) (string, error) {
+	s.value, s.options = ctx.Value(contextKey{}), options
+	return "subjectToken", nil
+}
+
+func TestRetrieveSubjectToken_ProgrammaticAuthContext(t *testing.T) {
+	supplier := &recordingSubjectTokenSupplier{}
+	tfc := testConfig
+	tfc.SubjectTokenSupplier = supplier
+	tfc.Scopes = []string{"scope2", "scope1"}
+
+	base, err := tfc.parse(context.WithValue(context.Background(), contextKey{}, "value"))
+	if err != nil {
+		t.Fatalf("parse() failed %v", err)
+	}
+	if _, err := base.subjectToken(); err != nil {
+		t.Fatalf("subjectToken() failed: %v", err)
+	}
+	if got, want := supplier.value, "value"; got != want {
+		t.Errorf("got context value %v but want %v", got, want)
+	}
+	want := SupplierOptions{Audience: tfc.Audience, SubjectTokenType: tfc.SubjectTokenType, Scopes: []string{"scope2", "scope1"}}
+	if !reflect.DeepEqual(supplier.options, want) {
+		t.Errorf("got options %+v but want %+v", supplier.options, want)
+	}
+}
@@ -5,8 +5,10 @@
 package externalaccount
 
 import (
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -16,12 +18,39 @@ import (
 	"strings"
 
 	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/internal"
+)
+
+// accessTokenType is the token type requested from the Security Token Service.
+const accessTokenType = "urn:ietf:params:oauth:token-type:access_token"
+
+// maxResponseSize is the largest decoded security token service response
+// accepted. Larger responses, e.g. from a misbehaving server at an
+// overridden TokenURL, are rejected rather than truncated.
+const maxResponseSize = 1 << 20
+
+// OptionsEncoding controls how additional options, such as the workforce pool
+// user project, are encoded in the token exchange request.
+type OptionsEncoding string
+
+const (
+	// OptionsEncodingJSON sends all options JSON-encoded in a single
+	// "options" form field, as the Google security token service expects.
+	// It is the default.
+	OptionsEncodingJSON OptionsEncoding = "json"
+	// OptionsEncodingForm sends every option as a separate form field, for
+	// security token service deployments that do not accept the "options"
+	// field. String values are sent as is and other values JSON-encoded.
+	OptionsEncodingForm OptionsEncoding = "form"
 )
 
 // exchangeToken performs an oauth2 token exchange with the provided endpoint.
 // The first 4 fields are all mandatory.  headers can be used to pass additional
 // headers beyond the bare minimum required by the token exchange.  options can
 // be used to pass additional JSON-structured options to the remote server.
+//
+// Responses may be gzip-encoded, and are rejected if larger than
+// maxResponseSize once decoded.
 func exchangeToken(ctx context.Context, endpoint string, request *stsTokenExchangeRequest, authentication clientAuthentication, headers http.Header, options map[string]interface{}) (*stsTokenExchangeResponse, error) {
 
 	client := oauth2.NewClient(ctx, nil)
@@ -29,16 +58,12 @@ func exchangeToken(ctx context.Context, endpoint string, request *stsTokenExchan
 	data := url.Values{}
 	data.Set("audience", request.Audience)
 	data.Set("grant_type", "urn:ietf:params:oauth:grant-type:token-exchange")
-	data.Set("requested_token_type", "urn:ietf:params:oauth:token-type:access_token")
+	data.Set("requested_token_type", accessTokenType)
 	data.Set("subject_token_type", request.SubjectTokenType)
 	data.Set("subject_token", request.SubjectToken)
-	data.Set("scope", strings.Join(request.Scope, " "))
-	if options != nil {
-		opts, err := json.Marshal(options)
-		if err != nil {
-			return nil, fmt.Errorf("oauth2/google: failed to marshal additional options: %v", err)
-		}
-		data.Set("options", string(opts))
+	data.Set("scope", internal.ScopeString(request.Scope))
+	if err := encodeOptions(data, options, request.OptionsEncoding); err != nil {
+		return nil, err
 	}
 
 	authentication.InjectAuthentication(data, headers)
@@ -56,6 +81,11 @@ func exchangeToken(ctx context.Context, endpoint string, request *stsTokenExchan
 		}
 	}
 	req.Header.Add("Content-Length", strconv.Itoa(len(encodedData)))
+	// The transport only decodes gzip responses to requests it added the
+	// header to itself, and custom transports may not at all. Requesting
+	// gzip explicitly gets the encoded body from any transport, which
+	// readResponseBody decodes within the size limit.
+	req.Header.Set("Accept-Encoding", "gzip")
 
 	resp, err := client.Do(req)
 
@@ -64,12 +94,12 @@ func exchangeToken(ctx context.Context, endpoint string, request *stsTokenExchan
 	}
 	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	body, err := readResponseBody(resp)
 	if err != nil {
 		return nil, err
 	}
 	if c := resp.StatusCode; c < 200 || c > 299 {
-		return nil, fmt.Errorf("oauth2/google: status code %d: %s", c, body)
+		return nil, newSTSError(resp, body)
 	}
 	var stsResp stsTokenExchangeResponse
 	err = json.Unmarshal(body, &stsResp)
@@ -77,10 +107,111 @@ func exchangeToken(ctx context.Context, endpoint string, request *stsTokenExchan
 		return nil, fmt.Errorf("oauth2/google: failed to unmarshal response body from Secure Token Server: %v", err)
 
 	}
+	if err := validateExchangeResponse(request, &stsResp); err != nil {
+		return nil, err
+	}
 
 	return &stsResp, nil
 }
 
+// readResponseBody returns the decoded body of resp, failing if it is larger
+// than maxResponseSize.
+func readResponseBody(resp *http.Response) ([]byte, error) {
+	var r io.Reader = resp.Body
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("oauth2/google: invalid gzip response from Secure Token Server: %v", err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r, maxResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxResponseSize {
+		return nil, fmt.Errorf("oauth2/google: Secure Token Server response exceeds %d bytes", maxResponseSize)
+	}
+	return body, nil
+}
+
+// encodeOptions adds options to data using the given encoding.
+func encodeOptions(data url.Values, options map[string]interface{}, encoding OptionsEncoding) error {
+	if options == nil {
+		return nil
+	}
+	switch encoding {
+	case "", OptionsEncodingJSON:
+		opts, err := json.Marshal(options)
+		if err != nil {
+			return fmt.Errorf("oauth2/google: failed to marshal additional options: %v", err)
+		}
+		data.Set("options", string(opts))
+	case OptionsEncodingForm:
+		for key, val := range options {
+			if _, ok := data[key]; ok {
+				return fmt.Errorf("oauth2/google: option %q conflicts with a token exchange parameter", key)
+			}
+			if s, ok := val.(string); ok {
+				data.Set(key, s)
+				continue
+			}
+			b, err := json.Marshal(val)
+			if err != nil {
+				return fmt.Errorf("oauth2/google: failed to marshal option %q: %v", key, err)
+			}
+			data.Set(key, string(b))
+		}
+	default:
+		return fmt.Errorf("oauth2/google: unsupported options encoding %q", encoding)
+	}
+	return nil
+}
+
+// CloudPlatformScope grants access to all Google Cloud APIs, so it satisfies
+// any requested scope. It is the scope requested when none is configured,
+// and is exported as google.ScopeCloudPlatform.
+const CloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// validateExchangeResponse checks that the token described by resp is the one
+// that was requested. Fields omitted from resp are not checked. Granted scopes
+// are left to scopesDowngraded, as narrower tokens are still usable.
+func validateExchangeResponse(request *stsTokenExchangeRequest, resp *stsTokenExchangeResponse) error {
+	if resp.AccessToken == "" {
+		return errors.New("oauth2/google: security token service response is missing access_token")
+	}
+	if resp.IssuedTokenType != "" && resp.IssuedTokenType != accessTokenType {
+		return &ResponseMismatchError{Field: "issued_token_type", Requested: accessTokenType, Returned: resp.IssuedTokenType}
+	}
+	if resp.TokenType != "" && !strings.EqualFold(resp.TokenType, "bearer") {
+		return &ResponseMismatchError{Field: "token_type", Requested: "Bearer", Returned: resp.TokenType}
+	}
+	return nil
+}
+
+// scopesDowngraded reports whether the granted scopes, the scope field of an
+// exchange response, lack some of the requested scopes. An omitted scope
+// field means the requested scopes were granted.
+func scopesDowngraded(requested []string, granted string) bool {
+	if granted == "" {
+		return false
+	}
+	set := make(map[string]bool)
+	for _, scope := range strings.Fields(granted) {
+		set[scope] = true
+	}
+	if set[CloudPlatformScope] {
+		return false
+	}
+	for _, scope := range requested {
+		if !set[scope] {
+			return true
+		}
+	}
+	return false
+}
+
 // stsTokenExchangeRequest contains fields necessary to make an oauth2 token exchange.
 type stsTokenExchangeRequest struct {
 	ActingParty struct {
@@ -94,6 +225,7 @@ type stsTokenExchangeRequest struct {
 	RequestedTokenType string
 	SubjectToken       string
 	SubjectTokenType   string
+	OptionsEncoding    OptionsEncoding
 }
 
 // stsTokenExchangeResponse is used to decode the remote server response during an oauth2 token exchange.
@@ -5,12 +5,16 @@
 package externalaccount
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 
 	"golang.org/x/oauth2"
@@ -102,6 +106,91 @@ func TestExchangeToken_Err(t *testing.T) {
 	}
 }
 
+func TestExchangeToken_OAuthError(t *testing.T) {
+	tests := []struct {
+		status   int
+		body     string
+		wantCode string
+		wantDesc string
+		wantURI  string
+	}{
+		{
+			status:   http.StatusBadRequest,
+			body:     `{"error":"invalid_grant","error_description":"The subject token is expired.","error_uri":"https://example.com/errors"}`,
+			wantCode: "invalid_grant",
+			wantDesc: "The subject token is expired.",
+			wantURI:  "https://example.com/errors",
+		},
+		{
+			status: http.StatusServiceUnavailable,
+			body:   "upstream unavailable",
+		},
+	}
+	for _, tt := range tests {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(tt.status)
+			w.Write([]byte(tt.body))
+		}))
+		_, err := exchangeToken(context.Background(), ts.URL, &tokenRequest, auth, http.Header{}, nil)
+		ts.Close()
+
+		var re *oauth2.RetrieveError
+		if !errors.As(err, &re) {
+			t.Fatalf("got error %v but want an *oauth2.RetrieveError", err)
+		}
+		if got, want := re.Response.StatusCode, tt.status; got != want {
+			t.Errorf("got status code %v but want %v", got, want)
+		}
+		if re.ErrorCode != tt.wantCode || re.ErrorDescription != tt.wantDesc || re.ErrorURI != tt.wantURI {
+			t.Errorf("got error fields %q, %q, %q but want %q, %q, %q", re.ErrorCode, re.ErrorDescription, re.ErrorURI, tt.wantCode, tt.wantDesc, tt.wantURI)
+		}
+		if got, want := string(re.Body), tt.body; got != want {
+			t.Errorf("got body %v but want %v", got, want)
+		}
+	}
+}
+
+func TestExchangeToken_Gzip(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("Accept-Encoding"), "gzip"; got != want {
+			t.Errorf("got Accept-Encoding %v but want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		zw.Write([]byte(responseBody))
+		zw.Close()
+	}))
+	defer ts.Close()
+
+	resp, err := exchangeToken(context.Background(), ts.URL, &tokenRequest, auth, http.Header{}, nil)
+	if err != nil {
+		t.Fatalf("exchangeToken failed with error: %v", err)
+	}
+	if expectedToken != *resp {
+		t.Errorf("got %v but want %v", *resp, expectedToken)
+	}
+}
+
+func TestExchangeToken_ResponseTooLarge(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		zw.Write([]byte(`{"access_token":"`))
+		zw.Write(bytes.Repeat([]byte("a"), maxResponseSize))
+		zw.Write([]byte(`"}`))
+		zw.Close()
+	}))
+	defer ts.Close()
+
+	_, err := exchangeToken(context.Background(), ts.URL, &tokenRequest, auth, http.Header{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Errorf("got %v but want a response size error", err)
+	}
+}
+
 /* Lean test specifically for options, as the other features are tested earlier. */
 type testOpts struct {
 	First  string `json:"first"`
@@ -185,3 +274,129 @@ func TestExchangeToken_Opts(t *testing.T) {
 	inputOpts["two"] = secondOption
 	exchangeToken(context.Background(), ts.URL, &tokenRequest, auth, headers, inputOpts)
 }
+
+func TestExchangeToken_ResponseValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		response  string
+		wantField string
+	}{
+		{
+			name:     "Requested Scope",
+			response: `{"access_token":"Sample.Access.Token","issued_token_type":"urn:ietf:params:oauth:token-type:access_token","token_type":"Bearer","expires_in":3600,"scope":"https://www.googleapis.com/auth/devstorage.full_control"}`,
+		},
+		{
+			name:     "Omitted Fields",
+			response: `{"access_token":"Sample.Access.Token","expires_in":3600}`,
+		},
+		{
+			name:      "Issued Token Type Mismatch",
+			response:  `{"access_token":"Sample.Access.Token","issued_token_type":"urn:ietf:params:oauth:token-type:id_token","token_type":"Bearer","expires_in":3600}`,
+			wantField: "issued_token_type",
+		},
+		{
+			name:      "Token Type Mismatch",
+			response:  `{"access_token":"Sample.Access.Token","issued_token_type":"urn:ietf:params:oauth:token-type:access_token","token_type":"MAC","expires_in":3600}`,
+			wantField: "token_type",
+		},
+		{
+			name:     "Narrower Scope",
+			response: `{"access_token":"Sample.Access.Token","issued_token_type":"urn:ietf:params:oauth:token-type:access_token","token_type":"Bearer","expires_in":3600,"scope":"https://www.googleapis.com/auth/devstorage.read_only"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(tt.response))
+			}))
+			defer ts.Close()
+
+			headers := http.Header{}
+			headers.Add("Content-Type", "application/x-www-form-urlencoded")
+			_, err := exchangeToken(context.Background(), ts.URL, &tokenRequest, auth, headers, nil)
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("exchangeToken failed with error: %v", err)
+				}
+				return
+			}
+			var mismatch *ResponseMismatchError
+			if !errors.As(err, &mismatch) {
+				t.Fatalf("exchangeToken error = %v, want a *ResponseMismatchError", err)
+			}
+			if got, want := mismatch.Field, tt.wantField; got != want {
+				t.Errorf("mismatched field = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestScopesDowngraded(t *testing.T) {
+	requested := []string{"https://www.googleapis.com/auth/devstorage.full_control"}
+	tests := []struct {
+		granted string
+		want    bool
+	}{
+		{"", false},
+		{"https://www.googleapis.com/auth/devstorage.full_control", false},
+		{CloudPlatformScope, false},
+		{"https://www.googleapis.com/auth/devstorage.read_only", true},
+	}
+	for _, tt := range tests {
+		if got := scopesDowngraded(requested, tt.granted); got != tt.want {
+			t.Errorf("scopesDowngraded(%q) = %v, want %v", tt.granted, got, tt.want)
+		}
+	}
+}
+
+func TestExchangeToken_MissingAccessToken(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer ts.Close()
+
+	headers := http.Header{}
+	headers.Add("Content-Type", "application/x-www-form-urlencoded")
+	if _, err := exchangeToken(context.Background(), ts.URL, &tokenRequest, auth, headers, nil); err == nil {
+		t.Errorf("Expected handled error; instead got nil.")
+	}
+}
+
+func TestEncodeOptions(t *testing.T) {
+	options := map[string]interface{}{
+		"userProject": "project",
+		"nested":      map[string]string{"key": "value"},
+	}
+	tests := []struct {
+		encoding OptionsEncoding
+		want     url.Values
+	}{
+		{
+			encoding: "",
+			want:     url.Values{"options": {`{"nested":{"key":"value"},"userProject":"project"}`}},
+		},
+		{
+			encoding: OptionsEncodingForm,
+			want:     url.Values{"userProject": {"project"}, "nested": {`{"key":"value"}`}},
+		},
+	}
+	for _, tt := range tests {
+		data := url.Values{}
+		if err := encodeOptions(data, options, tt.encoding); err != nil {
+			t.Fatalf("encodeOptions(%q) failed: %v", tt.encoding, err)
+		}
+		if got := data.Encode(); got != tt.want.Encode() {
+			t.Errorf("encodeOptions(%q) = %v but want %v", tt.encoding, got, tt.want.Encode())
+		}
+	}
+
+	data := url.Values{"audience": {"aud"}}
+	if err := encodeOptions(data, map[string]interface{}{"audience": "other"}, OptionsEncodingForm); err == nil {
+		t.Error("encodeOptions() overriding a standard parameter succeeded, want error")
+	}
+	if err := encodeOptions(url.Values{}, options, "xml"); err == nil {
+		t.Error("encodeOptions() with unknown encoding succeeded, want error")
+	}
+}
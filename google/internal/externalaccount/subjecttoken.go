@@ -0,0 +1,68 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package externalaccount
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2/jws"
+)
+
+// reusableSubjectToken holds the last subject token retrieved by a
+// tokenSource so that it can be exchanged again while it is still valid.
+type reusableSubjectToken struct {
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+	// version identifies the content of the watched token file the token
+	// was read from.
+	version fileVersion
+}
+
+// setVersion records the version of the watched token file, discarding the
+// stored subject token if the file changed since it was read.
+func (r *reusableSubjectToken) setVersion(v fileVersion) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if v != r.version {
+		r.token, r.expiry, r.version = "", time.Time{}, v
+	}
+}
+
+// get returns the stored subject token if it remains valid for at least
+// margin, and "" otherwise.
+func (r *reusableSubjectToken) get(margin time.Duration) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.token == "" || !now().Add(margin).Before(r.expiry) {
+		return ""
+	}
+	return r.token
+}
+
+// put stores token until expiry, as reported by the credential source. When
+// expiry is zero, token is stored if it is a JWT with an exp claim. Other
+// tokens are not reused since their lifetime is unknown.
+func (r *reusableSubjectToken) put(token string, expiry time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.token, r.expiry = "", time.Time{}
+	if expiry.IsZero() {
+		claims, err := jws.Decode(token)
+		if err != nil || claims.Exp == 0 {
+			return
+		}
+		expiry = time.Unix(claims.Exp, 0)
+	}
+	r.token, r.expiry = token, expiry
+}
+
+// expiringCredentialSource is implemented by credential sources that report
+// when their subject tokens expire, such as executables through their
+// expiration_time field.
+type expiringCredentialSource interface {
+	subjectTokenWithExpiry() (string, time.Time, error)
+}
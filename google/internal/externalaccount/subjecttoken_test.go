@@ -0,0 +1,160 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package externalaccount
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// countingSubjectTokenSupplier returns token and counts how often it is
+// asked for it.
+type countingSubjectTokenSupplier struct {
+	token string
+	calls int
+}
+
+func (s *countingSubjectTokenSupplier) SubjectToken(ctx context.Context, options SupplierOptions) (string, error) {
+	s.calls++
+	return s.token, nil
+}
+
+func testJWT(exp time.Time) string {
+	claims := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d}`, exp.Unix())))
+	return "eyJhbGciOiJSUzI1NiJ9." + claims + ".signature"
+}
+
+func TestSubjectTokenReuse(t *testing.T) {
+	oldNow := now
+	defer func() { now = oldNow }()
+	current := time.Unix(expiry, 0)
+	now = func() time.Time { return current }
+
+	tests := []struct {
+		name      string
+		token     string
+		margin    time.Duration
+		wantCalls int
+	}{
+		{
+			name:      "valid JWT is reused",
+			token:     testJWT(current.Add(time.Hour)),
+			margin:    time.Minute,
+			wantCalls: 1,
+		},
+		{
+			name:      "JWT within margin is not reused",
+			token:     testJWT(current.Add(30 * time.Second)),
+			margin:    time.Minute,
+			wantCalls: 2,
+		},
+		{
+			name:      "opaque token is not reused",
+			token:     "street123",
+			margin:    time.Minute,
+			wantCalls: 2,
+		},
+		{
+			name:      "reuse disabled",
+			token:     testJWT(current.Add(time.Hour)),
+			wantCalls: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			supplier := &countingSubjectTokenSupplier{token: tt.token}
+			config := testConfig
+			config.SubjectTokenSupplier = supplier
+			config.SubjectTokenReuseMargin = tt.margin
+			ts := tokenSource{
+				ctx:     context.Background(),
+				conf:    &config,
+				subject: &reusableSubjectToken{},
+			}
+			for i := 0; i < 2; i++ {
+				got, err := ts.subjectToken()
+				if err != nil {
+					t.Fatalf("subjectToken() failed: %v", err)
+				}
+				if got != tt.token {
+					t.Errorf("got %v but want %v", got, tt.token)
+				}
+			}
+			if supplier.calls != tt.wantCalls {
+				t.Errorf("supplier called %d times, want %d", supplier.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestReusableSubjectTokenExplicitExpiry(t *testing.T) {
+	oldNow := now
+	defer func() { now = oldNow }()
+	current := time.Unix(expiry, 0)
+	now = func() time.Time { return current }
+
+	var r reusableSubjectToken
+	// An opaque SAML assertion is reused until the expiry reported by its
+	// credential source.
+	r.put("saml-assertion", current.Add(time.Hour))
+	if got, want := r.get(time.Minute), "saml-assertion"; got != want {
+		t.Errorf("got %v but want %v", got, want)
+	}
+	if got := r.get(2 * time.Hour); got != "" {
+		t.Errorf("got %v but want no token within the margin", got)
+	}
+
+	// The reported expiry takes precedence over the exp claim.
+	r.put(testJWT(current.Add(time.Hour)), current.Add(30*time.Second))
+	if got := r.get(time.Minute); got != "" {
+		t.Errorf("got %v but want no token within the margin", got)
+	}
+}
+
+func TestSubjectTokenReuse_WatchFile(t *testing.T) {
+	oldNow := now
+	defer func() { now = oldNow }()
+	current := time.Unix(expiry, 0)
+	now = func() time.Time { return current }
+
+	for _, watch := range []bool{false, true} {
+		t.Run(fmt.Sprintf("watch=%v", watch), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "token")
+			first, second := testJWT(current.Add(time.Hour)), testJWT(current.Add(2*time.Hour))
+			if err := os.WriteFile(path, []byte(first), 0600); err != nil {
+				t.Fatal(err)
+			}
+			config := testConfig
+			config.CredentialSource = CredentialSource{File: path}
+			config.SubjectTokenReuseMargin = time.Minute
+			config.WatchSubjectTokenFile = watch
+			ts := tokenSource{ctx: context.Background(), conf: &config, subject: &reusableSubjectToken{}}
+			if got, err := ts.subjectToken(); err != nil || got != first {
+				t.Fatalf("got %v, %v but want %v", got, err, first)
+			}
+
+			// The refresher rotates the token before the first one expires.
+			if err := os.WriteFile(path, []byte(second), 0600); err != nil {
+				t.Fatal(err)
+			}
+			rotated := time.Now().Add(time.Minute)
+			if err := os.Chtimes(path, rotated, rotated); err != nil {
+				t.Fatal(err)
+			}
+			want := first
+			if watch {
+				want = second
+			}
+			if got, err := ts.subjectToken(); err != nil || got != want {
+				t.Errorf("got %v, %v but want %v", got, err, want)
+			}
+		})
+	}
+}
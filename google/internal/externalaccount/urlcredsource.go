@@ -6,12 +6,16 @@ package externalaccount
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"mime"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"golang.org/x/oauth2"
 )
@@ -19,19 +23,117 @@ import (
 type urlCredentialSource struct {
 	URL     string
 	Headers map[string]string
+	Method  string
+	Body    string
 	Format  format
 	ctx     context.Context
+	// client, if non-nil, replaces the client of ctx.
+	client *http.Client
+}
+
+// ClientCertificateConfig describes the client certificate a URL credential
+// source presents to the subject token endpoint.
+type ClientCertificateConfig struct {
+	// CertPath and KeyPath are the paths of the PEM encoded certificate
+	// chain and private key.
+	CertPath string `json:"cert_path"`
+	KeyPath  string `json:"key_path"`
+}
+
+// subjectTokenClient returns the client of the subject token requests of
+// URL credential sources, a copy of the client of ctx using the TLS
+// configuration and client certificate of c, or nil if c sets neither. The
+// client certificate is loaded again for every handshake, so that it can be
+// rotated on disk.
+func (c *Config) subjectTokenClient(ctx context.Context) (*http.Client, error) {
+	cc := c.CredentialSource.ClientCertificate
+	if cc == nil && c.SubjectTokenTLSConfig == nil {
+		return nil, nil
+	}
+	var cs *x509CredentialSource
+	if cc != nil {
+		cs = &x509CredentialSource{CertPath: cc.CertPath, KeyPath: cc.KeyPath}
+		if _, err := cs.clientCertificate(); err != nil {
+			return nil, fmt.Errorf("oauth2/google: failed to load the client certificate of the URL credential source: %v", err)
+		}
+	}
+	return withTransport(oauth2.NewClient(ctx, nil), func(t *http.Transport) {
+		if c.SubjectTokenTLSConfig != nil {
+			t.TLSClientConfig = c.SubjectTokenTLSConfig.Clone()
+		}
+		if cs != nil {
+			t.TLSClientConfig.Certificates = nil
+			t.TLSClientConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+				return cs.clientCertificate()
+			}
+		}
+	})
+}
+
+// defaultBodyContentType returns the content type of the request body of URL
+// credential sources without a Content-Type header: JSON for bodies that are
+// objects, form data otherwise.
+func defaultBodyContentType(body string) string {
+	if strings.HasPrefix(strings.TrimSpace(body), "{") {
+		return "application/json"
+	}
+	return "application/x-www-form-urlencoded"
+}
+
+// expandBody replaces the ${NAME} placeholders of the request body of URL
+// credential sources as expandAudience does, escaping the values for the
+// content type of the body so that they cannot alter its structure.
+func expandBody(body string, headers map[string]string, vars map[string]string) (string, error) {
+	contentType := defaultBodyContentType(body)
+	for key, val := range headers {
+		if http.CanonicalHeaderKey(key) == "Content-Type" {
+			contentType = val
+		}
+	}
+	var escape func(string) string
+	if mt, _, err := mime.ParseMediaType(contentType); err == nil {
+		switch {
+		case mt == "application/json" || strings.HasSuffix(mt, "+json"):
+			escape = escapeJSONString
+		case mt == "application/x-www-form-urlencoded":
+			escape = url.QueryEscape
+		}
+	}
+	return expandVariables(body, "credential_source body", vars, escape)
+}
+
+// escapeJSONString escapes s for use inside a JSON string literal.
+func escapeJSONString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b[1 : len(b)-1])
 }
 
 func (cs urlCredentialSource) subjectToken() (string, error) {
-	client := oauth2.NewClient(cs.ctx, nil)
-	req, err := http.NewRequest("GET", cs.URL, nil)
+	client := cs.client
+	if client == nil {
+		client = oauth2.NewClient(cs.ctx, nil)
+	}
+	method := cs.Method
+	if method == "" {
+		method = "GET"
+	}
+	var body io.Reader
+	if cs.Body != "" {
+		body = strings.NewReader(cs.Body)
+	}
+	req, err := http.NewRequest(method, cs.URL, body)
 	if err != nil {
 		return "", fmt.Errorf("oauth2/google: HTTP request for URL-sourced credential failed: %v", err)
 	}
 	req = req.WithContext(cs.ctx)
 
+	if cs.Body != "" {
+		req.Header.Set("Content-Type", defaultBodyContentType(cs.Body))
+	}
 	for key, val := range cs.Headers {
+		if http.CanonicalHeaderKey(key) == "Content-Type" {
+			req.Header.Del(key)
+		}
 		req.Header.Add(key, val)
 	}
 	resp, err := client.Do(req)
@@ -55,7 +157,7 @@ func (cs urlCredentialSource) subjectToken() (string, error) {
 		if err != nil {
 			return "", fmt.Errorf("oauth2/google: failed to unmarshal subject token file: %v", err)
 		}
-		val, ok := jsonData[cs.Format.SubjectTokenFieldName]
+		val, ok := cs.Format.field(jsonData)
 		if !ok {
 			return "", errors.New("oauth2/google: provided subject_token_field_name not found in credentials")
 		}
@@ -6,10 +6,15 @@ package externalaccount
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"golang.org/x/oauth2"
 )
 
 var myURLToken = "testTokenValue"
@@ -111,3 +116,171 @@ func TestRetrieveURLSubjectToken_JSON(t *testing.T) {
 		t.Errorf("got %v but want %v", out, myURLToken)
 	}
 }
+
+func TestRetrieveURLSubjectToken_NestedJSON(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": {"attributes": {"id_token": "testTokenValue"}, "tokens": ["other", "testTokenValue"]}, "a.b": "testTokenValue", "number": {"id_token": 1}}`))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		field   string
+		wantErr bool
+	}{
+		{field: "data.attributes.id_token"},
+		{field: "data.tokens.1"},
+		{field: "a.b"},
+		{field: "data.attributes.missing", wantErr: true},
+		{field: "data.tokens.2", wantErr: true},
+		{field: "data.attributes.id_token.value", wantErr: true},
+		{field: "number.id_token", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			tfc := testFileConfig
+			tfc.CredentialSource = CredentialSource{
+				URL:    ts.URL,
+				Format: format{Type: fileTypeJSON, SubjectTokenFieldName: tt.field},
+			}
+			base, err := tfc.parse(context.Background())
+			if err != nil {
+				t.Fatalf("parse() failed %v", err)
+			}
+			out, err := base.subjectToken()
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("subjectToken() returned %v, want error", out)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("subjectToken() failed: %v", err)
+			}
+			if out != myURLToken {
+				t.Errorf("got %v but want %v", out, myURLToken)
+			}
+		})
+	}
+}
+
+func TestRetrieveURLSubjectToken_MethodAndBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		headers     map[string]string
+		wantBody    string
+		contentType string
+	}{
+		{"JSON", `{"client": "${CLIENT}"}`, nil, `{"client": "gateway"}`, "application/json"},
+		{"form", "client=${CLIENT}", nil, "client=gateway", "application/x-www-form-urlencoded"},
+		{"content type header", "client=${CLIENT}", map[string]string{"content-type": "text/plain"}, "client=gateway", "text/plain"},
+		{"JSON escaping", `{"id": "${ID}"}`, nil, `{"id": "a\",\"admin\": \"true"}`, "application/json"},
+		{"form escaping", "id=${ID}", nil, "id=a%22%2C%22admin%22%3A+%22true", "application/x-www-form-urlencoded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got, want := r.Method, "POST"; got != want {
+					t.Errorf("got method %v but want %v", got, want)
+				}
+				if got := r.Header.Values("Content-Type"); len(got) != 1 || got[0] != tt.contentType {
+					t.Errorf("got Content-Type %q but want %q", got, tt.contentType)
+				}
+				body, _ := ioutil.ReadAll(r.Body)
+				if string(body) != tt.wantBody {
+					t.Errorf("got body %q but want %q", body, tt.wantBody)
+				}
+				w.Write([]byte(myURLToken))
+			}))
+			defer ts.Close()
+
+			tfc := testFileConfig
+			tfc.AudienceVariables = map[string]string{"CLIENT": "gateway", "ID": `a","admin": "true`}
+			tfc.CredentialSource = CredentialSource{URL: ts.URL, Method: "POST", Body: tt.body, Headers: tt.headers}
+			base, err := tfc.parse(context.Background())
+			if err != nil {
+				t.Fatalf("parse() failed %v", err)
+			}
+			out, err := base.subjectToken()
+			if err != nil {
+				t.Fatalf("subjectToken() failed: %v", err)
+			}
+			if out != myURLToken {
+				t.Errorf("got %v but want %v", out, myURLToken)
+			}
+		})
+	}
+
+	tfc := testFileConfig
+	tfc.CredentialSource = CredentialSource{URL: "http://localhost", Method: "POST", Body: "client=${UNSET_CLIENT}"}
+	if _, err := tfc.parse(context.Background()); err == nil {
+		t.Error("parse() succeeded with an unset body variable, want error")
+	}
+}
+
+func TestRetrieveURLSubjectToken_ClientCertificate(t *testing.T) {
+	certPath, keyPath, leaf := writeTestCertificate(t, t.TempDir(), "client")
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 || !r.TLS.PeerCertificates[0].Equal(mustParseCertificate(t, leaf)) {
+			t.Errorf("request without the client certificate")
+		}
+		w.Write([]byte(myURLToken))
+	}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	ts.StartTLS()
+	defer ts.Close()
+
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ts.Certificate())
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		source CredentialSource
+		tls    *tls.Config
+	}{
+		{
+			name:   "paths",
+			ctx:    context.WithValue(context.Background(), oauth2.HTTPClient, ts.Client()),
+			source: CredentialSource{URL: ts.URL, ClientCertificate: &ClientCertificateConfig{CertPath: certPath, KeyPath: keyPath}},
+		},
+		{
+			name:   "TLS config",
+			ctx:    context.Background(),
+			source: CredentialSource{URL: ts.URL},
+			tls:    &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{cert}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tfc := testFileConfig
+			tfc.CredentialSource = tt.source
+			tfc.SubjectTokenTLSConfig = tt.tls
+			client, err := tfc.subjectTokenClient(tt.ctx)
+			if err != nil {
+				t.Fatalf("subjectTokenClient() failed %v", err)
+			}
+			tfc.subjectClient = client
+			base, err := tfc.parse(tt.ctx)
+			if err != nil {
+				t.Fatalf("parse() failed %v", err)
+			}
+			out, err := base.subjectToken()
+			if err != nil {
+				t.Fatalf("subjectToken() failed: %v", err)
+			}
+			if out != myURLToken {
+				t.Errorf("got %v but want %v", out, myURLToken)
+			}
+		})
+	}
+
+	tfc := testFileConfig
+	tfc.CredentialSource = CredentialSource{URL: ts.URL, ClientCertificate: &ClientCertificateConfig{CertPath: certPath, KeyPath: certPath}}
+	if _, err := tfc.subjectTokenClient(context.Background()); err == nil {
+		t.Error("subjectTokenClient() succeeded with an invalid key, want error")
+	}
+}
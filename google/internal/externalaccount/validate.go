@@ -0,0 +1,102 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package externalaccount
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Validate checks the fields of c read from a credential configuration
+// file, so that mistakes such as a missing credential source or a malformed
+// executable section are reported when the file is loaded rather than on the
+// first token exchange.
+func (c *Config) Validate() error {
+	switch {
+	case c.Audience == "":
+		return configMissingFieldError("audience")
+	case c.SubjectTokenType == "":
+		return configMissingFieldError("subject_token_type")
+	case c.TokenURL == "":
+		return configMissingFieldError("token_url")
+	}
+	if c.ServiceAccountImpersonationLifetimeSeconds < 0 {
+		return errors.New("oauth2/google: invalid `service_account_impersonation.token_lifetime_seconds` field — lifetime must not be negative")
+	}
+	if c.ClientSecret != "" && c.ClientID == "" {
+		return errors.New("oauth2/google: `client_secret` requires `client_id`")
+	}
+	if e := c.OptionsEncoding; e != "" && e != OptionsEncodingJSON && e != OptionsEncodingForm {
+		return fmt.Errorf("oauth2/google: unsupported options encoding %q", e)
+	}
+	// Audiences with placeholders are only checked once resolved.
+	if c.WorkforcePoolUserProject != "" && !strings.Contains(c.Audience, "${") && !validateWorkforceAudience(c.Audience, c.universeDomain()) {
+		return errors.New("oauth2/google: workforce_pool_user_project should not be set for non-workforce pool credentials")
+	}
+	return c.CredentialSource.validate(c)
+}
+
+// validate checks that cs describes exactly one kind of credential source.
+func (cs *CredentialSource) validate(c *Config) error {
+	var kinds []string
+	if cs.EnvironmentID != "" {
+		// AWS and Azure credential sources use URL for their own purposes.
+		kinds = append(kinds, "environment_id")
+		if !strings.HasPrefix(cs.EnvironmentID, "aws") && !strings.HasPrefix(cs.EnvironmentID, "azure") {
+			return fmt.Errorf("oauth2/google: unsupported `environment_id` %q", cs.EnvironmentID)
+		}
+	} else if cs.URL != "" {
+		kinds = append(kinds, "url")
+	}
+	if cs.File != "" {
+		kinds = append(kinds, "file")
+	}
+	if cs.Executable != nil {
+		kinds = append(kinds, "executable")
+	}
+	if cs.Certificate != nil {
+		kinds = append(kinds, "certificate")
+	}
+	if (cs.Method != "" || cs.Body != "") && (cs.URL == "" || cs.EnvironmentID != "") {
+		return errors.New("oauth2/google: `credential_source` `method` and `body` are only supported with `url`")
+	}
+	if cs.ClientCertificate != nil && (cs.URL == "" || cs.EnvironmentID != "") {
+		return errors.New("oauth2/google: `credential_source` `client_certificate` is only supported with `url`")
+	}
+	switch cs.Method {
+	case "", "GET", "POST", "PUT":
+	default:
+		return fmt.Errorf("oauth2/google: unsupported `credential_source` `method` %q, want GET, POST or PUT", cs.Method)
+	}
+	switch len(kinds) {
+	case 0:
+		return errors.New("oauth2/google: `credential_source` must set one of `file`, `url`, `executable`, `certificate` or `environment_id`")
+	case 1:
+	default:
+		return fmt.Errorf("oauth2/google: `credential_source` sets more than one of %s", strings.Join(kinds, ", "))
+	}
+
+	switch cs.Format.Type {
+	case "", fileTypeText:
+	case fileTypeJSON:
+		if cs.Format.SubjectTokenFieldName == "" {
+			return errors.New("oauth2/google: `credential_source.format` of type json requires `subject_token_field_name`")
+		}
+	default:
+		return fmt.Errorf("oauth2/google: invalid `credential_source.format.type` %q, want %q or %q", cs.Format.Type, fileTypeText, fileTypeJSON)
+	}
+	if cs.Executable != nil {
+		if _, err := CreateExecutableCredential(context.Background(), cs.Executable, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func configMissingFieldError(field string) error {
+	return fmt.Errorf("oauth2/google: external account configuration is missing the `%s` field", field)
+}
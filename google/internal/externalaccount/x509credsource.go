@@ -0,0 +1,208 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package externalaccount
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// certificateConfigEnvVar names the environment variable overriding the
+// location of the default certificate configuration file.
+const certificateConfigEnvVar = "GOOGLE_API_CERTIFICATE_CONFIG"
+
+// CertificateConfig describes the client certificate of an X.509 credential
+// source. The certificate authenticates the workload to the security token
+// service over mutual TLS, and the subject token is the certificate chain.
+type CertificateConfig struct {
+	// UseDefaultCertificateConfig selects the certificate configuration
+	// file at the location named by GOOGLE_API_CERTIFICATE_CONFIG or, if
+	// unset, the gcloud default location.
+	UseDefaultCertificateConfig bool `json:"use_default_certificate_config"`
+	// CertificateConfigLocation is the path of the certificate
+	// configuration file. It cannot be combined with
+	// UseDefaultCertificateConfig.
+	CertificateConfigLocation string `json:"certificate_config_location"`
+	// TrustChainPath is the optional path of a PEM file with the
+	// intermediate certificates sent along with the leaf certificate.
+	TrustChainPath string `json:"trust_chain_path"`
+}
+
+// certificateConfigFile is the format of certificate configuration files
+// written by gcloud.
+type certificateConfigFile struct {
+	CertConfigs struct {
+		Workload *struct {
+			CertPath string `json:"cert_path"`
+			KeyPath  string `json:"key_path"`
+		} `json:"workload"`
+	} `json:"cert_configs"`
+}
+
+type x509CredentialSource struct {
+	CertPath       string
+	KeyPath        string
+	TrustChainPath string
+}
+
+// newX509CredentialSource resolves the certificate and key paths of config.
+func newX509CredentialSource(config *CertificateConfig) (x509CredentialSource, error) {
+	location := config.CertificateConfigLocation
+	switch {
+	case config.UseDefaultCertificateConfig && location != "":
+		return x509CredentialSource{}, errors.New("oauth2/google: use_default_certificate_config and certificate_config_location cannot both be set")
+	case config.UseDefaultCertificateConfig:
+		location = defaultCertificateConfigLocation()
+	case location == "":
+		return x509CredentialSource{}, errors.New("oauth2/google: one of use_default_certificate_config or certificate_config_location must be set")
+	}
+	b, err := os.ReadFile(location)
+	if err != nil {
+		return x509CredentialSource{}, fmt.Errorf("oauth2/google: failed to read certificate config: %v", err)
+	}
+	var f certificateConfigFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return x509CredentialSource{}, fmt.Errorf("oauth2/google: failed to parse certificate config: %v", err)
+	}
+	w := f.CertConfigs.Workload
+	if w == nil || w.CertPath == "" || w.KeyPath == "" {
+		return x509CredentialSource{}, fmt.Errorf("oauth2/google: certificate config %q has no workload certificate", location)
+	}
+	return x509CredentialSource{CertPath: w.CertPath, KeyPath: w.KeyPath, TrustChainPath: config.TrustChainPath}, nil
+}
+
+// defaultCertificateConfigLocation returns the location of the certificate
+// configuration file written by gcloud.
+func defaultCertificateConfigLocation() string {
+	if location := getenv(certificateConfigEnvVar); location != "" {
+		return location
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(getenv("APPDATA"), "gcloud", "certificate_config.json")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "gcloud", "certificate_config.json")
+}
+
+// subjectToken returns the certificate chain as a JSON array of base64
+// encoded DER certificates, leaf certificate first.
+func (cs x509CredentialSource) subjectToken() (string, error) {
+	leaf, err := readCertificates(cs.CertPath)
+	if err != nil {
+		return "", err
+	}
+	chain := leaf[:1]
+	if cs.TrustChainPath != "" {
+		trust, err := readCertificates(cs.TrustChainPath)
+		if err != nil {
+			return "", err
+		}
+		for i, cert := range trust {
+			if cert.Equal(leaf[0]) {
+				if i != 0 {
+					return "", errors.New("oauth2/google: the leaf certificate must be first in the trust chain")
+				}
+				continue
+			}
+			chain = append(chain, cert)
+		}
+	}
+	encoded := make([]string, len(chain))
+	for i, cert := range chain {
+		encoded[i] = base64.StdEncoding.EncodeToString(cert.Raw)
+	}
+	b, err := json.Marshal(encoded)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// readCertificates parses the PEM certificates in the file at path.
+func readCertificates(path string) ([]*x509.Certificate, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("oauth2/google: failed to read certificate: %v", err)
+	}
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("oauth2/google: failed to parse certificate %q: %v", path, err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("oauth2/google: no certificate found in %q", path)
+	}
+	return certs, nil
+}
+
+// httpClient returns a copy of base, or of a client using
+// http.DefaultTransport if base is nil, presenting the client certificate
+// of cs in TLS handshakes. The certificate is loaded again for every
+// handshake, so that the client keeps presenting the certificate whose
+// chain subjectToken returns when it is rotated on disk.
+func (cs x509CredentialSource) httpClient(base *http.Client) (*http.Client, error) {
+	if _, err := cs.clientCertificate(); err != nil {
+		return nil, err
+	}
+	return withTransport(base, func(t *http.Transport) {
+		t.TLSClientConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return cs.clientCertificate()
+		}
+	})
+}
+
+// clientCertificate loads the client certificate and key of cs.
+func (cs x509CredentialSource) clientCertificate() (*tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(cs.CertPath, cs.KeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("oauth2/google: failed to load client certificate: %v", err)
+	}
+	return &cert, nil
+}
+
+// withTransport returns a copy of base, or of a client using
+// http.DefaultTransport if base is nil, with a clone of its transport
+// modified by configure. The TLSClientConfig of the clone is never nil.
+func withTransport(base *http.Client, configure func(*http.Transport)) (*http.Client, error) {
+	client := &http.Client{}
+	if base != nil {
+		*client = *base
+	}
+	var transport *http.Transport
+	switch t := client.Transport.(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = t.Clone()
+	default:
+		return nil, errors.New("oauth2/google: client certificates require an *http.Transport")
+	}
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	configure(transport)
+	client.Transport = transport
+	return client, nil
+}
@@ -0,0 +1,209 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package externalaccount
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCertificate writes a self-signed certificate and its key to dir
+// and returns their paths and the DER encoded certificate.
+func writeTestCertificate(t *testing.T, dir, name string) (certPath, keyPath string, der []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err = x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPath = filepath.Join(dir, name+".pem")
+	keyPath = filepath.Join(dir, name+".key")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certPath, keyPath, der
+}
+
+func writeCertificateConfig(t *testing.T, dir, certPath, keyPath string) string {
+	t.Helper()
+	path := filepath.Join(dir, "certificate_config.json")
+	b := fmt.Sprintf(`{"cert_configs":{"workload":{"cert_path":%q,"key_path":%q}}}`, certPath, keyPath)
+	if err := os.WriteFile(path, []byte(b), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestX509CredentialSource_SubjectToken(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath, leaf := writeTestCertificate(t, dir, "leaf")
+	chainPath, _, intermediate := writeTestCertificate(t, dir, "intermediate")
+	location := writeCertificateConfig(t, dir, certPath, keyPath)
+
+	cs, err := newX509CredentialSource(&CertificateConfig{CertificateConfigLocation: location, TrustChainPath: chainPath})
+	if err != nil {
+		t.Fatalf("newX509CredentialSource() failed: %v", err)
+	}
+	token, err := cs.subjectToken()
+	if err != nil {
+		t.Fatalf("subjectToken() failed: %v", err)
+	}
+	var got []string
+	if err := json.Unmarshal([]byte(token), &got); err != nil {
+		t.Fatalf("subject token %q is not a JSON array: %v", token, err)
+	}
+	want := []string{base64.StdEncoding.EncodeToString(leaf), base64.StdEncoding.EncodeToString(intermediate)}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("got %v but want %v", got, want)
+	}
+}
+
+func TestX509CredentialSource_DefaultConfig(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath, _ := writeTestCertificate(t, dir, "leaf")
+	location := writeCertificateConfig(t, dir, certPath, keyPath)
+
+	oldGetenv := getenv
+	defer func() { getenv = oldGetenv }()
+	getenv = setEnvironment(map[string]string{certificateConfigEnvVar: location})
+
+	cs, err := newX509CredentialSource(&CertificateConfig{UseDefaultCertificateConfig: true})
+	if err != nil {
+		t.Fatalf("newX509CredentialSource() failed: %v", err)
+	}
+	if cs.CertPath != certPath || cs.KeyPath != keyPath {
+		t.Errorf("got paths %v, %v but want %v, %v", cs.CertPath, cs.KeyPath, certPath, keyPath)
+	}
+
+	if _, err := newX509CredentialSource(&CertificateConfig{UseDefaultCertificateConfig: true, CertificateConfigLocation: location}); err == nil {
+		t.Error("newX509CredentialSource() succeeded with both locations, want error")
+	}
+	if _, err := newX509CredentialSource(&CertificateConfig{}); err == nil {
+		t.Error("newX509CredentialSource() succeeded without a location, want error")
+	}
+}
+
+func TestToken_X509(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath, leaf := writeTestCertificate(t, dir, "leaf")
+	location := writeCertificateConfig(t, dir, certPath, keyPath)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 || !r.TLS.PeerCertificates[0].Equal(mustParseCertificate(t, leaf)) {
+			t.Errorf("request without the client certificate")
+		}
+		if got, want := r.FormValue("subject_token_type"), "urn:ietf:params:oauth:token-type:mtls"; got != want {
+			t.Errorf("got subject token type %v but want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(baseCredsResponseBody))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	config := Config{
+		Audience:         "//iam.googleapis.com/projects/123/locations/global/workloadIdentityPools/pool/providers/provider",
+		SubjectTokenType: "urn:ietf:params:oauth:token-type:mtls",
+		TokenURL:         server.URL,
+		CredentialSource: CredentialSource{Certificate: &CertificateConfig{CertificateConfigLocation: location}},
+		HTTPClient:       server.Client(),
+	}
+	ts, err := config.TokenSource(context.Background())
+	if err != nil {
+		t.Fatalf("TokenSource() failed: %v", err)
+	}
+	tok, err := ts.Token()
+	if err != nil {
+		t.Fatalf("Token() failed: %v", err)
+	}
+	if got, want := tok.AccessToken, "Sample.Access.Token"; got != want {
+		t.Errorf("got %v but want %v", got, want)
+	}
+	if got, want := config.credentialSourceType(), "x509"; got != want {
+		t.Errorf("got source %v but want %v", got, want)
+	}
+}
+
+func TestX509CredentialSource_HTTPClientRotation(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath, leaf := writeTestCertificate(t, dir, "leaf")
+
+	var peer []byte
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) > 0 {
+			peer = r.TLS.PeerCertificates[0].Raw
+		}
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.Config.SetKeepAlivesEnabled(false)
+	server.StartTLS()
+	defer server.Close()
+
+	cs := x509CredentialSource{CertPath: certPath, KeyPath: keyPath}
+	client, err := cs.httpClient(server.Client())
+	if err != nil {
+		t.Fatalf("httpClient() failed: %v", err)
+	}
+	get := func() {
+		t.Helper()
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Get() failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	get()
+	if !mustParseCertificate(t, leaf).Equal(mustParseCertificate(t, peer)) {
+		t.Errorf("request without the client certificate")
+	}
+	_, _, rotated := writeTestCertificate(t, dir, "leaf")
+	get()
+	if !mustParseCertificate(t, rotated).Equal(mustParseCertificate(t, peer)) {
+		t.Errorf("request without the rotated client certificate")
+	}
+}
+
+func mustParseCertificate(t *testing.T, der []byte) *x509.Certificate {
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
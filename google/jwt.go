@@ -51,16 +51,9 @@ func newJWTSource(jsonKey []byte, audience string, scopes []string) (oauth2.Toke
 	if err != nil {
 		return nil, fmt.Errorf("google: could not parse JSON key: %v", err)
 	}
-	pk, err := internal.ParseKey(cfg.PrivateKey)
+	ts, err := newJWTAccessTokenSource(cfg.Email, cfg.PrivateKey, cfg.PrivateKeyID, audience, scopes)
 	if err != nil {
-		return nil, fmt.Errorf("google: could not parse key: %v", err)
-	}
-	ts := &jwtAccessTokenSource{
-		email:    cfg.Email,
-		audience: audience,
-		scopes:   scopes,
-		pk:       pk,
-		pkID:     cfg.PrivateKeyID,
+		return nil, err
 	}
 	tok, err := ts.Token()
 	if err != nil {
@@ -70,6 +63,20 @@ func newJWTSource(jsonKey []byte, audience string, scopes []string) (oauth2.Toke
 	return rts, nil
 }
 
+func newJWTAccessTokenSource(email string, key []byte, keyID, audience string, scopes []string) (*jwtAccessTokenSource, error) {
+	pk, err := internal.ParseKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("google: could not parse key: %v", err)
+	}
+	return &jwtAccessTokenSource{
+		email:    email,
+		audience: audience,
+		scopes:   scopes,
+		pk:       pk,
+		pkID:     keyID,
+	}, nil
+}
+
 type jwtAccessTokenSource struct {
 	email, audience string
 	scopes          []string
@@ -77,6 +84,11 @@ type jwtAccessTokenSource struct {
 	pkID            string
 }
 
+// DescribeTokenSource describes ts, see oauth2.DescribeTokenSource.
+func (ts *jwtAccessTokenSource) DescribeTokenSource() (string, oauth2.TokenSource) {
+	return internal.DescribeParams("self_signed_jwt", "email", ts.email, "key_id", ts.pkID, "audience", ts.audience, "scopes", strings.Join(ts.scopes, ",")), nil
+}
+
 func (ts *jwtAccessTokenSource) Token() (*oauth2.Token, error) {
 	iat := time.Now()
 	exp := iat.Add(time.Hour)
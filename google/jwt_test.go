@@ -6,6 +6,7 @@ package google
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -158,3 +159,35 @@ func setupDummyKey(t *testing.T) {
 		jsonKey = bytes.Replace(jwtJSONKey, []byte(`"super secret key"`), enc, 1)
 	})
 }
+
+func TestCredentialsFromJSONWithParams_SelfSignedJWT(t *testing.T) {
+	setupDummyKey(t)
+
+	params := CredentialsParams{
+		Scopes:             []string{"https://www.googleapis.com/auth/cloud-platform"},
+		ServiceAccountFlow: ServiceAccountFlowSelfSignedJWT,
+	}
+	creds, err := CredentialsFromJSONWithParams(context.Background(), jsonKey, params)
+	if err != nil {
+		t.Fatalf("CredentialsFromJSONWithParams: %v", err)
+	}
+	tok, err := creds.TokenSource.Token()
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	if err := jws.Verify(tok.AccessToken, &privateKey.PublicKey); err != nil {
+		t.Errorf("jws.Verify on AccessToken: %v", err)
+	}
+	claim, err := jws.Decode(tok.AccessToken)
+	if err != nil {
+		t.Fatalf("jws.Decode on AccessToken: %v", err)
+	}
+	if got, want := claim.Scope, "https://www.googleapis.com/auth/cloud-platform"; got != want {
+		t.Errorf("Scope = %q, want %q", got, want)
+	}
+
+	params.Subject = "user@example.com"
+	if _, err := CredentialsFromJSONWithParams(context.Background(), jsonKey, params); err == nil {
+		t.Error("CredentialsFromJSONWithParams with Subject succeeded, want error")
+	}
+}
@@ -0,0 +1,15 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package kubernetes provides helpers for authenticating Kubernetes workloads
+// to Google Cloud with workload identity federation.
+//
+// The helpers in this package read the projected service account token that
+// the kubelet mounts into the pod and rotates before it expires. The token is
+// used as the subject token of a Security Token Service exchange for a Google
+// Cloud access token, with a workload identity pool provider trusting the
+// cluster's service account issuer.
+// For more information on workload identity federation with Kubernetes, refer to
+// https://cloud.google.com/iam/docs/workload-identity-federation-with-kubernetes.
+package kubernetes // import "golang.org/x/oauth2/google/kubernetes"
@@ -0,0 +1,61 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/compute/metadata"
+	"golang.org/x/oauth2/google/internal/externalaccount"
+)
+
+// NewGKEConfig returns the Config of a workload of the GKE cluster it runs
+// in, so that it needs no credential configuration file. The audience is
+// derived from the metadata server following the GKE naming convention: the
+// workload identity pool of project PROJECT_ID is PROJECT_ID.svc.id.goog,
+// and its provider is the cluster,
+// https://container.googleapis.com/v1/projects/PROJECT_ID/locations/LOCATION/clusters/CLUSTER.
+//
+// The GOOGLE_WORKLOAD_IDENTITY_POOL and GOOGLE_WORKLOAD_IDENTITY_PROVIDER
+// environment variables, if both set, are used instead of the metadata
+// server, and GOOGLE_WORKLOAD_IDENTITY_TOKEN_FILE overrides the path of the
+// projected service account token.
+func NewGKEConfig() (*Config, error) {
+	audience, tokenFile, ok := externalaccount.GKEWorkloadIdentityFromEnv()
+	if !ok {
+		pool, provider, err := gkeWorkloadIdentity(metadata.NewClient(nil))
+		if err != nil {
+			return nil, err
+		}
+		audience = externalaccount.IdentityNamespaceAudience(pool, provider)
+	}
+	return &Config{Audience: audience, TokenFile: tokenFile}, nil
+}
+
+// gkeWorkloadIdentity returns the workload identity pool and provider of the
+// cluster, as described by the metadata server.
+func gkeWorkloadIdentity(c *metadata.Client) (pool, provider string, err error) {
+	var project, location, cluster string
+	for _, v := range []struct {
+		dst    *string
+		suffix string
+	}{
+		{&project, "project/project-id"},
+		{&location, "instance/attributes/cluster-location"},
+		{&cluster, "instance/attributes/cluster-name"},
+	} {
+		value, err := c.Get(v.suffix)
+		if err != nil {
+			return "", "", fmt.Errorf("oauth2/google/kubernetes: unable to read %s from the GKE metadata server: %v", v.suffix, err)
+		}
+		if *v.dst = strings.TrimSpace(value); *v.dst == "" {
+			return "", "", fmt.Errorf("oauth2/google/kubernetes: empty %s in the GKE metadata server", v.suffix)
+		}
+	}
+	pool = project + ".svc.id.goog"
+	provider = fmt.Sprintf("https://container.googleapis.com/v1/projects/%s/locations/%s/clusters/%s", project, location, cluster)
+	return pool, provider, nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kubernetes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewGKEConfig(t *testing.T) {
+	values := map[string]string{
+		"/computeMetadata/v1/project/project-id":                   "my-project",
+		"/computeMetadata/v1/instance/attributes/cluster-location": "us-central1",
+		"/computeMetadata/v1/instance/attributes/cluster-name":     "my-cluster",
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("Metadata-Flavor"), "Google"; got != want {
+			t.Errorf("got Metadata-Flavor %q but want %q", got, want)
+		}
+		v, ok := values[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(v))
+	}))
+	defer ts.Close()
+	t.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(ts.URL, "http://"))
+	t.Setenv("GOOGLE_WORKLOAD_IDENTITY_POOL", "")
+	t.Setenv("GOOGLE_WORKLOAD_IDENTITY_PROVIDER", "")
+	t.Setenv("GOOGLE_WORKLOAD_IDENTITY_TOKEN_FILE", "/token")
+
+	c, err := NewGKEConfig()
+	if err != nil {
+		t.Fatalf("NewGKEConfig() failed: %v", err)
+	}
+	want := "identitynamespace:my-project.svc.id.goog:https://container.googleapis.com/v1/projects/my-project/locations/us-central1/clusters/my-cluster"
+	if c.Audience != want {
+		t.Errorf("got audience %v but want %v", c.Audience, want)
+	}
+	if got, want := c.tokenFile(), "/token"; got != want {
+		t.Errorf("got token file %v but want %v", got, want)
+	}
+
+	t.Setenv("GOOGLE_WORKLOAD_IDENTITY_POOL", "pool.svc.id.goog")
+	t.Setenv("GOOGLE_WORKLOAD_IDENTITY_PROVIDER", "https://container.googleapis.com/v1/projects/p/locations/l/clusters/c")
+	if c, err = NewGKEConfig(); err != nil {
+		t.Fatalf("NewGKEConfig() failed: %v", err)
+	}
+	if want := "identitynamespace:pool.svc.id.goog:https://container.googleapis.com/v1/projects/p/locations/l/clusters/c"; c.Audience != want {
+		t.Errorf("got audience %v from the environment but want %v", c.Audience, want)
+	}
+
+	t.Setenv("GOOGLE_WORKLOAD_IDENTITY_POOL", "")
+	delete(values, "/computeMetadata/v1/instance/attributes/cluster-name")
+	if _, err := NewGKEConfig(); err == nil {
+		t.Error("NewGKEConfig() succeeded outside of GKE, want error")
+	}
+}
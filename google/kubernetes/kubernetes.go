@@ -0,0 +1,176 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"golang.org/x/oauth2/google/internal/externalaccount"
+	"golang.org/x/oauth2/jws"
+)
+
+const (
+	// JWTTokenType is the subject token type of the tokens returned by
+	// SubjectToken.
+	JWTTokenType = externalaccount.JWTTokenType
+
+	// DefaultTokenFile is the path of the token projected by the
+	// serviceAccountToken volume source of the workload identity federation
+	// documentation.
+	DefaultTokenFile = externalaccount.DefaultGKETokenFile
+
+	// rereadBefore is how long before its expiry a cached token is read
+	// again from the file. The kubelet rotates tokens once 80% of their
+	// lifetime has elapsed, so a fresh token is normally already in place.
+	rereadBefore = 5 * time.Minute
+
+	// rotationRetryDelay is how long to wait before reading the token file
+	// again when it is momentarily empty or truncated.
+	rotationRetryDelay = 100 * time.Millisecond
+)
+
+// timeNow is time.Now but pulled out as a variable for tests.
+var timeNow = time.Now
+
+// Config describes the projected service account token of a Kubernetes
+// workload and the workload identity pool provider it is exchanged with.
+// A Config caches the token it reads and must not be copied after first use.
+type Config struct {
+	// Audience is the workload identity pool provider resource name, e.g.
+	// //iam.googleapis.com/projects/PROJECT_NUMBER/locations/global/workloadIdentityPools/POOL_ID/providers/PROVIDER_ID.
+	// Required for TokenSource and ExternalAccountConfig.
+	Audience string
+
+	// TokenFile is the path of the projected service account token. The
+	// default is DefaultTokenFile. Optional.
+	TokenFile string
+
+	// STSURL is the Security Token Service endpoint. The default is
+	// https://sts.googleapis.com/v1/token.
+	STSURL string
+
+	// ServiceAccountImpersonationURL is the URL of the generateAccessToken
+	// method of the service account to impersonate after the exchange.
+	// Optional.
+	ServiceAccountImpersonationURL string
+
+	// Scopes are the scopes of the returned Google Cloud access token. The
+	// default is https://www.googleapis.com/auth/cloud-platform.
+	Scopes []string
+
+	mu      sync.Mutex
+	token   string
+	expiry  time.Time
+	modTime time.Time
+}
+
+func (c *Config) tokenFile() string {
+	if c.TokenFile == "" {
+		return DefaultTokenFile
+	}
+	return c.TokenFile
+}
+
+// SubjectToken returns the projected service account token, a subject token
+// of type JWTTokenType.
+//
+// The token is read again from the file when the kubelet has replaced it or
+// when it is about to expire, and is otherwise served from memory. An
+// expired token is reported as an error, since it means that the kubelet
+// failed to rotate it.
+func (c *Config) SubjectToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	file := c.tokenFile()
+	fi, err := os.Stat(file)
+	if err != nil {
+		return "", fmt.Errorf("oauth2/google/kubernetes: unable to read service account token: %v", err)
+	}
+	if c.token != "" && fi.ModTime().Equal(c.modTime) && timeNow().Add(rereadBefore).Before(c.expiry) {
+		return c.token, nil
+	}
+	token, expiry, err := readToken(file)
+	if err != nil {
+		// The kubelet swaps the token atomically, but other writers may
+		// not; give a rotation in progress a chance to complete.
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(rotationRetryDelay):
+		}
+		if token, expiry, err = readToken(file); err != nil {
+			return "", err
+		}
+	}
+	if !timeNow().Before(expiry) {
+		return "", fmt.Errorf("oauth2/google/kubernetes: service account token %q expired at %v and was not rotated", file, expiry)
+	}
+	c.token, c.expiry, c.modTime = token, expiry, fi.ModTime()
+	return token, nil
+}
+
+// readToken reads the token at file and returns it with its expiry.
+func readToken(file string) (string, time.Time, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("oauth2/google/kubernetes: unable to read service account token: %v", err)
+	}
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", time.Time{}, fmt.Errorf("oauth2/google/kubernetes: service account token file %q is empty", file)
+	}
+	claims, err := jws.Decode(token)
+	if err != nil || claims.Exp == 0 {
+		return "", time.Time{}, fmt.Errorf("oauth2/google/kubernetes: service account token file %q does not contain a JWT with an expiry", file)
+	}
+	return token, time.Unix(claims.Exp, 0), nil
+}
+
+// TokenSource returns a TokenSource of Google Cloud access tokens obtained by
+// exchanging the projected service account token with the Security Token
+// Service.
+func (c *Config) TokenSource(ctx context.Context) (oauth2.TokenSource, error) {
+	return c.platformConfig().TokenSource(ctx)
+}
+
+func (c *Config) platformConfig() *externalaccount.PlatformConfig {
+	return &externalaccount.PlatformConfig{
+		Audience:                       c.Audience,
+		SubjectTokenType:               JWTTokenType,
+		STSURL:                         c.STSURL,
+		ServiceAccountImpersonationURL: c.ServiceAccountImpersonationURL,
+		Scopes:                         c.Scopes,
+		SubjectToken:                   c.SubjectToken,
+		MetricsSource:                  "kubernetes",
+	}
+}
+
+// ExternalAccountConfig returns the credential configuration file reading
+// the projected service account token of c, e.g. to write it to the path
+// named by GOOGLE_APPLICATION_CREDENTIALS. Credentials created from it read
+// the token file on every exchange.
+func (c *Config) ExternalAccountConfig() (*google.ExternalAccountConfig, error) {
+	source, err := json.Marshal(struct {
+		File string `json:"file"`
+	}{c.tokenFile()})
+	if err != nil {
+		return nil, err
+	}
+	return &google.ExternalAccountConfig{
+		Audience:                       c.Audience,
+		SubjectTokenType:               JWTTokenType,
+		TokenURL:                       c.platformConfig().TokenURL(),
+		ServiceAccountImpersonationURL: c.ServiceAccountImpersonationURL,
+		CredentialSource:               source,
+	}, nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kubernetes
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2/google/internal/externalaccount"
+)
+
+func testJWT(sub string, exp time.Time) string {
+	claims := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"sub":%q,"exp":%d}`, sub, exp.Unix())))
+	return "eyJhbGciOiJSUzI1NiJ9." + claims + ".signature"
+}
+
+// writeToken writes token to file with the given modification time.
+func writeToken(t *testing.T, file, token string, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(file, []byte(token+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(file, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSubjectToken_Rotation(t *testing.T) {
+	defer func(old func() time.Time) { timeNow = old }(timeNow)
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+
+	file := filepath.Join(t.TempDir(), "token")
+	first := testJWT("system:serviceaccount:default:app", now.Add(time.Hour))
+	writeToken(t, file, first, now.Add(-time.Minute))
+	c := &Config{TokenFile: file}
+
+	tok, err := c.SubjectToken(context.Background())
+	if err != nil {
+		t.Fatalf("SubjectToken() failed: %v", err)
+	}
+	if tok != first {
+		t.Errorf("got %v but want %v", tok, first)
+	}
+
+	// The kubelet replaces the token: the new one is picked up.
+	second := testJWT("system:serviceaccount:default:app", now.Add(2*time.Hour))
+	writeToken(t, file, second, now)
+	if tok, err = c.SubjectToken(context.Background()); err != nil {
+		t.Fatalf("SubjectToken() failed: %v", err)
+	}
+	if tok != second {
+		t.Errorf("got %v but want the rotated token %v", tok, second)
+	}
+
+	// The kubelet failed to rotate the token before it expired.
+	now = now.Add(3 * time.Hour)
+	if _, err := c.SubjectToken(context.Background()); err == nil {
+		t.Error("SubjectToken() succeeded with an expired token, want error")
+	}
+}
+
+func TestSubjectToken_Invalid(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "token")
+	writeToken(t, file, "not-a-jwt", time.Now())
+	c := &Config{TokenFile: file}
+	if _, err := c.SubjectToken(context.Background()); err == nil {
+		t.Error("SubjectToken() succeeded with a malformed token, want error")
+	}
+
+	c = &Config{TokenFile: filepath.Join(t.TempDir(), "missing")}
+	if _, err := c.SubjectToken(context.Background()); err == nil {
+		t.Error("SubjectToken() succeeded without a token file, want error")
+	}
+}
+
+func TestExternalAccountConfig(t *testing.T) {
+	c := &Config{
+		Audience:  "//iam.googleapis.com/projects/123/locations/global/workloadIdentityPools/pool/providers/k8s",
+		TokenFile: "/var/run/secrets/tokens/wif/token",
+	}
+	ec, err := c.ExternalAccountConfig()
+	if err != nil {
+		t.Fatalf("ExternalAccountConfig() failed: %v", err)
+	}
+	b, err := json.Marshal(ec)
+	if err != nil {
+		t.Fatalf("json.Marshal() failed: %v", err)
+	}
+	var got struct {
+		Type             string `json:"type"`
+		Audience         string `json:"audience"`
+		SubjectTokenType string `json:"subject_token_type"`
+		TokenURL         string `json:"token_url"`
+		CredentialSource struct {
+			File string `json:"file"`
+		} `json:"credential_source"`
+	}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Type != "external_account" || got.Audience != c.Audience || got.SubjectTokenType != JWTTokenType ||
+		got.TokenURL != externalaccount.DefaultSTSURL || got.CredentialSource.File != c.TokenFile {
+		t.Errorf("got configuration %s", b)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package google
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// Credential types reported to CredentialsMetrics for credentials that are
+// not loaded from a credentials file, whose type is the file's type, e.g.
+// "service_account" or "external_account".
+const (
+	computeMetadataType = "compute_metadata"
+	appEngineType       = "app_engine"
+	cloudShellType      = "cloud_shell"
+	// clientSecretType is the type of Google Developers Console client
+	// credentials files, which have no type field.
+	clientSecretType = "client_secret"
+)
+
+// CredentialsMetrics receives metrics about the tokens of credentials, so
+// that operators can alert on tokens that are about to expire without being
+// refreshed before it causes an outage. Implementations typically record
+// them with a metrics library, labeled with the credential type, such as
+// "service_account", "authorized_user", "external_account" or
+// "compute_metadata". Their methods are called synchronously from the token
+// source and must be safe for concurrent use.
+type CredentialsMetrics interface {
+	// TokenExpiry is called with the time left until the token expires
+	// every time credentials return a token with an expiry, e.g. to set a
+	// seconds-until-expiry gauge.
+	TokenExpiry(credType string, untilExpiry time.Duration)
+
+	// TokenRefresh is called every time credentials obtain a new token,
+	// with a nil err, or fail to, e.g. to count refresh successes and
+	// failures. Calls failing with an *oauth2.RefreshBackoffError, which
+	// do not attempt a refresh, are not reported.
+	TokenRefresh(credType string, err error)
+}
+
+// withMetrics makes the token source of creds report to m as credentials of
+// type credType. It returns creds unchanged if m is nil.
+func withMetrics(creds *Credentials, credType string, m CredentialsMetrics) *Credentials {
+	if m != nil {
+		creds.TokenSource = &metricsTokenSource{src: creds.TokenSource, credType: credType, metrics: m}
+	}
+	return creds
+}
+
+// metricsTokenSource reports the tokens of src to metrics. A token is
+// counted as refreshed when it differs from the previous one, since src
+// returns the same token while it is cached.
+type metricsTokenSource struct {
+	src      oauth2.TokenSource
+	credType string
+	metrics  CredentialsMetrics
+
+	mu   sync.Mutex // guards last
+	last *oauth2.Token
+}
+
+// DescribeTokenSource leaves s out of descriptions, see
+// oauth2.DescribeTokenSource.
+func (s *metricsTokenSource) DescribeTokenSource() (string, oauth2.TokenSource) {
+	return "", s.src
+}
+
+func (s *metricsTokenSource) Token() (*oauth2.Token, error) {
+	tok, err := s.src.Token()
+	if err != nil {
+		var backoff *oauth2.RefreshBackoffError
+		if !errors.As(err, &backoff) {
+			s.metrics.TokenRefresh(s.credType, err)
+		}
+		return nil, err
+	}
+	s.mu.Lock()
+	refreshed := tok != s.last
+	s.last = tok
+	s.mu.Unlock()
+	if refreshed {
+		s.metrics.TokenRefresh(s.credType, nil)
+	}
+	if !tok.Expiry.IsZero() {
+		s.metrics.TokenExpiry(s.credType, time.Until(tok.Expiry))
+	}
+	return tok, nil
+}
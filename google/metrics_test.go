@@ -0,0 +1,116 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package google
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// recordingMetrics records the metrics it receives.
+type recordingMetrics struct {
+	mu        sync.Mutex
+	expiries  []time.Duration
+	successes map[string]int
+	failures  map[string]int
+}
+
+func (m *recordingMetrics) TokenExpiry(credType string, untilExpiry time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expiries = append(m.expiries, untilExpiry)
+}
+
+func (m *recordingMetrics) TokenRefresh(credType string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		m.failures[credType]++
+	} else {
+		m.successes[credType]++
+	}
+}
+
+func TestCredentialsMetrics(t *testing.T) {
+	fail := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if fail {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "invalid_grant"}`))
+			return
+		}
+		w.Write([]byte(`{"access_token": "token", "token_type": "Bearer", "expires_in": 3600}`))
+	}))
+	defer server.Close()
+
+	m := &recordingMetrics{successes: map[string]int{}, failures: map[string]int{}}
+	json := fmt.Sprintf(`{"type": "authorized_user", "client_id": "id", "client_secret": "secret", "refresh_token": "refresh", "token_uri": %q}`, server.URL)
+	creds, err := CredentialsFromJSONWithParams(context.Background(), []byte(json), CredentialsParams{Metrics: m})
+	if err != nil {
+		t.Fatalf("CredentialsFromJSONWithParams() failed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := creds.TokenSource.Token(); err != nil {
+			t.Fatalf("Token() failed: %v", err)
+		}
+	}
+	if got, want := m.successes["authorized_user"], 1; got != want {
+		t.Errorf("got %d refreshes but want %d", got, want)
+	}
+	if len(m.expiries) != 2 {
+		t.Fatalf("got %d expiry observations but want 2", len(m.expiries))
+	}
+	if d := m.expiries[1]; d <= 59*time.Minute || d > time.Hour {
+		t.Errorf("got time until expiry %v but want about an hour", d)
+	}
+
+	fail = true
+	creds, err = CredentialsFromJSONWithParams(context.Background(), []byte(json), CredentialsParams{Metrics: m})
+	if err != nil {
+		t.Fatalf("CredentialsFromJSONWithParams() failed: %v", err)
+	}
+	if _, err := creds.TokenSource.Token(); err == nil {
+		t.Fatal("Token() succeeded, want error")
+	}
+	if got, want := m.failures["authorized_user"], 1; got != want {
+		t.Errorf("got %d refresh failures but want %d", got, want)
+	}
+}
+
+func TestCredentialsMetrics_RefreshBackoff(t *testing.T) {
+	m := &recordingMetrics{successes: map[string]int{}, failures: map[string]int{}}
+	calls := 0
+	src := oauth2.ReuseTokenSourceWithFailureBackoff(nil, tokenSourceFunc(func() (*oauth2.Token, error) {
+		calls++
+		return nil, errors.New("unavailable")
+	}), time.Hour)
+	creds := withMetrics(&Credentials{TokenSource: src}, "test", m)
+	for i := 0; i < 3; i++ {
+		if _, err := creds.TokenSource.Token(); err == nil {
+			t.Fatal("Token() succeeded, want error")
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("got %d calls of the source but want 1", calls)
+	}
+	if got, want := m.failures["test"], 1; got != want {
+		t.Errorf("got %d refresh failures but want %d, calls in backoff should not count", got, want)
+	}
+}
+
+type tokenSourceFunc func() (*oauth2.Token, error)
+
+func (f tokenSourceFunc) Token() (*oauth2.Token, error) {
+	return f()
+}
@@ -0,0 +1,58 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package google
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"golang.org/x/oauth2/google/internal/externalaccount"
+)
+
+// RevokeExternalAccount signs the user out of the identity provider of the
+// external_account credentials in jsonData. It is supported for executable
+// credential sources, whose executable is run with
+// GOOGLE_EXTERNAL_ACCOUNT_REVOKE=1 and GOOGLE_EXTERNAL_ACCOUNT_INTERACTIVE=1
+// and must report success in the executable response format.
+//
+// As with token retrieval, GOOGLE_EXTERNAL_ACCOUNT_ALLOW_EXECUTABLES must be
+// set to 1 for the executable to run.
+func RevokeExternalAccount(ctx context.Context, jsonData []byte, params CredentialsParams) error {
+	config, err := externalAccountFromJSON(jsonData, params, "revoke")
+	if err != nil {
+		return err
+	}
+	return config.Revoke(ctx)
+}
+
+// LoginExternalAccount signs the user in to the identity provider of the
+// external_account credentials in jsonData, e.g. from a workforce identity
+// federation sign-in tool. It is supported for executable credential sources,
+// whose executable is run with GOOGLE_EXTERNAL_ACCOUNT_INTERACTIVE=1 and
+// connected to the terminal so that it can prompt the user. The executable
+// must write its response to its output_file, where later token exchanges
+// find it.
+//
+// As with token retrieval, GOOGLE_EXTERNAL_ACCOUNT_ALLOW_EXECUTABLES must be
+// set to 1 for the executable to run.
+func LoginExternalAccount(ctx context.Context, jsonData []byte, params CredentialsParams) error {
+	config, err := externalAccountFromJSON(jsonData, params, "login")
+	if err != nil {
+		return err
+	}
+	return config.Login(ctx)
+}
+
+func externalAccountFromJSON(jsonData []byte, params CredentialsParams, op string) (*externalaccount.Config, error) {
+	var f credentialsFile
+	if err := json.Unmarshal(jsonData, &f); err != nil {
+		return nil, err
+	}
+	if f.Type != externalAccountKey {
+		return nil, fmt.Errorf("oauth2/google: %s requires %q credentials, got %q", op, externalAccountKey, f.Type)
+	}
+	return f.externalAccountConfig(params.deepCopy())
+}
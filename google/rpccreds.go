@@ -0,0 +1,142 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package google
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+// PerRPCCredentials authorizes gRPC calls with ID tokens whose audience is
+// chosen per RPC, for services that require audience-bound tokens, such as
+// gRPC services on Cloud Run. It implements the PerRPCCredentials interface
+// of google.golang.org/grpc/credentials without depending on gRPC:
+//
+//	creds := google.NewImpersonatedPerRPCCredentials(base, google.ImpersonateConfig{
+//		TargetPrincipal: "invoker@project.iam.gserviceaccount.com",
+//	})
+//	conn, err := grpc.Dial(addr, grpc.WithPerRPCCredentials(creds), ...)
+//
+// Token sources are created once per audience and cached.
+type PerRPCCredentials struct {
+	// NewTokenSource returns a TokenSource of ID tokens for audience.
+	// Required.
+	NewTokenSource func(ctx context.Context, audience string) (oauth2.TokenSource, error)
+
+	// Audience returns the audience of calls to the service identified by
+	// uri, e.g. "https://example.a.run.app/helloworld.Greeter". The
+	// default is the scheme and host of uri, "https://example.a.run.app".
+	Audience func(uri string) (string, error)
+
+	// AllowedAudiences, if non-empty, lists the only audiences tokens are
+	// requested for. Calls to services with other audiences fail.
+	AllowedAudiences []string
+
+	// AllowInsecure allows sending tokens over connections without
+	// transport security. It should only be set for tests and local
+	// development.
+	AllowInsecure bool
+
+	mu      sync.Mutex
+	sources map[string]oauth2.TokenSource
+}
+
+// NewImpersonatedPerRPCCredentials returns PerRPCCredentials requesting ID
+// tokens of the service account described by config, authenticating with
+// the tokens of base. base is typically the TokenSource of external account
+// credentials. The IDTokenAudience of config is set per RPC.
+func NewImpersonatedPerRPCCredentials(base oauth2.TokenSource, config ImpersonateConfig) *PerRPCCredentials {
+	return &PerRPCCredentials{
+		NewTokenSource: func(ctx context.Context, audience string) (oauth2.TokenSource, error) {
+			c := config
+			c.IDTokenAudience = audience
+			// The token source outlives the RPC that created it, so it
+			// must not use the context of the RPC.
+			return ImpersonateTokenSource(context.Background(), base, c)
+		},
+	}
+}
+
+// GetRequestMetadata returns the authorization metadata of a call to the
+// service identified by uri.
+func (c *PerRPCCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	if len(uri) == 0 {
+		return nil, errors.New("oauth2/google: missing service URI of the RPC")
+	}
+	aud, err := c.audience(uri[0])
+	if err != nil {
+		return nil, err
+	}
+	ts, err := c.tokenSource(ctx, aud)
+	if err != nil {
+		return nil, err
+	}
+	tok, err := ts.Token()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"authorization": tok.Type() + " " + tok.AccessToken}, nil
+}
+
+// RequireTransportSecurity reports whether the credentials require transport
+// security.
+func (c *PerRPCCredentials) RequireTransportSecurity() bool {
+	return !c.AllowInsecure
+}
+
+func (c *PerRPCCredentials) audience(uri string) (string, error) {
+	var aud string
+	if c.Audience != nil {
+		var err error
+		if aud, err = c.Audience(uri); err != nil {
+			return "", err
+		}
+	} else {
+		u, err := url.Parse(uri)
+		if err != nil {
+			return "", fmt.Errorf("oauth2/google: invalid service URI %q: %v", uri, err)
+		}
+		if u.Scheme != "" && u.Host != "" {
+			aud = u.Scheme + "://" + u.Host
+		}
+	}
+	if aud == "" {
+		return "", fmt.Errorf("oauth2/google: no audience for service URI %q", uri)
+	}
+	if len(c.AllowedAudiences) == 0 {
+		return aud, nil
+	}
+	for _, a := range c.AllowedAudiences {
+		if a == aud {
+			return aud, nil
+		}
+	}
+	return "", fmt.Errorf("oauth2/google: audience %q is not allowed", aud)
+}
+
+func (c *PerRPCCredentials) tokenSource(ctx context.Context, aud string) (oauth2.TokenSource, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ts, ok := c.sources[aud]; ok {
+		return ts, nil
+	}
+	if c.NewTokenSource == nil {
+		return nil, errors.New("oauth2/google: PerRPCCredentials.NewTokenSource is required")
+	}
+	ts, err := c.NewTokenSource(ctx, aud)
+	if err != nil {
+		return nil, err
+	}
+	if c.sources == nil {
+		c.sources = make(map[string]oauth2.TokenSource)
+	}
+	c.sources[aud] = ts
+	return ts, nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package google
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestPerRPCCredentials(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		var body struct {
+			Audience string `json:"audience"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("unable to decode request: %v", err)
+		}
+		claims := fmt.Sprintf(`{"aud":%q,"exp":%d}`, body.Audience, time.Now().Add(time.Hour).Unix())
+		fmt.Fprintf(w, `{"token":%q}`, base64.RawURLEncoding.EncodeToString([]byte(body.Audience))+"."+base64.RawURLEncoding.EncodeToString([]byte(claims))+".sig")
+	}))
+	defer server.Close()
+
+	base := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "base"})
+	creds := NewImpersonatedPerRPCCredentials(base, ImpersonateConfig{
+		TargetPrincipal: "sa@project.iam.gserviceaccount.com",
+		Endpoint:        server.URL,
+	})
+	creds.AllowedAudiences = []string{"https://a.example.com", "https://b.example.com"}
+
+	for uri, aud := range map[string]string{
+		"https://a.example.com/pkg.ServiceA": "https://a.example.com",
+		"https://a.example.com/pkg.ServiceB": "https://a.example.com",
+		"https://b.example.com/pkg.Service":  "https://b.example.com",
+	} {
+		md, err := creds.GetRequestMetadata(context.Background(), uri)
+		if err != nil {
+			t.Fatalf("GetRequestMetadata(%q) failed: %v", uri, err)
+		}
+		if got, want := md["authorization"], "Bearer "+base64.RawURLEncoding.EncodeToString([]byte(aud))+"."; !strings.HasPrefix(got, want) {
+			t.Errorf("got %v but want a token for %v", got, aud)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("got %d token requests but want one per audience", calls)
+	}
+	if _, err := creds.GetRequestMetadata(context.Background(), "https://c.example.com/pkg.Service"); err == nil {
+		t.Error("GetRequestMetadata() for a disallowed audience succeeded, want error")
+	}
+	if !creds.RequireTransportSecurity() {
+		t.Error("RequireTransportSecurity() = false, want true")
+	}
+}
+
+func TestPerRPCCredentials_Audience(t *testing.T) {
+	creds := &PerRPCCredentials{
+		Audience: func(uri string) (string, error) { return uri, nil },
+		NewTokenSource: func(ctx context.Context, audience string) (oauth2.TokenSource, error) {
+			return oauth2.StaticTokenSource(&oauth2.Token{AccessToken: audience}), nil
+		},
+	}
+	md, err := creds.GetRequestMetadata(context.Background(), "https://example.com/pkg.Service")
+	if err != nil {
+		t.Fatalf("GetRequestMetadata() failed: %v", err)
+	}
+	if got, want := md["authorization"], "Bearer https://example.com/pkg.Service"; got != want {
+		t.Errorf("got %v but want %v", got, want)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package google
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/oauth2/google/internal/externalaccount"
+)
+
+// Common OAuth 2.0 scopes of Google APIs. See
+// https://developers.google.com/identity/protocols/oauth2/scopes for the
+// scopes of every API.
+const (
+	ScopeCloudPlatform         = externalaccount.CloudPlatformScope
+	ScopeCloudPlatformReadOnly = "https://www.googleapis.com/auth/cloud-platform.read-only"
+	ScopeUserInfoEmail         = "https://www.googleapis.com/auth/userinfo.email"
+	ScopeUserInfoProfile       = "https://www.googleapis.com/auth/userinfo.profile"
+	ScopeOpenID                = "openid"
+	ScopeIAM                   = "https://www.googleapis.com/auth/iam"
+	ScopeCompute               = "https://www.googleapis.com/auth/compute"
+	ScopeComputeReadOnly       = "https://www.googleapis.com/auth/compute.readonly"
+	ScopeDevstorageReadOnly    = "https://www.googleapis.com/auth/devstorage.read_only"
+	ScopeDevstorageReadWrite   = "https://www.googleapis.com/auth/devstorage.read_write"
+	ScopeDevstorageFullControl = "https://www.googleapis.com/auth/devstorage.full_control"
+	ScopeBigQuery              = "https://www.googleapis.com/auth/bigquery"
+)
+
+// scopePrefix is the prefix of the scopes of most Google APIs.
+const scopePrefix = "https://www.googleapis.com/auth/"
+
+// knownScopes are the scope constants of this package, by name, used to
+// suggest corrections.
+var knownScopes = map[string]string{}
+
+func init() {
+	for _, s := range []string{
+		ScopeCloudPlatform, ScopeCloudPlatformReadOnly, ScopeUserInfoEmail,
+		ScopeUserInfoProfile, ScopeIAM, ScopeCompute, ScopeComputeReadOnly,
+		ScopeDevstorageReadOnly, ScopeDevstorageReadWrite,
+		ScopeDevstorageFullControl, ScopeBigQuery,
+	} {
+		knownScopes[strings.TrimPrefix(s, scopePrefix)] = s
+	}
+}
+
+// ValidateScopes reports the first of scopes that is malformed, such as a
+// Google API scope missing its /auth/ path element, a bare scope name, or
+// several scopes joined in one string. The error suggests a correction when
+// one is apparent. Well-formed scopes that this package does not know are
+// accepted.
+func ValidateScopes(scopes ...string) error {
+	for _, s := range scopes {
+		if err := validateScope(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateScope(s string) error {
+	switch {
+	case s == "":
+		return fmt.Errorf("google: empty scope")
+	case strings.ContainsAny(s, " \t\n,"):
+		return fmt.Errorf("google: invalid scope %q, scopes must be passed as separate strings", s)
+	case s == "openid" || s == "email" || s == "profile":
+		return nil
+	case !strings.Contains(s, "://"):
+		if full, ok := knownScopes[s]; ok {
+			return fmt.Errorf("google: invalid scope %q, did you mean %q?", s, full)
+		}
+		return fmt.Errorf("google: invalid scope %q, expected a URL such as %q", s, ScopeCloudPlatform)
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("google: invalid scope %q", s)
+	}
+	host := strings.ToLower(u.Host)
+	if host != "www.googleapis.com" && host != "googleapis.com" {
+		if u.Scheme != "https" {
+			return fmt.Errorf("google: invalid scope %q, scopes use the https scheme", s)
+		}
+		return nil
+	}
+	name := strings.Trim(u.Path, "/")
+	name = strings.TrimPrefix(name, "auth/")
+	if suggestion := scopePrefix + name; s != suggestion {
+		if name == "" || strings.Contains(name, "/") {
+			return fmt.Errorf("google: invalid scope %q", s)
+		}
+		return fmt.Errorf("google: invalid scope %q, did you mean %q?", s, suggestion)
+	}
+	return nil
+}
+
+// withScope returns scopes with scope appended, unless already present.
+func withScope(scopes []string, scope string) []string {
+	for _, s := range scopes {
+		if s == scope {
+			return scopes
+		}
+	}
+	return append(append([]string(nil), scopes...), scope)
+}
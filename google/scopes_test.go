@@ -0,0 +1,105 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package google
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateScopes(t *testing.T) {
+	tests := []struct {
+		scope   string
+		wantErr string
+	}{
+		{scope: ScopeCloudPlatform},
+		{scope: ScopeOpenID},
+		{scope: "https://www.googleapis.com/auth/spanner.data"},
+		{scope: "https://mail.google.com/"},
+		{scope: "", wantErr: "empty scope"},
+		{scope: "https://www.googleapis.com/cloud-platform", wantErr: `did you mean "https://www.googleapis.com/auth/cloud-platform"`},
+		{scope: "http://www.googleapis.com/auth/cloud-platform", wantErr: `did you mean "https://www.googleapis.com/auth/cloud-platform"`},
+		{scope: "https://www.googleapis.com/auth/cloud-platform/", wantErr: `did you mean "https://www.googleapis.com/auth/cloud-platform"`},
+		{scope: "cloud-platform", wantErr: `did you mean "https://www.googleapis.com/auth/cloud-platform"`},
+		{scope: ScopeCloudPlatform + " " + ScopeUserInfoEmail, wantErr: "separate strings"},
+		{scope: "http://mail.google.com/", wantErr: "https scheme"},
+	}
+	for _, tt := range tests {
+		err := ValidateScopes(ScopeUserInfoEmail, tt.scope)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("ValidateScopes(%q) failed: %v", tt.scope, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("ValidateScopes(%q) = %v, want error containing %q", tt.scope, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCredentialsFromJSON_ImpersonationScope(t *testing.T) {
+	var sourceScope string
+	sts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sourceScope = r.FormValue("scope")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"source-token","issued_token_type":"urn:ietf:params:oauth:token-type:access_token","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer sts.Close()
+	iam := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("X-Goog-User-Project"), "quota-project"; got != want {
+			t.Errorf("got quota project %q but want %q", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"accessToken":"impersonated-token","expireTime":"2099-01-01T00:00:00Z"}`))
+	}))
+	defer iam.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("subject-token"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	b := []byte(fmt.Sprintf(`{
+		"type": "impersonated_service_account",
+		"service_account_impersonation_url": %q,
+		"quota_project_id": "quota-project",
+		"source_credentials": {
+			"type": "external_account",
+			"audience": "32555940559.apps.googleusercontent.com",
+			"subject_token_type": "urn:ietf:params:oauth:token-type:jwt",
+			"token_url": %q,
+			"credential_source": {"file": %q}
+		}
+	}`, iam.URL, sts.URL, tokenFile))
+
+	for _, tt := range []struct {
+		impersonationScope bool
+		want               string
+	}{
+		{false, ScopeDevstorageReadOnly},
+		{true, ScopeDevstorageReadOnly + " " + ScopeCloudPlatform},
+	} {
+		creds, err := CredentialsFromJSONWithParams(context.Background(), b, CredentialsParams{
+			Scopes:             []string{ScopeDevstorageReadOnly},
+			ImpersonationScope: tt.impersonationScope,
+		})
+		if err != nil {
+			t.Fatalf("CredentialsFromJSONWithParams() failed: %v", err)
+		}
+		tok, err := creds.TokenSource.Token()
+		if err != nil {
+			t.Fatalf("Token() failed: %v", err)
+		}
+		if got, want := tok.AccessToken, "impersonated-token"; got != want {
+			t.Errorf("got %v but want %v", got, want)
+		}
+		if sourceScope != tt.want {
+			t.Errorf("ImpersonationScope %v: got source scope %q but want %q", tt.impersonationScope, sourceScope, tt.want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package google
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/internal"
+)
+
+// secretDataLink is the symlink that the kubelet atomically swaps when the
+// contents of a mounted Secret or ConfigMap volume change.
+const secretDataLink = "..data"
+
+// CredentialsFromSecretDir obtains Google credentials from the file named key
+// inside dir, which is typically a Kubernetes Secret or ConfigMap volume mount.
+// The file usually holds an external account (workload identity federation)
+// configuration.
+//
+// The returned TokenSource checks the volume for changes before handing out
+// a token and rebuilds itself from the new configuration when one is found.
+// Change detection follows the ..data symlink that the kubelet swaps on every
+// update; for directories that are not managed by the kubelet, the file's
+// modification time and size are used instead. This allows the credential
+// configuration and the subject token it references to rotate independently.
+func CredentialsFromSecretDir(ctx context.Context, dir, key string, params CredentialsParams) (*Credentials, error) {
+	// Make defensive copy of the slices in params.
+	params = params.deepCopy()
+
+	ts := &secretDirTokenSource{
+		ctx:    ctx,
+		dir:    dir,
+		key:    key,
+		params: params,
+	}
+	f, b, err := ts.refresh()
+	if err != nil {
+		return nil, err
+	}
+	return &Credentials{
+		ProjectID:     f.ProjectID,
+		TokenSource:   ts,
+		JSON:          b,
+		RequestHeader: params.RequestHeader,
+	}, nil
+}
+
+// secretDirTokenSource is a TokenSource that rebuilds its underlying
+// TokenSource whenever the watched credentials file changes.
+type secretDirTokenSource struct {
+	ctx    context.Context
+	dir    string
+	key    string
+	params CredentialsParams
+
+	mu      sync.Mutex // guards version and ts
+	version string
+	ts      oauth2.TokenSource
+}
+
+// Token returns a token from the TokenSource built from the current contents
+// of the watched credentials file.
+func (s *secretDirTokenSource) Token() (*oauth2.Token, error) {
+	if _, _, err := s.refresh(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	ts := s.ts
+	s.mu.Unlock()
+	return ts.Token()
+}
+
+// DescribeTokenSource describes s and the source built from the current
+// credentials file, see oauth2.DescribeTokenSource.
+func (s *secretDirTokenSource) DescribeTokenSource() (string, oauth2.TokenSource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return internal.DescribeParams("secret_dir", "file", filepath.Join(s.dir, s.key)), s.ts
+}
+
+// refresh rebuilds s.ts if the credentials file changed since the last call.
+// It returns the parsed file and its raw contents when a rebuild happened.
+func (s *secretDirTokenSource) refresh() (*credentialsFile, []byte, error) {
+	version, err := s.currentVersion()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ts != nil && version == s.version {
+		return nil, nil, nil
+	}
+
+	b, err := os.ReadFile(filepath.Join(s.dir, s.key))
+	if err != nil {
+		return nil, nil, fmt.Errorf("google: unable to read credentials from secret directory: %v", err)
+	}
+	var f credentialsFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, nil, err
+	}
+	ts, err := f.tokenSource(s.ctx, s.params)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.ts = newErrWrappingTokenSource(ts)
+	s.version = version
+	return &f, b, nil
+}
+
+// currentVersion returns a string that changes whenever the contents of the
+// watched credentials file change.
+func (s *secretDirTokenSource) currentVersion() (string, error) {
+	if target, err := os.Readlink(filepath.Join(s.dir, secretDataLink)); err == nil {
+		return target, nil
+	}
+	fi, err := os.Stat(filepath.Join(s.dir, s.key))
+	if err != nil {
+		return "", fmt.Errorf("google: unable to read credentials from secret directory: %v", err)
+	}
+	return fmt.Sprintf("%d-%d", fi.ModTime().UnixNano(), fi.Size()), nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package google
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newEchoRefreshTokenServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("ParseForm() failed: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":%q,"token_type":"Bearer","expires_in":3600}`, r.PostForm.Get("refresh_token"))
+	}))
+}
+
+// writeSecretVersion mimics the layout the kubelet uses for Secret volumes:
+// dir/..data points at a timestamped directory and dir/key points at
+// ..data/key.
+func writeSecretVersion(t *testing.T, dir, version, key, contents string) {
+	t.Helper()
+	versionDir := filepath.Join(dir, version)
+	if err := os.Mkdir(versionDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(versionDir, key), []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tmpLink := filepath.Join(dir, "..data_tmp")
+	if err := os.Symlink(version, tmpLink); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmpLink, filepath.Join(dir, secretDataLink)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, key)); os.IsNotExist(err) {
+		if err := os.Symlink(filepath.Join(secretDataLink, key), filepath.Join(dir, key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func authorizedUserJSON(tokenURL, refreshToken string) string {
+	return fmt.Sprintf(`{"type":"authorized_user","client_id":"id","client_secret":"secret","token_uri":%q,"refresh_token":%q}`, tokenURL, refreshToken)
+}
+
+func TestCredentialsFromSecretDir(t *testing.T) {
+	server := newEchoRefreshTokenServer(t)
+	defer server.Close()
+
+	dir := t.TempDir()
+	writeSecretVersion(t, dir, "..2023_01", "credentials.json", authorizedUserJSON(server.URL, "first"))
+
+	creds, err := CredentialsFromSecretDir(context.Background(), dir, "credentials.json", CredentialsParams{})
+	if err != nil {
+		t.Fatalf("CredentialsFromSecretDir() failed: %v", err)
+	}
+	tok, err := creds.TokenSource.Token()
+	if err != nil {
+		t.Fatalf("Token() failed: %v", err)
+	}
+	if got, want := tok.AccessToken, "first"; got != want {
+		t.Errorf("AccessToken = %q, want %q", got, want)
+	}
+
+	writeSecretVersion(t, dir, "..2023_02", "credentials.json", authorizedUserJSON(server.URL, "second"))
+
+	tok, err = creds.TokenSource.Token()
+	if err != nil {
+		t.Fatalf("Token() failed: %v", err)
+	}
+	if got, want := tok.AccessToken, "second"; got != want {
+		t.Errorf("AccessToken after rotation = %q, want %q", got, want)
+	}
+}
+
+func TestCredentialsFromSecretDir_PlainDirectory(t *testing.T) {
+	server := newEchoRefreshTokenServer(t)
+	defer server.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "credentials.json")
+	if err := os.WriteFile(path, []byte(authorizedUserJSON(server.URL, "plain")), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	creds, err := CredentialsFromSecretDir(context.Background(), dir, "credentials.json", CredentialsParams{})
+	if err != nil {
+		t.Fatalf("CredentialsFromSecretDir() failed: %v", err)
+	}
+	tok, err := creds.TokenSource.Token()
+	if err != nil {
+		t.Fatalf("Token() failed: %v", err)
+	}
+	if got, want := tok.AccessToken, "plain"; got != want {
+		t.Errorf("AccessToken = %q, want %q", got, want)
+	}
+}
+
+func TestCredentialsFromSecretDir_Missing(t *testing.T) {
+	if _, err := CredentialsFromSecretDir(context.Background(), t.TempDir(), "credentials.json", CredentialsParams{}); err == nil {
+		t.Error("CredentialsFromSecretDir() succeeded for a missing file, want error")
+	}
+}
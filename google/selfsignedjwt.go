@@ -0,0 +1,106 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package google
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/internal"
+	"golang.org/x/oauth2/jws"
+)
+
+// SelfSignedJWTConfig describes JWTs signed by a service account for an
+// arbitrary audience, such as third-party APIs that accept Google service
+// account signatures. Unlike JWTAccessTokenSourceFromJSON, the audience is
+// not restricted to Google APIs and any claims can be added.
+type SelfSignedJWTConfig struct {
+	// Email is the service account email address, used as the issuer and
+	// subject. Required.
+	Email string
+	// KeyID is the ID of the signing key, sent in the kid header. Optional.
+	KeyID string
+	// Signer signs the JWTs with RS256. It can be backed by a private key,
+	// see SelfSignedJWTConfigFromJSON, or by an external signer such as the
+	// IAM Credentials signBlob method or a hardware module. Required.
+	Signer jws.Signer
+	// Audience is the aud claim. Required.
+	Audience string
+	// Lifetime is how long the JWTs are valid for. The default is one hour.
+	// Optional.
+	Lifetime time.Duration
+	// Claims are additional claims of the JWTs. Optional.
+	Claims map[string]interface{}
+}
+
+// SelfSignedJWTConfigFromJSON returns a SelfSignedJWTConfig for the
+// service account key in jsonKey, signing with its private key.
+func SelfSignedJWTConfigFromJSON(jsonKey []byte, audience string) (*SelfSignedJWTConfig, error) {
+	cfg, err := JWTConfigFromJSON(jsonKey)
+	if err != nil {
+		return nil, fmt.Errorf("google: could not parse JSON key: %v", err)
+	}
+	pk, err := internal.ParseKey(cfg.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("google: could not parse key: %v", err)
+	}
+	return &SelfSignedJWTConfig{
+		Email:    cfg.Email,
+		KeyID:    cfg.PrivateKeyID,
+		Signer:   rsaSigner(pk),
+		Audience: audience,
+	}, nil
+}
+
+// rsaSigner returns a jws.Signer producing RS256 signatures with key.
+func rsaSigner(key *rsa.PrivateKey) jws.Signer {
+	return func(data []byte) ([]byte, error) {
+		h := sha256.Sum256(data)
+		return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, h[:])
+	}
+}
+
+// Mint returns a new signed JWT.
+func (c *SelfSignedJWTConfig) Mint() (*oauth2.Token, error) {
+	if c.Email == "" || c.Signer == nil || c.Audience == "" {
+		return nil, errors.New("google: self-signed JWTs require an email, a signer and an audience")
+	}
+	lifetime := c.Lifetime
+	if lifetime == 0 {
+		lifetime = time.Hour
+	}
+	iat := time.Now()
+	exp := iat.Add(lifetime)
+	cs := &jws.ClaimSet{
+		Iss:           c.Email,
+		Sub:           c.Email,
+		Aud:           c.Audience,
+		Iat:           iat.Unix(),
+		Exp:           exp.Unix(),
+		PrivateClaims: c.Claims,
+	}
+	hdr := &jws.Header{
+		Algorithm: "RS256",
+		Typ:       "JWT",
+		KeyID:     c.KeyID,
+	}
+	msg, err := jws.EncodeWithSigner(hdr, cs, c.Signer)
+	if err != nil {
+		return nil, fmt.Errorf("google: could not encode JWT: %v", err)
+	}
+	return &oauth2.Token{AccessToken: msg, TokenType: "Bearer", Expiry: exp}, nil
+}
+
+// TokenSource returns a TokenSource that mints a new JWT when the previous
+// one expires.
+func (c *SelfSignedJWTConfig) TokenSource() oauth2.TokenSource {
+	return oauth2.ReuseTokenSource(nil, oauth2.TokenSourceFunc(c.Mint))
+}
@@ -0,0 +1,56 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package google
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2/jws"
+)
+
+func TestSelfSignedJWTConfig(t *testing.T) {
+	setupDummyKey(t)
+
+	cfg, err := SelfSignedJWTConfigFromJSON(jsonKey, "https://api.example.com")
+	if err != nil {
+		t.Fatalf("SelfSignedJWTConfigFromJSON: %v", err)
+	}
+	cfg.Lifetime = 10 * time.Minute
+	cfg.Claims = map[string]interface{}{"tenant": "acme"}
+
+	tok, err := cfg.TokenSource().Token()
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	if err := jws.Verify(tok.AccessToken, &privateKey.PublicKey); err != nil {
+		t.Errorf("jws.Verify on AccessToken: %v", err)
+	}
+	claim, err := jws.Decode(tok.AccessToken)
+	if err != nil {
+		t.Fatalf("jws.Decode on AccessToken: %v", err)
+	}
+	if got, want := claim.Aud, "https://api.example.com"; got != want {
+		t.Errorf("Aud = %q, want %q", got, want)
+	}
+	if got, want := claim.Exp-claim.Iat, int64(600); got != want {
+		t.Errorf("lifetime = %d, want %d", got, want)
+	}
+	if got, want := claim.Iss, "gopher@developer.gserviceaccount.com"; got != want {
+		t.Errorf("Iss = %q, want %q", got, want)
+	}
+}
+
+func TestSelfSignedJWTConfig_MissingAudience(t *testing.T) {
+	setupDummyKey(t)
+
+	cfg, err := SelfSignedJWTConfigFromJSON(jsonKey, "")
+	if err != nil {
+		t.Fatalf("SelfSignedJWTConfigFromJSON: %v", err)
+	}
+	if _, err := cfg.Mint(); err == nil {
+		t.Error("Mint() without audience succeeded, want error")
+	}
+}
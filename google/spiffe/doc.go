@@ -0,0 +1,18 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package spiffe provides helpers for authenticating SPIFFE workloads, such
+// as workloads attested by SPIRE, to Google Cloud with workload identity
+// federation.
+//
+// The helpers in this package fetch a JWT-SVID for the workload from the
+// SPIFFE Workload API served by the local agent, typically over a Unix
+// domain socket. The JWT-SVID is used as the subject token of a Security
+// Token Service exchange for a Google Cloud access token, with a workload
+// identity pool provider trusting the SPIFFE trust domain's JWT bundle.
+//
+// The built-in Workload API client requires Go 1.24 or later. Applications
+// can supply their own Workload API client or JWT-SVID source instead, see
+// Config.Source.
+package spiffe // import "golang.org/x/oauth2/google/spiffe"
@@ -0,0 +1,47 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package spiffe
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type fakeSVIDSource struct {
+	svid               string
+	audience, spiffeID string
+}
+
+func (s *fakeSVIDSource) FetchJWTSVID(ctx context.Context, audience, spiffeID string) (string, error) {
+	s.audience, s.spiffeID = audience, spiffeID
+	return s.svid, nil
+}
+
+func TestSubjectToken_Source(t *testing.T) {
+	claims := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d}`, time.Now().Add(time.Hour).Unix())))
+	src := &fakeSVIDSource{svid: "eyJhbGciOiJFUzI1NiJ9." + claims + ".signature"}
+	c := &Config{
+		Audience: "//iam.googleapis.com/projects/123/locations/global/workloadIdentityPools/pool/providers/spire",
+		SPIFFEID: "spiffe://example.org/workload",
+		Endpoint: "unix:///does/not/exist",
+		Source:   src,
+	}
+	tok, err := c.SubjectToken(context.Background())
+	if err != nil {
+		t.Fatalf("SubjectToken() failed: %v", err)
+	}
+	if tok != src.svid {
+		t.Errorf("got %v but want %v", tok, src.svid)
+	}
+	if got, want := src.audience, "https:"+c.Audience; got != want {
+		t.Errorf("got audience %v but want %v", got, want)
+	}
+	if got, want := src.spiffeID, c.SPIFFEID; got != want {
+		t.Errorf("got SPIFFE ID %v but want %v", got, want)
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package spiffe
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google/internal/externalaccount"
+	"golang.org/x/oauth2/jws"
+)
+
+const (
+	// JWTTokenType is the subject token type of the tokens returned by
+	// SubjectToken.
+	JWTTokenType = externalaccount.JWTTokenType
+
+	// endpointSocketEnvVar names the Workload API endpoint by convention.
+	endpointSocketEnvVar = "SPIFFE_ENDPOINT_SOCKET"
+
+	// refetchBefore is how long before its expiry a cached JWT-SVID is
+	// fetched again.
+	refetchBefore = 5 * time.Minute
+)
+
+// getenv aliases os.Getenv for testing.
+var getenv = os.Getenv
+
+// timeNow is time.Now but pulled out as a variable for tests.
+var timeNow = time.Now
+
+// JWTSVIDSource fetches JWT-SVIDs of the workload. It lets applications that
+// already run a Workload API client, such as the JWTSource of
+// github.com/spiffe/go-spiffe, share it with Config instead of using the
+// minimal client of this package.
+type JWTSVIDSource interface {
+	// FetchJWTSVID returns a JWT-SVID for audience. spiffeID selects the
+	// SPIFFE ID of the JWT-SVID when it is not empty.
+	FetchJWTSVID(ctx context.Context, audience, spiffeID string) (string, error)
+}
+
+// Config describes how a SPIFFE workload obtains its JWT-SVID and the
+// workload identity pool provider it is exchanged with.
+// A Config caches the JWT-SVID it fetches and must not be copied after first
+// use.
+type Config struct {
+	// Audience is the workload identity pool provider resource name, e.g.
+	// //iam.googleapis.com/projects/PROJECT_NUMBER/locations/global/workloadIdentityPools/POOL_ID/providers/PROVIDER_ID.
+	// Required for TokenSource.
+	Audience string
+
+	// SVIDAudience is the audience of the requested JWT-SVID. It must be an
+	// allowed audience of the workload identity pool provider. The default
+	// is the https: form of Audience, e.g.
+	// https://iam.googleapis.com/projects/PROJECT_NUMBER/locations/global/workloadIdentityPools/POOL_ID/providers/PROVIDER_ID.
+	// Optional.
+	SVIDAudience string
+
+	// SPIFFEID selects the SPIFFE ID of the JWT-SVID when the workload has
+	// several. Optional.
+	SPIFFEID string
+
+	// Endpoint is the address of the Workload API, e.g.
+	// unix:///run/spire/sockets/agent.sock. The default is the value of the
+	// SPIFFE_ENDPOINT_SOCKET environment variable. It is ignored when
+	// Source is set.
+	Endpoint string
+
+	// Source, if non-nil, fetches the JWT-SVIDs in place of the built-in
+	// Workload API client. Optional.
+	Source JWTSVIDSource
+
+	// STSURL is the Security Token Service endpoint. The default is
+	// https://sts.googleapis.com/v1/token.
+	STSURL string
+
+	// ServiceAccountImpersonationURL is the URL of the generateAccessToken
+	// method of the service account to impersonate after the exchange.
+	// Optional.
+	ServiceAccountImpersonationURL string
+
+	// Scopes are the scopes of the returned Google Cloud access token. The
+	// default is https://www.googleapis.com/auth/cloud-platform.
+	Scopes []string
+
+	mu     sync.Mutex
+	client *http.Client
+	svid   string
+	expiry time.Time
+}
+
+func (c *Config) svidAudience() (string, error) {
+	if c.SVIDAudience != "" {
+		return c.SVIDAudience, nil
+	}
+	if !strings.HasPrefix(c.Audience, "//") {
+		return "", errors.New("oauth2/google/spiffe: missing JWT-SVID audience")
+	}
+	return "https:" + c.Audience, nil
+}
+
+// workloadAPIClient returns the client of the Workload API, creating it on
+// first use. c.mu must be held.
+func (c *Config) workloadAPIClient() (*http.Client, error) {
+	if c.client != nil {
+		return c.client, nil
+	}
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = getenv(endpointSocketEnvVar)
+	}
+	if endpoint == "" {
+		return nil, errors.New("oauth2/google/spiffe: no Workload API endpoint configured and SPIFFE_ENDPOINT_SOCKET is not set")
+	}
+	dial, err := dialer(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := newTransport(dial)
+	if err != nil {
+		return nil, err
+	}
+	c.client = &http.Client{Transport: transport}
+	return c.client, nil
+}
+
+// SubjectToken returns a JWT-SVID of the workload, a subject token of type
+// JWTTokenType. The JWT-SVID is reused until shortly before it expires.
+func (c *Config) SubjectToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.svid != "" && timeNow().Add(refetchBefore).Before(c.expiry) {
+		return c.svid, nil
+	}
+	audience, err := c.svidAudience()
+	if err != nil {
+		return "", err
+	}
+	var svid string
+	if c.Source != nil {
+		svid, err = c.Source.FetchJWTSVID(ctx, audience, c.SPIFFEID)
+	} else {
+		var client *http.Client
+		client, err = c.workloadAPIClient()
+		if err == nil {
+			svid, err = fetchJWTSVID(ctx, client, audience, c.SPIFFEID)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	c.svid, c.expiry = svid, time.Time{}
+	if claims, err := jws.Decode(svid); err == nil && claims.Exp != 0 {
+		c.expiry = time.Unix(claims.Exp, 0)
+	}
+	return svid, nil
+}
+
+// TokenSource returns a TokenSource of Google Cloud access tokens obtained by
+// exchanging the workload's JWT-SVID with the Security Token Service.
+func (c *Config) TokenSource(ctx context.Context) (oauth2.TokenSource, error) {
+	cfg := &externalaccount.PlatformConfig{
+		Audience:                       c.Audience,
+		SubjectTokenType:               JWTTokenType,
+		STSURL:                         c.STSURL,
+		ServiceAccountImpersonationURL: c.ServiceAccountImpersonationURL,
+		Scopes:                         c.Scopes,
+		SubjectToken:                   c.SubjectToken,
+		MetricsSource:                  "spiffe",
+	}
+	return cfg.TokenSource(ctx)
+}
@@ -0,0 +1,191 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.24
+
+package spiffe
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testJWT(sub string, exp time.Time) string {
+	claims := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"sub":%q,"exp":%d}`, sub, exp.Unix())))
+	return "eyJhbGciOiJFUzI1NiJ9." + claims + ".signature"
+}
+
+// workloadAPIServer serves FetchJWTSVID on a Unix domain socket, answering
+// with svid or, if status is not "0", with the gRPC error status.
+type workloadAPIServer struct {
+	endpoint string
+	calls    int32
+}
+
+func newWorkloadAPIServer(t *testing.T, wantAudience, svid, status string) *workloadAPIServer {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &workloadAPIServer{endpoint: "unix://" + socket}
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	server := &http.Server{
+		Protocols: &protocols,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&s.calls, 1)
+			if got, want := r.URL.Path, fetchJWTSVIDPath; got != want {
+				t.Errorf("got path %v but want %v", got, want)
+			}
+			if got, want := r.ProtoMajor, 2; got != want {
+				t.Errorf("got HTTP/%d but want HTTP/%d", got, want)
+			}
+			if got, want := r.Header.Get(workloadAPIHeader), "true"; got != want {
+				t.Errorf("got %v header %v but want %v", workloadAPIHeader, got, want)
+			}
+			body, err := io.ReadAll(r.Body)
+			if err != nil || len(body) < 5 {
+				t.Errorf("unable to read request: %v", err)
+				return
+			}
+			audiences, err := stringFields(body[5:], 1)
+			if err != nil {
+				t.Errorf("stringFields() failed: %v", err)
+			}
+			if len(audiences) != 1 || audiences[0] != wantAudience {
+				t.Errorf("got audiences %q but want [%v]", audiences, wantAudience)
+			}
+
+			w.Header().Set("Content-Type", "application/grpc")
+			w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+			if status == "0" {
+				jwtSVID := appendStringField(nil, 1, "spiffe://example.org/workload")
+				jwtSVID = appendStringField(jwtSVID, 2, svid)
+				msg := appendStringField(nil, 1, string(jwtSVID))
+				frame := make([]byte, 5, 5+len(msg))
+				binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+				w.Write(append(frame, msg...))
+			}
+			w.Header().Set("Grpc-Status", status)
+			if status != "0" {
+				w.Header().Set("Grpc-Message", "no identity issued")
+			}
+		}),
+	}
+	go server.Serve(l)
+	t.Cleanup(func() { server.Close() })
+	return s
+}
+
+const testAudience = "//iam.googleapis.com/projects/123/locations/global/workloadIdentityPools/pool/providers/spire"
+
+func TestSubjectToken(t *testing.T) {
+	defer func(old func() time.Time) { timeNow = old }(timeNow)
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+
+	svid := testJWT("spiffe://example.org/workload", now.Add(time.Hour))
+	s := newWorkloadAPIServer(t, "https:"+testAudience, svid, "0")
+	c := &Config{Audience: testAudience, Endpoint: s.endpoint}
+
+	for i := 0; i < 2; i++ {
+		tok, err := c.SubjectToken(context.Background())
+		if err != nil {
+			t.Fatalf("SubjectToken() failed: %v", err)
+		}
+		if tok != svid {
+			t.Errorf("got %v but want %v", tok, svid)
+		}
+	}
+	if got, want := atomic.LoadInt32(&s.calls), int32(1); got != want {
+		t.Errorf("got %d Workload API calls but want %d", got, want)
+	}
+
+	// Close to its expiry, the JWT-SVID is fetched again.
+	now = now.Add(58 * time.Minute)
+	if _, err := c.SubjectToken(context.Background()); err != nil {
+		t.Fatalf("SubjectToken() failed: %v", err)
+	}
+	if got, want := atomic.LoadInt32(&s.calls), int32(2); got != want {
+		t.Errorf("got %d Workload API calls but want %d", got, want)
+	}
+}
+
+func TestSubjectToken_EndpointFromEnvironment(t *testing.T) {
+	svid := testJWT("spiffe://example.org/workload", time.Now().Add(time.Hour))
+	s := newWorkloadAPIServer(t, "sts-audience", svid, "0")
+	defer func(old func(string) string) { getenv = old }(getenv)
+	getenv = func(key string) string {
+		if key == endpointSocketEnvVar {
+			return s.endpoint
+		}
+		return ""
+	}
+
+	c := &Config{Audience: testAudience, SVIDAudience: "sts-audience"}
+	tok, err := c.SubjectToken(context.Background())
+	if err != nil {
+		t.Fatalf("SubjectToken() failed: %v", err)
+	}
+	if tok != svid {
+		t.Errorf("got %v but want %v", tok, svid)
+	}
+}
+
+func TestSubjectToken_WorkloadAPIError(t *testing.T) {
+	s := newWorkloadAPIServer(t, "https:"+testAudience, "", "7")
+	c := &Config{Audience: testAudience, Endpoint: s.endpoint}
+	_, err := c.SubjectToken(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "no identity issued") {
+		t.Errorf("got error %v but want the Workload API error", err)
+	}
+}
+
+func TestTokenSource(t *testing.T) {
+	svid := testJWT("spiffe://example.org/workload", time.Now().Add(time.Hour))
+	s := newWorkloadAPIServer(t, "https:"+testAudience, svid, "0")
+	sts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.FormValue("subject_token"), svid; got != want {
+			t.Errorf("got subject_token %v but want %v", got, want)
+		}
+		if got, want := r.FormValue("subject_token_type"), JWTTokenType; got != want {
+			t.Errorf("got subject_token_type %v but want %v", got, want)
+		}
+		if got, want := r.FormValue("audience"), testAudience; got != want {
+			t.Errorf("got audience %v but want %v", got, want)
+		}
+		if got, want := r.FormValue("scope"), "https://www.googleapis.com/auth/cloud-platform"; got != want {
+			t.Errorf("got scope %v but want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"google-token","issued_token_type":"urn:ietf:params:oauth:token-type:access_token","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer sts.Close()
+
+	c := &Config{Audience: testAudience, Endpoint: s.endpoint, STSURL: sts.URL}
+	ts, err := c.TokenSource(context.Background())
+	if err != nil {
+		t.Fatalf("TokenSource() failed: %v", err)
+	}
+	tok, err := ts.Token()
+	if err != nil {
+		t.Fatalf("Token() failed: %v", err)
+	}
+	if got, want := tok.AccessToken, "google-token"; got != want {
+		t.Errorf("got %v but want %v", got, want)
+	}
+}
@@ -0,0 +1,21 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.24
+
+package spiffe
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+// newTransport returns a transport speaking HTTP/2 without TLS, as gRPC
+// does, over the connections returned by dial.
+func newTransport(dial func(ctx context.Context, network, addr string) (net.Conn, error)) (http.RoundTripper, error) {
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	return &http.Transport{DialContext: dial, Protocols: &protocols}, nil
+}
@@ -0,0 +1,20 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !go1.24
+
+package spiffe
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// newTransport reports that the Workload API cannot be reached: unencrypted
+// HTTP/2, which gRPC requires, is only supported by net/http since Go 1.24.
+func newTransport(dial func(ctx context.Context, network, addr string) (net.Conn, error)) (http.RoundTripper, error) {
+	return nil, errors.New("oauth2/google/spiffe: the Workload API client requires Go 1.24 or later")
+}
@@ -0,0 +1,199 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package spiffe
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// The Workload API is a gRPC service. Its FetchJWTSVID method is called
+// directly over HTTP/2 with hand-encoded protocol buffers, which keeps this
+// package free of gRPC dependencies.
+const (
+	fetchJWTSVIDPath = "/SpiffeWorkloadAPI/FetchJWTSVID"
+
+	// workloadAPIHeader must be set on all Workload API calls, so that the
+	// agent can tell them apart from requests forged by a browser.
+	workloadAPIHeader = "workload.spiffe.io"
+
+	maxWorkloadAPIResponse = 1 << 20
+)
+
+// dialer returns a function dialing the Workload API
+// endpoint socket, given as unix:///path/to/socket or tcp://IP:port.
+func dialer(endpoint string) (func(ctx context.Context, network, addr string) (net.Conn, error), error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("oauth2/google/spiffe: invalid Workload API endpoint %q: %v", endpoint, err)
+	}
+	var network, address string
+	switch u.Scheme {
+	case "unix":
+		network, address = "unix", u.Path
+		if address == "" {
+			address = u.Opaque
+		}
+	case "tcp":
+		network, address = "tcp", u.Host
+	default:
+		return nil, fmt.Errorf("oauth2/google/spiffe: unsupported Workload API endpoint %q, expected unix:// or tcp://", endpoint)
+	}
+	if address == "" {
+		return nil, fmt.Errorf("oauth2/google/spiffe: invalid Workload API endpoint %q", endpoint)
+	}
+	var d net.Dialer
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return d.DialContext(ctx, network, address)
+	}, nil
+}
+
+// fetchJWTSVID calls the FetchJWTSVID method of the Workload API and returns
+// the first JWT-SVID of the response.
+func fetchJWTSVID(ctx context.Context, client *http.Client, audience, spiffeID string) (string, error) {
+	var msg []byte
+	msg = appendStringField(msg, 1, audience)
+	if spiffeID != "" {
+		msg = appendStringField(msg, 2, spiffeID)
+	}
+	body := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(body[1:], uint32(len(msg)))
+	body = append(body, msg...)
+
+	req, err := http.NewRequest("POST", "http://localhost"+fetchJWTSVIDPath, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("Te", "trailers")
+	req.Header.Set(workloadAPIHeader, "true")
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("oauth2/google/spiffe: Workload API request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("oauth2/google/spiffe: Workload API returned status code %d", resp.StatusCode)
+	}
+	respBody, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxWorkloadAPIResponse))
+	if err != nil {
+		return "", fmt.Errorf("oauth2/google/spiffe: unable to read Workload API response: %v", err)
+	}
+	if err := grpcStatus(resp); err != nil {
+		return "", err
+	}
+	if len(respBody) < 5 || respBody[0] != 0 {
+		return "", errors.New("oauth2/google/spiffe: malformed Workload API response")
+	}
+	n := binary.BigEndian.Uint32(respBody[1:5])
+	if uint32(len(respBody)-5) < n {
+		return "", errors.New("oauth2/google/spiffe: truncated Workload API response")
+	}
+	return parseJWTSVIDResponse(respBody[5 : 5+n])
+}
+
+// grpcStatus returns the error reported by the grpc-status trailer of resp,
+// or by its header for responses without a body.
+func grpcStatus(resp *http.Response) error {
+	status, message := resp.Trailer.Get("Grpc-Status"), resp.Trailer.Get("Grpc-Message")
+	if status == "" {
+		status, message = resp.Header.Get("Grpc-Status"), resp.Header.Get("Grpc-Message")
+	}
+	if status == "0" {
+		return nil
+	}
+	if status == "" {
+		return errors.New("oauth2/google/spiffe: Workload API response is missing its status")
+	}
+	if m, err := url.PathUnescape(message); err == nil {
+		message = m
+	}
+	return fmt.Errorf("oauth2/google/spiffe: Workload API error (code %s): %s", status, message)
+}
+
+// parseJWTSVIDResponse returns the svid of the first JWTSVID of a
+// JWTSVIDResponse message:
+//
+//	message JWTSVIDResponse { repeated JWTSVID svids = 1; }
+//	message JWTSVID { string spiffe_id = 1; string svid = 2; string hint = 3; }
+func parseJWTSVIDResponse(b []byte) (string, error) {
+	svids, err := stringFields(b, 1)
+	if err != nil {
+		return "", err
+	}
+	if len(svids) == 0 {
+		return "", errors.New("oauth2/google/spiffe: Workload API returned no JWT-SVID")
+	}
+	tokens, err := stringFields([]byte(svids[0]), 2)
+	if err != nil {
+		return "", err
+	}
+	if len(tokens) == 0 || strings.TrimSpace(tokens[0]) == "" {
+		return "", errors.New("oauth2/google/spiffe: Workload API returned an empty JWT-SVID")
+	}
+	return tokens[0], nil
+}
+
+// appendStringField appends a length-delimited protocol buffer field.
+func appendStringField(b []byte, field int, s string) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	b = append(b, buf[:binary.PutUvarint(buf[:], uint64(field)<<3|2)]...)
+	b = append(b, buf[:binary.PutUvarint(buf[:], uint64(len(s)))]...)
+	return append(b, s...)
+}
+
+// stringFields returns the values of the length-delimited occurrences of
+// field in the protocol buffer message b, skipping other fields.
+func stringFields(b []byte, field int) ([]string, error) {
+	var values []string
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errMalformedMessage
+		}
+		b = b[n:]
+		switch key & 7 {
+		case 0: // varint
+			if _, n = binary.Uvarint(b); n <= 0 {
+				return nil, errMalformedMessage
+			}
+			b = b[n:]
+		case 1: // fixed64
+			if len(b) < 8 {
+				return nil, errMalformedMessage
+			}
+			b = b[8:]
+		case 2: // length-delimited
+			l, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < l {
+				return nil, errMalformedMessage
+			}
+			if key>>3 == uint64(field) {
+				values = append(values, string(b[n:n+int(l)]))
+			}
+			b = b[n+int(l):]
+		case 5: // fixed32
+			if len(b) < 4 {
+				return nil, errMalformedMessage
+			}
+			b = b[4:]
+		default:
+			return nil, errMalformedMessage
+		}
+	}
+	return values, nil
+}
+
+var errMalformedMessage = errors.New("oauth2/google/spiffe: malformed Workload API message")
@@ -0,0 +1,43 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package spiffe
+
+import "testing"
+
+func TestDialer(t *testing.T) {
+	for _, endpoint := range []string{"unix:///run/spire/agent.sock", "unix:/run/spire/agent.sock", "tcp://127.0.0.1:8081"} {
+		if _, err := dialer(endpoint); err != nil {
+			t.Errorf("dialer(%q) failed: %v", endpoint, err)
+		}
+	}
+	for _, endpoint := range []string{"", "/run/spire/agent.sock", "unix://", "http://localhost:8081"} {
+		if _, err := dialer(endpoint); err == nil {
+			t.Errorf("dialer(%q) succeeded, want error", endpoint)
+		}
+	}
+}
+
+func TestParseJWTSVIDResponse(t *testing.T) {
+	svid := appendStringField(nil, 1, "spiffe://example.org/a")
+	svid = appendStringField(svid, 2, "token-a")
+	svid = append(svid, 0x20, 0x01) // unknown varint field 4
+	other := appendStringField(nil, 2, "token-b")
+	msg := appendStringField(nil, 1, string(svid))
+	msg = appendStringField(msg, 1, string(other))
+
+	got, err := parseJWTSVIDResponse(msg)
+	if err != nil {
+		t.Fatalf("parseJWTSVIDResponse() failed: %v", err)
+	}
+	if want := "token-a"; got != want {
+		t.Errorf("got %v but want %v", got, want)
+	}
+
+	for _, b := range [][]byte{nil, {0x0a, 0x05, 'a'}, {0x0f}} {
+		if _, err := parseJWTSVIDResponse(b); err == nil {
+			t.Errorf("parseJWTSVIDResponse(%x) succeeded, want error", b)
+		}
+	}
+}
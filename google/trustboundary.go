@@ -0,0 +1,177 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package google
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	// allowedLocationsHeader carries the trust boundary of the caller.
+	allowedLocationsHeader = "x-allowed-locations"
+
+	// noOpEncodedLocations is returned for principals without a trust
+	// boundary. It is never sent.
+	noOpEncodedLocations = "0x0"
+
+	defaultTrustBoundaryRefresh = time.Hour
+
+	// trustBoundaryLookupTimeout bounds a lookup, so that an unreachable
+	// endpoint does not hold requests for long.
+	trustBoundaryLookupTimeout = 10 * time.Second
+
+	// trustBoundaryRetryInterval is how long after a failed lookup the next
+	// one is made.
+	trustBoundaryRetryInterval = time.Minute
+)
+
+// TrustBoundaryTransport is an http.RoundTripper that attaches the
+// x-allowed-locations header required by organizations with data boundary
+// restrictions. The allowed locations of ServiceAccount are looked up with
+// the IAM Credentials API and cached.
+//
+// Lookups fail softly: if the lookup fails, the last known value is used, and
+// requests are sent without the header when no value has been obtained yet.
+// A single lookup runs at a time, bounded by the request context and a 10
+// second timeout; requests made meanwhile use the last known value, or wait
+// for the first one. A failed lookup is retried after a minute.
+type TrustBoundaryTransport struct {
+	// Base is the transport that sends the requests, typically an
+	// *oauth2.Transport authenticating as ServiceAccount. If nil,
+	// http.DefaultTransport is used.
+	Base http.RoundTripper
+
+	// ServiceAccount is the email address of the service account whose
+	// allowed locations are attached. Required.
+	ServiceAccount string
+
+	// Source authenticates the lookup. Required.
+	Source oauth2.TokenSource
+
+	// Endpoint overrides the base URL of the IAM Credentials API. Optional.
+	Endpoint string
+
+	// RefreshInterval is how long a looked up value is used before it is
+	// refreshed. The default is one hour.
+	RefreshInterval time.Duration
+
+	mu         sync.Mutex // guards the fields below
+	encoded    string
+	fetched    time.Time
+	retryAfter time.Time     // set by a failed lookup
+	inflight   chan struct{} // closed when the lookup in progress ends
+}
+
+// allowedLocationsResponse is the response of the allowedLocations method.
+type allowedLocationsResponse struct {
+	Locations        []string `json:"locations"`
+	EncodedLocations string   `json:"encodedLocations"`
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *TrustBoundaryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	encoded := t.allowedLocations(req.Context())
+	if encoded == "" || encoded == noOpEncodedLocations {
+		return base.RoundTrip(req)
+	}
+	req2 := req.Clone(req.Context()) // per RoundTripper contract
+	req2.Header.Set(allowedLocationsHeader, encoded)
+	return base.RoundTrip(req2)
+}
+
+// allowedLocations returns the cached encoded locations, refreshing them
+// when they are stale.
+func (t *TrustBoundaryTransport) allowedLocations(ctx context.Context) string {
+	t.mu.Lock()
+	interval := t.RefreshInterval
+	if interval <= 0 {
+		interval = defaultTrustBoundaryRefresh
+	}
+	now := time.Now()
+	if (!t.fetched.IsZero() && now.Sub(t.fetched) < interval) || now.Before(t.retryAfter) {
+		defer t.mu.Unlock()
+		return t.encoded
+	}
+	if done := t.inflight; done != nil {
+		encoded, known := t.encoded, !t.fetched.IsZero()
+		t.mu.Unlock()
+		if known {
+			return encoded
+		}
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ""
+		}
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		return t.encoded
+	}
+	done := make(chan struct{})
+	t.inflight = done
+	t.mu.Unlock()
+
+	encoded, err := t.lookup(ctx)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.inflight = nil
+	close(done)
+	switch {
+	case err == nil:
+		t.encoded, t.fetched, t.retryAfter = encoded, time.Now(), time.Time{}
+	case ctx.Err() == nil:
+		// Keep serving the last known value. Lookups abandoned by their
+		// request are retried right away.
+		t.retryAfter = time.Now().Add(trustBoundaryRetryInterval)
+	}
+	return t.encoded
+}
+
+func (t *TrustBoundaryTransport) lookup(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, trustBoundaryLookupTimeout)
+	defer cancel()
+	endpoint := t.Endpoint
+	if endpoint == "" {
+		endpoint = defaultIAMCredentialsURL
+	}
+	u := fmt.Sprintf("%s/projects/-/serviceAccounts/%s/allowedLocations", strings.TrimSuffix(endpoint, "/"), t.ServiceAccount)
+	client := &http.Client{Transport: &oauth2.Transport{Source: t.Source}}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if c := resp.StatusCode; c < 200 || c > 299 {
+		return "", fmt.Errorf("oauth2/google: allowed locations lookup failed: status code %d: %s", c, body)
+	}
+	var r allowedLocationsResponse
+	if err := json.Unmarshal(body, &r); err != nil {
+		return "", fmt.Errorf("oauth2/google: unable to parse allowed locations: %v", err)
+	}
+	return r.EncodedLocations, nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package google
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestTrustBoundaryTransport(t *testing.T) {
+	var lookups int
+	encoded := "0xA30"
+	fail := false
+	iam := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lookups++
+		if got, want := r.URL.Path, "/projects/-/serviceAccounts/sa@project.iam.gserviceaccount.com/allowedLocations"; got != want {
+			t.Errorf("got path %v but want %v", got, want)
+		}
+		if got, want := r.Header.Get("Authorization"), "Bearer token"; got != want {
+			t.Errorf("got %v but want %v", got, want)
+		}
+		if fail {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"locations":["us-central1"],"encodedLocations":"` + encoded + `"}`))
+	}))
+	defer iam.Close()
+
+	var gotHeader string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Get("x-allowed-locations")
+	}))
+	defer api.Close()
+
+	tr := &TrustBoundaryTransport{
+		ServiceAccount: "sa@project.iam.gserviceaccount.com",
+		Source:         oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"}),
+		Endpoint:       iam.URL,
+	}
+	client := &http.Client{Transport: tr}
+	get := func() {
+		t.Helper()
+		resp, err := client.Get(api.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	get()
+	get()
+	if gotHeader != "0xA30" || lookups != 1 {
+		t.Errorf("got header %q after %d lookups, want %q after 1", gotHeader, lookups, "0xA30")
+	}
+
+	// A failed refresh keeps the last known value.
+	tr.fetched = tr.fetched.Add(-2 * defaultTrustBoundaryRefresh)
+	fail = true
+	get()
+	if gotHeader != "0xA30" || lookups != 2 {
+		t.Errorf("got header %q after %d lookups, want %q after 2", gotHeader, lookups, "0xA30")
+	}
+	// and is not retried right away.
+	get()
+	if lookups != 2 {
+		t.Errorf("got %d lookups after a failure, want 2", lookups)
+	}
+
+	// Principals without a boundary do not send the header.
+	fail = false
+	encoded = "0x0"
+	tr.fetched = tr.fetched.Add(-2 * defaultTrustBoundaryRefresh)
+	tr.retryAfter = time.Time{}
+	get()
+	if gotHeader != "" {
+		t.Errorf("got header %q, want none", gotHeader)
+	}
+}
@@ -0,0 +1,323 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package workforce
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google/internal/externalaccount"
+)
+
+const (
+	// IDTokenType is the subject token type of OIDC ID tokens.
+	IDTokenType = "urn:ietf:params:oauth:token-type:id_token"
+
+	deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+)
+
+// defaultPollInterval is the polling interval used when the identity provider
+// does not specify one, as recommended by RFC 8628 section 3.2.
+var defaultPollInterval = 5 * time.Second
+
+// DeviceConfig describes a headless workforce sign-in. The user is shown a
+// URL and a code to enter on any other device, following the OAuth 2.0
+// Device Authorization Grant (RFC 8628) of the identity provider. The OIDC ID
+// token obtained this way is exchanged with the Security Token Service.
+type DeviceConfig struct {
+	// Audience is the workforce pool provider resource name, e.g.
+	// //iam.googleapis.com/locations/global/workforcePools/POOL_ID/providers/PROVIDER_ID.
+	// Required.
+	Audience string
+
+	// WorkforcePoolUserProject is the project used for quota and billing of
+	// the exchange. Optional.
+	WorkforcePoolUserProject string
+
+	// DeviceAuthURL is the identity provider's device authorization endpoint.
+	// Required.
+	DeviceAuthURL string
+
+	// TokenURL is the identity provider's token endpoint. Required.
+	TokenURL string
+
+	// ClientID and ClientSecret identify the OAuth client registered with the
+	// identity provider. ClientSecret is optional.
+	ClientID     string
+	ClientSecret string
+
+	// IdPScopes are the scopes requested from the identity provider.
+	// The default is "openid".
+	IdPScopes []string
+
+	// STSURL is the Security Token Service endpoint. The default is
+	// https://sts.googleapis.com/v1/token.
+	STSURL string
+
+	// Scopes are the scopes of the returned Google Cloud access token. The
+	// default is https://www.googleapis.com/auth/cloud-platform.
+	Scopes []string
+}
+
+// DeviceAuthResponse holds the values to present to the user so that they can
+// complete the sign-in on another device.
+type DeviceAuthResponse struct {
+	// VerificationURI is the URL the user should visit.
+	VerificationURI string
+	// UserCode is the code the user should enter at VerificationURI.
+	UserCode string
+	// VerificationURIComplete is VerificationURI with UserCode included, so
+	// that the user does not have to enter it. It is optional in RFC 8628
+	// and empty when the identity provider did not return it.
+	VerificationURIComplete string
+	// Expiry is the time after which UserCode is no longer valid.
+	Expiry time.Time
+}
+
+// QRCodeContent returns the content of a QR code that the user can scan to
+// complete the sign-in, as suggested by RFC 8628 section 3.3.1. It is
+// VerificationURIComplete when available, so that the user does not need to
+// enter UserCode, and VerificationURI otherwise. Rendering the QR code is left
+// to the caller.
+func (r *DeviceAuthResponse) QRCodeContent() string {
+	if r.VerificationURIComplete != "" {
+		return r.VerificationURIComplete
+	}
+	return r.VerificationURI
+}
+
+// DeviceAuthHandler presents a DeviceAuthResponse to the user, typically by
+// printing it to the terminal.
+type DeviceAuthHandler func(resp *DeviceAuthResponse) error
+
+// deviceAuthJSON is the device authorization response, see RFC 8628
+// section 3.2. Some identity providers use verification_url instead of
+// verification_uri.
+type deviceAuthJSON struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURL         string `json:"verification_url"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	VerificationURLComplete string `json:"verification_url_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+// idpTokenJSON is the identity provider's token response, including the
+// error fields described in RFC 6749 section 5.2.
+type idpTokenJSON struct {
+	IDToken          string `json:"id_token"`
+	RefreshToken     string `json:"refresh_token"`
+	ErrorCode        string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// TokenSource returns a TokenSource of Google Cloud access tokens for the
+// signed-in user. The user is asked to sign in through handler when the first
+// token is requested. Later tokens are obtained with the identity provider's
+// refresh token when one was issued, and through handler otherwise.
+func (c *DeviceConfig) TokenSource(ctx context.Context, handler DeviceAuthHandler) (oauth2.TokenSource, error) {
+	signIn := &deviceSignIn{conf: c, handler: handler}
+	cfg := &externalaccount.PlatformConfig{
+		Audience:                 c.Audience,
+		SubjectTokenType:         IDTokenType,
+		STSURL:                   c.STSURL,
+		WorkforcePoolUserProject: c.WorkforcePoolUserProject,
+		Scopes:                   c.Scopes,
+		SubjectToken:             signIn.subjectToken,
+		MetricsSource:            "workforce-device",
+	}
+	return cfg.TokenSource(ctx)
+}
+
+// deviceSignIn supplies ID tokens to the STS exchange, reusing the identity
+// provider's refresh token when possible. The token source calls it for one
+// token at a time.
+type deviceSignIn struct {
+	conf    *DeviceConfig
+	handler DeviceAuthHandler
+
+	mu           sync.Mutex // guards refreshToken
+	refreshToken string
+}
+
+func (s *deviceSignIn) subjectToken(ctx context.Context) (string, error) {
+	// s.mu is not held during requests, and in particular not while the
+	// user completes the sign-in.
+	s.mu.Lock()
+	refreshToken := s.refreshToken
+	s.mu.Unlock()
+	if refreshToken != "" {
+		tok, err := s.conf.retrieveToken(ctx, url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {refreshToken},
+		})
+		if err == nil && tok.IDToken != "" {
+			if tok.RefreshToken != "" {
+				s.setRefreshToken(tok.RefreshToken)
+			}
+			return tok.IDToken, nil
+		}
+		// The refresh token is no longer usable; sign in again.
+		s.setRefreshToken("")
+	}
+	tok, err := s.conf.deviceToken(ctx, s.handler)
+	if err != nil {
+		return "", err
+	}
+	s.setRefreshToken(tok.RefreshToken)
+	return tok.IDToken, nil
+}
+
+func (s *deviceSignIn) setRefreshToken(refreshToken string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refreshToken = refreshToken
+}
+
+// deviceToken runs the device authorization grant to completion.
+func (c *DeviceConfig) deviceToken(ctx context.Context, handler DeviceAuthHandler) (*idpTokenJSON, error) {
+	scopes := c.IdPScopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid"}
+	}
+	v := url.Values{
+		"client_id": {c.ClientID},
+		"scope":     {strings.Join(scopes, " ")},
+	}
+	body, err := c.post(ctx, c.DeviceAuthURL, v)
+	if err != nil {
+		return nil, err
+	}
+	var da deviceAuthJSON
+	if err := json.Unmarshal(body, &da); err != nil {
+		return nil, fmt.Errorf("oauth2/google/workforce: unable to parse device authorization response: %v", err)
+	}
+	if da.DeviceCode == "" || da.UserCode == "" {
+		return nil, errors.New("oauth2/google/workforce: device authorization response is missing device_code or user_code")
+	}
+	resp := &DeviceAuthResponse{
+		VerificationURI:         da.VerificationURI,
+		UserCode:                da.UserCode,
+		VerificationURIComplete: da.VerificationURIComplete,
+	}
+	if resp.VerificationURI == "" {
+		resp.VerificationURI = da.VerificationURL
+	}
+	if resp.VerificationURIComplete == "" {
+		resp.VerificationURIComplete = da.VerificationURLComplete
+	}
+	if da.ExpiresIn > 0 {
+		resp.Expiry = time.Now().Add(time.Duration(da.ExpiresIn) * time.Second)
+	}
+	if err := handler(resp); err != nil {
+		return nil, err
+	}
+
+	interval := defaultPollInterval
+	if da.Interval > 0 {
+		interval = time.Duration(da.Interval) * time.Second
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+		tok, err := c.retrieveToken(ctx, url.Values{
+			"grant_type":  {deviceCodeGrantType},
+			"device_code": {da.DeviceCode},
+		})
+		if err == nil {
+			if tok.IDToken == "" {
+				return nil, errors.New("oauth2/google/workforce: identity provider did not return an id_token")
+			}
+			return tok, nil
+		}
+		var pending *pendingError
+		if !errors.As(err, &pending) {
+			return nil, err
+		}
+		if pending.slowDown {
+			// RFC 8628 section 3.5: increase the interval by 5 seconds.
+			interval += 5 * time.Second
+		}
+	}
+}
+
+// pendingError reports that the user has not completed the sign-in yet.
+type pendingError struct {
+	slowDown bool
+}
+
+func (e *pendingError) Error() string {
+	return "oauth2/google/workforce: authorization pending"
+}
+
+// retrieveToken calls the identity provider's token endpoint. It returns a
+// *pendingError while the device authorization is still pending.
+func (c *DeviceConfig) retrieveToken(ctx context.Context, v url.Values) (*idpTokenJSON, error) {
+	v.Set("client_id", c.ClientID)
+	if c.ClientSecret != "" {
+		v.Set("client_secret", c.ClientSecret)
+	}
+	body, postErr := c.post(ctx, c.TokenURL, v)
+	var tok idpTokenJSON
+	if err := json.Unmarshal(body, &tok); err != nil {
+		if postErr != nil {
+			return nil, postErr
+		}
+		return nil, fmt.Errorf("oauth2/google/workforce: unable to parse token response: %v", err)
+	}
+	switch tok.ErrorCode {
+	case "":
+	case "authorization_pending":
+		return nil, &pendingError{}
+	case "slow_down":
+		return nil, &pendingError{slowDown: true}
+	default:
+		return nil, fmt.Errorf("oauth2/google/workforce: sign-in failed: %s %s", tok.ErrorCode, tok.ErrorDescription)
+	}
+	if postErr != nil {
+		return nil, postErr
+	}
+	return &tok, nil
+}
+
+// post sends a form to endpoint. On a non-2xx status it returns the body
+// together with an error so that callers can inspect OAuth error fields.
+func (c *DeviceConfig) post(ctx context.Context, endpoint string, v url.Values) ([]byte, error) {
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(v.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := oauth2.NewClient(ctx, nil).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oauth2/google/workforce: request to identity provider failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("oauth2/google/workforce: unable to read identity provider response: %v", err)
+	}
+	if c := resp.StatusCode; c < 200 || c > 299 {
+		return body, fmt.Errorf("oauth2/google/workforce: status code %d: %s", c, body)
+	}
+	return body, nil
+}
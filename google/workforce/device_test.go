@@ -0,0 +1,203 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package workforce
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type testIdP struct {
+	t            *testing.T
+	pending      int
+	deviceTokens int
+	refreshes    int
+}
+
+func (idp *testIdP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		idp.t.Errorf("ParseForm() failed: %v", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	switch r.URL.Path {
+	case "/device":
+		if got, want := r.PostForm.Get("scope"), "openid"; got != want {
+			idp.t.Errorf("scope = %q, want %q", got, want)
+		}
+		w.Write([]byte(`{"device_code":"dev","user_code":"ABCD-EFGH","verification_url":"https://idp.example.com/device","expires_in":600}`))
+	case "/token":
+		switch r.PostForm.Get("grant_type") {
+		case deviceCodeGrantType:
+			if idp.pending > 0 {
+				idp.pending--
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":"authorization_pending"}`))
+				return
+			}
+			idp.deviceTokens++
+			w.Write([]byte(`{"id_token":"device-id-token","refresh_token":"idp-refresh"}`))
+		case "refresh_token":
+			if got, want := r.PostForm.Get("refresh_token"), "idp-refresh"; got != want {
+				idp.t.Errorf("refresh_token = %q, want %q", got, want)
+			}
+			idp.refreshes++
+			w.Write([]byte(`{"id_token":"refreshed-id-token"}`))
+		}
+	case "/sts":
+		w.Write([]byte(`{"access_token":"sts-` + r.PostForm.Get("subject_token") + `","issued_token_type":"urn:ietf:params:oauth:token-type:access_token","token_type":"Bearer","expires_in":1}`))
+	default:
+		idp.t.Errorf("unexpected request to %s", r.URL.Path)
+	}
+}
+
+func setPollInterval(t *testing.T) {
+	old := defaultPollInterval
+	defaultPollInterval = time.Millisecond
+	t.Cleanup(func() { defaultPollInterval = old })
+}
+
+// subjectToken runs the device sign-in of c and returns the ID token.
+func subjectToken(ctx context.Context, c *DeviceConfig, handler DeviceAuthHandler) (string, error) {
+	tok, err := c.deviceToken(ctx, handler)
+	if err != nil {
+		return "", err
+	}
+	return tok.IDToken, nil
+}
+
+func TestDeviceSubjectToken(t *testing.T) {
+	setPollInterval(t)
+	idp := &testIdP{t: t, pending: 2}
+	server := httptest.NewServer(idp)
+	defer server.Close()
+
+	c := &DeviceConfig{
+		DeviceAuthURL: server.URL + "/device",
+		TokenURL:      server.URL + "/token",
+		ClientID:      "client",
+	}
+	var shown *DeviceAuthResponse
+	tok, err := subjectToken(context.Background(), c, func(resp *DeviceAuthResponse) error {
+		shown = resp
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("subjectToken() failed: %v", err)
+	}
+	if got, want := tok, "device-id-token"; got != want {
+		t.Errorf("subjectToken() = %q, want %q", got, want)
+	}
+	if shown == nil || shown.UserCode != "ABCD-EFGH" || shown.VerificationURI != "https://idp.example.com/device" || shown.Expiry.IsZero() {
+		t.Errorf("unexpected DeviceAuthResponse: %+v", shown)
+	}
+	if idp.pending != 0 {
+		t.Errorf("token endpoint was not polled until the authorization completed")
+	}
+}
+
+func TestDeviceTokenSource(t *testing.T) {
+	setPollInterval(t)
+	idp := &testIdP{t: t}
+	server := httptest.NewServer(idp)
+	defer server.Close()
+
+	c := &DeviceConfig{
+		Audience:                 "//iam.googleapis.com/locations/global/workforcePools/pool/providers/provider",
+		WorkforcePoolUserProject: "project",
+		DeviceAuthURL:            server.URL + "/device",
+		TokenURL:                 server.URL + "/token",
+		ClientID:                 "client",
+		STSURL:                   server.URL + "/sts",
+	}
+	ts, err := c.TokenSource(context.Background(), func(*DeviceAuthResponse) error { return nil })
+	if err != nil {
+		t.Fatalf("TokenSource() failed: %v", err)
+	}
+
+	tok, err := ts.Token()
+	if err != nil {
+		t.Fatalf("Token() failed: %v", err)
+	}
+	if got, want := tok.AccessToken, "sts-device-id-token"; got != want {
+		t.Errorf("AccessToken = %q, want %q", got, want)
+	}
+
+	// The STS token expires within the default expiry delta, so the next
+	// call refreshes it using the identity provider's refresh token.
+	tok, err = ts.Token()
+	if err != nil {
+		t.Fatalf("Token() failed: %v", err)
+	}
+	if got, want := tok.AccessToken, "sts-refreshed-id-token"; got != want {
+		t.Errorf("AccessToken = %q, want %q", got, want)
+	}
+	if idp.deviceTokens != 1 || idp.refreshes != 1 {
+		t.Errorf("device sign-ins = %d, refreshes = %d; want 1 and 1", idp.deviceTokens, idp.refreshes)
+	}
+}
+
+func TestDeviceSubjectToken_Denied(t *testing.T) {
+	setPollInterval(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/device" {
+			w.Write([]byte(`{"device_code":"dev","user_code":"code","verification_uri":"https://idp.example.com/device"}`))
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"access_denied"}`))
+	}))
+	defer server.Close()
+
+	c := &DeviceConfig{
+		DeviceAuthURL: server.URL + "/device",
+		TokenURL:      server.URL + "/token",
+	}
+	if _, err := subjectToken(context.Background(), c, func(*DeviceAuthResponse) error { return nil }); err == nil {
+		t.Error("subjectToken() succeeded after access was denied")
+	}
+}
+
+func TestDeviceSubjectToken_VerificationURIComplete(t *testing.T) {
+	setPollInterval(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/device" {
+			w.Write([]byte(`{"device_code":"dev","user_code":"WDJB-MJHT","verification_uri":"https://idp.example.com/device","verification_uri_complete":"https://idp.example.com/device?user_code=WDJB-MJHT"}`))
+			return
+		}
+		w.Write([]byte(`{"id_token":"device-id-token"}`))
+	}))
+	defer server.Close()
+
+	c := &DeviceConfig{
+		DeviceAuthURL: server.URL + "/device",
+		TokenURL:      server.URL + "/token",
+	}
+	var shown *DeviceAuthResponse
+	if _, err := subjectToken(context.Background(), c, func(resp *DeviceAuthResponse) error {
+		shown = resp
+		return nil
+	}); err != nil {
+		t.Fatalf("subjectToken() failed: %v", err)
+	}
+	want := "https://idp.example.com/device?user_code=WDJB-MJHT"
+	if got := shown.VerificationURIComplete; got != want {
+		t.Errorf("VerificationURIComplete = %q, want %q", got, want)
+	}
+	if got := shown.QRCodeContent(); got != want {
+		t.Errorf("QRCodeContent() = %q, want %q", got, want)
+	}
+}
+
+func TestDeviceAuthResponse_QRCodeContent(t *testing.T) {
+	resp := &DeviceAuthResponse{VerificationURI: "https://idp.example.com/device", UserCode: "WDJB-MJHT"}
+	if got, want := resp.QRCodeContent(), "https://idp.example.com/device"; got != want {
+		t.Errorf("QRCodeContent() = %q, want %q", got, want)
+	}
+}
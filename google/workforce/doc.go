@@ -0,0 +1,14 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package workforce provides helpers for signing users in with workforce
+// identity federation when no credential configuration file or local
+// browser integration is available.
+//
+// The helpers in this package obtain a subject token from the workforce
+// pool's identity provider. The resulting token can be exchanged for a Google
+// Cloud access token through the Security Token Service.
+// For more information on workforce identity federation, refer to
+// https://cloud.google.com/iam/docs/workforce-identity-federation.
+package workforce // import "golang.org/x/oauth2/google/workforce"
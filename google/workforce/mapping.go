@@ -0,0 +1,408 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package workforce
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxSubjectLength is the longest google.subject value accepted by the
+// security token service, in bytes.
+const maxSubjectLength = 127
+
+// MappingPreview is the result of evaluating an attribute mapping locally
+// with PreviewAttributeMapping.
+type MappingPreview struct {
+	// Values holds the value of every mapped attribute that was evaluated
+	// successfully, keyed like the mapping, e.g. "google.subject" or
+	// "attribute.department". Values are strings or lists of strings.
+	Values map[string]interface{}
+
+	// Errors holds the evaluation error of every other attribute, and of
+	// google.subject if the mapping lacks it.
+	Errors map[string]error
+}
+
+// Subject returns the mapped google.subject, or "" if it could not be
+// evaluated.
+func (p *MappingPreview) Subject() string {
+	s, _ := p.Values["google.subject"].(string)
+	return s
+}
+
+// PreviewAttributeMapping evaluates the attribute mapping of a workforce
+// pool provider against the claims of subjectToken, an OIDC ID token, so that
+// "principal does not match binding" errors can be debugged without calling
+// the security token service. The token's signature is not verified.
+//
+// Only a subset of the Common Expression Language is supported: the
+// assertion variable, field selection (assertion.sub), indexing
+// (assertion['groups'], assertion.groups[0]), string literals, the +
+// operator on strings and lists, and the lowerAscii, upperAscii and
+// extract('prefix{x}suffix') string functions. Expressions using anything
+// else are reported in MappingPreview.Errors.
+func PreviewAttributeMapping(subjectToken string, mapping map[string]string) (*MappingPreview, error) {
+	claims, err := decodeClaims(subjectToken)
+	if err != nil {
+		return nil, err
+	}
+	p := &MappingPreview{Values: make(map[string]interface{}), Errors: make(map[string]error)}
+	for key, expr := range mapping {
+		v, err := evalMapping(key, expr, claims)
+		if err != nil {
+			p.Errors[key] = err
+			continue
+		}
+		p.Values[key] = v
+	}
+	if _, ok := mapping["google.subject"]; !ok {
+		p.Errors["google.subject"] = errors.New("google.subject must be mapped")
+	}
+	return p, nil
+}
+
+// decodeClaims returns the claims of the JWT token without verifying it.
+func decodeClaims(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("oauth2/google/workforce: subject token is not a JWT")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, fmt.Errorf("oauth2/google/workforce: malformed subject token: %v", err)
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(b, &claims); err != nil {
+		return nil, fmt.Errorf("oauth2/google/workforce: malformed subject token: %v", err)
+	}
+	return claims, nil
+}
+
+func evalMapping(key, expr string, claims map[string]interface{}) (interface{}, error) {
+	switch {
+	case key == "google.subject", key == "google.display_name", key == "google.profile_photo", key == "google.posix_username":
+	case key == "google.groups":
+	case strings.HasPrefix(key, "attribute.") && len(key) > len("attribute."):
+	default:
+		return nil, fmt.Errorf("unsupported attribute %q", key)
+	}
+	p := &exprParser{src: expr, assertion: claims}
+	v, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	switch key {
+	case "google.groups":
+		list, err := stringList(v)
+		if err != nil {
+			return nil, fmt.Errorf("google.groups must be a list of strings: %v", err)
+		}
+		return list, nil
+	case "google.subject":
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("google.subject must be a string, got %T", v)
+		}
+		if len(s) > maxSubjectLength {
+			return nil, fmt.Errorf("google.subject is %d bytes long, the limit is %d", len(s), maxSubjectLength)
+		}
+		return s, nil
+	}
+	if list, err := stringList(v); err == nil {
+		return list, nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a string or a list of strings, got %T", key, v)
+	}
+	return s, nil
+}
+
+func stringList(v interface{}) ([]string, error) {
+	l, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("got %T", v)
+	}
+	list := make([]string, len(l))
+	for i, e := range l {
+		s, ok := e.(string)
+		if !ok {
+			return nil, fmt.Errorf("element %d is %T", i, e)
+		}
+		list[i] = s
+	}
+	return list, nil
+}
+
+// exprParser evaluates the supported subset of CEL while parsing it.
+type exprParser struct {
+	src       string
+	pos       int
+	assertion map[string]interface{}
+}
+
+func (p *exprParser) parse() (interface{}, error) {
+	v, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos != len(p.src) {
+		return nil, fmt.Errorf("unexpected %q at offset %d", p.src[p.pos:], p.pos)
+	}
+	return v, nil
+}
+
+// sum parses term ('+' term)*.
+func (p *exprParser) sum() (interface{}, error) {
+	v, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for p.consume('+') {
+		w, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		switch a := v.(type) {
+		case string:
+			b, ok := w.(string)
+			if !ok {
+				return nil, fmt.Errorf("cannot add %T to a string", w)
+			}
+			v = a + b
+		case []interface{}:
+			b, ok := w.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("cannot add %T to a list", w)
+			}
+			v = append(append([]interface{}(nil), a...), b...)
+		default:
+			return nil, fmt.Errorf("cannot add %T values", v)
+		}
+	}
+	return v, nil
+}
+
+// term parses a primary expression followed by selections, indexes and
+// method calls.
+func (p *exprParser) term() (interface{}, error) {
+	v, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.consume('.'):
+			name := p.ident()
+			if name == "" {
+				return nil, fmt.Errorf("expected field name at offset %d", p.pos)
+			}
+			if p.consume('(') {
+				if v, err = p.call(name, v); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if v, err = selectField(v, name); err != nil {
+				return nil, err
+			}
+		case p.consume('['):
+			idx, err := p.sum()
+			if err != nil {
+				return nil, err
+			}
+			if !p.consume(']') {
+				return nil, fmt.Errorf("expected ] at offset %d", p.pos)
+			}
+			if v, err = index(v, idx); err != nil {
+				return nil, err
+			}
+		default:
+			return v, nil
+		}
+	}
+}
+
+func (p *exprParser) primary() (interface{}, error) {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return nil, errors.New("unexpected end of expression")
+	}
+	switch c := p.src[p.pos]; {
+	case c == '\'' || c == '"':
+		return p.str()
+	case c >= '0' && c <= '9':
+		start := p.pos
+		for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+			p.pos++
+		}
+		return strconv.ParseFloat(p.src[start:p.pos], 64)
+	case c == '(':
+		p.pos++
+		v, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume(')') {
+			return nil, fmt.Errorf("expected ) at offset %d", p.pos)
+		}
+		return v, nil
+	}
+	if name := p.ident(); name == "assertion" {
+		return p.assertion, nil
+	} else if name != "" {
+		return nil, fmt.Errorf("unsupported identifier %q", name)
+	}
+	return nil, fmt.Errorf("unexpected %q at offset %d", p.src[p.pos:], p.pos)
+}
+
+// call applies the string function name to v. The opening parenthesis has
+// been consumed.
+func (p *exprParser) call(name string, v interface{}) (interface{}, error) {
+	var args []interface{}
+	if !p.consume(')') {
+		for {
+			arg, err := p.sum()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if p.consume(')') {
+				break
+			}
+			if !p.consume(',') {
+				return nil, fmt.Errorf("expected , or ) at offset %d", p.pos)
+			}
+		}
+	}
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("%s() called on %T, want string", name, v)
+	}
+	switch {
+	case name == "lowerAscii" && len(args) == 0:
+		return strings.ToLower(s), nil
+	case name == "upperAscii" && len(args) == 0:
+		return strings.ToUpper(s), nil
+	case name == "extract" && len(args) == 1:
+		tmpl, ok := args[0].(string)
+		if !ok {
+			return nil, errors.New("extract() template must be a string")
+		}
+		return extract(s, tmpl)
+	}
+	return nil, fmt.Errorf("unsupported function %s with %d arguments", name, len(args))
+}
+
+// extract implements the extract function of IAM attribute mappings: it
+// returns the part of s matched by the single {placeholder} of tmpl, or ""
+// if s does not match.
+func extract(s, tmpl string) (string, error) {
+	open, close := strings.Index(tmpl, "{"), strings.Index(tmpl, "}")
+	if open < 0 || close < open {
+		return "", fmt.Errorf("extract() template %q has no {placeholder}", tmpl)
+	}
+	prefix, suffix := tmpl[:open], tmpl[close+1:]
+	i := strings.Index(s, prefix)
+	if i < 0 {
+		return "", nil
+	}
+	rest := s[i+len(prefix):]
+	if suffix == "" {
+		return rest, nil
+	}
+	j := strings.Index(rest, suffix)
+	if j < 0 {
+		return "", nil
+	}
+	return rest[:j], nil
+}
+
+func selectField(v interface{}, name string) (interface{}, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("cannot select %q from %T", name, v)
+	}
+	f, ok := m[name]
+	if !ok {
+		return nil, fmt.Errorf("no such key: %s", name)
+	}
+	return f, nil
+}
+
+func index(v, idx interface{}) (interface{}, error) {
+	switch c := v.(type) {
+	case map[string]interface{}:
+		key, ok := idx.(string)
+		if !ok {
+			return nil, fmt.Errorf("map index must be a string, got %T", idx)
+		}
+		return selectField(c, key)
+	case []interface{}:
+		f, ok := idx.(float64)
+		if !ok || f != float64(int(f)) {
+			return nil, fmt.Errorf("list index must be an integer, got %v", idx)
+		}
+		if i := int(f); i >= 0 && i < len(c) {
+			return c[i], nil
+		}
+		return nil, fmt.Errorf("index %v out of range", idx)
+	}
+	return nil, fmt.Errorf("cannot index %T", v)
+}
+
+func (p *exprParser) str() (interface{}, error) {
+	quote := p.src[p.pos]
+	p.pos++
+	var b strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		p.pos++
+		switch {
+		case c == quote:
+			return b.String(), nil
+		case c == '\\' && p.pos < len(p.src):
+			b.WriteByte(p.src[p.pos])
+			p.pos++
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return nil, errors.New("unterminated string literal")
+}
+
+func (p *exprParser) ident() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || p.pos > start && c >= '0' && c <= '9' {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *exprParser) consume(c byte) bool {
+	p.skipSpace()
+	if p.pos < len(p.src) && p.src[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t' || p.src[p.pos] == '\n') {
+		p.pos++
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package workforce
+
+import (
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testIDToken(claims string) string {
+	return "eyJhbGciOiJub25lIn0." + base64.RawURLEncoding.EncodeToString([]byte(claims)) + ".sig"
+}
+
+func TestPreviewAttributeMapping(t *testing.T) {
+	token := testIDToken(`{"sub":"1234","email":"Jane@Example.com","groups":["eng","ops"],"claims":{"dept":"R&D"}}`)
+	p, err := PreviewAttributeMapping(token, map[string]string{
+		"google.subject":       "assertion.sub",
+		"google.display_name":  `assertion.email.extract('{user}@').lowerAscii()`,
+		"google.groups":        "assertion.groups + ['all']",
+		"attribute.department": `"dept:" + assertion.claims['dept']`,
+		"attribute.first":      "assertion.groups[0]",
+		"attribute.missing":    "assertion.nickname",
+		"attribute.bad":        "request.time",
+	})
+	if err != nil {
+		t.Fatalf("PreviewAttributeMapping() failed: %v", err)
+	}
+	if got, want := p.Subject(), "1234"; got != want {
+		t.Errorf("got subject %v but want %v", got, want)
+	}
+	want := map[string]interface{}{
+		"google.subject":       "1234",
+		"google.display_name":  "jane",
+		"attribute.department": "dept:R&D",
+		"attribute.first":      "eng",
+	}
+	for k, v := range want {
+		if p.Values[k] != v {
+			t.Errorf("got %s = %v but want %v", k, p.Values[k], v)
+		}
+	}
+	if _, ok := p.Values["google.groups"]; ok {
+		t.Errorf("got google.groups = %v but want an error for the unsupported list literal", p.Values["google.groups"])
+	}
+	for _, k := range []string{"google.groups", "attribute.missing", "attribute.bad"} {
+		if p.Errors[k] == nil {
+			t.Errorf("got no error for %s", k)
+		}
+	}
+}
+
+func TestPreviewAttributeMapping_Groups(t *testing.T) {
+	token := testIDToken(`{"sub":"` + strings.Repeat("x", 200) + `","groups":["eng","ops"]}`)
+	p, err := PreviewAttributeMapping(token, map[string]string{
+		"google.subject": "assertion.sub",
+		"google.groups":  "assertion.groups",
+	})
+	if err != nil {
+		t.Fatalf("PreviewAttributeMapping() failed: %v", err)
+	}
+	if got, want := p.Values["google.groups"], []string{"eng", "ops"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v but want %v", got, want)
+	}
+	if p.Errors["google.subject"] == nil {
+		t.Error("got no error for a subject over the length limit")
+	}
+	if _, err := PreviewAttributeMapping("not-a-jwt", nil); err == nil {
+		t.Error("PreviewAttributeMapping() with a malformed token succeeded, want error")
+	}
+}
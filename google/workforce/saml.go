@@ -0,0 +1,248 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package workforce
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// SAMLTokenType is the subject token type of SAML 2.0 responses.
+const SAMLTokenType = "urn:ietf:params:oauth:token-type:saml2"
+
+const (
+	samlPOSTBinding       = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+	defaultSAMLListenAddr = "127.0.0.1:0"
+)
+
+// SAMLConfig describes a service-provider-initiated SAML 2.0 sign-in with a
+// workforce pool provider's identity provider.
+type SAMLConfig struct {
+	// IdPSSOURL is the single sign-on service URL of the identity provider.
+	// Required.
+	IdPSSOURL string
+
+	// EntityID is the service provider entity ID registered with the identity
+	// provider, typically
+	// https://iam.googleapis.com/locations/global/workforcePools/POOL_ID/providers/PROVIDER_ID.
+	// Required.
+	EntityID string
+
+	// AssertionConsumerServiceURL is the URL the identity provider posts the
+	// SAMLResponse to. When empty, SubjectToken uses the address of its local
+	// listener. Optional.
+	AssertionConsumerServiceURL string
+
+	// ListenAddr is the local address SubjectToken listens on for the
+	// SAMLResponse. The default is "127.0.0.1:0", which picks a free port.
+	// Optional.
+	ListenAddr string
+}
+
+// SAMLHandler presents authURL to the user, typically by opening it in a
+// browser, so that they can sign in with the identity provider.
+type SAMLHandler func(authURL string) error
+
+// authnRequest is the SAML 2.0 AuthnRequest protocol message.
+type authnRequest struct {
+	XMLName                     xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:protocol AuthnRequest"`
+	ID                          string   `xml:"ID,attr"`
+	Version                     string   `xml:"Version,attr"`
+	IssueInstant                string   `xml:"IssueInstant,attr"`
+	Destination                 string   `xml:"Destination,attr"`
+	AssertionConsumerServiceURL string   `xml:"AssertionConsumerServiceURL,attr"`
+	ProtocolBinding             string   `xml:"ProtocolBinding,attr"`
+	Issuer                      string   `xml:"urn:oasis:names:tc:SAML:2.0:assertion Issuer"`
+}
+
+// samlResponse holds the fields of a SAML 2.0 Response that are checked
+// before it is handed out as a subject token.
+type samlResponse struct {
+	InResponseTo string `xml:"InResponseTo,attr"`
+}
+
+// AuthnRequestURL returns the URL that starts the sign-in, carrying an
+// AuthnRequest with the given ID through the HTTP-Redirect binding. The
+// identity provider is asked to post its response to
+// c.AssertionConsumerServiceURL, which must be set. relayState is returned
+// unchanged by the identity provider and should be checked with
+// ParseSAMLResponse.
+func (c *SAMLConfig) AuthnRequestURL(id, relayState string) (string, error) {
+	if c.AssertionConsumerServiceURL == "" {
+		return "", errors.New("oauth2/google/workforce: missing assertion consumer service URL")
+	}
+	return c.authnRequestURL(id, relayState, c.AssertionConsumerServiceURL)
+}
+
+func (c *SAMLConfig) authnRequestURL(id, relayState, acsURL string) (string, error) {
+	if c.IdPSSOURL == "" || c.EntityID == "" {
+		return "", errors.New("oauth2/google/workforce: IdPSSOURL and EntityID are required")
+	}
+	req := authnRequest{
+		ID:                          id,
+		Version:                     "2.0",
+		IssueInstant:                time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+		Destination:                 c.IdPSSOURL,
+		AssertionConsumerServiceURL: acsURL,
+		ProtocolBinding:             samlPOSTBinding,
+		Issuer:                      c.EntityID,
+	}
+	b, err := xml.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("oauth2/google/workforce: unable to marshal AuthnRequest: %v", err)
+	}
+
+	// The HTTP-Redirect binding requires the message to be DEFLATE encoded.
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	if err != nil {
+		return "", err
+	}
+	if _, err := w.Write(b); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	v := url.Values{}
+	v.Set("SAMLRequest", base64.StdEncoding.EncodeToString(buf.Bytes()))
+	if relayState != "" {
+		v.Set("RelayState", relayState)
+	}
+	sep := "?"
+	if strings.Contains(c.IdPSSOURL, "?") {
+		sep = "&"
+	}
+	return c.IdPSSOURL + sep + v.Encode(), nil
+}
+
+// ParseSAMLResponse extracts the SAMLResponse posted by the identity provider
+// to an assertion consumer service through the HTTP-POST binding. It can be
+// used by callers that serve the assertion consumer service themselves.
+//
+// relayState must match the value passed to AuthnRequestURL. If requestID is
+// not empty, the InResponseTo attribute of the response must equal it, so
+// that unsolicited responses are rejected.
+// The returned value is the base64-encoded response, suitable as a subject
+// token of type SAMLTokenType.
+func ParseSAMLResponse(r *http.Request, requestID, relayState string) (string, error) {
+	if r.Method != "POST" {
+		return "", fmt.Errorf("oauth2/google/workforce: unexpected %s request to assertion consumer service", r.Method)
+	}
+	if err := r.ParseForm(); err != nil {
+		return "", fmt.Errorf("oauth2/google/workforce: unable to parse SAML response form: %v", err)
+	}
+	if got := r.PostForm.Get("RelayState"); got != relayState {
+		return "", errors.New("oauth2/google/workforce: RelayState mismatch in SAML response")
+	}
+	encoded := strings.TrimSpace(r.PostForm.Get("SAMLResponse"))
+	if encoded == "" {
+		return "", errors.New("oauth2/google/workforce: missing SAMLResponse")
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("oauth2/google/workforce: invalid SAMLResponse encoding: %v", err)
+	}
+	var resp samlResponse
+	if err := xml.Unmarshal(decoded, &resp); err != nil {
+		return "", fmt.Errorf("oauth2/google/workforce: invalid SAMLResponse: %v", err)
+	}
+	if requestID != "" && resp.InResponseTo != requestID {
+		return "", errors.New("oauth2/google/workforce: SAMLResponse was not issued for this request")
+	}
+	return encoded, nil
+}
+
+// SubjectToken drives a complete sign-in. It starts a local assertion
+// consumer service, passes the sign-in URL to handler and waits until the
+// identity provider posts the SAMLResponse back or ctx is done.
+//
+// The returned value is a subject token of type SAMLTokenType.
+func (c *SAMLConfig) SubjectToken(ctx context.Context, handler SAMLHandler) (string, error) {
+	id, err := randomHex()
+	if err != nil {
+		return "", err
+	}
+	id = "_" + id // IDs must not start with a digit.
+	relayState, err := randomHex()
+	if err != nil {
+		return "", err
+	}
+
+	addr := c.ListenAddr
+	if addr == "" {
+		addr = defaultSAMLListenAddr
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", fmt.Errorf("oauth2/google/workforce: unable to start assertion consumer service: %v", err)
+	}
+	acsURL := c.AssertionConsumerServiceURL
+	if acsURL == "" {
+		acsURL = "http://" + l.Addr().String() + "/"
+	}
+	authURL, err := c.authnRequestURL(id, relayState, acsURL)
+	if err != nil {
+		l.Close()
+		return "", err
+	}
+
+	type result struct {
+		token string
+		err   error
+	}
+	results := make(chan result, 1)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Other requests, such as the browser asking for a favicon, must
+		// not end the sign-in.
+		if r.Method != "POST" || r.FormValue("SAMLResponse") == "" {
+			http.NotFound(w, r)
+			return
+		}
+		token, err := ParseSAMLResponse(r, id, relayState)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			fmt.Fprintln(w, "Sign-in complete. You may close this window.")
+		}
+		select {
+		case results <- result{token, err}:
+		default:
+		}
+	})}
+	go srv.Serve(l)
+	defer srv.Close()
+
+	if err := handler(authURL); err != nil {
+		return "", err
+	}
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case res := <-results:
+		return res.token, res.err
+	}
+}
+
+func randomHex() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("oauth2/google/workforce: unable to generate random value: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,148 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package workforce
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func decodeAuthnRequest(t *testing.T, authURL string) (authnRequest, url.Values) {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("url.Parse(%q) failed: %v", authURL, err)
+	}
+	q := u.Query()
+	compressed, err := base64.StdEncoding.DecodeString(q.Get("SAMLRequest"))
+	if err != nil {
+		t.Fatalf("SAMLRequest is not base64 encoded: %v", err)
+	}
+	b, err := ioutil.ReadAll(flate.NewReader(bytes.NewReader(compressed)))
+	if err != nil {
+		t.Fatalf("SAMLRequest is not DEFLATE encoded: %v", err)
+	}
+	var req authnRequest
+	if err := xml.Unmarshal(b, &req); err != nil {
+		t.Fatalf("SAMLRequest is not an AuthnRequest: %v", err)
+	}
+	return req, q
+}
+
+func TestAuthnRequestURL(t *testing.T) {
+	c := &SAMLConfig{
+		IdPSSOURL:                   "https://idp.example.com/sso?tenant=1",
+		EntityID:                    "https://iam.googleapis.com/locations/global/workforcePools/pool/providers/provider",
+		AssertionConsumerServiceURL: "https://app.example.com/acs",
+	}
+	authURL, err := c.AuthnRequestURL("_abc", "state")
+	if err != nil {
+		t.Fatalf("AuthnRequestURL() failed: %v", err)
+	}
+	if !strings.HasPrefix(authURL, "https://idp.example.com/sso?tenant=1&") {
+		t.Errorf("AuthnRequestURL() = %q, want it to extend the IdP URL", authURL)
+	}
+	req, q := decodeAuthnRequest(t, authURL)
+	if got, want := q.Get("RelayState"), "state"; got != want {
+		t.Errorf("RelayState = %q, want %q", got, want)
+	}
+	if req.ID != "_abc" || req.Issuer != c.EntityID || req.AssertionConsumerServiceURL != c.AssertionConsumerServiceURL || req.ProtocolBinding != samlPOSTBinding {
+		t.Errorf("unexpected AuthnRequest: %+v", req)
+	}
+}
+
+func TestAuthnRequestURL_MissingACS(t *testing.T) {
+	c := &SAMLConfig{IdPSSOURL: "https://idp.example.com/sso", EntityID: "sp"}
+	if _, err := c.AuthnRequestURL("_abc", "state"); err == nil {
+		t.Error("AuthnRequestURL() succeeded without an assertion consumer service URL")
+	}
+}
+
+func TestParseSAMLResponse(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString([]byte(`<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" InResponseTo="_abc"></samlp:Response>`))
+	tests := []struct {
+		name       string
+		form       url.Values
+		requestID  string
+		relayState string
+		wantErr    bool
+	}{
+		{"valid", url.Values{"SAMLResponse": {encoded}, "RelayState": {"state"}}, "_abc", "state", false},
+		{"relay state mismatch", url.Values{"SAMLResponse": {encoded}, "RelayState": {"other"}}, "_abc", "state", true},
+		{"request mismatch", url.Values{"SAMLResponse": {encoded}, "RelayState": {"state"}}, "_xyz", "state", true},
+		{"unsolicited", url.Values{"SAMLResponse": {base64.StdEncoding.EncodeToString([]byte(`<Response></Response>`))}, "RelayState": {"state"}}, "_abc", "state", true},
+		{"missing response", url.Values{"RelayState": {"state"}}, "_abc", "state", true},
+		{"not base64", url.Values{"SAMLResponse": {"%%%"}, "RelayState": {"state"}}, "_abc", "state", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/acs", strings.NewReader(tt.form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			got, err := ParseSAMLResponse(r, tt.requestID, tt.relayState)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseSAMLResponse() = %q, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSAMLResponse() failed: %v", err)
+			}
+			if got != encoded {
+				t.Errorf("ParseSAMLResponse() = %q, want %q", got, encoded)
+			}
+		})
+	}
+}
+
+func TestSAMLSubjectToken(t *testing.T) {
+	c := &SAMLConfig{
+		IdPSSOURL: "https://idp.example.com/sso",
+		EntityID:  "sp",
+	}
+	var want string
+	// The handler plays the role of the browser and the identity provider.
+	handler := func(authURL string) error {
+		req, q := decodeAuthnRequest(t, authURL)
+		want = base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf(`<Response InResponseTo=%q></Response>`, req.ID)))
+		go func() {
+			// Stray requests are ignored.
+			if resp, err := http.Get(req.AssertionConsumerServiceURL + "favicon.ico"); err == nil {
+				resp.Body.Close()
+			}
+			resp, err := http.PostForm(req.AssertionConsumerServiceURL, url.Values{
+				"SAMLResponse": {want},
+				"RelayState":   {q.Get("RelayState")},
+			})
+			if err != nil {
+				t.Errorf("posting SAMLResponse failed: %v", err)
+				return
+			}
+			resp.Body.Close()
+		}()
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	got, err := c.SubjectToken(ctx, handler)
+	if err != nil {
+		t.Fatalf("SubjectToken() failed: %v", err)
+	}
+	if got != want {
+		t.Errorf("SubjectToken() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth2
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// HostTransport is an http.RoundTripper that authorizes requests with the
+// TokenSource registered for their host, so that a single client can call
+// many OAuth-protected hosts with different credentials. Like an
+// http.CookieJar scopes cookies, sources are registered and looked up by
+// URL at run time, and requests to hosts without one are sent unauthorized.
+//
+// Sources apply to their exact host, regardless of port, and not to its
+// subdomains. A source registered with an https URL is only used for https
+// requests, so that its tokens are not sent in the clear.
+//
+// The zero value is ready to use. A HostTransport is safe for concurrent
+// use.
+type HostTransport struct {
+	// Base is the base RoundTripper used to make HTTP requests.
+	// If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+
+	mu      sync.RWMutex
+	sources map[string]hostSource // keyed by lowercase host name
+}
+
+type hostSource struct {
+	src    TokenSource
+	secure bool
+}
+
+// SetTokenSource registers src for the host of u, replacing any source
+// registered for it. A nil src removes the source of the host.
+func (t *HostTransport) SetTokenSource(u *url.URL, src TokenSource) {
+	host := strings.ToLower(u.Hostname())
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if src == nil {
+		delete(t.sources, host)
+		return
+	}
+	if t.sources == nil {
+		t.sources = make(map[string]hostSource)
+	}
+	t.sources[host] = hostSource{src: src, secure: u.Scheme == "https"}
+}
+
+// TokenSource returns the source authorizing requests to u, or nil if there
+// is none.
+func (t *HostTransport) TokenSource(u *url.URL) TokenSource {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	hs, ok := t.sources[strings.ToLower(u.Hostname())]
+	if !ok || (hs.secure && u.Scheme != "https") {
+		return nil
+	}
+	return hs.src
+}
+
+// RoundTrip authorizes the request with a token from the source of its host,
+// if any, and sends it with Base.
+func (t *HostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	src := t.TokenSource(req.URL)
+	if src == nil {
+		return t.base().RoundTrip(req)
+	}
+	return (&Transport{Source: src, Base: t.Base}).RoundTrip(req)
+}
+
+func (t *HostTransport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
@@ -0,0 +1,72 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth2
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestHostTransport(t *testing.T) {
+	server := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization")))
+	})
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr := &HostTransport{}
+	client := &http.Client{Transport: tr}
+	get := func() string {
+		t.Helper()
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Get() failed: %v", err)
+		}
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		return string(b)
+	}
+	if got := get(); got != "" {
+		t.Errorf("got authorization %q for an unregistered host but want none", got)
+	}
+	tr.SetTokenSource(&url.URL{Scheme: "http", Host: u.Hostname() + ":1"}, StaticTokenSource(&Token{AccessToken: "a"}))
+	if got, want := get(), "Bearer a"; got != want {
+		t.Errorf("got authorization %q but want %q", got, want)
+	}
+	tr.SetTokenSource(u, nil)
+	if got := get(); got != "" {
+		t.Errorf("got authorization %q for a removed source but want none", got)
+	}
+}
+
+func TestHostTransport_TokenSource(t *testing.T) {
+	var tr HostTransport
+	src := StaticTokenSource(&Token{AccessToken: "a"})
+	tr.SetTokenSource(&url.URL{Scheme: "https", Host: "API.example.com"}, src)
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://api.example.com/v1", true},
+		{"https://api.example.com:8443/v1", true},
+		{"http://api.example.com/v1", false},
+		{"https://sub.api.example.com/v1", false},
+		{"https://example.com/v1", false},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := tr.TokenSource(u) != nil; got != tt.want {
+			t.Errorf("TokenSource(%s) found = %v; want %v", tt.url, got, tt.want)
+		}
+	}
+}
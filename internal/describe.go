@@ -0,0 +1,23 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package internal
+
+import "strings"
+
+// DescribeParams formats the key parameters of a token source description as
+// name(key=value, ...), omitting empty values. URL values should be
+// redacted with RedactURL first.
+func DescribeParams(name string, keyValues ...string) string {
+	var params []string
+	for i := 0; i+1 < len(keyValues); i += 2 {
+		if keyValues[i+1] != "" {
+			params = append(params, keyValues[i]+"="+keyValues[i+1])
+		}
+	}
+	if len(params) == 0 {
+		return name
+	}
+	return name + "(" + strings.Join(params, ", ") + ")"
+}
@@ -0,0 +1,32 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package internal
+
+import "strings"
+
+// NormalizeScopes returns scopes without empty and duplicate scopes, in
+// their original order since some servers depend on it even though RFC
+// 6749, section 3.3 gives it no meaning. scopes is not modified.
+func NormalizeScopes(scopes []string) []string {
+	if len(scopes) == 0 {
+		return scopes
+	}
+	out := make([]string, 0, len(scopes))
+	seen := make(map[string]bool, len(scopes))
+	for _, s := range scopes {
+		if s == "" || seen[s] {
+			continue
+		}
+		seen[s] = true
+		out = append(out, s)
+	}
+	return out
+}
+
+// ScopeString returns the normalized scopes joined by spaces, as sent in
+// the scope parameter of requests.
+func ScopeString(scopes []string) string {
+	return strings.Join(NormalizeScopes(scopes), " ")
+}
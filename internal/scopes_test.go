@@ -0,0 +1,27 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeScopes(t *testing.T) {
+	in := []string{"write", "read", "", "write", "admin"}
+	got := NormalizeScopes(in)
+	if want := []string{"write", "read", "admin"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q but want %q", got, want)
+	}
+	if want := []string{"write", "read", "", "write", "admin"}; !reflect.DeepEqual(in, want) {
+		t.Errorf("NormalizeScopes modified its argument to %q", in)
+	}
+	if got, want := ScopeString([]string{"b", "a", "b"}), "b a"; got != want {
+		t.Errorf("got %q but want %q", got, want)
+	}
+	if got := NormalizeScopes(nil); got != nil {
+		t.Errorf("got %q for nil scopes but want nil", got)
+	}
+}
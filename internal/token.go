@@ -52,6 +52,13 @@ type Token struct {
 	// Raw optionally contains extra metadata from the server
 	// when updating a token.
 	Raw interface{}
+
+	// IssuedAt is the time the token endpoint response was received.
+	IssuedAt time.Time
+
+	// StatusCode and Header are those of the token endpoint response.
+	StatusCode int
+	Header     http.Header
 }
 
 // tokenJSON is the struct representing the HTTP response from OAuth2
@@ -113,6 +120,7 @@ const (
 	AuthStyleUnknown  AuthStyle = 0
 	AuthStyleInParams AuthStyle = 1
 	AuthStyleInHeader AuthStyle = 2
+	AuthStyleStrict   AuthStyle = 3
 )
 
 // authStyleCache is the set of tokenURLs we've successfully used via
@@ -189,9 +197,18 @@ func cloneURLValues(v url.Values) url.Values {
 	return v2
 }
 
-func RetrieveToken(ctx context.Context, clientID, clientSecret, tokenURL string, v url.Values, authStyle AuthStyle) (*Token, error) {
+func RetrieveToken(ctx context.Context, clientID, clientSecret, tokenURL string, v url.Values, authStyle AuthStyle) (_ *Token, err error) {
+	ctx, end := StartSpan(ctx, "oauth2.RetrieveToken")
+	defer func() {
+		end(err)
+		if l := ContextLogger(ctx); l != nil && err != nil {
+			l.Printf("oauth2: token request to %s failed: %s", RedactURL(tokenURL), logSafeError(err))
+		}
+	}()
 	needsAuthStyleProbe := authStyle == 0
-	if needsAuthStyleProbe {
+	if authStyle == AuthStyleStrict {
+		authStyle = AuthStyleInHeader
+	} else if needsAuthStyleProbe {
 		if style, ok := lookupAuthStyle(tokenURL); ok {
 			authStyle = style
 			needsAuthStyleProbe = false
@@ -232,11 +249,30 @@ func RetrieveToken(ctx context.Context, clientID, clientSecret, tokenURL string,
 	return token, err
 }
 
+// IsClientAuthError reports whether r rejects the client authentication
+// of a token request: a 401 response, or an invalid_client error.
+func IsClientAuthError(r *RetrieveError) bool {
+	return r.ErrorCode == "invalid_client" || r.Response != nil && r.Response.StatusCode == http.StatusUnauthorized
+}
+
+// logSafeError describes err without the response body of a RetrieveError,
+// which may echo credentials.
+func logSafeError(err error) string {
+	if re, ok := err.(*RetrieveError); ok {
+		if re.ErrorCode != "" {
+			return fmt.Sprintf("status %d, error %q", re.Response.StatusCode, re.ErrorCode)
+		}
+		return fmt.Sprintf("status %d", re.Response.StatusCode)
+	}
+	return err.Error()
+}
+
 func doTokenRoundTrip(ctx context.Context, req *http.Request) (*Token, error) {
 	r, err := ContextClient(ctx).Do(req.WithContext(ctx))
 	if err != nil {
 		return nil, err
 	}
+	issuedAt := time.Now()
 	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1<<20))
 	r.Body.Close()
 	if err != nil {
@@ -305,9 +341,42 @@ func doTokenRoundTrip(ctx context.Context, req *http.Request) (*Token, error) {
 	if token.AccessToken == "" {
 		return nil, errors.New("oauth2: server response missing access_token")
 	}
+	token.IssuedAt = issuedAt
+	token.StatusCode = r.StatusCode
+	token.Header = r.Header
 	return token, nil
 }
 
+// sensitiveResponseHeaders are the token endpoint response headers that
+// must not be logged.
+var sensitiveResponseHeaders = []string{"Set-Cookie", "Authorization", "Www-Authenticate"}
+
+// SanitizeHeader returns a copy of the token endpoint response header h
+// without cookies and authentication challenges, for logging.
+func SanitizeHeader(h http.Header) http.Header {
+	out := make(http.Header, len(h))
+	for k, v := range h {
+		out[k] = append([]string(nil), v...)
+	}
+	for _, k := range sensitiveResponseHeaders {
+		out.Del(k)
+	}
+	return out
+}
+
+// RedactURL returns s without user information, query and fragment, for
+// logging. Strings that are not URLs are returned as "<redacted>".
+func RedactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return "<redacted>"
+	}
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
+
 // mirrors oauth2.RetrieveError
 type RetrieveError struct {
 	Response         *http.Response
@@ -330,3 +399,20 @@ func (r *RetrieveError) Error() string {
 	}
 	return fmt.Sprintf("oauth2: cannot fetch token: %v\nResponse: %s", r.Response.Status, r.Body)
 }
+
+// RawResponse returns raw, the Raw field of a Token, as a map of the
+// response fields. Fields of form-encoded responses are mapped to their
+// first value. The map of JSON responses is returned as is.
+func RawResponse(raw interface{}) map[string]interface{} {
+	switch raw := raw.(type) {
+	case map[string]interface{}:
+		return raw
+	case url.Values:
+		m := make(map[string]interface{}, len(raw))
+		for k := range raw {
+			m[k] = raw.Get(k)
+		}
+		return m
+	}
+	return nil
+}
@@ -31,3 +31,55 @@ func ContextClient(ctx context.Context) *http.Client {
 	}
 	return http.DefaultClient
 }
+
+// Logger is implemented by *log.Logger. See oauth2.WithLogger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Tracer starts a span named name, returning the context of the span and a
+// function ending it with the operation's error. See oauth2.WithTracer.
+type Tracer interface {
+	StartSpan(ctx context.Context, name string) (context.Context, func(err error))
+}
+
+type loggerKey struct{}
+
+type tracerKey struct{}
+
+// WithLogger returns a copy of ctx carrying l.
+func WithLogger(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// ContextLogger returns the Logger carried by ctx, or nil.
+func ContextLogger(ctx context.Context) Logger {
+	if ctx == nil {
+		return nil
+	}
+	l, _ := ctx.Value(loggerKey{}).(Logger)
+	return l
+}
+
+// WithTracer returns a copy of ctx carrying t.
+func WithTracer(ctx context.Context, t Tracer) context.Context {
+	return context.WithValue(ctx, tracerKey{}, t)
+}
+
+// ContextTracer returns the Tracer carried by ctx, or nil.
+func ContextTracer(ctx context.Context) Tracer {
+	if ctx == nil {
+		return nil
+	}
+	t, _ := ctx.Value(tracerKey{}).(Tracer)
+	return t
+}
+
+// StartSpan starts a span with the Tracer carried by ctx. Without one, it
+// returns ctx and a no-op function.
+func StartSpan(ctx context.Context, name string) (context.Context, func(err error)) {
+	if t := ContextTracer(ctx); t != nil {
+		return t.StartSpan(ctx, name)
+	}
+	return ctx, func(error) {}
+}
@@ -0,0 +1,122 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth2
+
+import (
+	"context"
+	"time"
+)
+
+// defaultRetryInterval is how long a TokenIterator waits after a failed
+// retrieval when RetryInterval is not set.
+const defaultRetryInterval = 10 * time.Second
+
+// TokenIterator yields the tokens of a TokenSource as they are refreshed over
+// time. It suits daemons that push fresh tokens to other processes:
+//
+//	it := &oauth2.TokenIterator{Source: src}
+//	for {
+//		tok, err := it.Next(ctx)
+//		if err != nil {
+//			if ctx.Err() != nil {
+//				return
+//			}
+//			log.Print(err)
+//			continue
+//		}
+//		publish(tok)
+//	}
+//
+// A TokenIterator must not be used concurrently.
+type TokenIterator struct {
+	// Source provides the tokens. When it caches tokens, as ReuseTokenSource
+	// does, the iterator keeps asking until the cached token is replaced.
+	Source TokenSource
+
+	// RefreshBefore is how long before the expiry of the current token the
+	// next one is retrieved. The default is ten seconds.
+	RefreshBefore time.Duration
+
+	// RetryInterval is how long Next waits before retrying after a failed
+	// retrieval. The default is ten seconds.
+	RetryInterval time.Duration
+
+	last   *Token
+	failed bool
+}
+
+// Next returns the next token of the source. The first call returns a token
+// right away; later calls block until the previous token is about to expire
+// and the source returns a different one. A token without an expiry is
+// never replaced, so Next blocks until ctx is done.
+//
+// Errors of the source are returned, and the following call retries after
+// RetryInterval.
+func (it *TokenIterator) Next(ctx context.Context) (*Token, error) {
+	if it.failed {
+		if err := sleepContext(ctx, it.retryInterval()); err != nil {
+			return nil, err
+		}
+	}
+	for {
+		if it.last != nil {
+			if it.last.Expiry.IsZero() {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+			if err := sleepContext(ctx, it.last.Expiry.Add(-it.refreshBefore()).Sub(timeNow())); err != nil {
+				return nil, err
+			}
+		}
+		tok, err := it.Source.Token()
+		it.failed = err != nil
+		if err != nil {
+			return nil, err
+		}
+		if it.last != nil && tok.AccessToken == it.last.AccessToken {
+			// The source still serves the previous token. Wait until it
+			// has certainly expired before asking again.
+			wait := it.retryInterval()
+			if d := it.last.Expiry.Sub(timeNow()); d > 0 && d < wait {
+				wait = d
+			}
+			if err := sleepContext(ctx, wait); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		it.last = tok
+		return tok, nil
+	}
+}
+
+func (it *TokenIterator) refreshBefore() time.Duration {
+	if it.RefreshBefore > 0 {
+		return it.RefreshBefore
+	}
+	return defaultExpiryDelta
+}
+
+func (it *TokenIterator) retryInterval() time.Duration {
+	if it.RetryInterval > 0 {
+		return it.RetryInterval
+	}
+	return defaultRetryInterval
+}
+
+// sleepContext waits for d or until ctx is done, whichever comes first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTokenIterator(t *testing.T) {
+	n := 0
+	src := TokenSourceFunc(func() (*Token, error) {
+		n++
+		return &Token{AccessToken: fmt.Sprint("token-", n), Expiry: time.Now().Add(time.Second + 20*time.Millisecond)}, nil
+	})
+	it := &TokenIterator{Source: src, RefreshBefore: time.Second}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for i := 1; i <= 3; i++ {
+		tok, err := it.Next(ctx)
+		if err != nil {
+			t.Fatalf("Next() failed: %v", err)
+		}
+		if got, want := tok.AccessToken, fmt.Sprint("token-", i); got != want {
+			t.Errorf("got %v but want %v", got, want)
+		}
+	}
+}
+
+func TestTokenIterator_SameToken(t *testing.T) {
+	calls := 0
+	expiry := time.Now().Add(50 * time.Millisecond)
+	src := TokenSourceFunc(func() (*Token, error) {
+		calls++
+		if calls < 3 {
+			return &Token{AccessToken: "old", Expiry: expiry}, nil
+		}
+		return &Token{AccessToken: "new", Expiry: time.Now().Add(time.Hour)}, nil
+	})
+	it := &TokenIterator{Source: src, RefreshBefore: time.Hour, RetryInterval: 10 * time.Millisecond}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	it.Next(ctx)
+	tok, err := it.Next(ctx)
+	if err != nil {
+		t.Fatalf("Next() failed: %v", err)
+	}
+	if tok.AccessToken != "new" || calls != 3 {
+		t.Errorf("got %v after %d calls but want new after 3", tok.AccessToken, calls)
+	}
+}
+
+func TestTokenIterator_Errors(t *testing.T) {
+	errFetch := errors.New("unavailable")
+	src := &countingTokenSource{err: errFetch}
+	it := &TokenIterator{Source: src, RetryInterval: time.Hour}
+	if _, err := it.Next(context.Background()); err != errFetch {
+		t.Fatalf("got %v but want %v", err, errFetch)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := it.Next(ctx); err != context.DeadlineExceeded {
+		t.Errorf("got %v but want %v", err, context.DeadlineExceeded)
+	}
+	if src.calls != 1 {
+		t.Errorf("got %d calls but want 1 during the retry interval", src.calls)
+	}
+}
+
+func TestTokenIterator_NoExpiry(t *testing.T) {
+	it := &TokenIterator{Source: StaticTokenSource(&Token{AccessToken: "static"})}
+	if _, err := it.Next(context.Background()); err != nil {
+		t.Fatalf("Next() failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := it.Next(ctx); err != context.Canceled {
+		t.Errorf("got %v but want %v", err, context.Canceled)
+	}
+}
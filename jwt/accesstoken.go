@@ -0,0 +1,126 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jwt
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2/jws"
+)
+
+// accessTokenType is the typ header value of JWT access tokens, see RFC 9068
+// section 2.1.
+const accessTokenType = "at+jwt"
+
+// AccessTokenMinter mints JWT access tokens as described by RFC 9068, for
+// services that issue their own access tokens to other services.
+type AccessTokenMinter struct {
+	// Issuer is the iss claim of minted tokens, typically the issuer's URL.
+	// Required.
+	Issuer string
+
+	// Signer signs the tokens. Required unless Keys is set.
+	Signer jws.Signer
+
+	// Keys, when set, supplies the signing key and its kid instead of
+	// Signer and KeyID, so that minted tokens follow key rotation.
+	Keys *KeySet
+
+	// Algorithm is the JWS algorithm implemented by Signer. The default is
+	// RS256.
+	Algorithm string
+
+	// KeyID is the optional kid header identifying the signing key.
+	KeyID string
+
+	// Expires is the lifetime of minted tokens. The default is one hour.
+	Expires time.Duration
+}
+
+// AccessTokenClaims are the per-token claims of a JWT access token.
+type AccessTokenClaims struct {
+	// Subject is the sub claim: the resource owner, or the client itself
+	// when no resource owner is involved. Required.
+	Subject string
+
+	// Audience is the aud claim, identifying the resource server the token
+	// is intended for. Required.
+	Audience string
+
+	// ClientID is the client_id claim identifying the client the token was
+	// issued to. Required.
+	ClientID string
+
+	// Scopes are encoded in the scope claim. Optional.
+	Scopes []string
+
+	// PrivateClaims are additional claims. Optional.
+	PrivateClaims map[string]interface{}
+}
+
+// Mint returns a signed JWT access token carrying c. Each token is given a
+// random jti claim.
+func (m *AccessTokenMinter) Mint(c AccessTokenClaims) (string, error) {
+	signer, kid := m.Signer, m.KeyID
+	if m.Keys != nil {
+		var err error
+		if kid, signer, err = m.Keys.Signer(); err != nil {
+			return "", err
+		}
+	}
+	if m.Issuer == "" || signer == nil {
+		return "", errors.New("oauth2: AccessTokenMinter requires an Issuer and a Signer")
+	}
+	if c.Subject == "" || c.Audience == "" || c.ClientID == "" {
+		return "", errors.New("oauth2: JWT access tokens require a subject, an audience and a client ID")
+	}
+	jti, err := newJTI()
+	if err != nil {
+		return "", err
+	}
+	private := map[string]interface{}{}
+	for k, v := range c.PrivateClaims {
+		private[k] = v
+	}
+	private["client_id"] = c.ClientID
+	private["jti"] = jti
+
+	expires := m.Expires
+	if expires <= 0 {
+		expires = time.Hour
+	}
+	now := time.Now()
+	claimSet := &jws.ClaimSet{
+		Iss:           m.Issuer,
+		Sub:           c.Subject,
+		Aud:           c.Audience,
+		Scope:         strings.Join(c.Scopes, " "),
+		Iat:           now.Unix(),
+		Exp:           now.Add(expires).Unix(),
+		PrivateClaims: private,
+	}
+	h := &jws.Header{
+		Algorithm: m.Algorithm,
+		Typ:       accessTokenType,
+		KeyID:     kid,
+	}
+	if h.Algorithm == "" {
+		h.Algorithm = "RS256"
+	}
+	return jws.EncodeWithSigner(h, claimSet, signer)
+}
+
+func newJTI() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("oauth2: unable to generate jti: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jwt
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2/internal"
+	"golang.org/x/oauth2/jws"
+)
+
+func rsaSigner(key *rsa.PrivateKey) jws.Signer {
+	return func(data []byte) ([]byte, error) {
+		h := sha256.Sum256(data)
+		return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, h[:])
+	}
+}
+
+func TestAccessTokenMinter(t *testing.T) {
+	key, err := internal.ParseKey(dummyPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &AccessTokenMinter{
+		Issuer: "https://issuer.example.com",
+		Signer: rsaSigner(key),
+		KeyID:  "key-1",
+	}
+	tok, err := m.Mint(AccessTokenClaims{
+		Subject:       "user",
+		Audience:      "https://api.example.com",
+		ClientID:      "client",
+		Scopes:        []string{"read", "write"},
+		PrivateClaims: map[string]interface{}{"tenant": "t1"},
+	})
+	if err != nil {
+		t.Fatalf("Mint() failed: %v", err)
+	}
+	if err := jws.Verify(tok, &key.PublicKey); err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+
+	parts := strings.Split(tok, ".")
+	var header jws.Header
+	decodeSegment(t, parts[0], &header)
+	if want := (jws.Header{Algorithm: "RS256", Typ: "at+jwt", KeyID: "key-1"}); header != want {
+		t.Errorf("got header %+v but want %+v", header, want)
+	}
+	var claims map[string]interface{}
+	decodeSegment(t, parts[1], &claims)
+	for k, want := range map[string]interface{}{
+		"iss":       "https://issuer.example.com",
+		"sub":       "user",
+		"aud":       "https://api.example.com",
+		"client_id": "client",
+		"scope":     "read write",
+		"tenant":    "t1",
+	} {
+		if got := claims[k]; got != want {
+			t.Errorf("claim %s: got %v but want %v", k, got, want)
+		}
+	}
+	if jti, _ := claims["jti"].(string); jti == "" {
+		t.Error("missing jti claim")
+	}
+	if claims["exp"].(float64)-claims["iat"].(float64) != 3600 {
+		t.Errorf("got lifetime %v but want 3600", claims["exp"].(float64)-claims["iat"].(float64))
+	}
+}
+
+func TestAccessTokenMinter_MissingClaims(t *testing.T) {
+	m := &AccessTokenMinter{Issuer: "iss", Signer: func([]byte) ([]byte, error) { return nil, nil }}
+	if _, err := m.Mint(AccessTokenClaims{Subject: "user", Audience: "aud"}); err == nil {
+		t.Error("Mint() without client ID succeeded, want error")
+	}
+}
+
+func decodeSegment(t *testing.T, seg string, v interface{}) {
+	t.Helper()
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jwt
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2/jws"
+)
+
+const (
+	// assertionReuseMargin is how long before its expiry a cached assertion
+	// stops being reused, so that it does not expire in flight or while the
+	// token endpoint's clock is ahead.
+	assertionReuseMargin = 5 * time.Minute
+
+	// maxCachedAssertions bounds the size of the assertion cache.
+	maxCachedAssertions = 1024
+)
+
+// assertionKey identifies the signing key and claims of an assertion.
+type assertionKey [sha256.Size]byte
+
+// assertionKey returns the cache key of the assertion of c with the given
+// claims, or false if the claims cannot be keyed.
+func (c *Config) assertionKey(claims *jws.ClaimSet) (assertionKey, bool) {
+	b, err := json.Marshal(struct {
+		PrivateKey   []byte
+		PrivateKeyID string
+		Expires      time.Duration
+		Claims       *jws.ClaimSet
+	}{c.PrivateKey, c.PrivateKeyID, c.Expires, claims})
+	if err != nil {
+		return assertionKey{}, false
+	}
+	return sha256.Sum256(b), true
+}
+
+type cachedAssertion struct {
+	payload string
+	expiry  time.Time
+}
+
+// assertionCache holds signed assertions shared between all Configs with
+// ReuseAssertion set.
+type assertionCache struct {
+	mu sync.Mutex
+	m  map[assertionKey]cachedAssertion
+}
+
+var assertions = &assertionCache{}
+
+// get returns the cached assertion for key if it is valid for at least
+// assertionReuseMargin.
+func (c *assertionCache) get(key assertionKey) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	a, ok := c.m[key]
+	if !ok || time.Now().Add(assertionReuseMargin).After(a.expiry) {
+		return "", false
+	}
+	return a.payload, true
+}
+
+// put caches payload until expiry, evicting expired assertions, or every
+// assertion if the cache is still full.
+func (c *assertionCache) put(key assertionKey, payload string, expiry time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.m == nil {
+		c.m = make(map[assertionKey]cachedAssertion)
+	}
+	if len(c.m) >= maxCachedAssertions {
+		now := time.Now()
+		for k, a := range c.m {
+			if now.After(a.expiry) {
+				delete(c.m, k)
+			}
+		}
+		if len(c.m) >= maxCachedAssertions {
+			c.m = make(map[assertionKey]cachedAssertion)
+		}
+	}
+	c.m[key] = cachedAssertion{payload: payload, expiry: expiry}
+}
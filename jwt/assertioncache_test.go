@@ -0,0 +1,62 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jwt
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestJWTFetch_ReuseAssertion(t *testing.T) {
+	var got []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.FormValue("assertion"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token": "90d64460d14870c08c81352a05dedd3465940a7c", "token_type": "bearer", "expires_in": 3600}`))
+	}))
+	defer ts.Close()
+
+	newConfig := func(scope string) *Config {
+		return &Config{
+			Email:          "aaa@xxx.com",
+			PrivateKey:     dummyPrivateKey,
+			TokenURL:       ts.URL,
+			Scopes:         []string{scope},
+			ReuseAssertion: true,
+		}
+	}
+	for _, conf := range []*Config{newConfig("scope1"), newConfig("scope1"), newConfig("scope2")} {
+		if _, err := conf.TokenSource(context.Background()).Token(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d token requests, want 3", len(got))
+	}
+	if got[0] != got[1] {
+		t.Errorf("assertion of identical config was not reused")
+	}
+	if got[0] == got[2] {
+		t.Errorf("assertion was reused for different scopes")
+	}
+}
+
+func TestAssertionCache_Margin(t *testing.T) {
+	c := &assertionCache{}
+	var fresh, stale assertionKey
+	stale[0] = 1
+	c.put(fresh, "fresh", time.Now().Add(time.Hour))
+	c.put(stale, "stale", time.Now().Add(assertionReuseMargin/2))
+
+	if got, ok := c.get(fresh); !ok || got != "fresh" {
+		t.Errorf("get(fresh) = %q, %v; want %q, true", got, ok, "fresh")
+	}
+	if got, ok := c.get(stale); ok {
+		t.Errorf("get(stale) = %q, %v; want no assertion within the reuse margin", got, ok)
+	}
+}
@@ -74,6 +74,14 @@ type Config struct {
 	// UseIDToken optionally specifies whether ID token should be used instead
 	// of access token when the server returns both.
 	UseIDToken bool
+
+	// ReuseAssertion optionally specifies whether the signed JWT sent to
+	// TokenURL may be reused for later token requests, by this or any other
+	// Config with the same key and claims, until shortly before it expires.
+	// This saves signing a new JWT for every token on workloads creating
+	// many token sources. It must not be set for providers that reject
+	// replayed assertions.
+	ReuseAssertion bool
 }
 
 // TokenSource returns a JWT TokenSource using the configuration
@@ -98,36 +106,64 @@ type jwtSource struct {
 	conf *Config
 }
 
-func (js jwtSource) Token() (*oauth2.Token, error) {
-	pk, err := internal.ParseKey(js.conf.PrivateKey)
-	if err != nil {
-		return nil, err
-	}
-	hc := oauth2.NewClient(js.ctx, nil)
+// assertion returns the signed JWT sent to the token endpoint, reusing a
+// cached one when c.ReuseAssertion is set.
+func (c *Config) assertion() (string, error) {
 	claimSet := &jws.ClaimSet{
-		Iss:           js.conf.Email,
-		Scope:         strings.Join(js.conf.Scopes, " "),
-		Aud:           js.conf.TokenURL,
-		PrivateClaims: js.conf.PrivateClaims,
+		Iss:           c.Email,
+		Scope:         strings.Join(c.Scopes, " "),
+		Aud:           c.TokenURL,
+		PrivateClaims: c.PrivateClaims,
 	}
-	if subject := js.conf.Subject; subject != "" {
+	if subject := c.Subject; subject != "" {
 		claimSet.Sub = subject
 		// prn is the old name of sub. Keep setting it
 		// to be compatible with legacy OAuth 2.0 providers.
 		claimSet.Prn = subject
 	}
-	if t := js.conf.Expires; t > 0 {
-		claimSet.Exp = time.Now().Add(t).Unix()
-	}
-	if aud := js.conf.Audience; aud != "" {
+	if aud := c.Audience; aud != "" {
 		claimSet.Aud = aud
 	}
+	var key assertionKey
+	cacheable := false
+	if c.ReuseAssertion {
+		key, cacheable = c.assertionKey(claimSet)
+		if cacheable {
+			if payload, ok := assertions.get(key); ok {
+				return payload, nil
+			}
+		}
+	}
+	pk, err := internal.ParseKey(c.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+	if t := c.Expires; t > 0 {
+		claimSet.Exp = time.Now().Add(t).Unix()
+	}
 	h := *defaultHeader
-	h.KeyID = js.conf.PrivateKeyID
+	h.KeyID = c.PrivateKeyID
 	payload, err := jws.Encode(&h, claimSet, pk)
+	if err != nil {
+		return "", err
+	}
+	if cacheable {
+		assertions.put(key, payload, time.Unix(claimSet.Exp, 0))
+	}
+	return payload, nil
+}
+
+// DescribeTokenSource describes js, see oauth2.DescribeTokenSource.
+func (js jwtSource) DescribeTokenSource() (string, oauth2.TokenSource) {
+	return internal.DescribeParams("jwt", "email", js.conf.Email, "key_id", js.conf.PrivateKeyID, "subject", js.conf.Subject, "token_url", internal.RedactURL(js.conf.TokenURL)), nil
+}
+
+func (js jwtSource) Token() (*oauth2.Token, error) {
+	payload, err := js.conf.assertion()
 	if err != nil {
 		return nil, err
 	}
+	hc := oauth2.NewClient(js.ctx, nil)
 	v := url.Values{}
 	v.Set("grant_type", defaultGrantType)
 	v.Set("assertion", payload)
@@ -0,0 +1,148 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jwt
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"sync"
+
+	"golang.org/x/oauth2/jws"
+)
+
+// defaultRetainedKeys is the number of keys a KeySet publishes when Retain
+// is not set: the signing key and its predecessor.
+const defaultRetainedKeys = 2
+
+// KeySet manages the RSA keys an issuer signs tokens with and publishes
+// their public halves as a JSON Web Key Set (RFC 7517). The most recently
+// added key signs new tokens; older keys stay published so that tokens they
+// signed can still be verified. KeySet is safe for concurrent use.
+type KeySet struct {
+	// Retain is the number of keys kept, including the signing key. Older
+	// keys are dropped by Rotate. The default is 2.
+	Retain int
+
+	mu   sync.RWMutex
+	keys []keySetEntry // newest first
+}
+
+type keySetEntry struct {
+	kid string
+	key *rsa.PrivateKey
+}
+
+// jwk is the JSON Web Key representation of an RSA public key.
+type jwk struct {
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// Rotate makes key the signing key and returns the key ID assigned to it,
+// its RFC 7638 thumbprint.
+func (ks *KeySet) Rotate(key *rsa.PrivateKey) string {
+	kid := thumbprint(&key.PublicKey)
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	keys := []keySetEntry{{kid: kid, key: key}}
+	for _, e := range ks.keys {
+		if e.kid != kid {
+			keys = append(keys, e)
+		}
+	}
+	retain := ks.Retain
+	if retain <= 0 {
+		retain = defaultRetainedKeys
+	}
+	if len(keys) > retain {
+		keys = keys[:retain]
+	}
+	ks.keys = keys
+	return kid
+}
+
+// Retire stops publishing the key with the given ID. The signing key cannot
+// be retired; rotate to a new key first.
+func (ks *KeySet) Retire(kid string) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	for i, e := range ks.keys {
+		if e.kid != kid {
+			continue
+		}
+		if i == 0 {
+			return errors.New("oauth2: cannot retire the signing key")
+		}
+		ks.keys = append(ks.keys[:i:i], ks.keys[i+1:]...)
+		return nil
+	}
+	return nil
+}
+
+// Signer returns the ID of the current signing key and an RS256 signer using
+// it.
+func (ks *KeySet) Signer() (kid string, signer jws.Signer, err error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	if len(ks.keys) == 0 {
+		return "", nil, errors.New("oauth2: key set has no signing key")
+	}
+	key := ks.keys[0].key
+	return ks.keys[0].kid, func(data []byte) ([]byte, error) {
+		h := sha256.Sum256(data)
+		return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, h[:])
+	}, nil
+}
+
+// ServeHTTP serves the public keys of ks as a JWKS document, newest first.
+func (ks *KeySet) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ks.mu.RLock()
+	doc := struct {
+		Keys []jwk `json:"keys"`
+	}{Keys: make([]jwk, 0, len(ks.keys))}
+	for _, e := range ks.keys {
+		doc.Keys = append(doc.Keys, publicJWK(e.kid, &e.key.PublicKey))
+	}
+	ks.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/jwk-set+json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(doc)
+}
+
+func publicJWK(kid string, pub *rsa.PublicKey) jwk {
+	return jwk{
+		Kty: "RSA",
+		Alg: "RS256",
+		Use: "sig",
+		Kid: kid,
+		N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}
+}
+
+// thumbprint returns the RFC 7638 SHA-256 thumbprint of pub.
+func thumbprint(pub *rsa.PublicKey) string {
+	k := publicJWK("", pub)
+	// The required members in lexicographic order, without whitespace.
+	b, _ := json.Marshal(struct {
+		E   string `json:"e"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+	}{k.E, k.Kty, k.N})
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
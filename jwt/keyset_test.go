@@ -0,0 +1,106 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2/internal"
+	"golang.org/x/oauth2/jws"
+)
+
+func TestKeySet(t *testing.T) {
+	oldKey, err := internal.ParseKey(dummyPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ks := &KeySet{}
+	oldKID := ks.Rotate(oldKey)
+	newKID := ks.Rotate(newKey)
+	if oldKID == newKID || oldKID == "" {
+		t.Fatalf("got key IDs %q and %q, want distinct non-empty IDs", oldKID, newKID)
+	}
+
+	m := &AccessTokenMinter{Issuer: "https://issuer.example.com", Keys: ks}
+	tok, err := m.Mint(AccessTokenClaims{Subject: "s", Audience: "a", ClientID: "c"})
+	if err != nil {
+		t.Fatalf("Mint() failed: %v", err)
+	}
+	if err := jws.Verify(tok, &newKey.PublicKey); err != nil {
+		t.Errorf("token not signed by the newest key: %v", err)
+	}
+	var header jws.Header
+	decodeSegment(t, strings.Split(tok, ".")[0], &header)
+	if header.KeyID != newKID {
+		t.Errorf("got kid %v but want %v", header.KeyID, newKID)
+	}
+
+	published := fetchJWKS(t, ks)
+	if len(published) != 2 || published[0].Kid != newKID || published[1].Kid != oldKID {
+		t.Fatalf("got published keys %+v, want %v then %v", published, newKID, oldKID)
+	}
+	n, _ := base64.RawURLEncoding.DecodeString(published[1].N)
+	if new(big.Int).SetBytes(n).Cmp(oldKey.N) != 0 {
+		t.Error("published modulus does not match the key")
+	}
+
+	if err := ks.Retire(newKID); err == nil {
+		t.Error("Retire() of the signing key succeeded, want error")
+	}
+	if err := ks.Retire(oldKID); err != nil {
+		t.Fatalf("Retire() failed: %v", err)
+	}
+	if published := fetchJWKS(t, ks); len(published) != 1 {
+		t.Errorf("got %d published keys after retiring one, want 1", len(published))
+	}
+
+	// Rotating beyond Retain drops the oldest key.
+	ks.Rotate(oldKey)
+	ks.Rotate(newKey)
+	if published := fetchJWKS(t, ks); len(published) != defaultRetainedKeys {
+		t.Errorf("got %d published keys, want %d", len(published), defaultRetainedKeys)
+	}
+}
+
+func TestKeySet_Empty(t *testing.T) {
+	ks := &KeySet{}
+	if _, _, err := ks.Signer(); err == nil {
+		t.Error("Signer() on an empty key set succeeded, want error")
+	}
+	if published := fetchJWKS(t, ks); len(published) != 0 {
+		t.Errorf("got %d published keys, want 0", len(published))
+	}
+}
+
+func fetchJWKS(t *testing.T, ks *KeySet) []jwk {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	ks.ServeHTTP(rec, httptest.NewRequest("GET", "/.well-known/jwks.json", nil))
+	if got, want := rec.Header().Get("Content-Type"), "application/jwk-set+json"; got != want {
+		t.Errorf("got Content-Type %v but want %v", got, want)
+	}
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("unable to decode JWKS: %v", err)
+	}
+	if doc.Keys == nil {
+		t.Error("keys must be an array, got null")
+	}
+	return doc.Keys
+}
@@ -0,0 +1,108 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth2
+
+import (
+	"sync"
+	"time"
+)
+
+// TokenSourceMiddleware adds behavior to a TokenSource by wrapping it.
+// Middlewares are composed with WrapTokenSource.
+type TokenSourceMiddleware func(TokenSource) TokenSource
+
+// WrapTokenSource returns src wrapped by the given middlewares. The first
+// middleware is the outermost one, so
+//
+//	WrapTokenSource(src, WithReuse(), WithLogging(logf))
+//
+// logs only the calls that are not served from the cache.
+func WrapTokenSource(src TokenSource, mw ...TokenSourceMiddleware) TokenSource {
+	for i := len(mw) - 1; i >= 0; i-- {
+		src = mw[i](src)
+	}
+	return src
+}
+
+// WithReuse returns a middleware that caches tokens until they expire, as
+// ReuseTokenSource does.
+func WithReuse() TokenSourceMiddleware {
+	return func(src TokenSource) TokenSource {
+		return ReuseTokenSource(nil, src)
+	}
+}
+
+// WithLogging returns a middleware that reports every token retrieval through
+// logf. Token values are never logged.
+func WithLogging(logf func(format string, args ...interface{})) TokenSourceMiddleware {
+	return func(src TokenSource) TokenSource {
+		return TokenSourceFunc(func() (*Token, error) {
+			tok, err := src.Token()
+			if err != nil {
+				logf("oauth2: token retrieval failed: %v", err)
+				return nil, err
+			}
+			if tok.Expiry.IsZero() {
+				logf("oauth2: retrieved %s token without expiry", tok.Type())
+			} else {
+				logf("oauth2: retrieved %s token expiring at %v", tok.Type(), tok.Expiry)
+			}
+			return tok, nil
+		})
+	}
+}
+
+// WithMetrics returns a middleware that calls observe after every token
+// retrieval with its duration and error.
+func WithMetrics(observe func(d time.Duration, err error)) TokenSourceMiddleware {
+	return func(src TokenSource) TokenSource {
+		return TokenSourceFunc(func() (*Token, error) {
+			start := timeNow()
+			tok, err := src.Token()
+			observe(timeNow().Sub(start), err)
+			return tok, err
+		})
+	}
+}
+
+// WithRateLimit returns a middleware that calls the wrapped TokenSource at
+// most once per interval. Calls made within interval of the previous one
+// share its result, including its error, instead of reaching the token
+// endpoint again.
+func WithRateLimit(interval time.Duration) TokenSourceMiddleware {
+	return func(src TokenSource) TokenSource {
+		return &rateLimitTokenSource{src: src, interval: interval}
+	}
+}
+
+// TokenSourceFunc is an adapter to allow the use of ordinary functions as
+// TokenSources.
+type TokenSourceFunc func() (*Token, error)
+
+// Token returns f().
+func (f TokenSourceFunc) Token() (*Token, error) {
+	return f()
+}
+
+type rateLimitTokenSource struct {
+	src      TokenSource
+	interval time.Duration
+
+	mu   sync.Mutex // guards last, tok and err
+	last time.Time
+	tok  *Token
+	err  error
+}
+
+func (s *rateLimitTokenSource) Token() (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.last.IsZero() && timeNow().Before(s.last.Add(s.interval)) {
+		return s.tok, s.err
+	}
+	s.tok, s.err = s.src.Token()
+	s.last = timeNow()
+	return s.tok, s.err
+}
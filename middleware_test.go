@@ -0,0 +1,102 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth2
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// countingTokenSource returns tok and err and counts its calls.
+type countingTokenSource struct {
+	tok   *Token
+	err   error
+	calls int
+}
+
+func (s *countingTokenSource) Token() (*Token, error) {
+	s.calls++
+	return s.tok, s.err
+}
+
+func TestWrapTokenSource_Order(t *testing.T) {
+	var order []string
+	mw := func(name string) TokenSourceMiddleware {
+		return func(src TokenSource) TokenSource {
+			return TokenSourceFunc(func() (*Token, error) {
+				order = append(order, name)
+				return src.Token()
+			})
+		}
+	}
+	src := &countingTokenSource{tok: &Token{AccessToken: "abc"}}
+	ts := WrapTokenSource(src, mw("outer"), mw("inner"))
+	if _, err := ts.Token(); err != nil {
+		t.Fatalf("Token() failed: %v", err)
+	}
+	if got, want := strings.Join(order, ","), "outer,inner"; got != want {
+		t.Errorf("got %v but want %v", got, want)
+	}
+}
+
+func TestWrapTokenSource_LoggingOutsideReuse(t *testing.T) {
+	var logs []string
+	logf := func(format string, args ...interface{}) {
+		logs = append(logs, fmt.Sprintf(format, args...))
+	}
+	src := &countingTokenSource{tok: &Token{AccessToken: "secret", Expiry: time.Now().Add(time.Hour)}}
+	ts := WrapTokenSource(src, WithReuse(), WithLogging(logf))
+	for i := 0; i < 3; i++ {
+		if _, err := ts.Token(); err != nil {
+			t.Fatalf("Token() failed: %v", err)
+		}
+	}
+	if src.calls != 1 || len(logs) != 1 {
+		t.Fatalf("got %d calls and %d log lines but want 1 and 1", src.calls, len(logs))
+	}
+	if strings.Contains(logs[0], "secret") {
+		t.Errorf("log line %q contains the token", logs[0])
+	}
+}
+
+func TestWithMetrics(t *testing.T) {
+	errFetch := errors.New("fetch failed")
+	var observed error
+	ts := WrapTokenSource(&countingTokenSource{err: errFetch}, WithMetrics(func(d time.Duration, err error) {
+		observed = err
+	}))
+	if _, err := ts.Token(); err != errFetch {
+		t.Errorf("got %v but want %v", err, errFetch)
+	}
+	if observed != errFetch {
+		t.Errorf("observed %v but want %v", observed, errFetch)
+	}
+}
+
+func TestWithRateLimit(t *testing.T) {
+	defer func(old func() time.Time) { timeNow = old }(timeNow)
+	current := time.Now()
+	timeNow = func() time.Time { return current }
+
+	src := &countingTokenSource{err: errors.New("unavailable")}
+	ts := WrapTokenSource(src, WithRateLimit(time.Minute))
+	for i := 0; i < 3; i++ {
+		if _, err := ts.Token(); err == nil {
+			t.Fatal("Token() succeeded, want error")
+		}
+	}
+	if src.calls != 1 {
+		t.Errorf("got %d calls within the interval but want 1", src.calls)
+	}
+
+	current = current.Add(time.Minute)
+	ts.Token()
+	if src.calls != 2 {
+		t.Errorf("got %d calls after the interval but want 2", src.calls)
+	}
+}
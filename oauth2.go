@@ -12,6 +12,8 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"math/rand"
 	"net/http"
 	"net/url"
 	"strings"
@@ -58,6 +60,69 @@ type Config struct {
 
 	// Scope specifies optional requested permissions.
 	Scopes []string
+
+	// ClientType optionally declares whether the client is public or
+	// confidential, see RFC 6749 section 2.1. When set, token requests
+	// fail if the configuration does not match the type.
+	ClientType ClientType
+
+	// PKCEMethods lists the code challenge methods the client may use, in
+	// order of preference; Config.Challenge uses the first. If empty, S256
+	// is used, so the plain method is only used when listed explicitly.
+	// Methods other than S256 and plain must be registered with
+	// RegisterChallengeMethod.
+	PKCEMethods []string
+
+	// ErrorHook, if non-nil, is called with the sanitized response whenever
+	// the token endpoint returns an error, before the *RetrieveError is
+	// returned to the caller.
+	ErrorHook func(*TokenErrorResponse)
+
+	// ResponseHook, if non-nil, is called with the status code and headers
+	// of every successful token endpoint response.
+	ResponseHook func(*TokenResponseMetadata)
+
+	// ReauthenticationRequired, if non-nil, is called when a token refresh
+	// is rejected with an invalid_grant error, meaning that the refresh
+	// token was revoked, expired or reused, so that applications can end
+	// the session of the user. The error is a *RefreshTokenReuseError if
+	// the server reported the reuse of a rotated refresh token, and a
+	// *RetrieveError otherwise.
+	ReauthenticationRequired func(error)
+}
+
+// ClientType is the type of an OAuth 2.0 client.
+type ClientType int
+
+const (
+	// ClientTypeUnspecified performs no client type checks.
+	ClientTypeUnspecified ClientType = iota
+
+	// ClientTypeConfidential is a client able to keep its credentials
+	// secret, such as a web server. Confidential clients must have a
+	// ClientSecret.
+	ClientTypeConfidential
+
+	// ClientTypePublic is a client unable to keep credentials secret, such
+	// as a native or browser application. Public clients must not have a
+	// ClientSecret; they identify themselves by sending client_id in the
+	// request body and should use PKCE.
+	ClientTypePublic
+)
+
+// checkClientType reports whether c is consistent with c.ClientType.
+func (c *Config) checkClientType() error {
+	switch c.ClientType {
+	case ClientTypeConfidential:
+		if c.ClientSecret == "" {
+			return errors.New("oauth2: confidential clients require a ClientSecret")
+		}
+	case ClientTypePublic:
+		if c.ClientSecret != "" {
+			return errors.New("oauth2: public clients must not have a ClientSecret; secrets shipped in native or browser applications are not secret")
+		}
+	}
+	return nil
 }
 
 // A TokenSource is anything that can return a token.
@@ -88,6 +153,10 @@ const (
 	// AuthStyleAutoDetect means to auto-detect which authentication
 	// style the provider wants by trying both ways and caching
 	// the successful way for the future.
+	//
+	// Probing sends a failing token request to providers that only
+	// accept one style, which some count as a failed sign-in. New code
+	// should set the style the provider documents, or AuthStyleStrict.
 	AuthStyleAutoDetect AuthStyle = 0
 
 	// AuthStyleInParams sends the "client_id" and "client_secret"
@@ -98,8 +167,34 @@ const (
 	// using HTTP Basic Authorization. This is an optional style
 	// described in the OAuth2 RFC 6749 section 2.3.1.
 	AuthStyleInHeader AuthStyle = 2
+
+	// AuthStyleStrict sends the client_id and client_secret using HTTP
+	// Basic Authorization, which RFC 6749 section 2.3.1 requires
+	// providers to support, and never probes other styles. If the
+	// provider rejects the client authentication, the token request
+	// fails with an *AuthStyleError rather than being sent again with
+	// the credentials in the POST body, for providers that lock
+	// accounts after repeated failed attempts.
+	AuthStyleStrict AuthStyle = 3
 )
 
+// AuthStyleError is the error of a token request made with AuthStyleStrict
+// whose client authentication was rejected by the provider.
+type AuthStyleError struct {
+	// Err is the error response of the token endpoint.
+	Err *RetrieveError
+}
+
+func (e *AuthStyleError) Error() string {
+	return "oauth2: token endpoint rejected the client credentials sent with HTTP Basic Authorization; " +
+		"AuthStyleStrict does not retry with other styles, set Endpoint.AuthStyle to AuthStyleInParams " +
+		"if the provider expects them in the POST body: " + e.Err.Error()
+}
+
+func (e *AuthStyleError) Unwrap() error {
+	return e.Err
+}
+
 var (
 	// AccessTypeOnline and AccessTypeOffline are options passed
 	// to the Options.AuthCodeURL method. They modify the
@@ -159,7 +254,7 @@ func (c *Config) AuthCodeURL(state string, opts ...AuthCodeOption) string {
 		v.Set("redirect_uri", c.RedirectURL)
 	}
 	if len(c.Scopes) > 0 {
-		v.Set("scope", strings.Join(c.Scopes, " "))
+		v.Set("scope", internal.ScopeString(c.Scopes))
 	}
 	if state != "" {
 		// TODO(light): Docs say never to omit state; don't allow empty.
@@ -188,15 +283,22 @@ func (c *Config) AuthCodeURL(state string, opts ...AuthCodeOption) string {
 //
 // The provided context optionally controls which HTTP client is used. See the HTTPClient variable.
 func (c *Config) PasswordCredentialsToken(ctx context.Context, username, password string) (*Token, error) {
+	return retrieveToken(ctx, c, c.passwordCredentialsValues(username, password, nil))
+}
+
+func (c *Config) passwordCredentialsValues(username, password string, opts []AuthCodeOption) url.Values {
 	v := url.Values{
 		"grant_type": {"password"},
 		"username":   {username},
 		"password":   {password},
 	}
 	if len(c.Scopes) > 0 {
-		v.Set("scope", strings.Join(c.Scopes, " "))
+		v.Set("scope", internal.ScopeString(c.Scopes))
 	}
-	return retrieveToken(ctx, c, v)
+	for _, opt := range opts {
+		opt.setValue(v)
+	}
+	return v
 }
 
 // Exchange converts an authorization code into a token.
@@ -257,6 +359,12 @@ type tokenRefresher struct {
 	ctx          context.Context // used to get HTTP requests
 	conf         *Config
 	refreshToken string
+
+	// reuseErr is the error of a refresh rejected for the reuse of
+	// reusedToken, returned by later calls instead of retrying as long as
+	// refreshToken is still reusedToken.
+	reuseErr    *RefreshTokenReuseError
+	reusedToken string
 }
 
 // WARNING: Token is not safe for concurrent access, as it
@@ -267,12 +375,21 @@ func (tf *tokenRefresher) Token() (*Token, error) {
 	if tf.refreshToken == "" {
 		return nil, errors.New("oauth2: token expired and refresh token is not set")
 	}
+	if tf.reuseErr != nil && tf.refreshToken == tf.reusedToken {
+		return nil, tf.reuseErr
+	}
 
 	tk, err := retrieveToken(tf.ctx, tf.conf, url.Values{
 		"grant_type":    {"refresh_token"},
 		"refresh_token": {tf.refreshToken},
 	})
 
+	if rErr, ok := err.(*RetrieveError); ok {
+		err = tf.conf.refreshError(rErr)
+		if reuseErr, ok := err.(*RefreshTokenReuseError); ok {
+			tf.reuseErr, tf.reusedToken = reuseErr, tf.refreshToken
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -289,10 +406,24 @@ func (tf *tokenRefresher) Token() (*Token, error) {
 type reuseTokenSource struct {
 	new TokenSource // called when t is expired.
 
+	// newMu serializes calls to new, which background refreshes make
+	// without holding mu.
+	newMu sync.Mutex
+
 	mu sync.Mutex // guards t
 	t  *Token
 
 	expiryDelta time.Duration
+
+	// jitter is the upper bound of a random duration added to expiryDelta
+	// for every new token.
+	jitter time.Duration
+
+	// failureBackoff is how long a refresh error is served before the
+	// source is called again.
+	failureBackoff time.Duration
+	lastErr        error     // guarded by mu
+	retryAfter     time.Time // guarded by mu
 }
 
 // Token returns the current token if it's still valid, else will
@@ -304,18 +435,44 @@ func (s *reuseTokenSource) Token() (*Token, error) {
 	if s.t.Valid() {
 		return s.t, nil
 	}
+	return s.refreshLocked()
+}
+
+// refreshLocked obtains a new token from s.new. s.mu must be held.
+func (s *reuseTokenSource) refreshLocked() (*Token, error) {
+	if s.lastErr != nil && timeNow().Before(s.retryAfter) {
+		return nil, &RefreshBackoffError{Err: s.lastErr, RetryAfter: s.retryAfter}
+	}
+	s.newMu.Lock()
 	t, err := s.new.Token()
+	s.newMu.Unlock()
 	if err != nil {
+		if s.failureBackoff > 0 {
+			s.lastErr, s.retryAfter = err, timeNow().Add(s.failureBackoff)
+		}
 		return nil, err
 	}
+	s.lastErr = nil
+	s.setLocked(t)
+	return t, nil
+}
+
+// setLocked makes t the current token. s.mu must be held.
+func (s *reuseTokenSource) setLocked(t *Token) {
 	t.expiryDelta = s.expiryDelta
+	if s.jitter > 0 {
+		if t.expiryDelta == 0 {
+			t.expiryDelta = defaultExpiryDelta
+		}
+		t.expiryDelta += time.Duration(rand.Int63n(int64(s.jitter)))
+	}
 	s.t = t
-	return t, nil
 }
 
 // StaticTokenSource returns a TokenSource that always returns the same token.
 // Because the provided token t is never refreshed, StaticTokenSource is only
-// useful for tokens that never expire.
+// useful for tokens that never expire. Use ExpiringStaticTokenSource for
+// tokens that do.
 func StaticTokenSource(t *Token) TokenSource {
 	return staticTokenSource{t}
 }
@@ -329,8 +486,54 @@ func (s staticTokenSource) Token() (*Token, error) {
 	return s.t, nil
 }
 
+// ErrStaticTokenExpired is returned, possibly wrapped, by a TokenSource from
+// ExpiringStaticTokenSource once its token has expired and no refresh
+// function is available.
+var ErrStaticTokenExpired = errors.New("oauth2: static token expired")
+
+// ExpiringStaticTokenSource returns a TokenSource that returns t until it
+// expires according to t.Expiry. After that, refresh is called to obtain a
+// replacement token, which is returned and reused in the same way. If
+// refresh is nil, the TokenSource fails with an error wrapping
+// ErrStaticTokenExpired, so that tokens injected in tests or CI
+// environments fail predictably rather than being rejected by the server.
+func ExpiringStaticTokenSource(t *Token, refresh func() (*Token, error)) TokenSource {
+	return &expiringStaticTokenSource{t: t, refresh: refresh}
+}
+
+type expiringStaticTokenSource struct {
+	refresh func() (*Token, error)
+
+	mu sync.Mutex // guards t
+	t  *Token
+}
+
+func (s *expiringStaticTokenSource) Token() (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.t.Valid() {
+		return s.t, nil
+	}
+	if s.refresh == nil {
+		if s.t == nil {
+			return nil, ErrStaticTokenExpired
+		}
+		return nil, fmt.Errorf("%w at %v", ErrStaticTokenExpired, s.t.Expiry)
+	}
+	t, err := s.refresh()
+	if err != nil {
+		return nil, err
+	}
+	if !t.Valid() {
+		return nil, fmt.Errorf("%w: refresh returned an invalid token", ErrStaticTokenExpired)
+	}
+	s.t = t
+	return t, nil
+}
+
 // HTTPClient is the context key to use with golang.org/x/net/context's
 // WithValue function to associate an *http.Client value with a context.
+// WithHTTPClient and HTTPClientFrom are typed alternatives.
 var HTTPClient internal.ContextKey
 
 // NewClient creates an *http.Client from a Context and TokenSource.
@@ -410,3 +613,58 @@ func ReuseTokenSourceWithExpiry(t *Token, src TokenSource, earlyExpiry time.Dura
 		expiryDelta: earlyExpiry,
 	}
 }
+
+// ReuseTokenSourceWithJitter returns a TokenSource that acts in the same
+// manner as the TokenSource returned by ReuseTokenSourceWithExpiry, except
+// that a random duration in [0, jitter) is added to earlyExpiry for every new
+// token. This spreads the refreshes of many processes started together, so
+// that they do not reach the token endpoint in lockstep.
+func ReuseTokenSourceWithJitter(t *Token, src TokenSource, earlyExpiry, jitter time.Duration) TokenSource {
+	ts := ReuseTokenSourceWithExpiry(t, src, earlyExpiry).(*reuseTokenSource)
+	ts.jitter = jitter
+	return ts
+}
+
+// ReuseTokenSourceWithFailureBackoff returns a TokenSource that acts in the
+// same manner as the TokenSource returned by ReuseTokenSource, except that
+// after a failed refresh the source is not called again for backoff. Calls
+// made in the meantime return a *RefreshBackoffError wrapping the failure.
+// The first successful refresh clears the failure.
+func ReuseTokenSourceWithFailureBackoff(t *Token, src TokenSource, backoff time.Duration) TokenSource {
+	ts := &reuseTokenSource{
+		t:              t,
+		new:            src,
+		failureBackoff: backoff,
+	}
+	// A reuseTokenSource may be shared, so it is wrapped rather than
+	// modified, with its settings copied. It is not unwrapped either, since
+	// the source it wraps may not be safe for concurrent use.
+	if rt, ok := src.(*reuseTokenSource); ok {
+		rt.mu.Lock()
+		ts.expiryDelta, ts.jitter = rt.expiryDelta, rt.jitter
+		rt.mu.Unlock()
+	}
+	if t != nil {
+		t.expiryDelta = ts.expiryDelta
+	}
+	return ts
+}
+
+// RefreshBackoffError is returned by a TokenSource created with
+// ReuseTokenSourceWithFailureBackoff while it waits before retrying a failed
+// refresh.
+type RefreshBackoffError struct {
+	// Err is the error of the last refresh attempt.
+	Err error
+	// RetryAfter is when the next refresh will be attempted.
+	RetryAfter time.Time
+}
+
+func (e *RefreshBackoffError) Error() string {
+	return fmt.Sprintf("oauth2: token refresh suspended until %v after failure: %v", e.RetryAfter.Format(time.RFC3339), e.Err)
+}
+
+// Unwrap returns the error of the last refresh attempt.
+func (e *RefreshBackoffError) Unwrap() error {
+	return e.Err
+}
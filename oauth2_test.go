@@ -137,6 +137,34 @@ func TestExchangeRequest(t *testing.T) {
 	}
 }
 
+func TestExchangeRequest_IssuedAtAndRawResponse(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token": "90d64460d14870c08c81352a05dedd3465940a7c", "token_type": "bearer", "expires_in": 86400, "id_token": "some-id-token"}`))
+	}))
+	defer ts.Close()
+	conf := newConf(ts.URL)
+	before := time.Now()
+	tok, err := conf.Exchange(context.Background(), "exchange-code")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.IssuedAt.Before(before) || tok.IssuedAt.After(time.Now()) {
+		t.Errorf("got IssuedAt %v but want a time during Exchange", tok.IssuedAt)
+	}
+	raw := tok.RawResponse()
+	if got, want := raw["id_token"], "some-id-token"; got != want {
+		t.Errorf("got id_token %v but want %v", got, want)
+	}
+	raw["id_token"] = "changed"
+	if got, want := tok.RawResponse()["id_token"], "some-id-token"; got != want {
+		t.Errorf("got id_token %v after modifying the returned map but want %v", got, want)
+	}
+	if raw := (&Token{AccessToken: "abc"}).RawResponse(); raw != nil {
+		t.Errorf("got RawResponse %v for a token built by hand, want nil", raw)
+	}
+}
+
 func TestExchangeRequest_CustomParam(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.String() != "/token" {
@@ -379,6 +407,35 @@ func TestExchangeRequest_NonBasicAuth(t *testing.T) {
 	conf.Exchange(ctx, "code")
 }
 
+func TestExchangeRequest_AuthStyleStrict(t *testing.T) {
+	internal.ResetAuthCache()
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if _, _, ok := r.BasicAuth(); !ok {
+			t.Error("token request lacks HTTP Basic Authorization")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error": "invalid_client"}`))
+	}))
+	defer ts.Close()
+	conf := newConf(ts.URL)
+	conf.Endpoint.AuthStyle = AuthStyleStrict
+	_, err := conf.Exchange(context.Background(), "exchange-code")
+	var asErr *AuthStyleError
+	if !errors.As(err, &asErr) {
+		t.Fatalf("got %v but want an *AuthStyleError", err)
+	}
+	var re *RetrieveError
+	if !errors.As(err, &re) || re.ErrorCode != "invalid_client" {
+		t.Errorf("got %v but want a *RetrieveError with error code invalid_client", err)
+	}
+	if requests != 1 {
+		t.Errorf("got %d token requests but want 1", requests)
+	}
+}
+
 func TestPasswordCredentialsTokenRequest(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer r.Body.Close()
@@ -598,3 +655,129 @@ func TestConfigClientWithToken(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestReuseTokenSourceWithJitter(t *testing.T) {
+	const jitter = time.Minute
+	src := TokenSourceFunc(func() (*Token, error) {
+		return &Token{AccessToken: "abc", Expiry: time.Now().Add(time.Hour)}, nil
+	})
+	for i := 0; i < 20; i++ {
+		tok, err := ReuseTokenSourceWithJitter(nil, src, 0, jitter).Token()
+		if err != nil {
+			t.Fatalf("Token() failed: %v", err)
+		}
+		if tok.expiryDelta < defaultExpiryDelta || tok.expiryDelta >= defaultExpiryDelta+jitter {
+			t.Fatalf("got expiryDelta %v, want within [%v, %v)", tok.expiryDelta, defaultExpiryDelta, defaultExpiryDelta+jitter)
+		}
+	}
+}
+
+func TestReuseTokenSourceWithFailureBackoff(t *testing.T) {
+	defer func(old func() time.Time) { timeNow = old }(timeNow)
+	current := time.Now()
+	timeNow = func() time.Time { return current }
+
+	errRefresh := errors.New("token endpoint unavailable")
+	src := &countingTokenSource{err: errRefresh}
+	ts := ReuseTokenSourceWithFailureBackoff(nil, src, time.Minute)
+
+	if _, err := ts.Token(); err != errRefresh {
+		t.Fatalf("got %v but want %v", err, errRefresh)
+	}
+	_, err := ts.Token()
+	var backoffErr *RefreshBackoffError
+	if !errors.As(err, &backoffErr) || !errors.Is(err, errRefresh) {
+		t.Fatalf("got %v but want a *RefreshBackoffError wrapping %v", err, errRefresh)
+	}
+	if src.calls != 1 {
+		t.Errorf("got %d calls during backoff but want 1", src.calls)
+	}
+
+	current = current.Add(time.Minute)
+	src.tok, src.err = &Token{AccessToken: "abc"}, nil
+	tok, err := ts.Token()
+	if err != nil {
+		t.Fatalf("Token() after backoff failed: %v", err)
+	}
+	if tok.AccessToken != "abc" || src.calls != 2 {
+		t.Errorf("got token %q after %d calls, want %q after 2", tok.AccessToken, src.calls, "abc")
+	}
+}
+
+func TestExchangeRequest_PublicClient(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "" {
+			t.Errorf("Unexpected authorization header %q", got)
+		}
+		r.ParseForm()
+		if got, want := r.PostForm.Get("client_id"), "CLIENT_ID"; got != want {
+			t.Errorf("client_id = %q; want %q", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"abc","token_type":"bearer"}`))
+	}))
+	defer ts.Close()
+
+	conf := newConf(ts.URL)
+	conf.ClientType = ClientTypePublic
+	if _, err := conf.Exchange(context.Background(), "exchange-code"); err == nil {
+		t.Error("Exchange() with a client secret succeeded for a public client")
+	}
+	conf.ClientSecret = ""
+	if _, err := conf.Exchange(context.Background(), "exchange-code"); err != nil {
+		t.Errorf("Exchange() failed: %v", err)
+	}
+
+	conf.ClientType = ClientTypeConfidential
+	if _, err := conf.Exchange(context.Background(), "exchange-code"); err == nil {
+		t.Error("Exchange() without a client secret succeeded for a confidential client")
+	}
+}
+
+func TestExpiringStaticTokenSource(t *testing.T) {
+	defer func(old func() time.Time) { timeNow = old }(timeNow)
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+
+	tok := &Token{AccessToken: "injected", Expiry: now.Add(time.Hour)}
+	ts := ExpiringStaticTokenSource(tok, nil)
+	if got, err := ts.Token(); err != nil || got != tok {
+		t.Fatalf("Token() = %v, %v; want the injected token", got, err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if _, err := ts.Token(); !errors.Is(err, ErrStaticTokenExpired) {
+		t.Errorf("Token() error = %v; want ErrStaticTokenExpired", err)
+	}
+
+	refreshed := &Token{AccessToken: "refreshed", Expiry: now.Add(time.Hour)}
+	calls := 0
+	ts = ExpiringStaticTokenSource(tok, func() (*Token, error) {
+		calls++
+		return refreshed, nil
+	})
+	for i := 0; i < 2; i++ {
+		if got, err := ts.Token(); err != nil || got != refreshed {
+			t.Fatalf("Token() = %v, %v; want the refreshed token", got, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("refresh called %d times; want 1", calls)
+	}
+}
+
+func TestReuseTokenSourceWithFailureBackoff_SharedSource(t *testing.T) {
+	errRefresh := errors.New("token endpoint unavailable")
+	shared := ReuseTokenSourceWithExpiry(nil, &countingTokenSource{err: errRefresh}, time.Minute)
+	ts := ReuseTokenSourceWithFailureBackoff(nil, shared, time.Minute)
+	if ts == shared {
+		t.Fatal("got the shared source back, want a new one")
+	}
+	if got := ts.(*reuseTokenSource).expiryDelta; got != time.Minute {
+		t.Errorf("got expiryDelta %v but want %v", got, time.Minute)
+	}
+	ts.Token()
+	if _, err := shared.Token(); err != errRefresh {
+		t.Errorf("got %v from the shared source but want %v, without backoff", err, errRefresh)
+	}
+}
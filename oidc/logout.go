@@ -0,0 +1,343 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package oidc implements OpenID Connect extensions for applications that
+// sign users in with this module.
+package oidc // import "golang.org/x/oauth2/oidc"
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2/internal"
+	"golang.org/x/oauth2/jws"
+)
+
+// backchannelLogoutEvent is the member of the events claim identifying
+// logout tokens.
+const backchannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+// defaultMaxAge is the default of LogoutVerifier.MaxAge.
+const defaultMaxAge = 5 * time.Minute
+
+// minRefetchInterval is how long after fetching the JWKS tokens signed by
+// unknown keys are rejected without fetching it again, so that such tokens
+// cannot make the verifier flood the provider with requests.
+const minRefetchInterval = time.Minute
+
+// timeNow is time.Now, overridden by tests.
+var timeNow = time.Now
+
+// LogoutToken holds the claims of a verified logout token, as described by
+// OpenID Connect Back-Channel Logout 1.0 section 2.4.
+type LogoutToken struct {
+	// Issuer is the iss claim.
+	Issuer string
+	// Subject is the sub claim. Either Subject or SessionID is set.
+	Subject string
+	// SessionID is the sid claim.
+	SessionID string
+	// ID is the jti claim. Applications can remember it to reject replays.
+	ID string
+	// IssuedAt is the iat claim.
+	IssuedAt time.Time
+}
+
+// LogoutVerifier verifies logout tokens sent by an OpenID provider to the
+// back-channel logout URI of the application. Tokens must be signed with
+// RS256 by a key of the provider's JWKS.
+type LogoutVerifier struct {
+	// Issuer is the issuer identifier of the OpenID provider. Required.
+	Issuer string
+	// ClientID is the client ID of the application, which must be an
+	// audience of logout tokens. Required.
+	ClientID string
+	// JWKSURL is the URL of the provider's JSON Web Key Set, the jwks_uri of
+	// its metadata. Required.
+	JWKSURL string
+	// MaxAge is how old a logout token may be. The default is five minutes.
+	MaxAge time.Duration
+	// HTTPClient fetches the JWKS. If nil, the client of the context is
+	// used.
+	HTTPClient *http.Client
+
+	mu       sync.Mutex
+	keys     map[string]*rsa.PublicKey
+	fetched  time.Time     // when the JWKS was last fetched
+	fetching chan struct{} // closed when the pending fetch completes
+}
+
+type logoutClaims struct {
+	Iss    string                     `json:"iss"`
+	Sub    string                     `json:"sub"`
+	Aud    audience                   `json:"aud"`
+	Iat    int64                      `json:"iat"`
+	Exp    int64                      `json:"exp"`
+	Jti    string                     `json:"jti"`
+	Sid    string                     `json:"sid"`
+	Events map[string]json.RawMessage `json:"events"`
+	Nonce  *string                    `json:"nonce"`
+}
+
+// audience is an aud claim, which is a string or an array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+	var l []string
+	if err := json.Unmarshal(b, &l); err != nil {
+		return err
+	}
+	*a = l
+	return nil
+}
+
+// Verify checks the signature and claims of the logout token raw.
+func (v *LogoutVerifier) Verify(ctx context.Context, raw string) (*LogoutToken, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("oauth2/oidc: malformed logout token")
+	}
+	var hdr struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+		Typ string `json:"typ"`
+	}
+	if err := decodeSegment(parts[0], &hdr); err != nil {
+		return nil, err
+	}
+	if hdr.Alg != "RS256" {
+		return nil, fmt.Errorf("oauth2/oidc: unsupported logout token algorithm %q", hdr.Alg)
+	}
+	if hdr.Typ != "" && !strings.EqualFold(hdr.Typ, "logout+jwt") && !strings.EqualFold(hdr.Typ, "JWT") {
+		return nil, fmt.Errorf("oauth2/oidc: unexpected logout token type %q", hdr.Typ)
+	}
+	key, err := v.key(ctx, hdr.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := jws.Verify(raw, key); err != nil {
+		return nil, fmt.Errorf("oauth2/oidc: invalid logout token signature: %v", err)
+	}
+
+	var c logoutClaims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return nil, err
+	}
+	if err := v.checkClaims(&c); err != nil {
+		return nil, err
+	}
+	return &LogoutToken{
+		Issuer:    c.Iss,
+		Subject:   c.Sub,
+		SessionID: c.Sid,
+		ID:        c.Jti,
+		IssuedAt:  time.Unix(c.Iat, 0),
+	}, nil
+}
+
+// checkClaims validates c as described by OpenID Connect Back-Channel Logout
+// 1.0 section 2.6.
+func (v *LogoutVerifier) checkClaims(c *logoutClaims) error {
+	if c.Iss != v.Issuer {
+		return fmt.Errorf("oauth2/oidc: logout token issuer %q does not match %q", c.Iss, v.Issuer)
+	}
+	found := false
+	for _, a := range c.Aud {
+		found = found || a == v.ClientID
+	}
+	if !found {
+		return errors.New("oauth2/oidc: logout token is not intended for this client")
+	}
+	maxAge := v.MaxAge
+	if maxAge == 0 {
+		maxAge = defaultMaxAge
+	}
+	now := timeNow()
+	if c.Iat == 0 || now.Sub(time.Unix(c.Iat, 0)) > maxAge {
+		return errors.New("oauth2/oidc: logout token is too old")
+	}
+	if c.Exp != 0 && now.After(time.Unix(c.Exp, 0)) {
+		return errors.New("oauth2/oidc: logout token has expired")
+	}
+	event, ok := c.Events[backchannelLogoutEvent]
+	if !ok || !strings.HasPrefix(strings.TrimSpace(string(event)), "{") {
+		return errors.New("oauth2/oidc: token is not a logout token")
+	}
+	if c.Sub == "" && c.Sid == "" {
+		return errors.New("oauth2/oidc: logout token has neither sub nor sid")
+	}
+	if c.Nonce != nil {
+		return errors.New("oauth2/oidc: logout token must not contain a nonce")
+	}
+	return nil
+}
+
+// Handler returns an http.Handler serving the back-channel logout URI. It
+// verifies the logout_token of POST requests and calls logout with it,
+// replying as described by OpenID Connect Back-Channel Logout 1.0 section
+// 2.8.
+func (v *LogoutVerifier) Handler(logout func(ctx context.Context, t *LogoutToken) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		if r.Method != "POST" {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		t, err := v.Verify(r.Context(), r.PostFormValue("logout_token"))
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_request", "error_description": err.Error()})
+			return
+		}
+		if err := logout(r.Context(), t); err != nil {
+			http.Error(w, "logout failed", http.StatusNotImplemented)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// key returns the public key kid, fetching the JWKS again if it is unknown
+// so that key rotations are picked up. The JWKS is fetched at most once per
+// minRefetchInterval and without holding v.mu; concurrent callers wait for
+// the pending fetch.
+func (v *LogoutVerifier) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	for {
+		v.mu.Lock()
+		if k, ok := v.lookup(kid); ok {
+			v.mu.Unlock()
+			return k, nil
+		}
+		if !v.fetched.IsZero() && timeNow().Sub(v.fetched) < minRefetchInterval {
+			v.mu.Unlock()
+			return nil, fmt.Errorf("oauth2/oidc: unknown signing key %q", kid)
+		}
+		if ch := v.fetching; ch != nil {
+			v.mu.Unlock()
+			select {
+			case <-ch:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		ch := make(chan struct{})
+		v.fetching = ch
+		v.mu.Unlock()
+
+		keys, err := v.fetchKeys(ctx)
+
+		v.mu.Lock()
+		v.fetching = nil
+		close(ch)
+		if ctx.Err() == nil {
+			v.fetched = timeNow()
+		}
+		if err == nil {
+			v.keys = keys
+		}
+		k, ok := v.lookup(kid)
+		v.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("oauth2/oidc: unknown signing key %q", kid)
+		}
+		return k, nil
+	}
+}
+
+// lookup returns the key kid, or the only key if kid is empty. v.mu must be
+// held.
+func (v *LogoutVerifier) lookup(kid string) (*rsa.PublicKey, bool) {
+	if kid == "" && len(v.keys) == 1 {
+		for _, k := range v.keys {
+			return k, true
+		}
+	}
+	k, ok := v.keys[kid]
+	return k, ok
+}
+
+func (v *LogoutVerifier) fetchKeys(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequest("GET", v.JWKSURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	hc := v.HTTPClient
+	if hc == nil {
+		hc = internal.ContextClient(ctx)
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oauth2/oidc: cannot fetch JWKS: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("oauth2/oidc: cannot fetch JWKS: %v", err)
+	}
+	if code := resp.StatusCode; code < 200 || code > 299 {
+		return nil, fmt.Errorf("oauth2/oidc: cannot fetch JWKS: status code %d: %s", code, body)
+	}
+	var doc struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("oauth2/oidc: cannot parse JWKS: %v", err)
+	}
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range doc.Keys {
+		if k.Kty != "RSA" || k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	return keys, nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return fmt.Errorf("oauth2/oidc: malformed logout token: %v", err)
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("oauth2/oidc: malformed logout token: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2/jws"
+	"golang.org/x/oauth2/jwt"
+)
+
+func newTestProvider(t *testing.T) (*jwt.KeySet, *httptest.Server) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks := &jwt.KeySet{}
+	ks.Rotate(key)
+	ts := httptest.NewServer(ks)
+	t.Cleanup(ts.Close)
+	return ks, ts
+}
+
+func signLogoutToken(t *testing.T, ks *jwt.KeySet, c *jws.ClaimSet) string {
+	t.Helper()
+	kid, signer, err := ks.Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := jws.EncodeWithSigner(&jws.Header{Algorithm: "RS256", Typ: "logout+jwt", KeyID: kid}, c, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func logoutClaimSet(private map[string]interface{}) *jws.ClaimSet {
+	claims := map[string]interface{}{
+		"sid":    "session-1",
+		"jti":    "id-1",
+		"events": map[string]interface{}{backchannelLogoutEvent: map[string]interface{}{}},
+	}
+	for k, v := range private {
+		claims[k] = v
+	}
+	return &jws.ClaimSet{
+		Iss:           "https://issuer.example.com",
+		Aud:           "client",
+		Sub:           "user",
+		PrivateClaims: claims,
+	}
+}
+
+func TestLogoutVerifier(t *testing.T) {
+	ks, ts := newTestProvider(t)
+	v := &LogoutVerifier{Issuer: "https://issuer.example.com", ClientID: "client", JWKSURL: ts.URL}
+
+	tok, err := v.Verify(context.Background(), signLogoutToken(t, ks, logoutClaimSet(nil)))
+	if err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+	if tok.Subject != "user" || tok.SessionID != "session-1" || tok.ID != "id-1" {
+		t.Errorf("got %+v but want sub user, sid session-1 and jti id-1", tok)
+	}
+
+	tests := []struct {
+		name   string
+		claims *jws.ClaimSet
+	}{
+		{"wrong issuer", func() *jws.ClaimSet { c := logoutClaimSet(nil); c.Iss = "https://other.example.com"; return c }()},
+		{"wrong audience", func() *jws.ClaimSet { c := logoutClaimSet(nil); c.Aud = "other"; return c }()},
+		{"too old", func() *jws.ClaimSet {
+			c := logoutClaimSet(nil)
+			c.Iat = time.Now().Add(-time.Hour).Unix()
+			c.Exp = time.Now().Add(time.Hour).Unix()
+			return c
+		}()},
+		{"missing event", logoutClaimSet(map[string]interface{}{"events": map[string]interface{}{}})},
+		{"nonce", logoutClaimSet(map[string]interface{}{"nonce": "n"})},
+		{"no sub or sid", func() *jws.ClaimSet { c := logoutClaimSet(map[string]interface{}{"sid": ""}); c.Sub = ""; return c }()},
+	}
+	for _, tt := range tests {
+		if _, err := v.Verify(context.Background(), signLogoutToken(t, ks, tt.claims)); err == nil {
+			t.Errorf("%s: Verify() succeeded, want error", tt.name)
+		}
+	}
+}
+
+func TestLogoutVerifier_KeyRotation(t *testing.T) {
+	ks, ts := newTestProvider(t)
+	v := &LogoutVerifier{Issuer: "https://issuer.example.com", ClientID: "client", JWKSURL: ts.URL}
+	if _, err := v.Verify(context.Background(), signLogoutToken(t, ks, logoutClaimSet(nil))); err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks.Rotate(key)
+	defer func() { timeNow = time.Now }()
+	timeNow = func() time.Time { return time.Now().Add(minRefetchInterval) }
+	if _, err := v.Verify(context.Background(), signLogoutToken(t, ks, logoutClaimSet(nil))); err != nil {
+		t.Errorf("Verify() after rotation failed: %v", err)
+	}
+}
+
+func TestLogoutVerifier_RefetchInterval(t *testing.T) {
+	ks, ts := newTestProvider(t)
+	var fetches int
+	counter := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		ks.ServeHTTP(w, r)
+	}))
+	defer counter.Close()
+	ts.Close()
+	v := &LogoutVerifier{Issuer: "https://issuer.example.com", ClientID: "client", JWKSURL: counter.URL}
+	if _, err := v.Verify(context.Background(), signLogoutToken(t, ks, logoutClaimSet(nil))); err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks.Rotate(key)
+	for i := 0; i < 3; i++ {
+		if _, err := v.Verify(context.Background(), signLogoutToken(t, ks, logoutClaimSet(nil))); err == nil {
+			t.Errorf("Verify() with an unknown key within the refetch interval succeeded, want error")
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("got %d JWKS fetches but want 1", fetches)
+	}
+}
+
+func TestLogoutVerifier_Handler(t *testing.T) {
+	ks, ts := newTestProvider(t)
+	v := &LogoutVerifier{Issuer: "https://issuer.example.com", ClientID: "client", JWKSURL: ts.URL}
+	var sid string
+	h := v.Handler(func(ctx context.Context, tok *LogoutToken) error {
+		sid = tok.SessionID
+		return nil
+	})
+
+	post := func(token string) *httptest.ResponseRecorder {
+		form := url.Values{"logout_token": {token}}
+		r := httptest.NewRequest("POST", "/logout", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := post(signLogoutToken(t, ks, logoutClaimSet(nil)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d but want %d", w.Code, http.StatusOK)
+	}
+	if got, want := w.Header().Get("Cache-Control"), "no-store"; got != want {
+		t.Errorf("got Cache-Control %v but want %v", got, want)
+	}
+	if sid != "session-1" {
+		t.Errorf("got sid %v but want session-1", sid)
+	}
+
+	if w := post("not-a-token"); w.Code != http.StatusBadRequest {
+		t.Errorf("got status %d for an invalid token but want %d", w.Code, http.StatusBadRequest)
+	}
+}
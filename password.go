@@ -0,0 +1,55 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth2
+
+import (
+	"context"
+	"errors"
+)
+
+// maxPasswordChallenges bounds the number of challenges answered by
+// PasswordCredentialsTokenWithChallenge, so that a misbehaving provider or
+// handler cannot loop forever.
+const maxPasswordChallenges = 5
+
+// A PasswordChallengeFunc answers a challenge returned by the token endpoint
+// in response to a resource owner password credentials request, typically
+// an "mfa_required" error carrying an mfa_token in challenge.Body.
+//
+// It returns the options of the next token request, which is built from
+// the username, password and options of the original request with these
+// options applied, e.g. SetAuthURLParam("otp", code) or, for providers
+// with a dedicated grant, SetAuthURLParam("grant_type", ...) together with
+// the challenge token. Returning an error, or no options, stops the flow
+// and fails the token request with challenge.
+type PasswordChallengeFunc func(ctx context.Context, challenge *RetrieveError) ([]AuthCodeOption, error)
+
+// PasswordCredentialsTokenWithChallenge is like PasswordCredentialsToken,
+// but calls challenge whenever the token endpoint rejects the request with
+// an OAuth 2.0 error, and retries the request with the options it returns.
+// This supports providers that require a one-time password or another
+// multi-factor response with the resource owner password credentials
+// grant. Opts may include extra parameters required by the provider up
+// front, such as a one-time password set with SetAuthURLParam("otp", code).
+func (c *Config) PasswordCredentialsTokenWithChallenge(ctx context.Context, username, password string, challenge PasswordChallengeFunc, opts ...AuthCodeOption) (*Token, error) {
+	if challenge == nil {
+		return nil, errors.New("oauth2: missing password challenge function")
+	}
+	for i := 0; ; i++ {
+		tok, err := retrieveToken(ctx, c, c.passwordCredentialsValues(username, password, opts))
+		rErr, ok := err.(*RetrieveError)
+		if !ok || rErr.ErrorCode == "" || i == maxPasswordChallenges {
+			return tok, err
+		}
+		answer, cErr := challenge(ctx, rErr)
+		if cErr != nil {
+			return nil, cErr
+		}
+		if len(answer) == 0 {
+			return nil, rErr
+		}
+		opts = append(opts[:len(opts):len(opts)], answer...)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newMFAServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("ParseForm() failed: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.PostForm.Get("mfa_token") != "mfa-123" || r.PostForm.Get("otp") != "654321" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":"mfa_required","error_description":"Multifactor authentication required","mfa_token":"mfa-123"}`))
+			return
+		}
+		if got, want := r.PostForm.Get("username"), "user1"; got != want {
+			t.Errorf("got username %v but want %v", got, want)
+		}
+		w.Write([]byte(`{"access_token":"mfa-token","token_type":"bearer","expires_in":3600}`))
+	}))
+}
+
+func TestPasswordCredentialsTokenWithChallenge(t *testing.T) {
+	ts := newMFAServer(t)
+	defer ts.Close()
+	conf := newConf(ts.URL)
+
+	calls := 0
+	challenge := func(ctx context.Context, challenge *RetrieveError) ([]AuthCodeOption, error) {
+		calls++
+		if challenge.ErrorCode != "mfa_required" {
+			t.Errorf("got error code %v but want mfa_required", challenge.ErrorCode)
+		}
+		var body struct {
+			MFAToken string `json:"mfa_token"`
+		}
+		if err := json.Unmarshal(challenge.Body, &body); err != nil {
+			return nil, err
+		}
+		return []AuthCodeOption{SetAuthURLParam("mfa_token", body.MFAToken), SetAuthURLParam("otp", "654321")}, nil
+	}
+	tok, err := conf.PasswordCredentialsTokenWithChallenge(context.Background(), "user1", "password1", challenge)
+	if err != nil {
+		t.Fatalf("PasswordCredentialsTokenWithChallenge() failed: %v", err)
+	}
+	if got, want := tok.AccessToken, "mfa-token"; got != want {
+		t.Errorf("got %v but want %v", got, want)
+	}
+	if calls != 1 {
+		t.Errorf("challenge called %d times, want 1", calls)
+	}
+}
+
+func TestPasswordCredentialsTokenWithChallenge_Declined(t *testing.T) {
+	ts := newMFAServer(t)
+	defer ts.Close()
+	conf := newConf(ts.URL)
+
+	_, err := conf.PasswordCredentialsTokenWithChallenge(context.Background(), "user1", "password1", func(context.Context, *RetrieveError) ([]AuthCodeOption, error) {
+		return nil, nil
+	})
+	var rErr *RetrieveError
+	if !errors.As(err, &rErr) || rErr.ErrorCode != "mfa_required" {
+		t.Errorf("got error %v but want the mfa_required challenge", err)
+	}
+
+	wantErr := errors.New("no authenticator")
+	_, err = conf.PasswordCredentialsTokenWithChallenge(context.Background(), "user1", "password1", func(context.Context, *RetrieveError) ([]AuthCodeOption, error) {
+		return nil, wantErr
+	})
+	if err != wantErr {
+		t.Errorf("got error %v but want %v", err, wantErr)
+	}
+}
+
+func TestPasswordCredentialsTokenWithChallenge_ExtraParams(t *testing.T) {
+	ts := newMFAServer(t)
+	defer ts.Close()
+	conf := newConf(ts.URL)
+
+	challenge := func(context.Context, *RetrieveError) ([]AuthCodeOption, error) {
+		t.Error("challenge called although the extra parameters answer it")
+		return nil, nil
+	}
+	tok, err := conf.PasswordCredentialsTokenWithChallenge(context.Background(), "user1", "password1", challenge, SetAuthURLParam("mfa_token", "mfa-123"), SetAuthURLParam("otp", "654321"))
+	if err != nil {
+		t.Fatalf("PasswordCredentialsTokenWithChallenge() failed: %v", err)
+	}
+	if got, want := tok.AccessToken, "mfa-token"; got != want {
+		t.Errorf("got %v but want %v", got, want)
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth2
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"sync"
+)
+
+const (
+	codeChallengeKey       = "code_challenge"
+	codeChallengeMethodKey = "code_challenge_method"
+	codeVerifierKey        = "code_verifier"
+)
+
+// CodeVerifier is a PKCE code verifier, see RFC 7636 section 4.1. It is kept
+// by the client and sent with the token request.
+type CodeVerifier string
+
+// GenerateVerifier returns a new random code verifier holding 32 bytes of
+// entropy. A new verifier should be generated for every authorization
+// request.
+func GenerateVerifier() CodeVerifier {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return CodeVerifier(base64.RawURLEncoding.EncodeToString(b))
+}
+
+// ChallengeMethod derives a code challenge from a code verifier.
+type ChallengeMethod func(v CodeVerifier) string
+
+var (
+	challengeMethodsMu sync.RWMutex
+	challengeMethods   = map[string]ChallengeMethod{
+		"S256": func(v CodeVerifier) string {
+			h := sha256.Sum256([]byte(v))
+			return base64.RawURLEncoding.EncodeToString(h[:])
+		},
+		"plain": func(v CodeVerifier) string {
+			return string(v)
+		},
+	}
+)
+
+// RegisterChallengeMethod registers the code challenge method name,
+// replacing any earlier registration of the same name. S256 and plain are
+// registered by default.
+func RegisterChallengeMethod(name string, m ChallengeMethod) {
+	challengeMethodsMu.Lock()
+	defer challengeMethodsMu.Unlock()
+	challengeMethods[name] = m
+}
+
+// CodeChallenge is a PKCE code challenge, sent with the authorization
+// request.
+type CodeChallenge struct {
+	// Method is the code challenge method, e.g. "S256".
+	Method string
+	// Value is the code challenge.
+	Value string
+}
+
+// Challenge derives the code challenge of v with the registered method. It
+// does not check the method against Config.PKCEMethods; challenges are only
+// sent with the authorization request through Config.ChallengeOption, which
+// does.
+func (v CodeVerifier) Challenge(method string) (CodeChallenge, error) {
+	challengeMethodsMu.RLock()
+	m, ok := challengeMethods[method]
+	challengeMethodsMu.RUnlock()
+	if !ok {
+		return CodeChallenge{}, fmt.Errorf("oauth2: unregistered code challenge method %q", method)
+	}
+	return CodeChallenge{Method: method, Value: m(v)}, nil
+}
+
+// Challenge derives the code challenge of v with the first method of
+// c.PKCEMethods, or S256 if it is empty.
+func (c *Config) Challenge(v CodeVerifier) (CodeChallenge, error) {
+	method := "S256"
+	if len(c.PKCEMethods) > 0 {
+		method = c.PKCEMethods[0]
+	}
+	return v.Challenge(method)
+}
+
+// ChallengeOption returns an AuthCodeOption sending ch with the
+// authorization request. It reports an error if the method of ch is not
+// listed in c.PKCEMethods, or is not S256 if c.PKCEMethods is empty.
+func (c *Config) ChallengeOption(ch CodeChallenge) (AuthCodeOption, error) {
+	if !c.allowsChallengeMethod(ch.Method) {
+		return nil, fmt.Errorf("oauth2: code challenge method %q is not listed in Config.PKCEMethods", ch.Method)
+	}
+	return challengeOption(ch), nil
+}
+
+// allowsChallengeMethod reports whether c may use the code challenge method.
+func (c *Config) allowsChallengeMethod(method string) bool {
+	if len(c.PKCEMethods) == 0 {
+		return method == "S256"
+	}
+	for _, m := range c.PKCEMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+type challengeOption CodeChallenge
+
+func (ch challengeOption) setValue(m url.Values) {
+	m.Set(codeChallengeKey, ch.Value)
+	m.Set(codeChallengeMethodKey, ch.Method)
+}
+
+// VerifierOption returns an AuthCodeOption sending v with the token request
+// of Config.Exchange.
+func VerifierOption(v CodeVerifier) AuthCodeOption {
+	return SetAuthURLParam(codeVerifierKey, string(v))
+}
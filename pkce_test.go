@@ -0,0 +1,105 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth2
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCodeVerifier_Challenge(t *testing.T) {
+	// Test vector of RFC 7636 appendix B.
+	v := CodeVerifier("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk")
+	ch, err := v.Challenge("S256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ch.Value, "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"; got != want {
+		t.Errorf("challenge = %q; want %q", got, want)
+	}
+	if _, err := v.Challenge("unregistered"); err == nil {
+		t.Error("Challenge with unregistered method succeeded, want error")
+	}
+}
+
+func TestConfig_Challenge(t *testing.T) {
+	RegisterChallengeMethod("reverse", func(v CodeVerifier) string {
+		b := []byte(v)
+		for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+			b[i], b[j] = b[j], b[i]
+		}
+		return string(b)
+	})
+	defer func() {
+		challengeMethodsMu.Lock()
+		delete(challengeMethods, "reverse")
+		challengeMethodsMu.Unlock()
+	}()
+
+	conf := newConf("https://example.com")
+	v := GenerateVerifier()
+	if len(v) != 43 {
+		t.Errorf("verifier length = %d; want 43", len(v))
+	}
+	ch, err := conf.Challenge(v)
+	if err != nil || ch.Method != "S256" {
+		t.Errorf("default challenge = %+v, %v; want S256", ch, err)
+	}
+	conf.PKCEMethods = []string{"reverse", "S256"}
+	ch, err = conf.Challenge("abc")
+	if err != nil || ch.Value != "cba" {
+		t.Errorf("registered challenge = %+v, %v; want cba", ch, err)
+	}
+
+	opt, err := conf.ChallengeOption(ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(conf.AuthCodeURL("state", opt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := u.Query().Get("code_challenge_method"); got != "reverse" {
+		t.Errorf("code_challenge_method = %q; want reverse", got)
+	}
+}
+
+func TestConfig_ChallengeOptionMethods(t *testing.T) {
+	plain, err := CodeVerifier("abc").Challenge("plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := newConf("https://example.com")
+	if _, err := conf.ChallengeOption(plain); err == nil {
+		t.Error("ChallengeOption with plain succeeded by default, want error")
+	}
+	conf.PKCEMethods = []string{"S256"}
+	if _, err := conf.ChallengeOption(plain); err == nil {
+		t.Error("ChallengeOption with unlisted plain succeeded, want error")
+	}
+	conf.PKCEMethods = []string{"S256", "plain"}
+	if _, err := conf.ChallengeOption(plain); err != nil {
+		t.Errorf("ChallengeOption with listed plain failed: %v", err)
+	}
+}
+
+func TestExchange_VerifierOption(t *testing.T) {
+	ts := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), "code_verifier=verifier") {
+			t.Errorf("token request body = %s; want code_verifier", body)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"token","token_type":"bearer"}`))
+	})
+	defer ts.Close()
+	if _, err := newConf(ts.URL).Exchange(context.Background(), "code", VerifierOption("verifier")); err != nil {
+		t.Fatal(err)
+	}
+}
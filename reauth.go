@@ -0,0 +1,66 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth2
+
+import (
+	"fmt"
+	"strings"
+)
+
+// refreshTokenReusePhrases are the phrases of the error descriptions with
+// which authorization servers report the reuse of a rotated refresh token,
+// e.g. Keycloak's "Maximum allowed refresh token reuse exceeded".
+var refreshTokenReusePhrases = []string{"reuse", "already used", "already been used", "replay"}
+
+// RefreshTokenReuseError is returned when a token refresh is rejected because
+// the authorization server detected the reuse of a refresh token it had
+// already rotated. Servers practicing refresh token rotation with reuse
+// detection then revoke every token issued from the same grant, so the user
+// must authenticate again; retrying the refresh cannot succeed.
+type RefreshTokenReuseError struct {
+	// Err is the invalid_grant error response of the token endpoint.
+	Err *RetrieveError
+}
+
+func (e *RefreshTokenReuseError) Error() string {
+	return fmt.Sprintf("oauth2: refresh token reuse detected, reauthentication required: %v", e.Err)
+}
+
+// Unwrap returns the error response of the token endpoint.
+func (e *RefreshTokenReuseError) Unwrap() error {
+	return e.Err
+}
+
+// isRefreshTokenReuse reports whether rErr reports the reuse of a rotated
+// refresh token.
+func isRefreshTokenReuse(rErr *RetrieveError) bool {
+	if rErr.ErrorCode != "invalid_grant" {
+		return false
+	}
+	desc := strings.ToLower(rErr.ErrorDescription)
+	for _, p := range refreshTokenReusePhrases {
+		if strings.Contains(desc, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// refreshError returns the error of a refresh rejected with rErr. An
+// invalid_grant error means that the refresh token can no longer be used, so
+// c.ReauthenticationRequired is called with it.
+func (c *Config) refreshError(rErr *RetrieveError) error {
+	if rErr.ErrorCode != "invalid_grant" {
+		return rErr
+	}
+	var err error = rErr
+	if isRefreshTokenReuse(rErr) {
+		err = &RefreshTokenReuseError{Err: rErr}
+	}
+	if c.ReauthenticationRequired != nil {
+		c.ReauthenticationRequired(err)
+	}
+	return err
+}
@@ -0,0 +1,110 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth2
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRefreshTokenReuse(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantReuse bool
+	}{
+		{"reuse", `{"error": "invalid_grant", "error_description": "Maximum allowed refresh token reuse exceeded"}`, true},
+		{"revoked", `{"error": "invalid_grant", "error_description": "Token is not active"}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			ts := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(tt.body))
+			})
+			defer ts.Close()
+
+			var reauthErrs []error
+			conf := newConf(ts.URL)
+			conf.Endpoint.AuthStyle = AuthStyleInParams
+			conf.ReauthenticationRequired = func(err error) { reauthErrs = append(reauthErrs, err) }
+			src := conf.TokenSource(context.Background(), &Token{RefreshToken: "rotated"})
+			_, err := src.Token()
+			var reuseErr *RefreshTokenReuseError
+			if got := errors.As(err, &reuseErr); got != tt.wantReuse {
+				t.Errorf("got error %v, want a *RefreshTokenReuseError: %v", err, tt.wantReuse)
+			}
+			var rErr *RetrieveError
+			if !errors.As(err, &rErr) || rErr.ErrorCode != "invalid_grant" {
+				t.Errorf("got error %v but want an invalid_grant *RetrieveError", err)
+			}
+			if len(reauthErrs) != 1 || reauthErrs[0] != err {
+				t.Errorf("ReauthenticationRequired called with %v but want [%v]", reauthErrs, err)
+			}
+
+			src.Token()
+			wantRequests := 2
+			if tt.wantReuse {
+				wantRequests = 1
+			}
+			if requests != wantRequests {
+				t.Errorf("got %d token requests but want %d", requests, wantRequests)
+			}
+		})
+	}
+}
+
+func TestRefreshTokenReuse_OtherErrors(t *testing.T) {
+	ts := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "invalid_request", "error_description": "refresh token reuse"}`))
+	})
+	defer ts.Close()
+
+	conf := newConf(ts.URL)
+	conf.ReauthenticationRequired = func(err error) { t.Errorf("ReauthenticationRequired called with %v", err) }
+	_, err := conf.TokenSource(context.Background(), &Token{RefreshToken: "rotated"}).Token()
+	if _, ok := err.(*RetrieveError); !ok {
+		t.Errorf("got error %T but want *RetrieveError", err)
+	}
+}
+
+func TestRefreshTokenReuse_RefreshIfStale(t *testing.T) {
+	ts := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.FormValue("refresh_token") == "rotated" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "invalid_grant", "error_description": "Maximum allowed refresh token reuse exceeded"}`))
+			return
+		}
+		w.Write([]byte(`{"access_token": "access", "refresh_token": "next", "token_type": "bearer", "expires_in": 3600}`))
+	})
+	defer ts.Close()
+
+	conf := newConf(ts.URL)
+	conf.Endpoint.AuthStyle = AuthStyleInParams
+	src := conf.TokenSource(context.Background(), &Token{RefreshToken: "rotated"})
+	var reuseErr *RefreshTokenReuseError
+	if _, err := src.Token(); !errors.As(err, &reuseErr) {
+		t.Fatalf("got error %v but want a *RefreshTokenReuseError", err)
+	}
+
+	// A newly obtained refresh token is used despite the reuse of the old one.
+	current := &Token{RefreshToken: "new", Expiry: time.Now().Add(-time.Minute)}
+	tok, err := RefreshIfStale(context.Background(), src, current)
+	if err != nil {
+		t.Fatalf("RefreshIfStale() failed: %v", err)
+	}
+	if got, want := tok.AccessToken, "access"; got != want {
+		t.Errorf("got %v but want %v", got, want)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth2
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Route pairs a request matcher with the TokenSource that authorizes the
+// requests it matches.
+type Route struct {
+	// Match reports whether the request is sent with tokens of Source.
+	Match func(*http.Request) bool
+	// Source supplies the tokens of matching requests.
+	Source TokenSource
+}
+
+// MatchHost returns a matcher for requests to any of hosts. Hosts are
+// compared case-insensitively, including the port if one is given.
+func MatchHost(hosts ...string) func(*http.Request) bool {
+	return func(r *http.Request) bool {
+		for _, h := range hosts {
+			if strings.EqualFold(r.URL.Host, h) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MatchPathPrefix returns a matcher for requests to host whose path starts
+// with prefix.
+func MatchPathPrefix(host, prefix string) func(*http.Request) bool {
+	return func(r *http.Request) bool {
+		return strings.EqualFold(r.URL.Host, host) && strings.HasPrefix(r.URL.Path, prefix)
+	}
+}
+
+// RoutingTransport is an http.RoundTripper that authorizes each request with
+// the TokenSource of the first Route matching it, so that a single client can
+// call services expecting tokens for different audiences, such as regional or
+// per-tenant APIs.
+type RoutingTransport struct {
+	// Routes are tried in order.
+	Routes []Route
+
+	// Default supplies the tokens of requests no route matches. If nil, such
+	// requests fail rather than being sent without credentials.
+	Default TokenSource
+
+	// Base is the base RoundTripper used to make HTTP requests.
+	// If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+}
+
+// RoundTrip authorizes the request with a token from the matching route's
+// Source and sends it with Base.
+func (t *RoutingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	src := t.Default
+	for _, r := range t.Routes {
+		if r.Match(req) {
+			src = r.Source
+			break
+		}
+	}
+	if src == nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("oauth2: no TokenSource routed for request to %s%s", req.URL.Host, req.URL.Path)
+	}
+	return (&Transport{Source: src, Base: t.Base}).RoundTrip(req)
+}
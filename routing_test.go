@@ -0,0 +1,53 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth2
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestRoutingTransport(t *testing.T) {
+	server := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization")))
+	})
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr := &RoutingTransport{
+		Routes: []Route{
+			{Match: MatchPathPrefix(u.Host, "/tenant-a/"), Source: StaticTokenSource(&Token{AccessToken: "a"})},
+			{Match: MatchHost(u.Host), Source: StaticTokenSource(&Token{AccessToken: "host"})},
+		},
+	}
+	client := &http.Client{Transport: tr}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/tenant-a/resource", "Bearer a"},
+		{"/tenant-b/resource", "Bearer host"},
+	}
+	for _, tt := range tests {
+		resp, err := client.Get(server.URL + tt.path)
+		if err != nil {
+			t.Fatalf("Get(%s) failed: %v", tt.path, err)
+		}
+		var buf [64]byte
+		n, _ := resp.Body.Read(buf[:])
+		resp.Body.Close()
+		if got := string(buf[:n]); got != tt.want {
+			t.Errorf("Get(%s) authorization = %q; want %q", tt.path, got, tt.want)
+		}
+	}
+
+	if _, err := client.Get("http://unrouted.example.com/"); err == nil {
+		t.Error("request without a matching route succeeded, want error")
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth2
+
+import "context"
+
+// StaleRefresher is implemented by TokenSources that can refresh a token held
+// by their caller, such as a token kept in a cache shared by the replicas of
+// a service. Passing the cached token in lets the source skip the upstream
+// refresh when the token is still valid, or when another caller has already
+// refreshed it, so that replicas sharing a cache do not all refresh at once.
+//
+// The TokenSources returned by Config.TokenSource and ReuseTokenSource
+// implement StaleRefresher.
+type StaleRefresher interface {
+	TokenSource
+
+	// RefreshIfStale returns current if it is valid. Otherwise it returns
+	// the token held by the source if that one is valid and differs from
+	// current, and a newly refreshed token as a last resort. If current
+	// carries a refresh token, it is used for the refresh. ctx is checked
+	// before refreshing.
+	RefreshIfStale(ctx context.Context, current *Token) (*Token, error)
+}
+
+// RefreshIfStale returns a valid token for a caller holding current, which may
+// be nil. It calls ts.RefreshIfStale if ts implements StaleRefresher.
+// Otherwise it returns current if it is valid, and the result of ts.Token()
+// if not.
+func RefreshIfStale(ctx context.Context, ts TokenSource, current *Token) (*Token, error) {
+	if sr, ok := ts.(StaleRefresher); ok {
+		return sr.RefreshIfStale(ctx, current)
+	}
+	if current.Valid() {
+		return current, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return ts.Token()
+}
+
+// RefreshIfStale implements StaleRefresher. A valid current token replaces
+// the held token if it expires later. A copy of current is held, as current
+// may be shared with other goroutines and must not be modified.
+func (s *reuseTokenSource) RefreshIfStale(ctx context.Context, current *Token) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if current.Valid() {
+		if !s.t.Valid() || current.Expiry.After(s.t.Expiry) {
+			c := *current
+			s.setLocked(&c)
+		}
+		return current, nil
+	}
+	if s.t.Valid() && (current == nil || s.t.AccessToken != current.AccessToken) {
+		return s.t, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if tf, ok := s.new.(*tokenRefresher); ok && current != nil && current.RefreshToken != "" {
+		// The shared copy carries the latest refresh token, which may have
+		// been rotated by another replica.
+		s.newMu.Lock()
+		tf.refreshToken = current.RefreshToken
+		s.newMu.Unlock()
+	}
+	return s.refreshLocked()
+}
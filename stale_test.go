@@ -0,0 +1,136 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth2
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRefreshIfStale(t *testing.T) {
+	var refreshes int
+	var gotRefreshToken string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		refreshes++
+		gotRefreshToken = r.FormValue("refresh_token")
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"access-%d","refresh_token":"refresh-%d","token_type":"bearer","expires_in":3600}`, refreshes, refreshes)
+	}))
+	defer ts.Close()
+	conf := newConf(ts.URL)
+	src := conf.TokenSource(context.Background(), &Token{RefreshToken: "refresh-0"})
+	sr, ok := src.(StaleRefresher)
+	if !ok {
+		t.Fatalf("Config.TokenSource() returned %T, which does not implement StaleRefresher", src)
+	}
+	ctx := context.Background()
+
+	// A valid cached token is returned without a refresh.
+	cached := &Token{AccessToken: "cached", Expiry: time.Now().Add(time.Hour)}
+	tok, err := sr.RefreshIfStale(ctx, cached)
+	if err != nil {
+		t.Fatalf("RefreshIfStale() failed: %v", err)
+	}
+	if tok != cached || refreshes != 0 {
+		t.Errorf("got token %v after %d refreshes but want the cached token and no refresh", tok.AccessToken, refreshes)
+	}
+
+	// A stale token is refreshed with the refresh token it carries.
+	stale := &Token{AccessToken: "cached", RefreshToken: "rotated", Expiry: time.Now().Add(-time.Minute)}
+	tok, err = sr.RefreshIfStale(ctx, stale)
+	if err != nil {
+		t.Fatalf("RefreshIfStale() failed: %v", err)
+	}
+	if got, want := tok.AccessToken, "access-1"; got != want {
+		t.Errorf("got %v but want %v", got, want)
+	}
+	if got, want := gotRefreshToken, "rotated"; got != want {
+		t.Errorf("got refresh_token %v but want %v", got, want)
+	}
+
+	// Another replica holding the same stale token gets the token already
+	// refreshed, without another refresh.
+	tok, err = sr.RefreshIfStale(ctx, stale)
+	if err != nil {
+		t.Fatalf("RefreshIfStale() failed: %v", err)
+	}
+	if got, want := tok.AccessToken, "access-1"; got != want || refreshes != 1 {
+		t.Errorf("got %v after %d refreshes but want %v after 1", got, refreshes, want)
+	}
+
+	// A stale copy of the held token is refreshed.
+	held, _ := src.Token()
+	held = &Token{AccessToken: held.AccessToken, Expiry: time.Now().Add(-time.Minute)}
+	if _, err := sr.RefreshIfStale(ctx, held); err != nil {
+		t.Fatalf("RefreshIfStale() failed: %v", err)
+	}
+	if refreshes != 2 {
+		t.Errorf("got %d refreshes but want 2", refreshes)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := sr.RefreshIfStale(cancelled, &Token{AccessToken: "access-2", Expiry: time.Now().Add(-time.Minute)}); err != context.Canceled {
+		t.Errorf("got error %v but want %v", err, context.Canceled)
+	}
+}
+
+func TestRefreshIfStale_TokenSource(t *testing.T) {
+	src := &countingTokenSource{tok: &Token{AccessToken: "new"}}
+	valid := &Token{AccessToken: "valid"}
+	if tok, err := RefreshIfStale(context.Background(), src, valid); err != nil || tok != valid {
+		t.Errorf("got %v, %v but want the valid token", tok, err)
+	}
+	tok, err := RefreshIfStale(context.Background(), src, nil)
+	if err != nil || tok.AccessToken != "new" || src.calls != 1 {
+		t.Errorf("got %v, %v after %d calls but want a new token after 1", tok, err, src.calls)
+	}
+}
+
+func TestRefreshIfStale_ExpiryDelta(t *testing.T) {
+	src := &shortLivedTokenSource{lifetime: 2 * time.Hour}
+	ts := ReuseTokenSourceWithExpiry(nil, src, time.Hour).(*reuseTokenSource)
+
+	// The adopted token is held with the early expiry of ts, so it is
+	// replaced by Token although it remains valid by default.
+	current := &Token{AccessToken: "current", Expiry: time.Now().Add(30 * time.Minute)}
+	if _, err := ts.RefreshIfStale(context.Background(), current); err != nil {
+		t.Fatalf("RefreshIfStale() failed: %v", err)
+	}
+	tok, err := ts.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.AccessToken == "current" || src.callCount() != 1 {
+		t.Errorf("got token %v after %d calls but want a new token", tok.AccessToken, src.callCount())
+	}
+}
+
+func TestRefreshIfStale_SharedToken(t *testing.T) {
+	src := &shortLivedTokenSource{lifetime: 2 * time.Hour}
+	ts := ReuseTokenSourceWithJitter(nil, src, time.Hour, time.Minute).(*reuseTokenSource)
+	shared := &Token{AccessToken: "shared", Expiry: time.Now().Add(3 * time.Hour)}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			shared.Valid()
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if _, err := ts.RefreshIfStale(context.Background(), shared); err != nil {
+			t.Fatalf("RefreshIfStale() failed: %v", err)
+		}
+	}
+	<-done
+	if shared.expiryDelta != 0 {
+		t.Errorf("RefreshIfStale() set the expiry delta of the token passed in to %v", shared.expiryDelta)
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth2
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// TokenStore persists a token, typically the refresh token of a signed-in
+// user, between runs of a program.
+type TokenStore interface {
+	// Load returns the stored token, or nil and no error if no token is
+	// stored.
+	Load() (*Token, error)
+	// Save replaces the stored token with t.
+	Save(t *Token) error
+}
+
+// KeyWrapper protects the data keys of an encrypted TokenStore. It can be
+// implemented with a platform keyring or a key management service; a
+// KeyWrapper for a caller-provided key is returned by AESKeyWrapper.
+type KeyWrapper interface {
+	// WrapKey encrypts a data key.
+	WrapKey(key []byte) ([]byte, error)
+	// UnwrapKey decrypts a data key encrypted by WrapKey.
+	UnwrapKey(wrapped []byte) ([]byte, error)
+}
+
+// FileTokenStore is a TokenStore keeping the token in a file readable only
+// by its owner.
+//
+// The token is envelope encrypted: it is sealed with AES-256-GCM under a
+// random data key generated for every Save, and the data key is stored
+// alongside it wrapped by Key. Only with Insecure set is the token stored
+// as plain JSON instead; such files are rejected when loaded with a Key.
+type FileTokenStore struct {
+	// Path is the location of the file. Required.
+	Path string
+	// Key protects the stored token. Required unless Insecure is set.
+	Key KeyWrapper
+	// Insecure stores the token unencrypted when Key is nil, relying on
+	// the file permissions alone to protect it.
+	Insecure bool
+}
+
+// errNoKeyWrapper is returned by FileTokenStore without a Key or Insecure.
+var errNoKeyWrapper = errors.New("oauth2: FileTokenStore requires a Key, or Insecure to store tokens unencrypted")
+
+// encryptedToken is the file format of FileTokenStore with a Key.
+type encryptedToken struct {
+	Version    int    `json:"version"`
+	WrappedKey []byte `json:"wrapped_key"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// Load reads the token from s.Path.
+func (s *FileTokenStore) Load() (*Token, error) {
+	if s.Key == nil && !s.Insecure {
+		return nil, errNoKeyWrapper
+	}
+	b, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s.Key != nil {
+		b, err = s.open(b)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var t Token
+	if err := json.Unmarshal(b, &t); err != nil {
+		return nil, fmt.Errorf("oauth2: cannot parse stored token: %v", err)
+	}
+	return &t, nil
+}
+
+// Save writes t to s.Path, replacing the previous file atomically.
+func (s *FileTokenStore) Save(t *Token) error {
+	if s.Key == nil && !s.Insecure {
+		return errNoKeyWrapper
+	}
+	b, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	if s.Key != nil {
+		b, err = s.seal(b)
+		if err != nil {
+			return err
+		}
+	}
+	return writeFileAtomic(s.Path, b, 0600)
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it over path, so that readers never observe a partial file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *FileTokenStore) seal(plaintext []byte) ([]byte, error) {
+	dek := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dek); err != nil {
+		return nil, err
+	}
+	nonce, ciphertext, err := aesGCMSeal(dek, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := s.Key.WrapKey(dek)
+	if err != nil {
+		return nil, fmt.Errorf("oauth2: cannot wrap data key: %v", err)
+	}
+	return json.Marshal(encryptedToken{Version: 1, WrappedKey: wrapped, Nonce: nonce, Ciphertext: ciphertext})
+}
+
+func (s *FileTokenStore) open(b []byte) ([]byte, error) {
+	var e encryptedToken
+	if err := json.Unmarshal(b, &e); err != nil || e.Version != 1 {
+		return nil, errors.New("oauth2: stored token is not encrypted")
+	}
+	dek, err := s.Key.UnwrapKey(e.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("oauth2: cannot unwrap data key: %v", err)
+	}
+	return aesGCMOpen(dek, e.Nonce, e.Ciphertext)
+}
+
+// AESKeyWrapper returns a KeyWrapper that wraps data keys with AES-GCM under
+// key, which must be 16, 24 or 32 bytes long.
+func AESKeyWrapper(key []byte) (KeyWrapper, error) {
+	if _, err := aes.NewCipher(key); err != nil {
+		return nil, fmt.Errorf("oauth2: invalid key: %v", err)
+	}
+	return aesKeyWrapper(append([]byte(nil), key...)), nil
+}
+
+type aesKeyWrapper []byte
+
+func (k aesKeyWrapper) WrapKey(dek []byte) ([]byte, error) {
+	nonce, ciphertext, err := aesGCMSeal(k, dek)
+	if err != nil {
+		return nil, err
+	}
+	return append(nonce, ciphertext...), nil
+}
+
+func (k aesKeyWrapper) UnwrapKey(wrapped []byte) ([]byte, error) {
+	gcm, err := newGCM(k)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < gcm.NonceSize() {
+		return nil, errors.New("oauth2: wrapped key too short")
+	}
+	return gcm.Open(nil, wrapped[:gcm.NonceSize()], wrapped[gcm.NonceSize():], nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func aesGCMSeal(key, plaintext []byte) (nonce, ciphertext []byte, err error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce = make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, nil, err
+	}
+	return nonce, gcm.Seal(nil, nonce, plaintext, nil), nil
+}
+
+func aesGCMOpen(key, nonce, ciphertext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, errors.New("oauth2: invalid nonce")
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("oauth2: cannot decrypt stored token: %v", err)
+	}
+	return plaintext, nil
+}
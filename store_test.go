@@ -0,0 +1,91 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth2
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileTokenStore(t *testing.T) {
+	key, err := AESKeyWrapper(bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name string
+		key  KeyWrapper
+	}{
+		{"plain", nil},
+		{"encrypted", key},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &FileTokenStore{Path: filepath.Join(t.TempDir(), "token.json"), Key: tt.key, Insecure: tt.key == nil}
+			if tok, err := s.Load(); tok != nil || err != nil {
+				t.Fatalf("Load() of missing file = %v, %v; want nil, nil", tok, err)
+			}
+			want := &Token{AccessToken: "access", RefreshToken: "refresh-secret", Expiry: time.Now().Round(0).Truncate(time.Second)}
+			if err := s.Save(want); err != nil {
+				t.Fatalf("Save() failed: %v", err)
+			}
+			got, err := s.Load()
+			if err != nil {
+				t.Fatalf("Load() failed: %v", err)
+			}
+			if got.RefreshToken != want.RefreshToken || !got.Expiry.Equal(want.Expiry) {
+				t.Errorf("Load() = %+v; want %+v", got, want)
+			}
+
+			b, err := os.ReadFile(s.Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if encrypted := !bytes.Contains(b, []byte("refresh-secret")); encrypted != (tt.key != nil) {
+				t.Errorf("file content %s encrypted = %v; want %v", b, encrypted, tt.key != nil)
+			}
+			fi, err := os.Stat(s.Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mode := fi.Mode().Perm(); mode != 0600 {
+				t.Errorf("file mode = %v; want 0600", mode)
+			}
+		})
+	}
+}
+
+func TestFileTokenStore_WrongKey(t *testing.T) {
+	key1, _ := AESKeyWrapper(bytes.Repeat([]byte{1}, 32))
+	key2, _ := AESKeyWrapper(bytes.Repeat([]byte{2}, 32))
+	path := filepath.Join(t.TempDir(), "token.json")
+	if err := (&FileTokenStore{Path: path, Key: key1}).Save(&Token{RefreshToken: "refresh"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&FileTokenStore{Path: path, Key: key2}).Load(); err == nil {
+		t.Error("Load() with the wrong key succeeded, want error")
+	}
+	if err := (&FileTokenStore{Path: path, Insecure: true}).Save(&Token{RefreshToken: "refresh"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&FileTokenStore{Path: path, Key: key1}).Load(); err == nil {
+		t.Error("Load() of a plain file with a key succeeded, want error")
+	}
+}
+
+func TestFileTokenStore_NoKey(t *testing.T) {
+	s := &FileTokenStore{Path: filepath.Join(t.TempDir(), "token.json")}
+	if err := s.Save(&Token{RefreshToken: "refresh"}); err == nil {
+		t.Error("Save() without a key succeeded, want error")
+	}
+	if _, err := os.Stat(s.Path); !os.IsNotExist(err) {
+		t.Errorf("Save() without a key wrote the file: %v", err)
+	}
+	if _, err := s.Load(); err == nil {
+		t.Error("Load() without a key succeeded, want error")
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/oauth2/internal"
@@ -49,6 +50,11 @@ type Token struct {
 	// mechanisms for that TokenSource will not be used.
 	Expiry time.Time `json:"expiry,omitempty"`
 
+	// IssuedAt is the time the token endpoint response carrying the
+	// token was received, or zero if unknown. It lets caching layers
+	// compute the age of a token.
+	IssuedAt time.Time `json:"issued_at,omitempty"`
+
 	// raw optionally contains extra metadata from the server
 	// when updating a token.
 	raw interface{}
@@ -59,16 +65,51 @@ type Token struct {
 	expiryDelta time.Duration
 }
 
-// Type returns t.TokenType if non-empty, else "Bearer".
-func (t *Token) Type() string {
-	if strings.EqualFold(t.TokenType, "bearer") {
-		return "Bearer"
-	}
-	if strings.EqualFold(t.TokenType, "mac") {
-		return "MAC"
+// TokenTypeHandler describes how tokens of a registered type authorize
+// requests.
+type TokenTypeHandler struct {
+	// Scheme is the canonical spelling of the authorization scheme returned
+	// by Token.Type, e.g. "DPoP".
+	Scheme string
+
+	// SetAuthHeader, if non-nil, authorizes r with t instead of setting the
+	// Authorization header to Scheme followed by the access token. It can
+	// add further headers, such as proof-of-possession headers.
+	SetAuthHeader func(t *Token, r *http.Request)
+}
+
+var (
+	tokenTypesMu sync.RWMutex
+	tokenTypes   = map[string]TokenTypeHandler{
+		"bearer": {Scheme: "Bearer"},
+		"mac":    {Scheme: "MAC"},
+		"basic":  {Scheme: "Basic"},
+		"dpop":   {Scheme: "DPoP"},
 	}
-	if strings.EqualFold(t.TokenType, "basic") {
-		return "Basic"
+)
+
+// RegisterTokenType registers how tokens whose TokenType matches tokenType,
+// compared case-insensitively, are sent. It replaces any earlier
+// registration for the same type. It is typically called from an init
+// function of a package implementing a token scheme.
+func RegisterTokenType(tokenType string, h TokenTypeHandler) {
+	tokenTypesMu.Lock()
+	defer tokenTypesMu.Unlock()
+	tokenTypes[strings.ToLower(tokenType)] = h
+}
+
+func lookupTokenType(tokenType string) (TokenTypeHandler, bool) {
+	tokenTypesMu.RLock()
+	defer tokenTypesMu.RUnlock()
+	h, ok := tokenTypes[strings.ToLower(tokenType)]
+	return h, ok
+}
+
+// Type returns the canonical scheme of a registered t.TokenType, else
+// t.TokenType if non-empty, else "Bearer".
+func (t *Token) Type() string {
+	if h, ok := lookupTokenType(t.TokenType); ok && h.Scheme != "" {
+		return h.Scheme
 	}
 	if t.TokenType != "" {
 		return t.TokenType
@@ -77,11 +118,16 @@ func (t *Token) Type() string {
 }
 
 // SetAuthHeader sets the Authorization header to r using the access
-// token in t.
+// token in t, or authorizes r as registered for t.TokenType with
+// RegisterTokenType.
 //
 // This method is unnecessary when using Transport or an HTTP Client
 // returned by this package.
 func (t *Token) SetAuthHeader(r *http.Request) {
+	if h, ok := lookupTokenType(t.TokenType); ok && h.SetAuthHeader != nil {
+		h.SetAuthHeader(t, r)
+		return
+	}
 	r.Header.Set("Authorization", t.Type()+" "+t.AccessToken)
 }
 
@@ -123,6 +169,22 @@ func (t *Token) Extra(key string) interface{} {
 	return v
 }
 
+// RawResponse returns the fields of the token endpoint response t was
+// parsed from, for inspecting provider-specific fields. Fields of
+// form-encoded responses map to their first value. It returns nil if t was
+// not obtained from a token endpoint or carries no extra metadata.
+func (t *Token) RawResponse() map[string]interface{} {
+	m := internal.RawResponse(t.raw)
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
 // timeNow is time.Now but pulled out as a variable for tests.
 var timeNow = time.Now
 
@@ -156,6 +218,7 @@ func tokenFromInternal(t *internal.Token) *Token {
 		TokenType:    t.TokenType,
 		RefreshToken: t.RefreshToken,
 		Expiry:       t.Expiry,
+		IssuedAt:     t.IssuedAt,
 		raw:          t.Raw,
 	}
 }
@@ -164,13 +227,31 @@ func tokenFromInternal(t *internal.Token) *Token {
 // This token is then mapped from *internal.Token into an *oauth2.Token which is returned along
 // with an error..
 func retrieveToken(ctx context.Context, c *Config, v url.Values) (*Token, error) {
-	tk, err := internal.RetrieveToken(ctx, c.ClientID, c.ClientSecret, c.Endpoint.TokenURL, v, internal.AuthStyle(c.Endpoint.AuthStyle))
+	if err := c.checkClientType(); err != nil {
+		return nil, err
+	}
+	authStyle := c.Endpoint.AuthStyle
+	if c.ClientType == ClientTypePublic {
+		// Public clients have no secret to send in a header.
+		authStyle = AuthStyleInParams
+	}
+	tk, err := internal.RetrieveToken(ctx, c.ClientID, c.ClientSecret, c.Endpoint.TokenURL, v, internal.AuthStyle(authStyle))
 	if err != nil {
 		if rErr, ok := err.(*internal.RetrieveError); ok {
-			return nil, (*RetrieveError)(rErr)
+			retrieveErr := (*RetrieveError)(rErr)
+			if c.ErrorHook != nil {
+				c.ErrorHook(retrieveErr.SanitizedResponse())
+			}
+			if authStyle == AuthStyleStrict && internal.IsClientAuthError(rErr) {
+				return nil, &AuthStyleError{Err: retrieveErr}
+			}
+			return nil, retrieveErr
 		}
 		return nil, err
 	}
+	if c.ResponseHook != nil {
+		c.ResponseHook(&TokenResponseMetadata{StatusCode: tk.StatusCode, Header: internal.SanitizeHeader(tk.Header)})
+	}
 	return tokenFromInternal(tk), nil
 }
 
@@ -5,6 +5,7 @@
 package oauth2
 
 import (
+	"net/http"
 	"testing"
 	"time"
 )
@@ -71,6 +72,8 @@ func TestTokenTypeMethod(t *testing.T) {
 		{name: "mac", tok: &Token{TokenType: "mac"}, want: "MAC"},
 		{name: "mac-caps", tok: &Token{TokenType: "MAC"}, want: "MAC"},
 		{name: "mac-mixed_case", tok: &Token{TokenType: "mAc"}, want: "MAC"},
+		{name: "dpop", tok: &Token{TokenType: "dpop"}, want: "DPoP"},
+		{name: "unregistered", tok: &Token{TokenType: "Vendor"}, want: "Vendor"},
 	}
 	for _, tc := range cases {
 		if got, want := tc.tok.Type(), tc.want; got != want {
@@ -78,3 +81,31 @@ func TestTokenTypeMethod(t *testing.T) {
 		}
 	}
 }
+
+func TestRegisterTokenType(t *testing.T) {
+	RegisterTokenType("Vendor-PoP", TokenTypeHandler{
+		Scheme: "Vendor-PoP",
+		SetAuthHeader: func(tok *Token, r *http.Request) {
+			r.Header.Set("Authorization", "Vendor-PoP "+tok.AccessToken)
+			r.Header.Set("Vendor-Proof", "proof")
+		},
+	})
+	defer func() {
+		tokenTypesMu.Lock()
+		delete(tokenTypes, "vendor-pop")
+		tokenTypesMu.Unlock()
+	}()
+
+	tok := &Token{AccessToken: "abc", TokenType: "vendor-pop"}
+	if got, want := tok.Type(), "Vendor-PoP"; got != want {
+		t.Errorf("Type() = %v; want %v", got, want)
+	}
+	r, _ := http.NewRequest("GET", "https://example.com", nil)
+	tok.SetAuthHeader(r)
+	if got, want := r.Header.Get("Authorization"), "Vendor-PoP abc"; got != want {
+		t.Errorf("Authorization = %v; want %v", got, want)
+	}
+	if got, want := r.Header.Get("Vendor-Proof"), "proof"; got != want {
+		t.Errorf("Vendor-Proof = %v; want %v", got, want)
+	}
+}
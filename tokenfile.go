@@ -0,0 +1,169 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// TokenFileFormat is the layout of a file written by a TokenFileWriter.
+type TokenFileFormat int
+
+const (
+	// TokenFileRaw writes the access token alone, as expected by programs
+	// reading bearer tokens from files.
+	TokenFileRaw TokenFileFormat = iota
+
+	// TokenFileJSON writes the token as JSON, with the access_token,
+	// token_type and expiry fields of Token.
+	TokenFileJSON
+
+	// TokenFileProjected writes the access token alone, using the layout
+	// of Kubernetes projected volumes: the token lives in a timestamped
+	// directory next to Path, a "..data" symlink points to the current
+	// directory and Path is a symlink through "..data". Every update
+	// swaps "..data" atomically.
+	TokenFileProjected
+)
+
+// dataDirName is the symlink to the current directory of a
+// TokenFileProjected file.
+const dataDirName = "..data"
+
+// TokenFileWriter keeps a file updated with fresh tokens from a TokenSource,
+// for workloads that read credentials from files rather than through this
+// package. Files are replaced atomically, so readers never observe a
+// partially written token.
+//
+// Refresh tokens are never written.
+type TokenFileWriter struct {
+	// Source provides the tokens. Required.
+	Source TokenSource
+
+	// Path is the location of the file. Required.
+	Path string
+
+	// Format is the layout of the file. The default is TokenFileRaw.
+	Format TokenFileFormat
+
+	// Mode is the permission of the file. The default is 0600.
+	Mode os.FileMode
+
+	// RefreshBefore is how long before the expiry of the written token a
+	// new one is written by Run. The default is ten seconds.
+	RefreshBefore time.Duration
+
+	// OnError, if non-nil, is called by Run with every failure to retrieve
+	// or write a token. Run keeps going after errors.
+	OnError func(error)
+}
+
+// Write retrieves a token from w.Source and writes it to w.Path.
+func (w *TokenFileWriter) Write() error {
+	tok, err := w.Source.Token()
+	if err != nil {
+		return err
+	}
+	return w.write(tok)
+}
+
+// Run writes a token and keeps replacing it as tokens are refreshed until
+// ctx is done, when it returns ctx.Err().
+func (w *TokenFileWriter) Run(ctx context.Context) error {
+	it := &TokenIterator{Source: w.Source, RefreshBefore: w.RefreshBefore}
+	for {
+		tok, err := it.Next(ctx)
+		if err == nil {
+			err = w.write(tok)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil && w.OnError != nil {
+			w.OnError(err)
+		}
+	}
+}
+
+func (w *TokenFileWriter) write(tok *Token) error {
+	if tok.AccessToken == "" {
+		return fmt.Errorf("oauth2: cannot write token file %s: empty access token", w.Path)
+	}
+	mode := w.Mode
+	if mode == 0 {
+		mode = 0600
+	}
+	var err error
+	switch w.Format {
+	case TokenFileRaw:
+		err = writeFileAtomic(w.Path, []byte(tok.AccessToken), mode)
+	case TokenFileJSON:
+		var b []byte
+		b, err = json.Marshal(&Token{AccessToken: tok.AccessToken, TokenType: tok.TokenType, Expiry: tok.Expiry})
+		if err == nil {
+			err = writeFileAtomic(w.Path, b, mode)
+		}
+	case TokenFileProjected:
+		err = writeProjected(w.Path, []byte(tok.AccessToken), mode)
+	default:
+		return fmt.Errorf("oauth2: unknown token file format %d", w.Format)
+	}
+	if err != nil {
+		return fmt.Errorf("oauth2: cannot write token file %s: %v", w.Path, err)
+	}
+	return nil
+}
+
+// writeProjected writes data in the layout described by TokenFileProjected.
+func writeProjected(path string, data []byte, perm os.FileMode) error {
+	dir, name := filepath.Dir(path), filepath.Base(path)
+	dataDir := filepath.Join(dir, dataDirName)
+	old, _ := os.Readlink(dataDir)
+
+	tsDir, err := os.MkdirTemp(dir, ".."+time.Now().UTC().Format("2006_01_02_15_04_05."))
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(tsDir, 0755); err != nil {
+		os.RemoveAll(tsDir)
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(tsDir, name), data, perm); err != nil {
+		os.RemoveAll(tsDir)
+		return err
+	}
+	// os.WriteFile only applies perm to new files through the umask.
+	if err := os.Chmod(filepath.Join(tsDir, name), perm); err != nil {
+		os.RemoveAll(tsDir)
+		return err
+	}
+
+	tmpLink := dataDir + "_tmp"
+	os.Remove(tmpLink)
+	if err := os.Symlink(filepath.Base(tsDir), tmpLink); err != nil {
+		os.RemoveAll(tsDir)
+		return err
+	}
+	if err := os.Rename(tmpLink, dataDir); err != nil {
+		os.Remove(tmpLink)
+		os.RemoveAll(tsDir)
+		return err
+	}
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		if err := os.Symlink(filepath.Join(dataDirName, name), path); err != nil {
+			return err
+		}
+	}
+	if strings.HasPrefix(old, "..") && !strings.ContainsRune(old, filepath.Separator) && old != filepath.Base(tsDir) && old != dataDirName {
+		os.RemoveAll(filepath.Join(dir, old))
+	}
+	return nil
+}
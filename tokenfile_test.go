@@ -0,0 +1,83 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth2
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTokenFileWriter(t *testing.T) {
+	tok := &Token{AccessToken: "abc", TokenType: "Bearer", RefreshToken: "secret", Expiry: time.Now().Add(time.Hour).Round(time.Second)}
+	dir := t.TempDir()
+
+	raw := &TokenFileWriter{Source: StaticTokenSource(tok), Path: filepath.Join(dir, "raw")}
+	if err := raw.Write(); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	b, err := os.ReadFile(raw.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "abc"; got != want {
+		t.Errorf("got %v but want %v", got, want)
+	}
+	if fi, err := os.Stat(raw.Path); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("got mode %v, %v but want 0600", fi.Mode().Perm(), err)
+	}
+
+	js := &TokenFileWriter{Source: StaticTokenSource(tok), Path: filepath.Join(dir, "token.json"), Format: TokenFileJSON, Mode: 0644}
+	if err := js.Write(); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	b, err = os.ReadFile(js.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Token
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.AccessToken != "abc" || got.RefreshToken != "" || !got.Expiry.Equal(tok.Expiry) {
+		t.Errorf("got %+v but want the access token and expiry without the refresh token", got)
+	}
+	if fi, err := os.Stat(js.Path); err != nil || fi.Mode().Perm() != 0644 {
+		t.Errorf("got mode %v, %v but want 0644", fi.Mode().Perm(), err)
+	}
+}
+
+func TestTokenFileWriter_Projected(t *testing.T) {
+	dir := t.TempDir()
+	src := &countingTokenSource{tok: &Token{AccessToken: "first"}}
+	w := &TokenFileWriter{Source: src, Path: filepath.Join(dir, "token"), Format: TokenFileProjected}
+	if err := w.Write(); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	src.tok = &Token{AccessToken: "second"}
+	if err := w.Write(); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	b, err := os.ReadFile(w.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "second"; got != want {
+		t.Errorf("got %v but want %v", got, want)
+	}
+	if target, err := os.Readlink(w.Path); err != nil || target != filepath.Join(dataDirName, "token") {
+		t.Errorf("got link %q, %v but want %q", target, err, filepath.Join(dataDirName, "token"))
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("got %d entries but want the token link, %s and one data directory", len(entries), dataDirName)
+	}
+}
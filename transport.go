@@ -25,8 +25,40 @@ type Transport struct {
 	// Base is the base RoundTripper used to make HTTP requests.
 	// If nil, http.DefaultTransport is used.
 	Base http.RoundTripper
+
+	// IDTokenSource optionally supplies an identity token that is sent in
+	// addition to the access token, e.g. to call a Cloud Run service behind
+	// Identity-Aware Proxy. The identity token is taken from the AccessToken
+	// field of its tokens, as ID token sources return it there.
+	IDTokenSource TokenSource
+
+	// IDTokenHeader is the header the identity token is sent in as a bearer
+	// token. If empty, X-Serverless-Authorization is used.
+	IDTokenHeader string
+
+	// RequestHeader optionally returns headers to set on each request,
+	// derived from the token authorizing it and the request itself, e.g.
+	// routing parameters or audit tags. They replace headers of the same
+	// name; the Authorization header cannot be replaced.
+	RequestHeader func(t *Token, req *http.Request) http.Header
+
+	// APIKey optionally identifies the calling project to APIs that
+	// require an API key in addition to the token on some endpoints. It
+	// is sent in the X-Goog-Api-Key header, unless APIKeyParam is set.
+	APIKey string
+
+	// APIKeyParam, if set, is the URL query parameter APIKey is sent in
+	// instead of the header, e.g. "key".
+	APIKeyParam string
 }
 
+// apiKeyHeader is the header Google APIs read API keys from.
+const apiKeyHeader = "X-Goog-Api-Key"
+
+// defaultIDTokenHeader is the header Cloud Run and Cloud Functions read the
+// identity token from when the Authorization header carries another token.
+const defaultIDTokenHeader = "X-Serverless-Authorization"
+
 // RoundTrip authorizes and authenticates the request with an
 // access token from Transport's Source.
 func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
@@ -49,6 +81,36 @@ func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
 
 	req2 := cloneRequest(req) // per RoundTripper contract
 	token.SetAuthHeader(req2)
+	if t.IDTokenSource != nil {
+		idToken, err := t.IDTokenSource.Token()
+		if err != nil {
+			return nil, err
+		}
+		header := t.IDTokenHeader
+		if header == "" {
+			header = defaultIDTokenHeader
+		}
+		req2.Header.Set(header, "Bearer "+idToken.AccessToken)
+	}
+	if t.APIKey != "" {
+		if t.APIKeyParam != "" {
+			u := *req2.URL
+			q := u.Query()
+			q.Set(t.APIKeyParam, t.APIKey)
+			u.RawQuery = q.Encode()
+			req2.URL = &u
+		} else {
+			req2.Header.Set(apiKeyHeader, t.APIKey)
+		}
+	}
+	if t.RequestHeader != nil {
+		for k, v := range t.RequestHeader(token, req2) {
+			if http.CanonicalHeaderKey(k) == "Authorization" {
+				continue
+			}
+			req2.Header[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
+		}
+	}
 
 	// req.Body is assumed to be closed by the base RoundTripper.
 	reqBodyClosed = true
@@ -110,6 +110,94 @@ func TestTransportTokenSource(t *testing.T) {
 	res.Body.Close()
 }
 
+func TestTransportIDTokenSource(t *testing.T) {
+	tr := &Transport{
+		Source:        &tokenSource{token: &Token{AccessToken: "access"}},
+		IDTokenSource: &tokenSource{token: &Token{AccessToken: "identity"}},
+	}
+	server := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("Authorization"), "Bearer access"; got != want {
+			t.Errorf("Authorization header = %q; want %q", got, want)
+		}
+		if got, want := r.Header.Get("X-Serverless-Authorization"), "Bearer identity"; got != want {
+			t.Errorf("X-Serverless-Authorization header = %q; want %q", got, want)
+		}
+	})
+	defer server.Close()
+	client := &http.Client{Transport: tr}
+	res, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+}
+
+func TestTransportRequestHeader(t *testing.T) {
+	tr := &Transport{
+		Source: &tokenSource{token: &Token{AccessToken: "access"}},
+		RequestHeader: func(tok *Token, req *http.Request) http.Header {
+			return http.Header{
+				"x-goog-request-params": {"name=" + req.URL.Path},
+				"Authorization":         {"Bearer stolen"},
+			}
+		},
+	}
+	server := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("Authorization"), "Bearer access"; got != want {
+			t.Errorf("Authorization header = %q; want %q", got, want)
+		}
+		if got, want := r.Header.Get("X-Goog-Request-Params"), "name=/bucket"; got != want {
+			t.Errorf("X-Goog-Request-Params header = %q; want %q", got, want)
+		}
+	})
+	defer server.Close()
+	client := &http.Client{Transport: tr}
+	res, err := client.Get(server.URL + "/bucket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+}
+
+func TestTransportAPIKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		param      string
+		wantHeader string
+		wantQuery  string
+	}{
+		{name: "header", wantHeader: "api-key"},
+		{name: "query", param: "key", wantQuery: "a=b&key=api-key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transport{
+				Source:      &tokenSource{token: &Token{AccessToken: "access"}},
+				APIKey:      "api-key",
+				APIKeyParam: tt.param,
+			}
+			server := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+				if got, want := r.Header.Get("Authorization"), "Bearer access"; got != want {
+					t.Errorf("Authorization header = %q; want %q", got, want)
+				}
+				if got := r.Header.Get("X-Goog-Api-Key"); got != tt.wantHeader {
+					t.Errorf("X-Goog-Api-Key header = %q; want %q", got, tt.wantHeader)
+				}
+				if got, want := r.URL.RawQuery, tt.wantQuery; want != "" && got != want {
+					t.Errorf("query = %q; want %q", got, want)
+				}
+			})
+			defer server.Close()
+			client := &http.Client{Transport: tr}
+			res, err := client.Get(server.URL + "/?a=b")
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+		})
+	}
+}
+
 // Test for case-sensitive token types, per https://github.com/golang/oauth2/issues/113
 func TestTransportTokenSourceTypes(t *testing.T) {
 	const val = "abc"